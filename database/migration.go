@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"greenbecak-backend/models"
+	"greenbecak-backend/services"
 
 	"gorm.io/gorm"
 )
@@ -21,6 +22,22 @@ func RunMigrations(db *gorm.DB) error {
 		&models.Payment{},
 		&models.Withdrawal{},
 		&models.DriverLocation{},
+		&models.OrderStatusHistory{},
+		&models.DispatchOffer{},
+		&models.DriverLocationPing{},
+		&models.OrderReview{},
+		&models.OrderCancellation{},
+		&models.SequenceCounter{},
+		&models.IdempotencyRecord{},
+		&models.OrderStop{},
+		&models.TourPackage{},
+		&models.LedgerAccount{},
+		&models.JournalEntry{},
+		&models.LedgerPosting{},
+		&models.CommissionRule{},
+		&models.Refund{},
+		&models.ReconciliationRun{},
+		&models.ReconciliationItem{},
 	)
 
 	if err != nil {
@@ -34,6 +51,22 @@ func RunMigrations(db *gorm.DB) error {
 		log.Printf("Info: Skipping enum alter for users.role (may already be up-to-date): %v", err)
 	}
 
+	// Same for orders.status, which gained the full order lifecycle states
+	if err := db.Exec("ALTER TABLE orders MODIFY COLUMN status ENUM('pending','accepted','picked_up','in_progress','completed','cancelled','expired','no_show') DEFAULT 'pending'").Error; err != nil {
+		log.Printf("Info: Skipping enum alter for orders.status (may already be up-to-date): %v", err)
+	}
+
+	// payments.status gained partially_refunded for partial refunds
+	if err := db.Exec("ALTER TABLE payments MODIFY COLUMN status ENUM('pending','paid','failed','refunded','partially_refunded') DEFAULT 'pending'").Error; err != nil {
+		log.Printf("Info: Skipping enum alter for payments.status (may already be up-to-date): %v", err)
+	}
+
+	// Record historical orders and withdrawals in the driver wallet ledger
+	if err := services.BackfillLedger(db); err != nil {
+		log.Printf("Ledger backfill failed: %v", err)
+		return err
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
@@ -55,6 +88,8 @@ func CreateIndexes(db *gorm.DB) error {
 		"CREATE INDEX IF NOT EXISTS idx_orders_customer_phone ON orders(customer_phone)",
 		"CREATE INDEX IF NOT EXISTS idx_orders_driver ON orders(driver_id)",
 		"CREATE INDEX IF NOT EXISTS idx_orders_created ON orders(created_at)",
+		"CREATE INDEX IF NOT EXISTS idx_orders_scheduled ON orders(status, scheduled_at)",
+		"CREATE INDEX IF NOT EXISTS idx_orders_package_status ON orders(package_id, status)",
 		"CREATE INDEX IF NOT EXISTS idx_tariffs_active ON tariffs(is_active)",
 		"CREATE INDEX IF NOT EXISTS idx_payments_order ON payments(order_id)",
 		"CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status)",
@@ -63,6 +98,14 @@ func CreateIndexes(db *gorm.DB) error {
 		"CREATE INDEX IF NOT EXISTS idx_driver_locations_driver ON driver_locations(driver_id)",
 		"CREATE INDEX IF NOT EXISTS idx_driver_locations_online ON driver_locations(is_online)",
 		"CREATE INDEX IF NOT EXISTS idx_driver_locations_last_seen ON driver_locations(last_seen)",
+		"CREATE INDEX IF NOT EXISTS idx_order_status_history_order ON order_status_history(order_id, created_at)",
+		"CREATE INDEX IF NOT EXISTS idx_dispatch_offers_order_status ON dispatch_offers(order_id, status)",
+		"CREATE INDEX IF NOT EXISTS idx_location_pings_driver_time ON driver_location_pings(driver_id, recorded_at)",
+		"CREATE INDEX IF NOT EXISTS idx_location_pings_order_time ON driver_location_pings(order_id, recorded_at)",
+		"CREATE INDEX IF NOT EXISTS idx_location_pings_recorded ON driver_location_pings(recorded_at)",
+		"CREATE INDEX IF NOT EXISTS idx_order_reviews_driver_status ON order_reviews(driver_id, status, created_at)",
+		"CREATE INDEX IF NOT EXISTS idx_order_cancellations_created ON order_cancellations(created_at)",
+		"CREATE INDEX IF NOT EXISTS idx_order_cancellations_driver_strike ON order_cancellations(driver_id, driver_strike, created_at)",
 	}
 
 	for _, index := range indexes {
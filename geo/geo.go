@@ -0,0 +1,141 @@
+// Package geo provides geodesic helpers used for nearby search, routing,
+// fares and geofencing. Distances are in kilometres and angles in degrees.
+package geo
+
+import (
+	"math"
+)
+
+// EarthRadiusKm is the mean Earth radius used for all calculations
+const EarthRadiusKm = 6371.0088
+
+// Point is a WGS84 coordinate
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// BoundingBox is an axis-aligned lat/lng rectangle
+type BoundingBox struct {
+	MinLat float64 `json:"min_lat"`
+	MaxLat float64 `json:"max_lat"`
+	MinLng float64 `json:"min_lng"`
+	MaxLng float64 `json:"max_lng"`
+}
+
+// Valid reports whether the point is within WGS84 bounds
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// IsZero reports whether the point is the zero value (0, 0), which clients send for "unknown"
+func (p Point) IsZero() bool {
+	return p.Lat == 0 && p.Lng == 0
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Distance returns the great-circle (haversine) distance between a and b in km
+func Distance(a, b Point) float64 {
+	lat1 := toRadians(a.Lat)
+	lat2 := toRadians(b.Lat)
+	dLat := lat2 - lat1
+	dLng := toRadians(b.Lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// PathDistance returns the total length of a path in km
+func PathDistance(points []Point) float64 {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += Distance(points[i-1], points[i])
+	}
+	return total
+}
+
+// Bearing returns the initial bearing from a to b in degrees, clockwise from north [0, 360)
+func Bearing(a, b Point) float64 {
+	lat1 := toRadians(a.Lat)
+	lat2 := toRadians(b.Lat)
+	dLng := toRadians(b.Lng - a.Lng)
+
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+	return math.Mod(toDegrees(math.Atan2(y, x))+360, 360)
+}
+
+// Destination returns the point reached by travelling distanceKm from p on the given bearing
+func Destination(p Point, bearing, distanceKm float64) Point {
+	lat1 := toRadians(p.Lat)
+	lng1 := toRadians(p.Lng)
+	brng := toRadians(bearing)
+	d := distanceKm / EarthRadiusKm
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(brng))
+	lng2 := lng1 + math.Atan2(math.Sin(brng)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+
+	return Point{
+		Lat: toDegrees(lat2),
+		Lng: math.Mod(toDegrees(lng2)+540, 360) - 180,
+	}
+}
+
+// BoundingBoxAround returns the smallest box containing every point within radiusKm of center.
+// It is meant as a cheap SQL pre-filter; callers still check Distance for each result.
+func BoundingBoxAround(center Point, radiusKm float64) BoundingBox {
+	latDelta := toDegrees(radiusKm / EarthRadiusKm)
+	minLat := center.Lat - latDelta
+	maxLat := center.Lat + latDelta
+
+	// Near the poles every longitude is within range
+	if maxLat >= 90 || minLat <= -90 {
+		return BoundingBox{
+			MinLat: math.Max(minLat, -90),
+			MaxLat: math.Min(maxLat, 90),
+			MinLng: -180,
+			MaxLng: 180,
+		}
+	}
+
+	lngDelta := toDegrees(math.Asin(math.Sin(radiusKm/EarthRadiusKm) / math.Cos(toRadians(center.Lat))))
+	return BoundingBox{
+		MinLat: minLat,
+		MaxLat: maxLat,
+		MinLng: center.Lng - lngDelta,
+		MaxLng: center.Lng + lngDelta,
+	}
+}
+
+// Contains reports whether p lies inside the box
+func (b BoundingBox) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+}
+
+// PointInPolygon reports whether p lies inside polygon using ray casting.
+// The polygon may be open or closed (first point repeated at the end).
+func PointInPolygon(p Point, polygon []Point) bool {
+	if len(polygon) < 3 {
+		return false
+	}
+
+	inside := false
+	j := len(polygon) - 1
+	for i := 0; i < len(polygon); i++ {
+		pi, pj := polygon[i], polygon[j]
+		if (pi.Lat > p.Lat) != (pj.Lat > p.Lat) &&
+			p.Lng < (pj.Lng-pi.Lng)*(p.Lat-pi.Lat)/(pj.Lat-pi.Lat)+pi.Lng {
+			inside = !inside
+		}
+		j = i
+	}
+	return inside
+}
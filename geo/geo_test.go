@@ -0,0 +1,139 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	tugu       = Point{Lat: -7.782889, Lng: 110.367083}
+	kraton     = Point{Lat: -7.805284, Lng: 110.364203}
+	malioboro  = Point{Lat: -7.792600, Lng: 110.365800}
+	tamanSari  = Point{Lat: -7.810070, Lng: 110.359180}
+	prambanan  = Point{Lat: -7.752020, Lng: 110.491474}
+	adisucipto = Point{Lat: -7.788181, Lng: 110.431670}
+	borobudur  = Point{Lat: -7.607874, Lng: 110.203751}
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Point
+		want float64 // km
+	}{
+		{"Tugu to Kraton", tugu, kraton, 2.510},
+		{"Tugu to Malioboro", tugu, malioboro, 1.089},
+		{"Kraton to Taman Sari", kraton, tamanSari, 0.768},
+		{"Tugu to Prambanan", tugu, prambanan, 14.128},
+		{"Malioboro to Adisucipto", malioboro, adisucipto, 7.273},
+		{"Tugu to Borobudur", tugu, borobudur, 26.508},
+		{"same point", tugu, tugu, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, Distance(tt.a, tt.b), 0.005)
+			assert.InDelta(t, tt.want, Distance(tt.b, tt.a), 0.005)
+		})
+	}
+}
+
+func TestBearing(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Point
+		want float64 // degrees
+	}{
+		{"Tugu to Kraton heads south", tugu, kraton, 187.26},
+		{"Tugu to Prambanan heads east", tugu, prambanan, 75.95},
+		{"Tugu to Borobudur heads north-west", tugu, borobudur, 317.23},
+		{"due north", Point{0, 0}, Point{1, 0}, 0},
+		{"due east", Point{0, 0}, Point{0, 1}, 90},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, Bearing(tt.a, tt.b), 0.01)
+		})
+	}
+}
+
+func TestDestinationRoundTrip(t *testing.T) {
+	dest := Destination(tugu, Bearing(tugu, prambanan), Distance(tugu, prambanan))
+	assert.InDelta(t, prambanan.Lat, dest.Lat, 1e-6)
+	assert.InDelta(t, prambanan.Lng, dest.Lng, 1e-6)
+}
+
+func TestBoundingBoxAround(t *testing.T) {
+	box := BoundingBoxAround(tugu, 3)
+
+	assert.True(t, box.Contains(tugu))
+	assert.True(t, box.Contains(kraton))
+	assert.False(t, box.Contains(adisucipto))
+
+	// Every point 3 km away in any direction must be inside the box
+	for bearing := 0.0; bearing < 360; bearing += 15 {
+		assert.True(t, box.Contains(Destination(tugu, bearing, 2.999)), "bearing %.0f", bearing)
+	}
+}
+
+func TestPointInPolygon(t *testing.T) {
+	// Rough outline of the Kraton / Malioboro tourist zone
+	zone := []Point{
+		{Lat: -7.7880, Lng: 110.3600},
+		{Lat: -7.7880, Lng: 110.3720},
+		{Lat: -7.8150, Lng: 110.3720},
+		{Lat: -7.8150, Lng: 110.3560},
+	}
+
+	tests := []struct {
+		name string
+		p    Point
+		want bool
+	}{
+		{"Malioboro", malioboro, true},
+		{"Kraton", kraton, true},
+		{"Taman Sari", tamanSari, true},
+		{"Tugu is north of the zone", tugu, false},
+		{"Prambanan", prambanan, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PointInPolygon(tt.p, zone))
+		})
+	}
+
+	assert.False(t, PointInPolygon(malioboro, zone[:2]))
+}
+
+func TestPolylineRoundTrip(t *testing.T) {
+	// Reference example from the encoded polyline algorithm documentation
+	points := []Point{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}}
+	encoded := "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+
+	assert.Equal(t, encoded, EncodePolyline(points))
+
+	decoded, err := DecodePolyline(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, points, decoded)
+
+	trip := []Point{tugu, malioboro, kraton, tamanSari}
+	decoded, err = DecodePolyline(EncodePolyline(trip))
+	assert.NoError(t, err)
+	for i := range trip {
+		assert.InDelta(t, trip[i].Lat, decoded[i].Lat, 1e-5)
+		assert.InDelta(t, trip[i].Lng, decoded[i].Lng, 1e-5)
+	}
+}
+
+func TestDecodePolylineRejectsTruncatedInput(t *testing.T) {
+	_, err := DecodePolyline("_p~iF~ps|U_ulLnnqC_mqN")
+	assert.ErrorIs(t, err, ErrInvalidPolyline)
+}
+
+func TestPathDistance(t *testing.T) {
+	assert.InDelta(t, Distance(tugu, malioboro)+Distance(malioboro, kraton), PathDistance([]Point{tugu, malioboro, kraton}), 1e-9)
+	assert.Equal(t, 0.0, PathDistance(nil))
+}
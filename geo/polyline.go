@@ -0,0 +1,92 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// ErrInvalidPolyline is returned when an encoded polyline is truncated or malformed
+var ErrInvalidPolyline = errors.New("invalid encoded polyline")
+
+const polylinePrecision = 1e5
+
+// EncodePolyline encodes points with the Google encoded polyline algorithm (precision 5)
+func EncodePolyline(points []Point) string {
+	var b strings.Builder
+	var prevLat, prevLng int64
+
+	for _, p := range points {
+		lat := int64(math.Round(p.Lat * polylinePrecision))
+		lng := int64(math.Round(p.Lng * polylinePrecision))
+		encodePolylineValue(&b, lat-prevLat)
+		encodePolylineValue(&b, lng-prevLng)
+		prevLat, prevLng = lat, lng
+	}
+	return b.String()
+}
+
+func encodePolylineValue(b *strings.Builder, value int64) {
+	v := value << 1
+	if value < 0 {
+		v = ^v
+	}
+	for v >= 0x20 {
+		b.WriteByte(byte((0x20 | (v & 0x1f)) + 63))
+		v >>= 5
+	}
+	b.WriteByte(byte(v + 63))
+}
+
+// DecodePolyline decodes a Google encoded polyline (precision 5)
+func DecodePolyline(encoded string) ([]Point, error) {
+	var points []Point
+	var lat, lng int64
+	index := 0
+
+	for index < len(encoded) {
+		dLat, next, err := decodePolylineValue(encoded, index)
+		if err != nil {
+			return nil, err
+		}
+		dLng, next, err := decodePolylineValue(encoded, next)
+		if err != nil {
+			return nil, err
+		}
+		index = next
+
+		lat += dLat
+		lng += dLng
+		points = append(points, Point{
+			Lat: float64(lat) / polylinePrecision,
+			Lng: float64(lng) / polylinePrecision,
+		})
+	}
+	return points, nil
+}
+
+func decodePolylineValue(encoded string, index int) (int64, int, error) {
+	var result int64
+	shift := uint(0)
+
+	for {
+		if index >= len(encoded) {
+			return 0, index, ErrInvalidPolyline
+		}
+		b := int64(encoded[index]) - 63
+		index++
+		if b < 0 || shift > 60 {
+			return 0, index, ErrInvalidPolyline
+		}
+		result |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			break
+		}
+	}
+
+	if result&1 != 0 {
+		return ^(result >> 1), index, nil
+	}
+	return result >> 1, index, nil
+}
@@ -32,6 +32,16 @@ func GetAnalytics(c *gin.Context) {
 	var totalCustomers int64
 	db.Model(&models.User{}).Where("role = ? AND is_active = ?", models.RoleCustomer, true).Count(&totalCustomers)
 
+	// Get cancellation fees and driver strikes
+	var cancellations struct {
+		Count   int64
+		Fees    float64
+		Strikes int64
+	}
+	db.Model(&models.OrderCancellation{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(fee), 0) AS fees, COALESCE(SUM(driver_strike), 0) AS strikes").
+		Scan(&cancellations)
+
 	// Get recent orders
 	var recentOrders []models.Order
 	db.Preload("Customer").Preload("Driver").Preload("Tariff").Order("created_at DESC").Limit(10).Find(&recentOrders)
@@ -44,6 +54,9 @@ func GetAnalytics(c *gin.Context) {
 		"total_customers":   totalCustomers,
 		"recent_orders":     recentOrders,
 		"completion_rate":   float64(completedOrders) / float64(totalOrders) * 100,
+		"cancelled_orders":  cancellations.Count,
+		"cancellation_fees": cancellations.Fees,
+		"driver_strikes":    cancellations.Strikes,
 	}
 
 	c.JSON(http.StatusOK, gin.H{"analytics": analytics})
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DispatchScheduledOrder offers a due booking to nearby drivers; used by the booking scheduler
+func DispatchScheduledOrder(order models.Order) {
+	dispatchOrder(order)
+}
+
+// currentDriver loads the driver profile of the authenticated user, writing a 404 when missing
+func currentDriver(c *gin.Context, db *gorm.DB) (models.Driver, bool) {
+	userID, _ := c.Get("user_id")
+	var driver models.Driver
+	if err := db.Where("user_id = ?", userID).First(&driver).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return driver, false
+	}
+	return driver, true
+}
+
+// respondBookingError maps booking errors to HTTP responses
+func respondBookingError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrBookingNotOpen), errors.Is(err, services.ErrBookingOverlap):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBookingNotCommitted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		respondOrderTransitionError(c, err, fallback)
+	}
+}
+
+// GetAvailableBookings - Booking terjadwal yang belum punya driver dan bisa disanggupi
+func GetAvailableBookings(c *gin.Context) {
+	db := database.GetDB()
+
+	var orders []models.Order
+	if err := db.Preload("Tariff").
+		Where("status = ? AND scheduled_at IS NOT NULL AND driver_id IS NULL AND dispatched_at IS NULL AND scheduled_at > ?",
+			models.OrderStatusPending, time.Now()).
+		Order("scheduled_at ASC").
+		Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bookings": orders})
+}
+
+// GetUpcomingTrips - Booking yang sudah disanggupi atau diterima driver, urut waktu jemput
+func GetUpcomingTrips(c *gin.Context) {
+	db := database.GetDB()
+
+	driver, ok := currentDriver(c, db)
+	if !ok {
+		return
+	}
+
+	var orders []models.Order
+	if err := db.Preload("Tariff").Preload("Stops", services.OrderStopsBySequence).
+		Where("driver_id = ? AND scheduled_at IS NOT NULL AND status IN ?", driver.ID,
+			[]models.OrderStatus{models.OrderStatusPending, models.OrderStatusAccepted}).
+		Order("scheduled_at ASC").
+		Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch upcoming trips"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"trips": orders})
+}
+
+// CommitBooking - Driver menyanggupi booking terjadwal sebelum waktu dispatch
+func CommitBooking(c *gin.Context) {
+	db := database.GetDB()
+
+	driver, ok := currentDriver(c, db)
+	if !ok {
+		return
+	}
+
+	var order models.Order
+	if err := db.First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	if order.DriverID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Booking already has a driver"})
+		return
+	}
+
+	if err := services.CommitDriverToBooking(db, &order, driver); err != nil {
+		respondBookingError(c, err, "Failed to commit to booking")
+		return
+	}
+
+	services.PublishOrderStatus(&order)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Booking committed successfully",
+		"order":   order,
+	})
+}
+
+// WithdrawBooking - Driver membatalkan kesanggupan; mendekati waktu jemput dikenai strike
+func WithdrawBooking(c *gin.Context) {
+	db := database.GetDB()
+
+	driver, ok := currentDriver(c, db)
+	if !ok {
+		return
+	}
+
+	var order models.Order
+	if err := db.First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	strike, err := services.WithdrawDriverCommitment(db, &order, driver, services.LoadScheduleConfig(), time.Now())
+	if err != nil {
+		respondBookingError(c, err, "Failed to withdraw from booking")
+		return
+	}
+
+	services.PublishOrderStatus(&order)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Booking commitment withdrawn",
+		"order":         order,
+		"driver_strike": strike,
+	})
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"greenbecak-backend/config"
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CancelOrderRequest struct {
+	ReasonCode string `json:"reason_code" binding:"required"`
+	Note       string `json:"note" binding:"max=500"`
+}
+
+type CancelOrderPublicRequest struct {
+	CancelOrderRequest
+	OrderToken string `json:"order_token"` // Dari respons POST /api/orders/public, atau header X-Order-Token
+}
+
+// GetCancellationReasons - Daftar kode alasan pembatalan per peran
+func GetCancellationReasons(c *gin.Context) {
+	if role := c.Query("role"); role != "" {
+		c.JSON(http.StatusOK, gin.H{"reasons": services.CancellationReasons[role]})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reasons": services.CancellationReasons})
+}
+
+// CancelOrder - Customer, driver atau admin membatalkan order dengan kode alasan
+func CancelOrder(c *gin.Context) {
+	var req CancelOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var order models.Order
+	if err := db.First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	actorID, actorRole := orderActor(c)
+	switch actorRole {
+	case services.ActorRoleAdmin:
+	case services.ActorRoleDriver:
+		var driver models.Driver
+		if err := db.Where("user_id = ?", actorID).First(&driver).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+			return
+		}
+		if order.DriverID == nil || *order.DriverID != driver.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Order does not belong to this driver"})
+			return
+		}
+		if order.IsScheduled() && order.Status == models.OrderStatusPending && order.DispatchedAt == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Booking is not dispatched yet, withdraw your commitment instead"})
+			return
+		}
+	default:
+		if actorID == nil || order.CustomerID == nil || *order.CustomerID != *actorID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+	}
+
+	cancelOrderAs(c, &order, services.CancelRequest{
+		ActorID:   actorID,
+		ActorRole: actorRole,
+		Reason:    models.CancellationReason(req.ReasonCode),
+		Note:      req.Note,
+	})
+}
+
+// CancelOrderPublic - Customer tanpa akun membatalkan order dengan order_token dari respons pembuatan order
+func CancelOrderPublic(c *gin.Context) {
+	var req CancelOrderPublicRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, ok := loadPublicOrder(c, req.OrderToken)
+	if !ok {
+		return
+	}
+
+	cancelOrderAs(c, order, services.CancelRequest{
+		ActorRole: services.ActorRoleCustomer,
+		Reason:    models.CancellationReason(req.ReasonCode),
+		Note:      req.Note,
+	})
+}
+
+// cancelOrderAs runs the cancellation policy, notifies the driver and writes the response
+func cancelOrderAs(c *gin.Context, order *models.Order, req services.CancelRequest) {
+	db := database.GetDB()
+
+	cancellation, err := services.CancelOrder(db, order, req, services.LoadCancellationPolicy())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCancelReason) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":         err.Error(),
+				"valid_reasons": services.CancellationReasons[req.ActorRole],
+			})
+			return
+		}
+		respondOrderTransitionError(c, err, "Failed to cancel order")
+		return
+	}
+
+	services.PublishOrderStatus(order)
+	if order.DriverID != nil && req.ActorRole != services.ActorRoleDriver {
+		notifyDriverCancelled(*order.DriverID, *order, *cancellation)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Order cancelled successfully",
+		"order":        order,
+		"cancellation": cancellation,
+	})
+}
+
+// notifyDriverCancelled tells the assigned driver their order was cancelled via stream and FCM
+func notifyDriverCancelled(driverID uint, order models.Order, cancellation models.OrderCancellation) {
+	orderData := map[string]interface{}{
+		"id":           order.ID,
+		"order_number": order.OrderNumber,
+		"reason_code":  cancellation.ReasonCode,
+		"cancelled_by": cancellation.ActorRole,
+		"fee":          cancellation.Fee,
+	}
+	services.Hub.Publish(services.DriverTopic(driverID), services.EventOrderCancelled, orderData)
+
+	var driver models.Driver
+	if config.FirebaseService == nil || database.GetDB().Select("id", "fcm_token").First(&driver, driverID).Error != nil || driver.FCMToken == "" {
+		return
+	}
+	if err := config.FirebaseService.SendOrderCancelledNotification(driver.FCMToken, orderData); err != nil {
+		log.Printf("Failed to send cancellation for order %d to driver %d: %v", order.ID, driverID, err)
+	}
+}
+
+// GetCancellationAnalytics - Statistik pembatalan, biaya dan strike driver (admin)
+func GetCancellationAnalytics(c *gin.Context) {
+	db := database.GetDB()
+
+	from := time.Now().AddDate(0, 0, -30)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, use YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, use YYYY-MM-DD"})
+			return
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	query := func() *gorm.DB {
+		return db.Model(&models.OrderCancellation{}).Where("order_cancellations.created_at >= ? AND order_cancellations.created_at < ?", from, to)
+	}
+
+	var totals struct {
+		Cancellations int64
+		Fees          float64
+		Strikes       int64
+	}
+	query().Select("COUNT(*) AS cancellations, COALESCE(SUM(fee), 0) AS fees, COALESCE(SUM(driver_strike), 0) AS strikes").Scan(&totals)
+
+	var byActor, byReason, byStage, feesByPayer []gin.H
+	query().Select("actor_role, COUNT(*) AS count, COALESCE(SUM(fee), 0) AS fees").Group("actor_role").Scan(&byActor)
+	query().Select("actor_role, reason_code, COUNT(*) AS count").Group("actor_role, reason_code").Order("count DESC").Scan(&byReason)
+	query().Select("stage, COUNT(*) AS count").Group("stage").Scan(&byStage)
+	query().Select("fee_payer, COUNT(*) AS count, SUM(fee) AS fees").Where("fee > 0").Group("fee_payer").Scan(&feesByPayer)
+
+	var topStrikes []gin.H
+	query().
+		Select("order_cancellations.driver_id, drivers.name AS driver_name, drivers.status AS driver_status, COUNT(*) AS strikes").
+		Joins("JOIN drivers ON drivers.id = order_cancellations.driver_id").
+		Where("order_cancellations.driver_strike = ?", true).
+		Group("order_cancellations.driver_id, drivers.name, drivers.status").
+		Order("strikes DESC").
+		Limit(10).
+		Scan(&topStrikes)
+
+	c.JSON(http.StatusOK, gin.H{"cancellation_analytics": gin.H{
+		"from":                 from.Format("2006-01-02"),
+		"to":                   to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"total_cancellations":  totals.Cancellations,
+		"total_fees":           totals.Fees,
+		"total_strikes":        totals.Strikes,
+		"by_actor":             byActor,
+		"by_reason":            byReason,
+		"by_stage":             byStage,
+		"fees_by_payer":        feesByPayer,
+		"drivers_with_strikes": topStrikes,
+	}})
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommissionRuleRequest struct {
+	Name        string             `json:"name" binding:"required"`
+	TariffID    *uint              `json:"tariff_id"`
+	VehicleType models.VehicleType `json:"vehicle_type"`
+	Program     string             `json:"program"`
+	Rate        float64            `json:"rate"`
+	FlatFee     float64            `json:"flat_fee"`
+	MinFee      float64            `json:"min_fee"`
+	MaxFee      float64            `json:"max_fee"`
+	Priority    int                `json:"priority"`
+	IsActive    *bool              `json:"is_active"`
+}
+
+type SettleReceivableRequest struct {
+	Amount    float64 `json:"amount" binding:"required"`
+	Method    string  `json:"method" binding:"required"` // cash, transfer, wallet
+	Reference string  `json:"reference"`
+	Notes     string  `json:"notes"`
+}
+
+// GetCommissionRules - Daftar aturan komisi platform (admin)
+func GetCommissionRules(c *gin.Context) {
+	query := database.GetDB().Preload("Tariff")
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
+	var rules []models.CommissionRule
+	if err := query.Order("id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch commission rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules":        rules,
+		"default_rate": services.CommissionRate(),
+	})
+}
+
+// CreateCommissionRule - Tambah aturan komisi (admin)
+func CreateCommissionRule(c *gin.Context) {
+	var req CommissionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.CommissionRule{IsActive: true}
+	saveCommissionRule(c, &rule, req, http.StatusCreated, "Commission rule created successfully")
+}
+
+// UpdateCommissionRule - Ubah aturan komisi (admin); order yang sudah selesai tidak berubah
+func UpdateCommissionRule(c *gin.Context) {
+	var req CommissionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rule models.CommissionRule
+	if err := database.GetDB().First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Commission rule not found"})
+		return
+	}
+	saveCommissionRule(c, &rule, req, http.StatusOK, "Commission rule updated successfully")
+}
+
+// saveCommissionRule validates and stores an admin commission rule request
+func saveCommissionRule(c *gin.Context, rule *models.CommissionRule, req CommissionRuleRequest, status int, message string) {
+	db := database.GetDB()
+
+	rule.Name = req.Name
+	rule.TariffID = req.TariffID
+	rule.VehicleType = req.VehicleType
+	rule.Program = req.Program
+	rule.Rate = req.Rate
+	rule.FlatFee = req.FlatFee
+	rule.MinFee = req.MinFee
+	rule.MaxFee = req.MaxFee
+	rule.Priority = req.Priority
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	if err := services.ValidateCommissionRule(*rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if rule.TariffID != nil {
+		if err := db.First(&models.Tariff{}, *rule.TariffID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tariff not found"})
+			return
+		}
+	}
+
+	if err := db.Omit("Tariff").Save(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save commission rule"})
+		return
+	}
+	// Save skips a false is_active on insert because of its default
+	if err := db.Model(rule).Update("is_active", rule.IsActive).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save commission rule"})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"message": message,
+		"rule":    rule,
+	})
+}
+
+// DeleteCommissionRule - Hapus aturan komisi (admin)
+func DeleteCommissionRule(c *gin.Context) {
+	if err := database.GetDB().Delete(&models.CommissionRule{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete commission rule"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Commission rule deleted successfully"})
+}
+
+// GetReceivables - Driver yang masih berutang komisi order tunai (admin)
+func GetReceivables(c *gin.Context) {
+	db := database.GetDB()
+
+	minOutstanding, _ := strconv.ParseFloat(c.DefaultQuery("min_outstanding", "1"), 64)
+	receivables, err := services.ListReceivables(db, minOutstanding)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receivables"})
+		return
+	}
+
+	driverIDs := make([]uint, 0, len(receivables))
+	var total float64
+	for _, receivable := range receivables {
+		driverIDs = append(driverIDs, receivable.DriverID)
+		total += receivable.Outstanding
+	}
+	var drivers []models.Driver
+	if len(driverIDs) > 0 {
+		if err := db.Where("id IN ?", driverIDs).Find(&drivers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drivers"})
+			return
+		}
+	}
+	byID := make(map[uint]models.Driver, len(drivers))
+	for _, driver := range drivers {
+		byID[driver.ID] = driver
+	}
+
+	items := make([]gin.H, 0, len(receivables))
+	for _, receivable := range receivables {
+		driver := byID[receivable.DriverID]
+		items = append(items, gin.H{
+			"driver_id":   receivable.DriverID,
+			"driver_code": driver.DriverCode,
+			"driver_name": driver.Name,
+			"phone":       driver.Phone,
+			"charged":     receivable.Charged,
+			"settled":     receivable.Settled,
+			"outstanding": receivable.Outstanding,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"receivables":       items,
+		"total_outstanding": total,
+	})
+}
+
+// GetDriverReceivable - Piutang komisi satu driver beserta mutasinya (admin)
+func GetDriverReceivable(c *gin.Context) {
+	db := database.GetDB()
+
+	var driver models.Driver
+	if err := db.First(&driver, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	receivable, err := services.GetDriverReceivable(db, driver.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate receivable"})
+		return
+	}
+	lines, total, err := services.AccountStatement(db, services.DriverReceivableAccount(driver.ID), (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receivable history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"receivable":   receivable,
+		"transactions": lines,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// SettleDriverReceivable - Catat pelunasan piutang komisi driver (admin)
+func SettleDriverReceivable(c *gin.Context) {
+	var req SettleReceivableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var driver models.Driver
+	if err := db.First(&driver, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
+
+	actorID, _ := orderActor(c)
+	entry, err := services.SettleReceivable(db, driver.ID, services.SettlementRequest{
+		Amount:    req.Amount,
+		Method:    req.Method,
+		Reference: req.Reference,
+		Memo:      req.Notes,
+		CreatedBy: actorID,
+	}, time.Now())
+	switch {
+	case errors.Is(err, services.ErrInvalidSettlement):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrSettlementTooHigh), errors.Is(err, services.ErrInsufficientFunds):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record settlement"})
+		return
+	}
+
+	receivable, err := services.GetDriverReceivable(db, driver.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate receivable"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Settlement recorded successfully",
+		"entry":      entry,
+		"receivable": receivable,
+	})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"greenbecak-backend/config"
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	dispatcher     *services.Dispatcher
+	dispatcherOnce sync.Once
+)
+
+// orderDispatcher returns the shared dispatcher, created on first use
+func orderDispatcher() *services.Dispatcher {
+	dispatcherOnce.Do(func() {
+		dispatcher = services.NewDispatcher(database.GetDB(), services.LoadDispatchConfig(), notifyDriverOffer)
+	})
+	return dispatcher
+}
+
+// dispatchOrder offers a new order to the nearest available drivers
+func dispatchOrder(order models.Order) {
+	if database.GetDB() == nil {
+		return
+	}
+	orderDispatcher().Dispatch(order)
+}
+
+// notifyDriverOffer sends an order offer to a single driver via stream and FCM
+func notifyDriverOffer(driver models.Driver, order models.Order, offer models.DispatchOffer) {
+	orderData := map[string]interface{}{
+		"id":              order.ID,
+		"offer_id":        offer.ID,
+		"price":           order.Price,
+		"pickup_location": order.PickupLocation,
+		"drop_location":   order.DropLocation,
+		"distance":        order.Distance,
+		"pickup_distance": offer.DistanceKm,
+		"eta":             order.ETA,
+		"expires_at":      offer.ExpiresAt,
+	}
+
+	services.Hub.Publish(services.DriverTopic(driver.ID), services.EventOrderOffer, orderData)
+
+	if config.FirebaseService == nil || driver.FCMToken == "" {
+		return
+	}
+	if err := config.FirebaseService.SendNewOrderNotification(driver.FCMToken, orderData); err != nil {
+		log.Printf("Failed to send offer for order %d to driver %d: %v", order.ID, driver.ID, err)
+	}
+}
+
+// DeclineOrder - Driver menolak tawaran order dari dispatch
+func DeclineOrder(c *gin.Context) {
+	orderID := c.Param("id")
+	db := database.GetDB()
+	userID, _ := c.Get("user_id")
+
+	var order models.Order
+	if err := db.First(&order, orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var driver models.Driver
+	if err := db.Where("user_id = ?", userID).First(&driver).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
+
+	if err := services.RespondToOffer(db, order.ID, driver.ID, models.DispatchOfferStatusDeclined); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No open offer for this order"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order offer declined"})
+}
+
+// GetOrderDispatchOffers - Admin melihat riwayat tawaran dispatch untuk order
+func GetOrderDispatchOffers(c *gin.Context) {
+	orderID := c.Param("id")
+	db := database.GetDB()
+
+	var offers []models.DispatchOffer
+	if err := db.Preload("Driver").Where("order_id = ?", orderID).Order("wave ASC, distance_km ASC").Find(&offers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dispatch offers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id": orderID,
+		"offers":   offers,
+		"count":    len(offers),
+	})
+}
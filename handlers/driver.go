@@ -243,9 +243,9 @@ func GetDriverPerformance(c *gin.Context) {
 	var totalEarnings float64
 	db.Model(&models.Order{}).Where("driver_id = ? AND status = ?", driverID, models.OrderStatusCompleted).Select("SUM(price)").Scan(&totalEarnings)
 
-	// Get average rating
+	// Get average rating (raw mean of published reviews; driver.Rating is the Bayesian score)
 	var avgRating float64
-	db.Model(&models.Order{}).Where("driver_id = ? AND status = ?", driverID, models.OrderStatusCompleted).Select("AVG(rating)").Scan(&avgRating)
+	db.Model(&models.OrderReview{}).Where("driver_id = ? AND status = ?", driverID, models.ReviewStatusPublished).Select("COALESCE(AVG(rating), 0)").Scan(&avgRating)
 
 	performance := gin.H{
 		"driver_id":        driver.ID,
@@ -255,6 +255,7 @@ func GetDriverPerformance(c *gin.Context) {
 		"average_rating":   avgRating,
 		"total_trips":      driver.TotalTrips,
 		"rating":           driver.Rating,
+		"rating_count":     driver.RatingCount,
 	}
 
 	c.JSON(http.StatusOK, gin.H{"performance": performance})
@@ -4,10 +4,13 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"greenbecak-backend/database"
+	"greenbecak-backend/geo"
 	"greenbecak-backend/models"
+	"greenbecak-backend/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,7 +27,7 @@ func GetDriverOrders(c *gin.Context) {
 	}
 
 	var orders []models.Order
-	query := db.Preload("Customer").Preload("Driver").Preload("Driver.User").Preload("Tariff").Preload("Payment").Where("driver_id = ?", driver.ID)
+	query := db.Preload("Customer").Preload("Driver").Preload("Driver.User").Preload("Tariff").Preload("Payment").Preload("Stops", services.OrderStopsBySequence).Where("driver_id = ?", driver.ID)
 
 	// Add filters
 	if status := c.Query("status"); status != "" {
@@ -44,31 +47,51 @@ func GetAvailableOrders(c *gin.Context) {
 	db := database.GetDB()
 
 	var orders []models.Order
-	query := db.Preload("Customer").Preload("Tariff").
-		Where("status = ? AND driver_id IS NULL", models.OrderStatusPending)
-
-	// Add distance filter if driver location is provided
-	if lat := c.Query("lat"); lat != "" && c.Query("lng") != "" {
-		// TODO: Add distance calculation and filtering
-		// For now, just get all pending orders
-	}
+	query := db.Preload("Customer").Preload("Tariff").Preload("Stops", services.OrderStopsBySequence).
+		Where("status = ? AND driver_id IS NULL", models.OrderStatusPending).
+		Where("scheduled_at IS NULL OR dispatched_at IS NOT NULL") // Booking muncul setelah didispatch
 
 	if err := query.Order("created_at ASC").Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch available orders"})
 		return
 	}
 
-	// Add distance and ETA calculation for each order
-	for i := range orders {
-		// TODO: Calculate distance from driver's current location
-		// For now, use the order's distance
-		if orders[i].Distance > 0 {
-			// Estimate ETA: 1 minute per km + 2 minutes base
-			orders[i].ETA = int(orders[i].Distance) + 2
+	// Filter by distance from the driver when a location is provided
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(c.Query("lng"), 64)
+	hasLocation := latErr == nil && lngErr == nil
+	radius, _ := strconv.ParseFloat(c.DefaultQuery("radius", "5"), 64)
+
+	// Travel times use the requesting driver's vehicle speed profile
+	vehicle := models.VehicleTypeBecakManual
+	var driver models.Driver
+	if userID, exists := c.Get("user_id"); exists && db.Select("id", "vehicle_type").Where("user_id = ?", userID).First(&driver).Error == nil {
+		vehicle = driver.VehicleType
+	}
+	ctx := c.Request.Context()
+
+	available := make([]models.Order, 0, len(orders))
+	for _, order := range orders {
+		if order.Distance > 0 {
+			// Without a driver location, ETA is the trip duration itself
+			order.ETA = services.DurationMinutes(services.DefaultSpeedProfiles.TravelTime(order.Distance, vehicle))
 		}
+
+		if pickup, ok := order.PickupPoint(); hasLocation && ok {
+			here := geo.Point{Lat: lat, Lng: lng}
+			order.PickupDistance = geo.Distance(here, pickup)
+			if order.PickupDistance > radius {
+				continue
+			}
+			// ETA to the pickup point
+			if eta, err := routingProvider().ETA(ctx, here, pickup, vehicle); err == nil {
+				order.ETA = services.DurationMinutes(eta)
+			}
+		}
+		available = append(available, order)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"orders": orders})
+	c.JSON(http.StatusOK, gin.H{"orders": available})
 }
 
 // GetOrdersByDriverID - Get orders by specific driver ID (for admin or testing)
@@ -131,12 +154,6 @@ func AcceptOrder(c *gin.Context) {
 		return
 	}
 
-	// Check if order is pending
-	if order.Status != models.OrderStatusPending {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Order is not available for acceptance"})
-		return
-	}
-
 	// Check if driver is available - find driver by user_id
 	var driver models.Driver
 	if err := db.Where("user_id = ?", userID).First(&driver).Error; err != nil {
@@ -149,25 +166,27 @@ func AcceptOrder(c *gin.Context) {
 		return
 	}
 
-	// Update order
-	now := time.Now()
-	order.Status = models.OrderStatusAccepted
-	order.DriverID = &driver.ID
-	order.AcceptedAt = &now
-
-	// Update driver status
-	driver.Status = models.DriverStatusOnTrip
+	// Bookings are taken through /api/driver/bookings until they are dispatched
+	if order.IsScheduled() && order.DispatchedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Booking is not dispatched yet, commit to it instead"})
+		return
+	}
 
-	if err := db.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept order"})
+	// Orders created from a becak sticker are reserved for that driver
+	if order.DriverID != nil && *order.DriverID != driver.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is assigned to another driver"})
 		return
 	}
 
-	if err := db.Save(&driver).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update driver status"})
+	// Accept order and mark driver on_trip atomically
+	actorID, _ := orderActor(c)
+	if err := services.AcceptOrderForDriver(db, &order, &driver, actorID); err != nil {
+		respondOrderTransitionError(c, err, "Failed to accept order")
 		return
 	}
 
+	services.PublishOrderStatus(&order)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Order accepted successfully",
 		"order":   order,
@@ -198,31 +217,14 @@ func CompleteOrder(c *gin.Context) {
 		return
 	}
 
-	// Check if order is accepted
-	if order.Status != models.OrderStatusAccepted {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Order is not in accepted status"})
-		return
-	}
-
-	// Update order
-	now := time.Now()
-	order.Status = models.OrderStatusCompleted
-	order.CompletedAt = &now
-
-	// Update driver status and earnings
-	driver.Status = models.DriverStatusActive
-	driver.TotalTrips++
-	driver.TotalEarnings += order.Price
-
-	if err := db.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete order"})
+	// Complete order and credit driver earnings atomically
+	actorID, actorRole := orderActor(c)
+	if err := services.CompleteOrderForDriver(db, &order, &driver, actorID, actorRole); err != nil {
+		respondOrderTransitionError(c, err, "Failed to complete order")
 		return
 	}
 
-	if err := db.Save(&driver).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update driver"})
-		return
-	}
+	services.PublishOrderStatus(&order)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Order completed successfully",
@@ -284,6 +286,13 @@ func GetDriverEarnings(c *gin.Context) {
 			driver.ID, models.OrderStatusCompleted, currentMonth).
 		Count(&monthlyTrips)
 
+	// Wallet balance comes from the ledger
+	wallet, err := services.GetDriverWallet(db, driver.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balance"})
+		return
+	}
+
 		earnings := gin.H{
 		"driver_id":   driver.ID,
 		"driver_name": driver.Name,
@@ -313,6 +322,9 @@ func GetDriverEarnings(c *gin.Context) {
 		"completed_orders": completedOrders,
 		"total_trips":      driver.TotalTrips,
 		"rating":           driver.Rating,
+		"wallet_balance":    wallet.Balance,
+		"available_balance": wallet.Available,
+		"receivable":        wallet.Receivable,
 	}
 
 	fmt.Printf("Earnings data: %+v\n", earnings)
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"greenbecak-backend/config"
+	"greenbecak-backend/database"
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quoteVehicleTypes are quoted in this order when no becak or vehicle type is given
+var quoteVehicleTypes = []models.VehicleType{
+	models.VehicleTypeBecakManual,
+	models.VehicleTypeBecakListrik,
+	models.VehicleTypeBecakMotor,
+	models.VehicleTypeAndong,
+}
+
+type FareQuoteRequest struct {
+	Pickup      services.OrderPlace `json:"pickup"`
+	Drop        services.OrderPlace `json:"drop"` // Tidak dikirim untuk round trip
+	BecakCode   string              `json:"becak_code"`
+	TariffID    uint                `json:"tariff_id"`
+	VehicleType string              `json:"vehicle_type"`
+	OrderStopsInput
+}
+
+type FareQuoteResponse struct {
+	QuoteToken      string               `json:"quote_token"`
+	ExpiresAt       time.Time            `json:"expires_at"`
+	TariffID        uint                 `json:"tariff_id"`
+	TariffName      string               `json:"tariff_name"`
+	VehicleType     models.VehicleType   `json:"vehicle_type"`
+	DistanceKm      float64              `json:"distance_km"`
+	DurationMinutes int                  `json:"duration_minutes"`
+	ETAMinutes      *int                 `json:"eta_minutes"` // Estimasi driver tiba di pickup, null jika tidak ada driver terdekat
+	Price           float64              `json:"price"`
+	CustomerTotal   float64              `json:"customer_total"`
+	FareBreakdown   models.FareBreakdown `json:"fare_breakdown"`
+}
+
+// fareQuoteSecret returns the secret quote tokens are signed with
+func fareQuoteSecret() string {
+	if secret := os.Getenv("FARE_QUOTE_SECRET"); secret != "" {
+		return secret
+	}
+	return config.LoadConfig().JWTSecret
+}
+
+func fareQuoteTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("FARE_QUOTE_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return 5 * time.Minute
+}
+
+// QuoteFare - Hitung tarif untuk pickup/drop per tarif dan jenis kendaraan, dengan quote token bertanda tangan
+func QuoteFare(c *gin.Context) {
+	var req FareQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var trip models.Order
+	if err := services.SetOrderPickup(&trip, req.Pickup); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pickup coordinates"})
+		return
+	}
+	if !req.RoundTrip {
+		if err := services.SetOrderDrop(&trip, req.Drop); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drop coordinates"})
+			return
+		}
+	}
+	if err := services.SetOrderStops(&trip, req.Stops, req.RoundTrip, services.LoadStopConfig()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pickup, _ := trip.PickupPoint()
+	drop, _ := trip.DropPoint()
+	var stops []geo.Point
+	for _, stop := range trip.Stops {
+		point, _ := stop.Point()
+		stops = append(stops, point)
+	}
+
+	db := database.GetDB()
+
+	// Vehicle types to quote: the scanned becak's, the requested one, or all
+	vehicles := quoteVehicleTypes
+	var driver *models.Driver
+	if req.BecakCode != "" {
+		var found models.Driver
+		if err := db.Where("driver_code = ?", req.BecakCode).First(&found).Error; err == nil {
+			driver = &found
+			vehicles = []models.VehicleType{found.VehicleType}
+		}
+	}
+	if driver == nil && req.VehicleType != "" {
+		vehicle := models.VehicleType(req.VehicleType)
+		if _, ok := services.DefaultVehicleMultipliers[vehicle]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle type"})
+			return
+		}
+		vehicles = []models.VehicleType{vehicle}
+	}
+
+	// The trip is routed for the vehicle when only one is quoted
+	var routeVehicle models.VehicleType
+	if len(vehicles) == 1 {
+		routeVehicle = vehicles[0]
+	}
+	distance, _ := routedTripDistance(c.Request.Context(), trip, routeVehicle)
+
+	var tariffs []models.Tariff
+	if err := db.Where("is_active = ?", true).Order("min_distance ASC").Find(&tariffs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tariffs"})
+		return
+	}
+
+	// One band per fare program, optionally limited to the requested tariff's program
+	var programs []models.Tariff
+	if req.TariffID != 0 {
+		var requested models.Tariff
+		if err := db.First(&requested, req.TariffID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tariff not found"})
+			return
+		}
+		programs = append(programs, requested)
+	} else {
+		for _, tariff := range tariffs {
+			if !containsFareProgram(programs, tariff) {
+				programs = append(programs, tariff)
+			}
+		}
+	}
+	if len(programs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No active tariffs"})
+		return
+	}
+
+	etas := quoteETAs(c, pickup, driver, vehicles)
+	fareConfig := services.LoadFareConfig()
+	secret := fareQuoteSecret()
+	ttl := fareQuoteTTL()
+
+	quotes := make([]FareQuoteResponse, 0, len(programs)*len(vehicles))
+	for _, program := range programs {
+		band := services.SelectTariffBand(tariffs, program, distance)
+		for _, vehicle := range vehicles {
+			fare := services.CalculateTripFare(band, distance, trip.WaitMinutes, vehicle, fareConfig)
+			quote := services.FareQuote{
+				TariffID:        band.ID,
+				VehicleType:     vehicle,
+				BecakCode:       req.BecakCode,
+				Pickup:          pickup,
+				Drop:            drop,
+				PickupLabel:     services.OrderPickupLabel(trip),
+				DropLabel:       services.OrderDropLabel(trip),
+				Stops:           stops,
+				RoundTrip:       trip.RoundTrip,
+				WaitMinutes:     trip.WaitMinutes,
+				DistanceKm:      distance,
+				DurationMinutes: fare.DurationMinutes,
+				Fare:            fare,
+			}
+			token, err := services.SignFareQuote(&quote, secret, ttl)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign fare quote"})
+				return
+			}
+
+			quotes = append(quotes, FareQuoteResponse{
+				QuoteToken:      token,
+				ExpiresAt:       quote.ExpiresAt,
+				TariffID:        band.ID,
+				TariffName:      band.Name,
+				VehicleType:     vehicle,
+				DistanceKm:      distance,
+				DurationMinutes: fare.DurationMinutes,
+				ETAMinutes:      etas[vehicle],
+				Price:           fare.Total,
+				CustomerTotal:   fare.CustomerTotal,
+				FareBreakdown:   fare,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"distance_km": distance,
+		"quotes":      quotes,
+	})
+}
+
+func containsFareProgram(programs []models.Tariff, tariff models.Tariff) bool {
+	for _, program := range programs {
+		if services.SameFareProgram(program, tariff) {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteETAs estimates minutes until a driver reaches pickup: the scanned becak's
+// driver when known, otherwise the nearest available driver of each vehicle type
+func quoteETAs(c *gin.Context, pickup geo.Point, driver *models.Driver, vehicles []models.VehicleType) map[models.VehicleType]*int {
+	ctx := c.Request.Context()
+	db := database.GetDB()
+	etas := make(map[models.VehicleType]*int, len(vehicles))
+
+	eta := func(from geo.Point, vehicle models.VehicleType) *int {
+		duration, err := routingProvider().ETA(ctx, from, pickup, vehicle)
+		if err != nil {
+			return nil
+		}
+		minutes := services.DurationMinutes(duration)
+		return &minutes
+	}
+
+	if driver != nil {
+		var location models.DriverLocation
+		if err := db.Where("driver_id = ?", driver.ID).First(&location).Error; err == nil {
+			etas[driver.VehicleType] = eta(geo.Point{Lat: location.Latitude, Lng: location.Longitude}, driver.VehicleType)
+		}
+		return etas
+	}
+
+	candidates, err := orderDispatcher().FindCandidates(pickup.Lat, pickup.Lng, services.LoadDispatchConfig().MaxRadiusKm)
+	if err != nil {
+		return etas
+	}
+	for _, candidate := range candidates {
+		vehicle := candidate.Driver.VehicleType
+		if _, done := etas[vehicle]; done {
+			continue
+		}
+		etas[vehicle] = eta(geo.Point{Lat: candidate.Location.Latitude, Lng: candidate.Location.Longitude}, vehicle)
+	}
+	return etas
+}
+
+// verifyQuoteToken validates a quote token from an order request and writes
+// the error response when it is expired or tampered with
+func verifyQuoteToken(c *gin.Context, token string) (*services.FareQuote, bool) {
+	quote, err := services.VerifyFareQuote(token, fareQuoteSecret())
+	if err != nil {
+		if errors.Is(err, services.ErrQuoteExpired) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fare quote has expired, please request a new quote"})
+			return nil, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fare quote"})
+		return nil, false
+	}
+	return quote, true
+}
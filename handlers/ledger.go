@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WalletAdjustmentRequest struct {
+	Amount float64 `json:"amount" binding:"required"` // Positif menambah saldo, negatif mengurangi
+	Memo   string  `json:"memo" binding:"required"`
+}
+
+// respondWallet writes a driver's ledger balance and paginated wallet movements
+func respondWallet(c *gin.Context, driverID uint) {
+	db := database.GetDB()
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	wallet, err := services.GetDriverWallet(db, driverID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balance"})
+		return
+	}
+
+	lines, total, err := services.WalletStatement(db, driverID, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wallet":       wallet,
+		"transactions": lines,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetMyWallet - Saldo wallet driver dan riwayat mutasinya dari ledger
+func GetMyWallet(c *gin.Context) {
+	db := database.GetDB()
+	userID, _ := c.Get("user_id")
+
+	var driver models.Driver
+	if err := db.Where("user_id = ?", userID).First(&driver).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
+
+	respondWallet(c, driver.ID)
+}
+
+// GetDriverWallet - Admin melihat saldo wallet dan mutasi driver berdasarkan ID
+func GetDriverWallet(c *gin.Context) {
+	db := database.GetDB()
+
+	var driver models.Driver
+	if err := db.First(&driver, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
+
+	respondWallet(c, driver.ID)
+}
+
+// CreateWalletAdjustment - Admin mengoreksi saldo wallet driver dengan journal entry
+func CreateWalletAdjustment(c *gin.Context) {
+	var req WalletAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var driver models.Driver
+	if err := db.First(&driver, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
+
+	// The key makes a double-submitted adjustment post only once
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key header is required"})
+		return
+	}
+
+	actorID, _ := orderActor(c)
+	entry, created, err := services.PostWalletAdjustment(db, driver.ID, key, req.Amount, req.Memo, actorID, time.Now())
+	if errors.Is(err, services.ErrInvalidAdjustment) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record adjustment"})
+		return
+	}
+
+	wallet, err := services.GetDriverWallet(db, driver.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balance"})
+		return
+	}
+
+	status, message := http.StatusCreated, "Wallet adjusted successfully"
+	if !created {
+		status, message = http.StatusOK, "Adjustment was already recorded"
+	}
+	c.JSON(status, gin.H{
+		"message": message,
+		"entry":   entry,
+		"wallet":  wallet,
+	})
+}
+
+// GetLedgerAccounts - Neraca saldo (trial balance) semua akun ledger
+func GetLedgerAccounts(c *gin.Context) {
+	accounts, err := services.TrialBalance(database.GetDB(), c.Query("type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ledger accounts"})
+		return
+	}
+
+	var debits, credits float64
+	for _, account := range accounts {
+		debits += account.Debits
+		credits += account.Credits
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accounts":      accounts,
+		"total_debits":  debits,
+		"total_credits": credits,
+	})
+}
+
+// GetJournalEntries - Daftar journal entry beserta posting-nya
+func GetJournalEntries(c *gin.Context) {
+	db := database.GetDB()
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := db.Model(&models.JournalEntry{})
+	if entryType := c.Query("type"); entryType != "" {
+		query = query.Where("type = ?", entryType)
+	}
+	if referenceType := c.Query("reference_type"); referenceType != "" {
+		query = query.Where("reference_type = ?", referenceType)
+	}
+	if referenceID := c.Query("reference_id"); referenceID != "" {
+		query = query.Where("reference_id = ?", referenceID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []models.JournalEntry
+	if err := query.Preload("Postings.Account").Order("posted_at DESC, id DESC").Offset((page - 1) * limit).Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch journal entries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
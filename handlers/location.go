@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"greenbecak-backend/database"
+	"greenbecak-backend/geo"
 	"greenbecak-backend/models"
+	"greenbecak-backend/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -42,7 +45,7 @@ func UpdateDriverLocation(c *gin.Context) {
 	}
 
 	db := database.GetDB()
-	driverID, _ := c.Get("user_id")
+	userID, _ := c.Get("user_id")
 
 	// Validate coordinates
 	if req.Latitude < -90 || req.Latitude > 90 {
@@ -59,14 +62,21 @@ func UpdateDriverLocation(c *gin.Context) {
 		req.Timestamp = time.Now()
 	}
 
-	// Update or create location record
+	// Locations are keyed by driver ID, not user ID
+	var driver models.Driver
+	if err := db.Where("user_id = ?", userID).First(&driver).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
+
+	// Update or create current location record
 	var location models.DriverLocation
-	result := db.Where("driver_id = ?", driverID).First(&location)
+	result := db.Where("driver_id = ?", driver.ID).First(&location)
 
 	if result.Error == gorm.ErrRecordNotFound {
 		// Create new location record
 		location = models.DriverLocation{
-			DriverID:  driverID.(uint),
+			DriverID:  driver.ID,
 			Latitude:  req.Latitude,
 			Longitude: req.Longitude,
 			Accuracy:  req.Accuracy,
@@ -91,7 +101,22 @@ func UpdateDriverLocation(c *gin.Context) {
 		db.Save(&location)
 	}
 
-	// Broadcast location update to connected clients (simulasi)
+	// Append to the breadcrumb trail
+	ping := models.DriverLocationPing{
+		DriverID:   driver.ID,
+		OrderID:    services.ActiveOrderIDForDriver(db, driver.ID),
+		Latitude:   req.Latitude,
+		Longitude:  req.Longitude,
+		Accuracy:   req.Accuracy,
+		Speed:      req.Speed,
+		Heading:    req.Heading,
+		RecordedAt: req.Timestamp,
+	}
+	if err := db.Create(&ping).Error; err != nil {
+		log.Printf("Failed to record location ping for driver %d: %v", driver.ID, err)
+	}
+
+	// Broadcast location update to stream subscribers
 	go broadcastLocationUpdate(location)
 
 	c.JSON(http.StatusOK, gin.H{
@@ -186,15 +211,13 @@ func GetNearbyDrivers(c *gin.Context) {
 		return
 	}
 
-	// Calculate bounding box for efficient querying
-	// 1 degree latitude ≈ 111km, 1 degree longitude ≈ 111km * cos(latitude)
-	latDelta := radius / 111.0
-	lngDelta := radius / (111.0 * cos(lat*3.14159/180))
+	// Bounding box pre-filter for efficient querying
+	box := geo.BoundingBoxAround(geo.Point{Lat: lat, Lng: lng}, radius)
 
 	var drivers []models.DriverLocation
 	query := db.Where("is_online = ? AND last_seen > ?", true, time.Now().Add(-5*time.Minute)).
-		Where("latitude BETWEEN ? AND ?", lat-latDelta, lat+latDelta).
-		Where("longitude BETWEEN ? AND ?", lng-lngDelta, lng+lngDelta)
+		Where("latitude BETWEEN ? AND ?", box.MinLat, box.MaxLat).
+		Where("longitude BETWEEN ? AND ?", box.MinLng, box.MaxLng)
 
 	if err := query.Limit(limit).Find(&drivers).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch nearby drivers"})
@@ -231,7 +254,7 @@ func GetDriverRoute(c *gin.Context) {
 	orderID := c.Param("order_id")
 
 	var order models.Order
-	if err := db.Preload("Driver").First(&order, orderID).Error; err != nil {
+	if err := db.Preload("Driver").Preload("Stops", services.OrderStopsBySequence).First(&order, orderID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
@@ -248,8 +271,12 @@ func GetDriverRoute(c *gin.Context) {
 		return
 	}
 
-	// Simulate route calculation (in real app, use Google Maps API or similar)
-	route := calculateRoute(driverLocation.Latitude, driverLocation.Longitude, order.PickupLocation, order.DropLocation)
+	start := geo.Point{Lat: driverLocation.Latitude, Lng: driverLocation.Longitude}
+	route, err := calculateRoute(c.Request.Context(), start, order, order.Driver.VehicleType)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to calculate route"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"order_id": orderID,
@@ -342,129 +369,148 @@ func SetDriverOnlineStatus(c *gin.Context) {
 	})
 }
 
-// GetLocationHistory - Mendapatkan history lokasi driver
+// GetLocationHistory - Mendapatkan history lokasi driver yang sedang login
 func GetLocationHistory(c *gin.Context) {
 	db := database.GetDB()
-	driverID := c.Param("id")
+	userID, _ := c.Get("user_id")
 
-	// Get query parameters
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	var driver models.Driver
+	if err := db.Where("user_id = ?", userID).First(&driver).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
 
-	query := db.Where("driver_id = ?", driverID)
+	respondLocationHistory(c, driver.ID)
+}
 
-	if startDate != "" {
-		query = query.Where("created_at >= ?", startDate)
-	}
-	if endDate != "" {
-		query = query.Where("created_at <= ?", endDate)
-	}
+// GetDriverLocationHistory - Admin melihat history lokasi driver berdasarkan ID
+func GetDriverLocationHistory(c *gin.Context) {
+	db := database.GetDB()
+	driverID := c.Param("id")
 
-	var locations []models.DriverLocation
-	if err := query.Order("created_at DESC").Limit(limit).Find(&locations).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location history"})
+	var driver models.Driver
+	if err := db.First(&driver, driverID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"driver_id": driverID,
-		"locations": locations,
-		"count":     len(locations),
-	})
+	respondLocationHistory(c, driver.ID)
 }
 
-// Helper functions
-func cos(x float64) float64 {
-	// Simple cosine approximation
-	return 1 - x*x/2 + x*x*x*x/24
-}
+// GetOrderTrack - Mendapatkan jejak perjalanan driver untuk order (replay trip)
+func GetOrderTrack(c *gin.Context) {
+	db := database.GetDB()
+	orderID := c.Param("id")
 
-func calculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
-	// Haversine formula for calculating distance between two points
-	const R = 6371 // Earth's radius in kilometers
+	var order models.Order
+	if err := db.Preload("Driver").First(&order, orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
 
-	lat1Rad := lat1 * 3.14159 / 180
-	lat2Rad := lat2 * 3.14159 / 180
-	deltaLat := (lat2 - lat1) * 3.14159 / 180
-	deltaLng := (lng2 - lng1) * 3.14159 / 180
+	if !canAccessOrder(c, order) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
 
-	a := sin(deltaLat/2)*sin(deltaLat/2) + cos(lat1Rad)*cos(lat2Rad)*sin(deltaLng/2)*sin(deltaLng/2)
-	c := 2 * atan2(sqrt(a), sqrt(1-a))
+	var pings []models.DriverLocationPing
+	if err := db.Where("order_id = ?", order.ID).Order("recorded_at ASC").Find(&pings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order track"})
+		return
+	}
 
-	return R * c
+	c.JSON(http.StatusOK, gin.H{
+		"order_id":  order.ID,
+		"driver_id": order.DriverID,
+		"status":    order.Status,
+		"locations": pings,
+		"count":     len(pings),
+	})
 }
 
-func sin(x float64) float64 {
-	// Simple sine approximation
-	return x - x*x*x/6 + x*x*x*x*x/120
-}
+// respondLocationHistory writes a driver's pings filtered by the start_date/end_date/limit query
+func respondLocationHistory(c *gin.Context, driverID uint) {
+	db := database.GetDB()
 
-func atan2(y, x float64) float64 {
-	// Simple atan2 approximation
-	if x > 0 {
-		return atan(y / x)
-	} else if x < 0 && y >= 0 {
-		return atan(y/x) + 3.14159
-	} else if x < 0 && y < 0 {
-		return atan(y/x) - 3.14159
-	} else if x == 0 && y > 0 {
-		return 3.14159 / 2
-	} else if x == 0 && y < 0 {
-		return -3.14159 / 2
-	} else {
-		return 0
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 5000 {
+		limit = 100
 	}
-}
 
-func atan(x float64) float64 {
-	// Simple arctangent approximation
-	return x - x*x*x/3 + x*x*x*x*x/5
-}
+	query := db.Where("driver_id = ?", driverID)
 
-func sqrt(x float64) float64 {
-	// Simple square root approximation
-	if x < 0 {
-		return 0
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := parseTimeQuery(startDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date"})
+			return
+		}
+		query = query.Where("recorded_at >= ?", start)
 	}
-	if x == 0 {
-		return 0
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := parseTimeQuery(endDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date"})
+			return
+		}
+		// A plain date includes the whole day
+		if len(endDate) == len("2006-01-02") {
+			end = end.Add(24*time.Hour - time.Nanosecond)
+		}
+		query = query.Where("recorded_at <= ?", end)
 	}
 
-	z := x / 2
-	for i := 0; i < 10; i++ {
-		z = (z + x/z) / 2
+	var locations []models.DriverLocationPing
+	if err := query.Order("recorded_at DESC").Limit(limit).Find(&locations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location history"})
+		return
 	}
-	return z
-}
 
-type Route struct {
-	Waypoints     []map[string]float64 `json:"waypoints"`
-	Distance      float64              `json:"distance"`
-	EstimatedTime int                  `json:"estimated_time"`
+	c.JSON(http.StatusOK, gin.H{
+		"driver_id": driverID,
+		"locations": locations,
+		"count":     len(locations),
+	})
 }
 
-func calculateRoute(startLat, startLng float64, pickupLocation, dropLocation string) Route {
-	// Simulate route calculation
-	// In real implementation, use Google Maps Directions API or similar
-
-	// Mock waypoints
-	waypoints := []map[string]float64{
-		{"lat": startLat, "lng": startLng},
-		{"lat": startLat + 0.001, "lng": startLng + 0.001}, // Pickup location
-		{"lat": startLat + 0.002, "lng": startLng + 0.002}, // Drop location
+// parseTimeQuery accepts RFC3339 timestamps or plain dates (2006-01-02, local time)
+func parseTimeQuery(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
 	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
 
-	return Route{
-		Waypoints:     waypoints,
-		Distance:      2.5, // km
-		EstimatedTime: 15,  // minutes
-	}
+// calculateDistance returns the great-circle distance between two points in km
+func calculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
+	return geo.Distance(geo.Point{Lat: lat1, Lng: lng1}, geo.Point{Lat: lat2, Lng: lng2})
 }
 
 func broadcastLocationUpdate(location models.DriverLocation) {
-	// Simulate broadcasting location update to connected clients
-	// In real implementation, use WebSocket or Server-Sent Events
-	fmt.Printf("Broadcasting location update for driver %d: %.6f, %.6f\n",
-		location.DriverID, location.Latitude, location.Longitude)
+	data := map[string]interface{}{
+		"driver_id": location.DriverID,
+		"latitude":  location.Latitude,
+		"longitude": location.Longitude,
+		"accuracy":  location.Accuracy,
+		"speed":     location.Speed,
+		"heading":   location.Heading,
+		"is_online": location.IsOnline,
+		"last_seen": location.LastSeen,
+	}
+
+	services.Hub.Publish(services.TopicAdmin, services.EventDriverLocation, data)
+	services.Hub.Publish(services.DriverTopic(location.DriverID), services.EventDriverLocation, data)
+
+	// Push to customers following an active trip of this driver
+	db := database.GetDB()
+	if db == nil {
+		return
+	}
+	var orderIDs []uint
+	db.Model(&models.Order{}).
+		Where("driver_id = ? AND status IN ?", location.DriverID, services.ActiveTripStatuses).
+		Pluck("id", &orderIDs)
+	for _, orderID := range orderIDs {
+		services.Hub.Publish(services.OrderTopic(orderID), services.EventDriverLocation, data)
+	}
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"greenbecak-backend/database"
 	"greenbecak-backend/models"
+	"greenbecak-backend/services"
 )
 
 type NotificationRequest struct {
@@ -109,7 +110,7 @@ func CreateNotification(c *gin.Context) {
 		return
 	}
 
-	// Send real-time notification (simulasi)
+	// Send real-time notification
 	go sendRealTimeNotification(notification)
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -383,11 +384,7 @@ func SendBulkNotification(c *gin.Context) {
 	})
 }
 
-// sendRealTimeNotification - Simulasi pengiriman notifikasi real-time
+// sendRealTimeNotification - Push notifikasi ke stream user yang sedang terhubung
 func sendRealTimeNotification(notification models.Notification) {
-	// Simulasi delay pengiriman
-	time.Sleep(100 * time.Millisecond)
-	
-	// Di sini bisa diintegrasikan dengan WebSocket, Firebase Cloud Messaging, atau layanan push notification lainnya
-	fmt.Printf("Real-time notification sent to user %d: %s\n", notification.UserID, notification.Title)
+	services.Hub.Publish(services.UserTopic(notification.UserID), services.EventNotification, notification)
 }
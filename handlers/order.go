@@ -1,50 +1,185 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
-	"greenbecak-backend/config"
 	"greenbecak-backend/database"
 	"greenbecak-backend/models"
+	"greenbecak-backend/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// OrderLocationInput carries pickup/drop either as structured places or, for
+// older clients, as plain address strings. The trip distance is always computed
+// by the server; a distance sent by the client is ignored.
+type OrderLocationInput struct {
+	PickupLocation string               `json:"pickup_location"`
+	DropLocation   string               `json:"drop_location"`
+	Pickup         *services.OrderPlace `json:"pickup"`
+	Drop           *services.OrderPlace `json:"drop"`
+}
+
+// OrderScheduleInput turns an order into a booking for a future pickup window
+type OrderScheduleInput struct {
+	ScheduledAt    *time.Time `json:"scheduled_at"`    // Awal jendela jemput (RFC 3339)
+	ScheduledUntil *time.Time `json:"scheduled_until"` // Opsional, default SCHEDULE_DEFAULT_WINDOW setelah scheduled_at
+}
+
+// OrderStopsInput adds ordered stops between pickup and drop, or a round trip back to pickup
+type OrderStopsInput struct {
+	Stops     []services.StopInput `json:"stops"`
+	RoundTrip bool                 `json:"round_trip"` // Kembali ke pickup; drop tidak perlu dikirim
+}
+
 type CreateOrderRequest struct {
-	CustomerID     uint    `json:"customer_id" binding:"required"`
-	TariffID       uint    `json:"tariff_id" binding:"required"`
-	PickupLocation string  `json:"pickup_location" binding:"required"`
-	DropLocation   string  `json:"drop_location" binding:"required"`
-	Distance       float64 `json:"distance" binding:"required"`
-	CustomerPhone  string  `json:"customer_phone"`
-	CustomerName   string  `json:"customer_name"`
-	Notes          string  `json:"notes"`
+	OrderLocationInput
+	OrderScheduleInput
+	OrderStopsInput
+	CustomerID    uint     `json:"customer_id" binding:"required"`
+	TariffID      uint     `json:"tariff_id"`    // Wajib jika tanpa quote_token
+	QuoteToken    string   `json:"quote_token"`  // Mengunci harga dari POST /api/orders/quote
+	VehicleType   string   `json:"vehicle_type"` // Opsional, default becak_manual
+	PickupLat     *float64 `json:"pickup_lat"`
+	PickupLng     *float64 `json:"pickup_lng"`
+	CustomerPhone string   `json:"customer_phone"`
+	CustomerName  string   `json:"customer_name"`
+	Notes         string   `json:"notes"`
 }
 
 type CreateOrderPublicRequest struct {
+	OrderLocationInput
+	OrderScheduleInput
+	OrderStopsInput
+	OrderPackageInput
 	BecakCode     string `json:"becak_code" binding:"required"` // Kode dari sticker barcode
-	TariffID      uint   `json:"tariff_id" binding:"required"`
+	TariffID      uint   `json:"tariff_id"`                     // Wajib jika tanpa quote_token
+	QuoteToken    string `json:"quote_token"`                   // Mengunci harga dari POST /api/orders/quote
 	CustomerPhone string `json:"customer_phone" binding:"required"`
 	CustomerName  string `json:"customer_name"`
 	Notes         string `json:"notes"`
 }
 
+type ConfirmOrderPaymentPublicRequest struct {
+	Method     string `json:"method"`      // cash (default), transfer atau qr
+	OrderToken string `json:"order_token"` // Dari respons POST /api/orders/public, atau header X-Order-Token
+}
+
 type UpdateOrderRequest struct {
 	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
 }
 
 type UpdateOrderLocationRequest struct {
-	PickupLocation string  `json:"pickup_location" binding:"required"`
-	DropLocation   string  `json:"drop_location" binding:"required"`
-	Distance       float64 `json:"distance" binding:"required"`
+	OrderLocationInput
 }
 
-// generateOrderNumber creates a unique order number
-func generateOrderNumber() string {
-	return fmt.Sprintf("ORD-%d", time.Now().Unix())
+// applyOrderLocation copies pickup/drop onto the order. Structured places win over
+// address strings, and the distance is recomputed by the routing provider whenever
+// both points are known.
+func applyOrderLocation(ctx context.Context, order *models.Order, input OrderLocationInput, vehicle models.VehicleType) error {
+	if input.Pickup != nil {
+		if err := services.SetOrderPickup(order, *input.Pickup); err != nil {
+			return errors.New("Invalid pickup coordinates")
+		}
+	} else if input.PickupLocation != "" {
+		order.PickupLocation = input.PickupLocation
+	}
+
+	if input.Drop != nil {
+		if err := services.SetOrderDrop(order, *input.Drop); err != nil {
+			return errors.New("Invalid drop coordinates")
+		}
+	} else if input.DropLocation != "" {
+		order.DropLocation = input.DropLocation
+	}
+
+	if distance, ok := routedTripDistance(ctx, *order, vehicle); ok {
+		order.Distance = distance
+	}
+	return nil
+}
+
+// priceOrder picks the tariff band for the order's distance and stores the itemized fare.
+// Without a routed distance the requested band is priced at its full distance.
+func priceOrder(db *gorm.DB, order *models.Order, tariffID uint, vehicle models.VehicleType) error {
+	if order.Distance <= 0 {
+		var tariff models.Tariff
+		if err := db.First(&tariff, tariffID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return services.ErrTariffNotFound
+			}
+			return err
+		}
+		order.Distance = tariff.MaxDistance
+	}
+	band, fare, err := services.QuoteFare(db, tariffID, order.Distance, order.WaitMinutes, vehicle, services.LoadFareConfig())
+	if err != nil {
+		return err
+	}
+	order.TariffID = band.ID
+	order.Price = fare.Total
+	order.FareBreakdown = &fare
+	return nil
+}
+
+// applyOrderStops sets stops and the round-trip return on the order and
+// recomputes the trip distance over the whole route
+func applyOrderStops(ctx context.Context, order *models.Order, input OrderStopsInput, vehicle models.VehicleType) error {
+	if len(input.Stops) == 0 && !input.RoundTrip {
+		return nil
+	}
+	if err := services.SetOrderStops(order, input.Stops, input.RoundTrip, services.LoadStopConfig()); err != nil {
+		return err
+	}
+	if distance, ok := routedTripDistance(ctx, *order, vehicle); ok {
+		order.Distance = distance
+	}
+	return nil
+}
+
+// quoteMatchesStops reports whether an order's stops are the ones the fare quote was priced for
+func quoteMatchesStops(quote services.FareQuote, order models.Order) bool {
+	if quote.RoundTrip != order.RoundTrip || quote.WaitMinutes != order.WaitMinutes || len(quote.Stops) != len(order.Stops) {
+		return false
+	}
+	for i, stop := range order.Stops {
+		if point, _ := stop.Point(); point != quote.Stops[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// applyOrderSchedule validates and sets the booking window, writing a 400 on failure
+func applyOrderSchedule(c *gin.Context, order *models.Order, input OrderScheduleInput) bool {
+	if input.ScheduledAt == nil {
+		if input.ScheduledUntil != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled_until requires scheduled_at"})
+			return false
+		}
+		return true
+	}
+	if err := services.ApplySchedule(order, *input.ScheduledAt, input.ScheduledUntil, time.Now(), services.LoadScheduleConfig()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+func respondFareError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrTariffNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tariff not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate fare"})
 }
 
 func CreateOrder(c *gin.Context) {
@@ -55,46 +190,95 @@ func CreateOrder(c *gin.Context) {
 			return
 		}
 
-		db := database.GetDB()
-
-		// Get tariff
-		var tariff models.Tariff
-		if err := db.First(&tariff, req.TariffID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Tariff not found"})
+		var quote *services.FareQuote
+		if req.QuoteToken != "" {
+			var ok bool
+			if quote, ok = verifyQuoteToken(c, req.QuoteToken); !ok {
+				return
+			}
+			if req.VehicleType != "" && models.VehicleType(req.VehicleType) != quote.VehicleType {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Fare quote does not match vehicle type"})
+				return
+			}
+		} else if req.TariffID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tariff_id or quote_token is required"})
 			return
 		}
 
-		// Calculate price based on distance range
-		var price float64
-		if req.Distance >= tariff.MinDistance && req.Distance <= tariff.MaxDistance {
-			price = tariff.Price
-		} else {
-			// If distance is outside range, use the tariff price anyway (flat pricing)
-			price = tariff.Price
+		// The trip is routed and priced for the quoted or requested vehicle
+		vehicle := models.VehicleType(req.VehicleType)
+		if quote != nil {
+			vehicle = quote.VehicleType
+		} else if _, ok := services.DefaultVehicleMultipliers[vehicle]; vehicle != "" && !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle type"})
+			return
 		}
 
 		order := models.Order{
-			OrderNumber:    generateOrderNumber(),
-			CustomerID:     &req.CustomerID,
-			TariffID:       req.TariffID,
-			PickupLocation: req.PickupLocation,
-			DropLocation:   req.DropLocation,
-			Distance:       req.Distance,
-			Price:          price,
-			Status:         "pending",
-			PaymentStatus:  "pending",
-			CustomerPhone:  req.CustomerPhone,
-			CustomerName:   req.CustomerName,
-			Notes:          req.Notes,
-		}
-
-		if err := db.Create(&order).Error; err != nil {
+			CustomerID:    &req.CustomerID,
+			TariffID:      req.TariffID,
+			Status:        "pending",
+			PaymentStatus: "pending",
+			CustomerPhone: req.CustomerPhone,
+			CustomerName:  req.CustomerName,
+			Notes:         req.Notes,
+		}
+		if !applyOrderSchedule(c, &order, req.OrderScheduleInput) {
+			return
+		}
+
+		// Older clients send the pickup point as flat pickup_lat/pickup_lng
+		if req.Pickup == nil && req.PickupLat != nil && req.PickupLng != nil {
+			req.Pickup = &services.OrderPlace{Lat: req.PickupLat, Lng: req.PickupLng, Address: req.PickupLocation}
+		}
+		if err := applyOrderLocation(c.Request.Context(), &order, req.OrderLocationInput, vehicle); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := applyOrderStops(c.Request.Context(), &order, req.OrderStopsInput, vehicle); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if quote != nil {
+			if !quoteMatchesStops(*quote, order) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Fare quote does not match stops"})
+				return
+			}
+			services.ApplyFareQuote(&order, *quote)
+		}
+		if order.PickupLocation == "" && order.PickupLat == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pickup or pickup_location is required"})
+			return
+		}
+		if order.DropLocation == "" && order.DropLat == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "drop or drop_location is required"})
+			return
+		}
+		db := database.GetDB()
+
+		if quote == nil {
+			if err := priceOrder(db, &order, req.TariffID, vehicle); err != nil {
+				respondFareError(c, err)
+				return
+			}
+		}
+
+		if err := services.CreateWithReferenceNumber(db, services.ReferencePrefixOrder, &order, func(number string) { order.OrderNumber = number }); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 			return
 		}
 
-		// Send notification to available drivers
-		go sendNewOrderNotification(order)
+		actorID, actorRole := orderActor(c)
+		if err := services.RecordOrderCreated(db, &order, actorID, actorRole); err != nil {
+			log.Printf("Failed to record creation of order %d: %v", order.ID, err)
+		}
+		services.Hub.Publish(services.TopicAdmin, services.EventOrderCreated, order)
+
+		// Offer the order to the nearest available drivers; bookings are
+		// dispatched by the scheduler at their lead time
+		if !order.IsScheduled() {
+			go dispatchOrder(order)
+		}
 
 		c.JSON(http.StatusCreated, gin.H{
 			"message": "Order created successfully",
@@ -113,9 +297,24 @@ func CreateOrderPublic(c *gin.Context) {
 
 	db := database.GetDB()
 
-	// Get tariff
+	var quote *services.FareQuote
 	var tariff models.Tariff
-	if err := db.First(&tariff, req.TariffID).Error; err != nil {
+	if req.PackageID != 0 {
+		// Packages are priced by the package itself
+		if req.QuoteToken != "" || len(req.Stops) > 0 || req.RoundTrip {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "package_id cannot be combined with quote_token or stops"})
+			return
+		}
+	} else if req.QuoteToken != "" {
+		var ok bool
+		if quote, ok = verifyQuoteToken(c, req.QuoteToken); !ok {
+			return
+		}
+		if quote.BecakCode != "" && quote.BecakCode != req.BecakCode {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fare quote does not match becak code"})
+			return
+		}
+	} else if err := db.First(&tariff, req.TariffID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Tariff not found"})
 		return
 	}
@@ -126,41 +325,95 @@ func CreateOrderPublic(c *gin.Context) {
 	if err := db.Where("driver_code = ?", req.BecakCode).First(&driver).Error; err == nil {
 		driverID = &driver.ID
 	}
+	if quote != nil && driverID != nil && driver.VehicleType != quote.VehicleType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fare quote does not match vehicle type"})
+		return
+	}
 
 	// Set default customer name if not provided
 	if req.CustomerName == "" {
 		req.CustomerName = "Customer"
 	}
 
-	// Use tariff's max distance as default distance (flat pricing)
-	distance := tariff.MaxDistance
-	price := tariff.Price
-
 	order := models.Order{
-		OrderNumber:    generateOrderNumber(),
-		BecakCode:      req.BecakCode,
-		DriverID:       driverID,
-		TariffID:       req.TariffID,
-		PickupLocation: "", // Akan diisi nanti oleh sistem/driver
-		DropLocation:   "", // Akan diisi nanti oleh sistem/driver
-		Distance:       distance,
-		Price:          price,
-		Status:         "pending",
-		PaymentStatus:  "pending",
-		CustomerPhone:  req.CustomerPhone,
-		CustomerName:   req.CustomerName,
-		Notes:          req.Notes,
-	}
-
-	if err := db.Create(&order).Error; err != nil {
+		BecakCode:     req.BecakCode,
+		DriverID:      driverID,
+		TariffID:      req.TariffID,
+		Status:        "pending",
+		PaymentStatus: "pending",
+		CustomerPhone: req.CustomerPhone,
+		CustomerName:  req.CustomerName,
+		Notes:         req.Notes,
+	}
+	if !applyOrderSchedule(c, &order, req.OrderScheduleInput) {
+		return
+	}
+	// Booking made at the becak itself reserves that driver for the pickup
+	// window, unless the driver already holds an overlapping booking
+	if order.IsScheduled() && driverID != nil {
+		if err := services.CheckBookingOverlap(db, &order, *driverID); err != nil {
+			respondBookingError(c, err, "Failed to check driver bookings")
+			return
+		}
+		committedAt := time.Now()
+		order.CommittedAt = &committedAt
+	}
+
+	// Pickup/drop are optional here; otherwise they are filled in later by the
+	// driver. The trip is routed for the becak's vehicle, or the quoted one.
+	vehicle := driver.VehicleType
+	if driverID == nil && quote != nil {
+		vehicle = quote.VehicleType
+	}
+	if err := applyOrderLocation(c.Request.Context(), &order, req.OrderLocationInput, vehicle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := applyOrderStops(c.Request.Context(), &order, req.OrderStopsInput, vehicle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if quote != nil {
+		if !quoteMatchesStops(*quote, order) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fare quote does not match stops"})
+			return
+		}
+		services.ApplyFareQuote(&order, *quote)
+	} else if req.PackageID != 0 {
+		if !applyOrderPackage(c, db, &order, req.OrderPackageInput, driver.VehicleType) {
+			return
+		}
+	} else if err := priceOrder(db, &order, tariff.ID, driver.VehicleType); err != nil {
+		respondFareError(c, err)
+		return
+	}
+
+	if order.PackageID != nil {
+		if !createPackageOrder(c, db, &order) {
+			return
+		}
+	} else if err := services.CreateWithReferenceNumber(db, services.ReferencePrefixOrder, &order, func(number string) { order.OrderNumber = number }); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 		return
 	}
 
+	if err := services.RecordOrderCreated(db, &order, nil, services.ActorRoleCustomer); err != nil {
+		log.Printf("Failed to record creation of order %d: %v", order.ID, err)
+	}
+	services.Hub.Publish(services.TopicAdmin, services.EventOrderCreated, order)
+
+	// Orders without a known becak go to the nearest available drivers, like
+	// CreateOrder; bookings are dispatched by the scheduler at their lead time
+	if driverID == nil && !order.IsScheduled() {
+		go dispatchOrder(order)
+	}
+
 	// Response data
 	resp := gin.H{
-		"message": "Order created successfully",
-		"order":   order,
+		"message":     "Order created successfully",
+		"order":       order,
+		"order_token": services.OrderAccessToken(order, orderAccessSecret()),
 	}
 
 	// Add driver info if found
@@ -179,28 +432,79 @@ func CreateOrderPublic(c *gin.Context) {
 	c.JSON(http.StatusCreated, resp)
 }
 
-// ConfirmOrderPaymentPublic allows customers to confirm payment publicly (especially for subsidized/cash orders)
+// ConfirmOrderPaymentPublic lets a customer without an account pay an order
+// created with CreateOrderPublic, proven by its order token. Cash stays
+// pending until the driver completes the trip; transfer and qr payments are
+// billed through the payment gateway (or QRIS) and settle when the payment is
+// confirmed there.
 func ConfirmOrderPaymentPublic(c *gin.Context) {
-	orderID := c.Param("id")
-	db := database.GetDB()
+	var req ConfirmOrderPaymentPublicRequest
+	// The body is optional when the token comes in the header
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	var order models.Order
-	if err := db.First(&order, orderID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	order, ok := loadPublicOrder(c, req.OrderToken)
+	if !ok {
 		return
 	}
 
-	order.PaymentStatus = "paid"
+	method := models.PaymentMethod(req.Method)
+	if method == "" {
+		method = models.PaymentMethodCash
+	}
+	if method != models.PaymentMethodCash && method != models.PaymentMethodTransfer && method != models.PaymentMethodQR {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment method"})
+		return
+	}
+	switch order.Status {
+	case models.OrderStatusCancelled, models.OrderStatusExpired, models.OrderStatusNoShow:
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is no longer payable"})
+		return
+	}
+
+	db := database.GetDB()
 
-	if err := db.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order payment status"})
+	payment, err := services.CreateOrderPayment(db, *order, method, "Dikonfirmasi customer")
+	if errors.Is(err, services.ErrPaymentExists) {
+		// Retrying the same pending payment returns it (with its payment URL) again
+		if payment.Status != models.PaymentStatusPending || payment.Method != method {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Order already has a payment",
+				"payment": payment,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Payment already started",
+			"payment": payment,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Payment confirmed successfully",
-		"order":   order,
-	})
+	resp := gin.H{
+		"message": "Payment created successfully",
+		"payment": payment,
+	}
+	if services.OrderAmountDue(*order) <= 0 {
+		// Order bersubsidi penuh tidak ditagih
+		if _, err := services.ApplyPaymentResult(db, &payment, services.PaymentResult{Status: models.PaymentStatusPaid, At: time.Now()}); err != nil {
+			respondPaymentError(c, err, "Failed to confirm payment")
+			return
+		}
+		resp["message"] = "Payment confirmed successfully"
+		resp["payment"] = payment
+	} else {
+		// Tunai tetap pending sampai driver menyelesaikan order (menerima uangnya)
+		startPaymentCollection(c, db, &payment, resp)
+	}
+
+	c.JSON(http.StatusCreated, resp)
 }
 
 // GetOrderHistory returns orders by customer phone
@@ -245,7 +549,7 @@ func GetOrders(c *gin.Context) {
 	role, _ := c.Get("role")
 
 	var orders []models.Order
-	query := db.Preload("Customer").Preload("Driver").Preload("Tariff")
+	query := db.Preload("Customer").Preload("Driver").Preload("Tariff").Preload("Stops", services.OrderStopsBySequence)
 
 	// Filter based on role
 	if role == "admin" {
@@ -276,11 +580,13 @@ func GetOrder(c *gin.Context) {
 	db := database.GetDB()
 
 	var order models.Order
-	if err := db.Preload("Customer").Preload("Driver").Preload("Tariff").First(&order, orderID).Error; err != nil {
+	if err := db.Preload("Customer").Preload("Driver").Preload("Tariff").Preload("Stops", services.OrderStopsBySequence).Preload("Package").First(&order, orderID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
 
+	setOrderETA(c.Request.Context(), &order)
+
 	c.JSON(http.StatusOK, gin.H{"order": order})
 }
 
@@ -300,27 +606,86 @@ func UpdateOrder(c *gin.Context) {
 		return
 	}
 
-	// Update status
-	order.Status = models.OrderStatus(req.Status)
-	now := time.Now()
+	status := models.OrderStatus(req.Status)
+	if !services.IsValidOrderStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order status"})
+		return
+	}
+
+	actorID, actorRole := orderActor(c)
 
-	switch req.Status {
-	case "accepted":
-		order.AcceptedAt = &now
-		userID, _ := c.Get("user_id")
-		driverID := uint(userID.(uint))
-		order.DriverID = &driverID
-	case "completed":
-		order.CompletedAt = &now
-	case "cancelled":
-		order.CancelledAt = &now
+	// Check the caller against the order before any transition. Only the
+	// assigned driver or an admin may complete an order, as completion credits
+	// the driver's wallet.
+	var driver models.Driver
+	switch actorRole {
+	case services.ActorRoleAdmin:
+	case services.ActorRoleDriver:
+		var ok bool
+		if driver, ok = currentDriver(c, db); !ok {
+			return
+		}
+		assigned := order.DriverID != nil && *order.DriverID == driver.ID
+		if !assigned && !(status == models.OrderStatusAccepted && order.DriverID == nil) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Order does not belong to this driver"})
+			return
+		}
+	default:
+		if actorID == nil || order.CustomerID == nil || *order.CustomerID != *actorID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if status == models.OrderStatusCompleted {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the assigned driver or an admin can complete an order"})
+			return
+		}
 	}
 
-	if err := db.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+	if status == models.OrderStatusCancelled {
+		// Cancellations go through the cancellation policy; a reason that is not a
+		// known code is kept as the note of an "other" cancellation
+		reason := models.CancellationReason(req.Reason)
+		note := ""
+		if !services.IsValidCancelReason(actorRole, reason) {
+			reason, note = models.CancelReasonOther, req.Reason
+		}
+		cancelOrderAs(c, &order, services.CancelRequest{
+			ActorID:   actorID,
+			ActorRole: actorRole,
+			Reason:    reason,
+			Note:      note,
+		})
 		return
 	}
 
+	var err error
+	if status == models.OrderStatusAccepted && actorRole == services.ActorRoleDriver {
+		// Drivers accepting through the generic endpoint get the same atomic path as AcceptOrder
+		err = services.AcceptOrderForDriver(db, &order, &driver, actorID)
+	} else if status == models.OrderStatusCompleted && order.DriverID != nil {
+		// Completion always credits the assigned driver's wallet, also when an admin completes it
+		if actorRole == services.ActorRoleAdmin {
+			if err := db.First(&driver, *order.DriverID).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+				return
+			}
+		}
+		err = services.CompleteOrderForDriver(db, &order, &driver, actorID, actorRole)
+	} else {
+		err = services.TransitionOrder(db, &order, services.OrderTransition{
+			To:        status,
+			ActorID:   actorID,
+			ActorRole: actorRole,
+			Reason:    req.Reason,
+		})
+	}
+	if err != nil {
+		respondOrderTransitionError(c, err, "Failed to update order")
+		return
+	}
+
+	services.PublishOrderStatus(&order)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Order updated successfully",
 		"order":   order,
@@ -339,18 +704,39 @@ func UpdateOrderLocation(c *gin.Context) {
 	db := database.GetDB()
 
 	var order models.Order
-	if err := db.First(&order, orderID).Error; err != nil {
+	if err := db.Preload("Driver").Preload("Stops", services.OrderStopsBySequence).First(&order, orderID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
 
+	// Only the order's customer, its driver or an admin may move it
+	if !canAccessOrder(c, order) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if req.Pickup == nil && req.Drop == nil && req.PickupLocation == "" && req.DropLocation == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No location provided"})
+		return
+	}
+
 	// Update location details
-	order.PickupLocation = req.PickupLocation
-	order.DropLocation = req.DropLocation
-	order.Distance = req.Distance
+	if err := applyOrderLocation(c.Request.Context(), &order, req.OrderLocationInput, order.Driver.VehicleType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Fares are only recalculated before a driver has accepted the order, and
+	// never for packages or fares locked by a quote
+	if order.Status == models.OrderStatusPending && !services.HasFixedFare(order) {
+		if err := priceOrder(db, &order, order.TariffID, order.Driver.VehicleType); err != nil {
+			respondFareError(c, err)
+			return
+		}
+	}
 
-	if err := db.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order location"})
+	if err := services.SaveOrderLocation(db, &order); err != nil {
+		respondOrderTransitionError(c, err, "Failed to update order location")
 		return
 	}
 
@@ -371,39 +757,3 @@ func DeleteOrder(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
 }
-
-// sendNewOrderNotification sends push notification to available drivers
-func sendNewOrderNotification(order models.Order) {
-	if config.FirebaseService == nil {
-		return
-	}
-
-	db := database.GetDB()
-
-	// Get all active drivers with FCM tokens
-	var drivers []models.Driver
-	if err := db.Where("is_active = ? AND fcm_token != ''", true).Find(&drivers).Error; err != nil {
-		return
-	}
-
-	// Prepare order data for notification
-	orderData := map[string]interface{}{
-		"id":              order.ID,
-		"price":           order.Price,
-		"pickup_location": order.PickupLocation,
-		"drop_location":   order.DropLocation,
-		"distance":        order.Distance,
-		"eta":             order.ETA,
-	}
-
-	// Send notification to each driver
-	for _, driver := range drivers {
-		if driver.FCMToken != "" {
-			err := config.FirebaseService.SendNewOrderNotification(driver.FCMToken, orderData)
-			if err != nil {
-				// Log error but continue with other drivers
-				fmt.Printf("Failed to send notification to driver %d: %v\n", driver.ID, err)
-			}
-		}
-	}
-}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+
+	"greenbecak-backend/config"
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrderTokenHeader may carry the order token instead of the request body
+const OrderTokenHeader = "X-Order-Token"
+
+// orderAccessSecret returns the secret public order tokens are signed with
+func orderAccessSecret() string {
+	if secret := os.Getenv("ORDER_ACCESS_SECRET"); secret != "" {
+		return secret
+	}
+	return config.LoadConfig().JWTSecret
+}
+
+// loadPublicOrder loads the order in the :id path and checks its order token,
+// taken from the body or the X-Order-Token header. A wrong token gets the
+// same 404 as a missing order so order IDs cannot be probed.
+func loadPublicOrder(c *gin.Context, token string) (*models.Order, bool) {
+	if token == "" {
+		token = c.GetHeader(OrderTokenHeader)
+	}
+
+	var order models.Order
+	if err := database.GetDB().First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return nil, false
+	}
+	if err := services.VerifyOrderAccessToken(order, orderAccessSecret(), token); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return nil, false
+	}
+	return &order, true
+}
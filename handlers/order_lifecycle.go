@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderActor returns the authenticated user and role for order history.
+// Unauthenticated (public) requests are recorded as customer actions.
+func orderActor(c *gin.Context) (*uint, string) {
+	role, _ := c.Get("role")
+	roleStr, _ := role.(string)
+	if roleStr == "" {
+		roleStr = services.ActorRoleCustomer
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return nil, roleStr
+	}
+	id, ok := userID.(uint)
+	if !ok {
+		return nil, roleStr
+	}
+	return &id, roleStr
+}
+
+// respondOrderTransitionError maps lifecycle errors to HTTP responses
+func respondOrderTransitionError(c *gin.Context, err error, fallback string) {
+	if errors.Is(err, services.ErrOrderConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order was already taken or updated by another request"})
+		return
+	}
+	if errors.Is(err, services.ErrDriverUnavailable) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Driver is not available"})
+		return
+	}
+
+	var transitionErr *services.InvalidTransitionError
+	if errors.As(err, &transitionErr) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          transitionErr.Error(),
+			"current_status": transitionErr.From,
+			"target_status":  transitionErr.To,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
+
+// GetOrderStatusHistory - Mendapatkan riwayat perubahan status order
+func GetOrderStatusHistory(c *gin.Context) {
+	orderID := c.Param("id")
+	db := database.GetDB()
+
+	var order models.Order
+	if err := db.Preload("Driver").First(&order, orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if !canAccessOrder(c, order) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var history []models.OrderStatusHistory
+	if err := db.Where("order_id = ?", order.ID).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id": order.ID,
+		"status":   order.Status,
+		"history":  history,
+	})
+}
+
+// PickupOrder - Driver menandai customer sudah dijemput
+func PickupOrder(c *gin.Context) {
+	advanceDriverOrder(c, models.OrderStatusPickedUp, "Order marked as picked up")
+}
+
+// StartOrder - Driver memulai perjalanan
+func StartOrder(c *gin.Context) {
+	advanceDriverOrder(c, models.OrderStatusInProgress, "Trip started")
+}
+
+// advanceDriverOrder moves an order owned by the current driver to the given status
+func advanceDriverOrder(c *gin.Context, to models.OrderStatus, message string) {
+	orderID := c.Param("id")
+	db := database.GetDB()
+	userID, _ := c.Get("user_id")
+
+	var order models.Order
+	if err := db.First(&order, orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var driver models.Driver
+	if err := db.Where("user_id = ?", userID).First(&driver).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
+
+	if order.DriverID == nil || *order.DriverID != driver.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Order does not belong to this driver"})
+		return
+	}
+
+	actorID, _ := orderActor(c)
+	err := services.TransitionOrder(db, &order, services.OrderTransition{
+		To:        to,
+		ActorID:   actorID,
+		ActorRole: services.ActorRoleDriver,
+	})
+	if err != nil {
+		respondOrderTransitionError(c, err, "Failed to update order")
+		return
+	}
+
+	services.PublishOrderStatus(&order)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"order":   order,
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// ArriveAtStop - Driver tiba di titik singgah
+func ArriveAtStop(c *gin.Context) {
+	updateOrderStop(c, true)
+}
+
+// DepartFromStop - Driver berangkat dari titik singgah; waktu tunggu lebih dari pesanan ikut ditagih
+func DepartFromStop(c *gin.Context) {
+	updateOrderStop(c, false)
+}
+
+// updateOrderStop marks a stop of the current driver's order as arrived or departed
+func updateOrderStop(c *gin.Context, arrive bool) {
+	db := database.GetDB()
+
+	sequence, err := strconv.Atoi(c.Param("sequence"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stop sequence"})
+		return
+	}
+
+	driver, ok := currentDriver(c, db)
+	if !ok {
+		return
+	}
+
+	var order models.Order
+	if err := db.Preload("Stops", services.OrderStopsBySequence).First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if order.DriverID == nil || *order.DriverID != driver.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Order does not belong to this driver"})
+		return
+	}
+
+	var stop *models.OrderStop
+	repriced := false
+	if arrive {
+		stop, err = services.ArriveAtStop(db, &order, sequence, time.Now())
+	} else {
+		stop, repriced, err = services.DepartFromStop(db, &order, sequence, time.Now())
+	}
+	if err != nil {
+		respondOrderStopError(c, err)
+		return
+	}
+
+	// Extra waiting is added to the fare. Quoted fares keep their price plus a
+	// waiting item; rentals charge time past the booking as overtime instead.
+	if repriced {
+		if services.HasFixedFare(order) {
+			if order.PackageID == nil {
+				services.AddWaitingCharge(&order, services.LoadFareConfig())
+			}
+		} else if err := priceOrder(db, &order, order.TariffID, driver.VehicleType); err != nil {
+			respondFareError(c, err)
+			return
+		}
+		if err := db.Model(&order).
+			Select("wait_minutes", "tariff_id", "price", "fare_breakdown").
+			Omit(clause.Associations).
+			Updates(&order).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update fare"})
+			return
+		}
+	}
+
+	services.Hub.Publish(services.OrderTopic(order.ID), services.EventOrderStop, gin.H{
+		"order_id":     order.ID,
+		"stop":         stop,
+		"wait_minutes": order.WaitMinutes,
+		"price":        order.Price,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Stop updated successfully",
+		"stop":    stop,
+		"order":   order,
+	})
+}
+
+// respondOrderStopError maps stop errors to HTTP responses
+func respondOrderStopError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrStopNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrStopOutOfOrder), errors.Is(err, services.ErrStopState), errors.Is(err, services.ErrNotOnBoard):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		respondOrderTransitionError(c, err, "Failed to update stop")
+	}
+}
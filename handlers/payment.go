@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,20 +11,22 @@ import (
 	"github.com/gin-gonic/gin"
 	"greenbecak-backend/database"
 	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+	"gorm.io/gorm"
 )
 
 type PaymentRequest struct {
 	OrderID uint   `json:"order_id" binding:"required"`
 	Method  string `json:"method" binding:"required"`
-	Amount  float64 `json:"amount" binding:"required"`
-	Notes   string  `json:"notes"`
+	Notes   string `json:"notes"`
 }
 
 type PaymentStatusRequest struct {
 	Status string `json:"status" binding:"required"`
 }
 
-// CreatePayment - Membuat pembayaran baru
+// CreatePayment - Membuat pembayaran baru untuk order milik customer.
+// Nominal selalu diambil dari tagihan order, bukan dari request.
 func CreatePayment(c *gin.Context) {
 	var req PaymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -38,40 +43,72 @@ func CreatePayment(c *gin.Context) {
 		return
 	}
 
-	// Check if payment already exists for this order
-	var existingPayment models.Payment
-	if err := db.Where("order_id = ?", req.OrderID).First(&existingPayment).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Payment already exists for this order"})
+	// Only the order's customer (or an admin) may start its payment
+	if !canPayOrder(c, order) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
 
 	// Validate payment method
-	if req.Method != string(models.PaymentMethodCash) && 
-	   req.Method != string(models.PaymentMethodTransfer) && 
-	   req.Method != string(models.PaymentMethodQR) {
+	method := models.PaymentMethod(req.Method)
+	if method != models.PaymentMethodCash && method != models.PaymentMethodTransfer && method != models.PaymentMethodQR {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment method"})
 		return
 	}
-
-	payment := models.Payment{
-		OrderID:    req.OrderID,
-		Amount:     req.Amount,
-		Method:     models.PaymentMethod(req.Method),
-		Status:     models.PaymentStatusPending,
-		Reference:  req.Notes, // Using Notes as Reference
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+	switch order.Status {
+	case models.OrderStatusCancelled, models.OrderStatusExpired, models.OrderStatusNoShow:
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is no longer payable"})
+		return
 	}
 
-	if err := db.Create(&payment).Error; err != nil {
+	payment, err := services.CreateOrderPayment(db, order, method, req.Notes)
+	if errors.Is(err, services.ErrPaymentExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment already exists for this order"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	resp := gin.H{
 		"message": "Payment created successfully",
 		"payment": payment,
-	})
+	}
+
+	startPaymentCollection(c, db, &payment, resp)
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// canPayOrder reports whether the caller is the order's customer or an admin
+func canPayOrder(c *gin.Context, order models.Order) bool {
+	actorID, role := orderActor(c)
+	if role == "admin" {
+		return true
+	}
+	return actorID != nil && order.CustomerID != nil && *order.CustomerID == *actorID
+}
+
+// startPaymentCollection bills a new non-cash payment and adds it (and any
+// charge error) to resp. Pembayaran qr mendapat QRIS dinamis jika merchant
+// QRIS dikonfigurasi; pembayaran non-tunai lainnya langsung ditagihkan ke
+// payment gateway.
+func startPaymentCollection(c *gin.Context, db *gorm.DB, payment *models.Payment, resp gin.H) {
+	if payment.Method == models.PaymentMethodQR && currentQRISConfig().Enabled() {
+		if err := services.IssueQRIS(db, payment, currentQRISConfig(), time.Now()); err != nil {
+			log.Printf("Failed to issue QRIS for payment %s: %v", payment.Reference, err)
+			resp["charge_error"] = "Failed to issue QRIS, retry with POST /qris"
+		}
+	} else if payment.Method != models.PaymentMethodCash {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), paymentGatewayTimeout)
+		defer cancel()
+		if _, err := services.StartGatewayCharge(ctx, db, paymentGateway(), payment); err != nil {
+			log.Printf("Failed to create gateway charge for payment %s: %v", payment.Reference, err)
+			resp["charge_error"] = "Failed to create charge at payment gateway, retry with /process"
+		}
+	}
+	resp["payment"] = payment
 }
 
 // GetPayments - Mendapatkan daftar pembayaran
@@ -148,7 +185,8 @@ func GetPayment(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"payment": payment})
 }
 
-// UpdatePaymentStatus - Update status pembayaran
+// UpdatePaymentStatus - Admin mencatat hasil pembayaran (paid/failed) secara manual,
+// lewat jalur yang sama dengan notifikasi gateway
 func UpdatePaymentStatus(c *gin.Context) {
 	var req PaymentStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -165,43 +203,24 @@ func UpdatePaymentStatus(c *gin.Context) {
 		return
 	}
 
-	// Validate status
-	validStatuses := []string{
-		string(models.PaymentStatusPending),
-		string(models.PaymentStatusPaid),
-		string(models.PaymentStatusFailed),
-		string(models.PaymentStatusRefunded),
-	}
-
-	isValidStatus := false
-	for _, status := range validStatuses {
-		if req.Status == status {
-			isValidStatus = true
-			break
-		}
+	// Refunds go through POST /admin/payments/:id/refunds so the refund is recorded
+	status := models.PaymentStatus(req.Status)
+	if status == models.PaymentStatusRefunded || status == models.PaymentStatusPartiallyRefunded {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use the refunds endpoint to refund a payment"})
+		return
 	}
-
-	if !isValidStatus {
+	if status != models.PaymentStatusPaid && status != models.PaymentStatusFailed {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment status"})
 		return
 	}
-
-	payment.Status = models.PaymentStatus(req.Status)
-	payment.UpdatedAt = time.Now()
-
-	if err := db.Save(&payment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
+	if payment.Status == models.PaymentStatusRefunded || payment.Status == models.PaymentStatusPartiallyRefunded {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment has been refunded"})
 		return
 	}
 
-	// If payment is paid, update order status
-	if req.Status == string(models.PaymentStatusPaid) {
-		var order models.Order
-		if err := db.First(&order, payment.OrderID).Error; err == nil {
-			order.PaymentStatus = "paid"
-			order.UpdatedAt = time.Now()
-			db.Save(&order)
-		}
+	if _, err := services.ApplyPaymentResult(db, &payment, services.PaymentResult{Status: status, At: time.Now()}); err != nil {
+		respondPaymentError(c, err, "Failed to update payment")
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -247,48 +266,54 @@ func GetPaymentStats(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"stats": stats})
 }
 
-// ProcessPayment - Memproses pembayaran (simulasi)
+// ProcessPayment - Mengecek status tagihan di payment gateway dan mencatat hasilnya
 func ProcessPayment(c *gin.Context) {
 	paymentID := c.Param("id")
 	db := database.GetDB()
 
 	var payment models.Payment
-	if err := db.First(&payment, paymentID).Error; err != nil {
+	if err := db.Preload("Order").First(&payment, paymentID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
 		return
 	}
 
-	// Simulasi proses pembayaran
-	if payment.Status == models.PaymentStatusPending {
-		// Simulasi delay processing
-		time.Sleep(2 * time.Second)
-
-		// 90% success rate untuk simulasi
-		success := time.Now().Unix()%10 < 9
-		if success {
-			payment.Status = models.PaymentStatusPaid
-		} else {
-			payment.Status = models.PaymentStatusFailed
-		}
+	// Only the order's customer (or an admin) may ask for its payment to be checked
+	if !canPayOrder(c, payment.Order) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
 
-		payment.UpdatedAt = time.Now()
-		db.Save(&payment)
-
-		// Update order status jika berhasil
-		if success {
-			var order models.Order
-			if err := db.First(&order, payment.OrderID).Error; err == nil {
-				order.PaymentStatus = "paid"
-				order.UpdatedAt = time.Now()
-				db.Save(&order)
-			}
-		}
+	if payment.Status != models.PaymentStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment is not in pending status"})
+		return
+	}
+	if payment.Method == models.PaymentMethodCash {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cash payments are not processed by the payment gateway"})
+		return
+	}
 
+	// QRIS tidak ditanyakan ke gateway: hasilnya datang lewat notifikasi settlement
+	if payment.Gateway == services.PaymentGatewayQRIS {
+		if _, err := services.ExpireQRISPayment(db, &payment, time.Now()); err != nil {
+			respondPaymentError(c, err, "Failed to process payment")
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Payment processed successfully",
 			"payment": payment,
 		})
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment is not in pending status"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), paymentGatewayTimeout)
+	defer cancel()
+	if _, err := services.SyncPaymentWithGateway(ctx, db, paymentGateway(), &payment); err != nil {
+		respondPaymentError(c, err, "Failed to process payment")
+		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment processed successfully",
+		"payment": payment,
+	})
 }
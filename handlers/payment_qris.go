@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/qrcode"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	qrisConfig     services.QRISConfig
+	qrisConfigOnce sync.Once
+)
+
+// currentQRISConfig returns the QRIS merchant configuration, read on first use
+func currentQRISConfig() services.QRISConfig {
+	qrisConfigOnce.Do(func() {
+		qrisConfig = services.LoadQRISConfig()
+	})
+	return qrisConfig
+}
+
+// respondQRISError maps QRIS errors to HTTP responses
+func respondQRISError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrQRISNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotQRISPayment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrQRISExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidWebhookPayload):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondPaymentError(c, err, fallback)
+	}
+}
+
+// IssuePaymentQRIS - Membuat atau memperbarui QRIS dinamis untuk pembayaran metode qr
+func IssuePaymentQRIS(c *gin.Context) {
+	db := database.GetDB()
+
+	var payment models.Payment
+	if err := db.Preload("Order").First(&payment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if !canPayOrder(c, payment.Order) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if err := services.IssueQRIS(db, &payment, currentQRISConfig(), time.Now()); err != nil {
+		respondQRISError(c, err, "Failed to issue QRIS")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "QRIS issued successfully",
+		"qr_payload": payment.QRPayload,
+		"expires_at": payment.ExpiresAt,
+		"payment":    payment,
+	})
+}
+
+// GetPaymentQRIS - QRIS pembayaran sebagai payload (json), gambar png atau svg
+func GetPaymentQRIS(c *gin.Context) {
+	db := database.GetDB()
+
+	var payment models.Payment
+	if err := db.Preload("Order").First(&payment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if !canPayOrder(c, payment.Order) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if payment.QRPayload == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QRIS has not been issued for this payment"})
+		return
+	}
+	if payment.Status != models.PaymentStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment is no longer pending", "status": payment.Status})
+		return
+	}
+	if services.QRISExpired(payment, time.Now()) {
+		c.JSON(http.StatusGone, gin.H{"error": services.ErrQRISExpired.Error(), "expires_at": payment.ExpiresAt})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{
+			"reference":  payment.Reference,
+			"amount":     payment.Amount,
+			"qr_payload": payment.QRPayload,
+			"expires_at": payment.ExpiresAt,
+		})
+		return
+	}
+
+	code, err := qrcode.Encode([]byte(payment.QRPayload), qrcode.LevelM)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode QR code"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	switch format {
+	case "png":
+		scale, _ := strconv.Atoi(c.DefaultQuery("scale", "8"))
+		if scale < 1 || scale > 20 {
+			scale = 8
+		}
+		image, err := code.PNG(scale)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+			return
+		}
+		c.Data(http.StatusOK, "image/png", image)
+	case "svg":
+		c.Data(http.StatusOK, "image/svg+xml", []byte(code.SVG()))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, png or svg"})
+	}
+}
+
+// QRISSettlementWebhook - Notifikasi settlement QRIS dari acquirer (ditandatangani HMAC)
+func QRISSettlementWebhook(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read notification"})
+		return
+	}
+
+	paymentGateway() // Memuat PAYMENT_WEBHOOK_SECRET
+	if err := services.VerifyWebhookSignature(gatewayConfig.WebhookSecret, body, c.GetHeader(services.WebhookSignatureHeader)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var settlement services.QRISSettlement
+	if err := json.Unmarshal(body, &settlement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidWebhookPayload.Error()})
+		return
+	}
+
+	payment, changed, err := services.MatchQRISSettlement(database.GetDB(), settlement)
+	if errors.Is(err, services.ErrPaymentStateConflict) {
+		c.JSON(http.StatusOK, gin.H{"message": "Notification ignored", "status": payment.Status})
+		return
+	}
+	if err != nil {
+		respondQRISError(c, err, "Failed to apply QRIS settlement")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Settlement processed",
+		"reference": payment.Reference,
+		"status":    payment.Status,
+		"paid_late": payment.PaidLate,
+		"changed":   changed,
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	gatewayConfig services.PaymentGatewayConfig
+	gateway       services.PaymentGateway
+	gatewayOnce   sync.Once
+)
+
+// paymentGatewayTimeout bounds gateway calls made inside a request
+const paymentGatewayTimeout = 15 * time.Second
+
+// paymentGateway returns the shared payment gateway, created on first use.
+// main refuses to start with an invalid configuration; should one slip
+// through, every charge is rejected rather than settled by a stand-in.
+func paymentGateway() services.PaymentGateway {
+	gatewayOnce.Do(func() {
+		gatewayConfig = services.LoadPaymentGatewayConfig()
+		var err error
+		if gateway, err = services.LoadPaymentGateway(gatewayConfig); err != nil {
+			log.Printf("Payment gateway disabled: %v", err)
+			gateway = services.UnconfiguredGateway{}
+		}
+	})
+	return gateway
+}
+
+// respondPaymentError maps payment errors to HTTP responses
+func respondPaymentError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentStateConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentAmountMismatch), errors.Is(err, services.ErrPaymentGatewayNotAllowed):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": fallback})
+	}
+}
+
+// PaymentWebhook - Notifikasi hasil pembayaran dari payment gateway (ditandatangani HMAC)
+func PaymentWebhook(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read notification"})
+		return
+	}
+
+	gw := paymentGateway()
+	payment, changed, err := services.HandlePaymentWebhook(database.GetDB(), gw, gatewayConfig.WebhookSecret, body, c.GetHeader(services.WebhookSignatureHeader))
+	switch {
+	case errors.Is(err, services.ErrInvalidWebhookSignature):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrInvalidWebhookPayload):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrPaymentStateConflict):
+		// Dijawab 200 agar gateway tidak mengirim ulang; status yang sudah final dipertahankan
+		log.Printf("Ignoring payment notification: %v", err)
+		c.JSON(http.StatusOK, gin.H{"message": "Notification ignored", "status": payment.Status})
+		return
+	case err != nil:
+		respondPaymentError(c, err, "Failed to apply payment notification")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification processed",
+		"status":  payment.Status,
+		"changed": changed,
+	})
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSettlementFileSize bounds uploaded settlement files
+const maxSettlementFileSize = 10 << 20
+
+// reconciliationGatewayTimeout bounds a gateway reconciliation, which queries every charge of the day
+const reconciliationGatewayTimeout = 2 * time.Minute
+
+type GatewayReconciliationRequest struct {
+	Date string `json:"date"` // YYYY-MM-DD (WIB), default kemarin
+}
+
+// respondReconciliationError maps reconciliation errors to HTTP responses
+func respondReconciliationError(c *gin.Context, run models.ReconciliationRun, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidStatement), errors.Is(err, services.ErrReconciliationNoDate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReconciliationExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Reconciliation failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Reconciliation failed",
+			"run":   run,
+		})
+	}
+}
+
+// reconciliationDate reads an optional YYYY-MM-DD settlement day
+func reconciliationDate(c *gin.Context, value string) (*time.Time, bool) {
+	if value == "" {
+		return nil, true
+	}
+	date, err := services.ParseReconciliationDate(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return nil, false
+	}
+	return &date, true
+}
+
+// UploadSettlementFile - Rekonsiliasi file settlement CSV dari PSP (admin)
+func UploadSettlementFile(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Settlement file is required"})
+		return
+	}
+	if header.Size > maxSettlementFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Settlement file is too large"})
+		return
+	}
+	date, ok := reconciliationDate(c, c.PostForm("date"))
+	if !ok {
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read settlement file"})
+		return
+	}
+	defer file.Close()
+
+	entries, err := services.ParseSettlementCSV(file)
+	if err != nil {
+		respondReconciliationError(c, models.ReconciliationRun{}, err)
+		return
+	}
+
+	actorID, _ := orderActor(c)
+	run, err := services.RunFileReconciliation(database.GetDB(), services.ReconciliationInput{
+		Provider:  c.PostForm("provider"),
+		Gateway:   c.PostForm("gateway"),
+		FileName:  header.Filename,
+		Date:      date,
+		CreatedBy: actorID,
+	}, entries)
+	if err != nil {
+		respondReconciliationError(c, run, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Settlement file reconciled",
+		"run":     run,
+	})
+}
+
+// RunGatewayReconciliation - Rekonsiliasi status tagihan di payment gateway untuk satu hari (admin)
+func RunGatewayReconciliation(c *gin.Context) {
+	var req GatewayReconciliationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	date, ok := reconciliationDate(c, req.Date)
+	if !ok {
+		return
+	}
+	if date == nil {
+		today, _ := services.ReconciliationDay(time.Now())
+		yesterday := today.AddDate(0, 0, -1)
+		date = &yesterday
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), reconciliationGatewayTimeout)
+	defer cancel()
+
+	actorID, _ := orderActor(c)
+	run, err := services.RunGatewayReconciliation(ctx, database.GetDB(), paymentGateway(), services.ReconciliationInput{
+		Date:      date,
+		CreatedBy: actorID,
+	})
+	if err != nil {
+		respondReconciliationError(c, run, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Gateway reconciled",
+		"run":     run,
+	})
+}
+
+// GetReconciliationRuns - Daftar reconciliation run (admin)
+func GetReconciliationRuns(c *gin.Context) {
+	db := database.GetDB()
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := db.Model(&models.ReconciliationRun{})
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var runs []models.ReconciliationRun
+	if err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliation runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs": runs,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetReconciliationRun - Detail reconciliation run beserta item dan ringkasan per hasil (admin)
+func GetReconciliationRun(c *gin.Context) {
+	db := database.GetDB()
+
+	var run models.ReconciliationRun
+	if err := db.First(&run, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reconciliation run not found"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	query := db.Model(&models.ReconciliationItem{}).Where("run_id = ?", run.ID)
+	if result := c.Query("result"); result != "" {
+		query = query.Where("result = ?", result)
+	} else if c.Query("exceptions") == "true" {
+		query = query.Where("result <> ?", models.ReconResultMatched)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var items []models.ReconciliationItem
+	if err := query.Order("id ASC").Offset((page - 1) * limit).Limit(limit).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliation items"})
+		return
+	}
+
+	var counts []struct {
+		Result models.ReconciliationResult
+		Count  int64
+	}
+	db.Model(&models.ReconciliationItem{}).Select("result, COUNT(*) AS count").
+		Where("run_id = ?", run.ID).Group("result").Scan(&counts)
+	summary := gin.H{}
+	for _, count := range counts {
+		summary[string(count.Result)] = count.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"run":     run,
+		"summary": summary,
+		"items":   items,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ExportReconciliationExceptions - Unduh item yang tidak cocok sebagai CSV (admin)
+func ExportReconciliationExceptions(c *gin.Context) {
+	db := database.GetDB()
+
+	var run models.ReconciliationRun
+	if err := db.First(&run, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reconciliation run not found"})
+		return
+	}
+
+	var items []models.ReconciliationItem
+	if err := db.Where("run_id = ? AND result <> ?", run.ID, models.ReconResultMatched).Order("id ASC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliation items"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="reconciliation-%d-exceptions.csv"`, run.ID))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"run_id", "line", "result", "reference", "provider_ref", "payment_id",
+		"statement_amount", "payment_amount", "statement_status", "payment_status", "transaction_at", "detail"})
+	for _, item := range items {
+		paymentID, transactionAt := "", ""
+		if item.PaymentID != nil {
+			paymentID = strconv.FormatUint(uint64(*item.PaymentID), 10)
+		}
+		if item.TransactionAt != nil {
+			transactionAt = item.TransactionAt.Format(time.RFC3339)
+		}
+		writer.Write([]string{
+			strconv.FormatUint(uint64(run.ID), 10),
+			strconv.Itoa(item.Line),
+			string(item.Result),
+			item.Reference,
+			item.ProviderRef,
+			paymentID,
+			strconv.FormatFloat(item.StatementAmount, 'f', 2, 64),
+			strconv.FormatFloat(item.PaymentAmount, 'f', 2, 64),
+			item.StatementStatus,
+			item.PaymentStatus,
+			transactionAt,
+			item.Detail,
+		})
+	}
+	writer.Flush()
+}
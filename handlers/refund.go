@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefundRequest struct {
+	Amount float64 `json:"amount"` // Kosong = seluruh sisa pembayaran
+	Reason string  `json:"reason" binding:"required"`
+	Note   string  `json:"note"`
+	Kind   string  `json:"kind"` // refund (default) atau chargeback
+}
+
+// respondRefundError maps refund errors to HTTP responses
+func respondRefundError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRefund):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRefundNotAllowed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRefundExceedsRefundable), errors.Is(err, services.ErrInvalidRefundShare),
+		errors.Is(err, services.ErrRefundGatewayMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		respondPaymentError(c, err, fallback)
+	}
+}
+
+// CreatePaymentRefund - Admin mengembalikan sebagian atau seluruh pembayaran
+func CreatePaymentRefund(c *gin.Context) {
+	var req RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var payment models.Payment
+	if err := db.First(&payment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	actorID, _ := orderActor(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), paymentGatewayTimeout)
+	defer cancel()
+
+	refund, err := services.RefundPayment(ctx, db, paymentGateway(), &payment, services.RefundInput{
+		Amount:      req.Amount,
+		Reason:      models.RefundReason(req.Reason),
+		Note:        req.Note,
+		Kind:        models.RefundKind(req.Kind),
+		RequestedBy: actorID,
+	}, time.Now())
+	if errors.Is(err, services.ErrRefundGatewayFailed) {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":  err.Error(),
+			"refund": refund,
+		})
+		return
+	}
+	if err != nil {
+		respondRefundError(c, err, "Failed to refund payment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Payment refunded successfully",
+		"refund":  refund,
+		"payment": payment,
+	})
+}
+
+// RetryRefund - Admin menyelesaikan ulang refund yang tertahan pending
+func RetryRefund(c *gin.Context) {
+	db := database.GetDB()
+
+	var refund models.Refund
+	if err := db.First(&refund, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Refund not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), paymentGatewayTimeout)
+	defer cancel()
+
+	if err := services.RetryRefund(ctx, db, paymentGateway(), &refund, time.Now()); err != nil {
+		if errors.Is(err, services.ErrRefundGatewayFailed) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "refund": refund})
+			return
+		}
+		respondRefundError(c, err, "Failed to retry refund")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Refund completed successfully",
+		"refund":  refund,
+	})
+}
+
+// GetPaymentRefunds - Riwayat refund satu pembayaran (admin)
+func GetPaymentRefunds(c *gin.Context) {
+	db := database.GetDB()
+
+	var payment models.Payment
+	if err := db.First(&payment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	var refunds []models.Refund
+	if err := db.Where("payment_id = ?", payment.ID).Order("id DESC").Find(&refunds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payment":    payment,
+		"refunds":    refunds,
+		"refundable": payment.Refundable(),
+	})
+}
+
+// GetRefunds - Daftar semua refund dan chargeback (admin)
+func GetRefunds(c *gin.Context) {
+	db := database.GetDB()
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := db.Model(&models.Refund{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	if orderID := c.Query("order_id"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var refunds []models.Refund
+	if err := query.Preload("Payment").Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&refunds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"refunds": refunds,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateReviewRequest struct {
+	Rating  int      `json:"rating" binding:"required,min=1,max=5"`
+	Tags    []string `json:"tags"`
+	Comment string   `json:"comment" binding:"max=1000"`
+}
+
+type CreateReviewPublicRequest struct {
+	CreateReviewRequest
+	OrderToken string `json:"order_token"` // Dari respons POST /api/orders/public, atau header X-Order-Token
+}
+
+type ModerateReviewRequest struct {
+	Status string `json:"status" binding:"required,oneof=published hidden"`
+	Note   string `json:"note"`
+}
+
+// respondReviewError maps review errors to HTTP responses
+func respondReviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRating), errors.Is(err, services.ErrInvalidReviewTag):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReviewWindowClosed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Review window for this order has closed"})
+	case errors.Is(err, services.ErrOrderNotReviewable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Only completed orders can be reviewed"})
+	case errors.Is(err, services.ErrAlreadyReviewed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order has already been reviewed"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+	}
+}
+
+// CreateOrderReview - Customer memberi rating untuk order yang sudah selesai
+func CreateOrderReview(c *gin.Context) {
+	var req CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	userID, _ := c.Get("user_id")
+	customerID, _ := userID.(uint)
+
+	var order models.Order
+	if err := db.First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if order.CustomerID == nil || *order.CustomerID != customerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	review, err := services.SubmitReview(db, order, services.ReviewInput{
+		Rating:     req.Rating,
+		Tags:       req.Tags,
+		Comment:    req.Comment,
+		CustomerID: &customerID,
+	}, services.LoadReviewConfig())
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Review submitted successfully",
+		"review":  review,
+	})
+}
+
+// CreateOrderReviewPublic - Customer tanpa akun memberi rating dengan order_token dari respons pembuatan order
+func CreateOrderReviewPublic(c *gin.Context) {
+	var req CreateReviewPublicRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	order, ok := loadPublicOrder(c, req.OrderToken)
+	if !ok {
+		return
+	}
+
+	review, err := services.SubmitReview(db, *order, services.ReviewInput{
+		Rating:     req.Rating,
+		Tags:       req.Tags,
+		Comment:    req.Comment,
+		CustomerID: order.CustomerID,
+	}, services.LoadReviewConfig())
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Review submitted successfully",
+		"review":  review,
+	})
+}
+
+// GetReviewTags - Daftar tag yang bisa dipilih customer
+func GetReviewTags(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"tags": services.ReviewTags})
+}
+
+// paginationQuery reads page/limit query parameters with the usual defaults
+func paginationQuery(c *gin.Context) (page, limit, offset int) {
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	return page, limit, (page - 1) * limit
+}
+
+// GetMyReviews - Driver melihat review yang dipublikasikan untuk dirinya
+func GetMyReviews(c *gin.Context) {
+	db := database.GetDB()
+	userID, _ := c.Get("user_id")
+
+	var driver models.Driver
+	if err := db.Where("user_id = ?", userID).First(&driver).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+		return
+	}
+
+	page, limit, offset := paginationQuery(c)
+	query := db.Model(&models.OrderReview{}).Where("driver_id = ? AND status = ?", driver.ID, models.ReviewStatusPublished)
+
+	var total int64
+	query.Count(&total)
+
+	var reviews []models.OrderReview
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	var rows []struct {
+		Rating int
+		Count  int64
+	}
+	db.Model(&models.OrderReview{}).
+		Select("rating, COUNT(*) AS count").
+		Where("driver_id = ? AND status = ?", driver.ID, models.ReviewStatusPublished).
+		Group("rating").
+		Scan(&rows)
+	distribution := gin.H{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}
+	for _, row := range rows {
+		distribution[strconv.Itoa(row.Rating)] = row.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews": reviews,
+		"summary": gin.H{
+			"rating":       driver.Rating,
+			"rating_count": driver.RatingCount,
+			"distribution": distribution,
+		},
+		"pagination": gin.H{"page": page, "limit": limit, "total": total},
+	})
+}
+
+// GetReviews - Admin melihat semua review untuk moderasi
+func GetReviews(c *gin.Context) {
+	db := database.GetDB()
+	page, limit, offset := paginationQuery(c)
+
+	query := db.Model(&models.OrderReview{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if driverID := c.Query("driver_id"); driverID != "" {
+		query = query.Where("driver_id = ?", driverID)
+	}
+	if rating := c.Query("rating"); rating != "" {
+		query = query.Where("rating = ?", rating)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var reviews []models.OrderReview
+	if err := query.Preload("Order").Preload("Driver").Order("created_at DESC").Offset(offset).Limit(limit).Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews":    reviews,
+		"pagination": gin.H{"page": page, "limit": limit, "total": total},
+	})
+}
+
+// ModerateReview - Admin menyembunyikan atau mempublikasikan ulang review
+func ModerateReview(c *gin.Context) {
+	var req ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var review models.OrderReview
+	if err := db.First(&review, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+
+	moderatorID, _ := orderActor(c)
+	if err := services.ModerateReview(db, &review, models.ReviewStatus(req.Status), moderatorID, req.Note, services.LoadReviewConfig()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate review"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Review moderated successfully",
+		"review":  review,
+	})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+)
+
+var (
+	router     services.RoutingProvider
+	routerOnce sync.Once
+)
+
+// routingProvider returns the shared routing provider, created on first use
+func routingProvider() services.RoutingProvider {
+	routerOnce.Do(func() {
+		router = services.LoadRoutingProvider()
+	})
+	return router
+}
+
+type Route struct {
+	Waypoints     []map[string]float64 `json:"waypoints"`
+	Polyline      string               `json:"polyline"`
+	Distance      float64              `json:"distance"`
+	EstimatedTime int                  `json:"estimated_time"`
+	Provider      string               `json:"provider"`
+}
+
+// remainingWaypoints lists the order's points still ahead of the driver:
+// pickup until the customer is picked up, stops not yet departed, then the drop point
+func remainingWaypoints(order models.Order) []geo.Point {
+	var points []geo.Point
+	if order.PickedUpAt == nil && order.Status != models.OrderStatusInProgress {
+		if pickup, ok := order.PickupPoint(); ok {
+			points = append(points, pickup)
+		}
+	}
+	for i := range order.Stops {
+		if order.Stops[i].DepartedAt != nil {
+			continue
+		}
+		if point, ok := order.Stops[i].Point(); ok {
+			points = append(points, point)
+		}
+	}
+	if drop, ok := order.DropPoint(); ok {
+		points = append(points, drop)
+	}
+	return points
+}
+
+// calculateRoute routes the driver from start through the order's remaining points.
+// Without coordinates it falls back to the stored trip distance.
+func calculateRoute(ctx context.Context, start geo.Point, order models.Order, vehicle models.VehicleType) (Route, error) {
+	points := append([]geo.Point{start}, remainingWaypoints(order)...)
+
+	waypoints := make([]map[string]float64, 0, len(points))
+	for _, point := range points {
+		waypoints = append(waypoints, map[string]float64{"lat": point.Lat, "lng": point.Lng})
+	}
+
+	if len(points) == 1 {
+		return Route{
+			Waypoints:     waypoints,
+			Polyline:      geo.EncodePolyline(points),
+			Distance:      order.Distance,
+			EstimatedTime: services.DurationMinutes(services.DefaultSpeedProfiles.TravelTime(order.Distance, vehicle)),
+			Provider:      "order_distance",
+		}, nil
+	}
+
+	result, err := routingProvider().Route(ctx, points, vehicle)
+	if err != nil {
+		return Route{}, err
+	}
+	return Route{
+		Waypoints:     waypoints,
+		Polyline:      geo.EncodePolyline(result.Points),
+		Distance:      result.DistanceKm,
+		EstimatedTime: result.Minutes(),
+		Provider:      result.Provider,
+	}, nil
+}
+
+// routedTripDistance returns the road distance for the vehicle from pickup
+// through any stops to drop when pickup and drop are known
+func routedTripDistance(ctx context.Context, order models.Order, vehicle models.VehicleType) (float64, bool) {
+	if _, ok := order.PickupPoint(); !ok {
+		return 0, false
+	}
+	if _, ok := order.DropPoint(); !ok {
+		return 0, false
+	}
+	result, err := routingProvider().Route(ctx, services.RoutePoints(order), vehicle)
+	if err != nil {
+		return services.TripDistanceKm(order)
+	}
+	return result.DistanceKm, true
+}
+
+// setOrderETA fills order.ETA with the minutes until the driver reaches the next
+// point of an active trip, based on the driver's last known location
+func setOrderETA(ctx context.Context, order *models.Order) {
+	if order.DriverID == nil || !isActiveTripStatus(order.Status) {
+		return
+	}
+	next := remainingWaypoints(*order)
+	if len(next) == 0 {
+		return
+	}
+
+	var location models.DriverLocation
+	if err := database.GetDB().Where("driver_id = ?", *order.DriverID).First(&location).Error; err != nil {
+		return
+	}
+
+	eta, err := routingProvider().ETA(ctx, geo.Point{Lat: location.Latitude, Lng: location.Longitude}, next[0], order.Driver.VehicleType)
+	if err != nil {
+		return
+	}
+	order.ETA = services.DurationMinutes(eta)
+}
+
+func isActiveTripStatus(status models.OrderStatus) bool {
+	for _, active := range services.ActiveTripStatuses {
+		if status == active {
+			return true
+		}
+	}
+	return false
+}
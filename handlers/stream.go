@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamRetryMillis       = 3000
+)
+
+// StreamOrderEvents - Stream real-time update order (lokasi driver, status, notifikasi) via SSE
+func StreamOrderEvents(c *gin.Context) {
+	orderID := c.Param("id")
+	db := database.GetDB()
+
+	var order models.Order
+	if err := db.Preload("Driver").First(&order, orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	if !canAccessOrder(c, order) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	serveEventStream(c, services.OrderTopic(order.ID))
+}
+
+// canAccessOrder reports whether the authenticated user is the order's customer,
+// its driver or an admin. The order must be loaded with its Driver.
+func canAccessOrder(c *gin.Context, order models.Order) bool {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+	id, _ := userID.(uint)
+
+	switch role {
+	case "admin":
+		return true
+	case "customer":
+		return order.CustomerID != nil && *order.CustomerID == id
+	case "driver":
+		return order.Driver.UserID != nil && *order.Driver.UserID == id
+	}
+	return false
+}
+
+// StreamAdminEvents - Stream seluruh update kota (lokasi driver, order) untuk dashboard admin
+func StreamAdminEvents(c *gin.Context) {
+	serveEventStream(c, services.TopicAdmin)
+}
+
+// StreamNotifications - Stream notifikasi untuk user yang sedang login
+func StreamNotifications(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	serveEventStream(c, services.UserTopic(userID.(uint)))
+}
+
+// StreamDriverEvents - Stream tawaran order, pembatalan, pengingat booking dan
+// order kedaluwarsa untuk driver yang sedang login
+func StreamDriverEvents(c *gin.Context) {
+	driver, ok := currentDriver(c, database.GetDB())
+	if !ok {
+		return
+	}
+	serveEventStream(c, services.DriverTopic(driver.ID))
+}
+
+// serveEventStream subscribes to topics and writes events as Server-Sent Events
+// until the client disconnects. Events missed since Last-Event-ID are replayed
+// first, and a heartbeat keeps proxies from closing idle connections.
+func serveEventStream(c *gin.Context, topics ...string) {
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	lastSent, _ := strconv.ParseUint(lastEventID, 10, 64)
+
+	sub := services.Hub.Subscribe(topics...)
+	defer services.Hub.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetryMillis)
+	if lastSent > 0 {
+		for _, event := range services.Hub.Replay(topics, lastSent) {
+			writeStreamEvent(c.Writer, event)
+			lastSent = event.ID
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Evicted for falling behind; the client reconnects and replays
+				return false
+			}
+			if event.ID <= lastSent {
+				return true
+			}
+			writeStreamEvent(w, event)
+			lastSent = event.ID
+			return true
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": heartbeat %d\n\n", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func writeStreamEvent(w io.Writer, event services.StreamEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -14,6 +14,9 @@ type CreateTariffRequest struct {
 	MinDistance  float64 `json:"min_distance" binding:"required"`
 	MaxDistance  float64 `json:"max_distance" binding:"required"`
 	Price        float64 `json:"price" binding:"required"`
+	PerKm        float64 `json:"per_km"`
+	PerMinute    float64 `json:"per_minute"`
+	MinimumFare  float64 `json:"minimum_fare"`
 	Destinations string  `json:"destinations"`
 	IsGojek      bool    `json:"is_gojek"`
 	IsSubsidi    bool    `json:"is_subsidi"`
@@ -21,15 +24,18 @@ type CreateTariffRequest struct {
 }
 
 type UpdateTariffRequest struct {
-	Name         string  `json:"name"`
-	MinDistance  float64 `json:"min_distance"`
-	MaxDistance  float64 `json:"max_distance"`
-	Price        float64 `json:"price"`
-	Destinations string  `json:"destinations"`
-	IsActive     *bool   `json:"is_active"`
-	IsGojek      *bool   `json:"is_gojek"`
-	IsSubsidi    *bool   `json:"is_subsidi"`
-	IsNonTunai   *bool   `json:"is_non_tunai"`
+	Name         string   `json:"name"`
+	MinDistance  float64  `json:"min_distance"`
+	MaxDistance  float64  `json:"max_distance"`
+	Price        float64  `json:"price"`
+	PerKm        *float64 `json:"per_km"`
+	PerMinute    *float64 `json:"per_minute"`
+	MinimumFare  *float64 `json:"minimum_fare"`
+	Destinations string   `json:"destinations"`
+	IsActive     *bool    `json:"is_active"`
+	IsGojek      *bool    `json:"is_gojek"`
+	IsSubsidi    *bool    `json:"is_subsidi"`
+	IsNonTunai   *bool    `json:"is_non_tunai"`
 }
 
 type ToggleTariffActiveRequest struct {
@@ -50,6 +56,9 @@ func CreateTariff(c *gin.Context) {
 		MinDistance:  req.MinDistance,
 		MaxDistance:  req.MaxDistance,
 		Price:        req.Price,
+		PerKm:        req.PerKm,
+		PerMinute:    req.PerMinute,
+		MinimumFare:  req.MinimumFare,
 		Destinations: req.Destinations,
 		IsActive:     true,
 		IsGojek:      req.IsGojek,
@@ -130,6 +139,15 @@ func UpdateTariff(c *gin.Context) {
 	if req.Price > 0 {
 		tariff.Price = req.Price
 	}
+	if req.PerKm != nil {
+		tariff.PerKm = *req.PerKm
+	}
+	if req.PerMinute != nil {
+		tariff.PerMinute = *req.PerMinute
+	}
+	if req.MinimumFare != nil {
+		tariff.MinimumFare = *req.MinimumFare
+	}
 	if req.Destinations != "" {
 		tariff.Destinations = req.Destinations
 	}
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TourPackageRequest struct {
+	TariffID             uint                 `json:"tariff_id" binding:"required"`
+	Code                 string               `json:"code" binding:"required,max=40"`
+	Name                 string               `json:"name" binding:"required"`
+	Description          string               `json:"description"`
+	Type                 models.PackageType   `json:"type" binding:"required"`
+	Itinerary            []models.PackageStop `json:"itinerary"`
+	DurationMinutes      int                  `json:"duration_minutes" binding:"required"`
+	MaxDurationMinutes   int                  `json:"max_duration_minutes"`
+	VehicleTypes         []models.VehicleType `json:"vehicle_types" binding:"required"`
+	Price                float64              `json:"price" binding:"required"`
+	OvertimePerHour      float64              `json:"overtime_per_hour"`
+	OvertimeBlockMinutes *int                 `json:"overtime_block_minutes"`
+	OvertimeGraceMinutes *int                 `json:"overtime_grace_minutes"`
+	Capacity             int                  `json:"capacity"`
+	MaxConcurrent        int                  `json:"max_concurrent"`
+	AvailableFrom        string               `json:"available_from"`
+	AvailableUntil       string               `json:"available_until"`
+	IsActive             *bool                `json:"is_active"`
+}
+
+// OrderPackageInput books a tour package or hourly rental with an order
+type OrderPackageInput struct {
+	PackageID  uint `json:"package_id"`
+	Passengers int  `json:"passengers"` // Default 1
+	Hours      int  `json:"hours"`      // Wajib untuk sewa per jam
+}
+
+type CreateDriverPackageOrderRequest struct {
+	OrderPackageInput
+	Pickup        *services.OrderPlace `json:"pickup"`
+	CustomerPhone string               `json:"customer_phone" binding:"required"`
+	CustomerName  string               `json:"customer_name"`
+	Notes         string               `json:"notes"`
+}
+
+// applyTourPackageRequest copies an admin request onto a package and validates it
+func applyTourPackageRequest(pkg *models.TourPackage, req TourPackageRequest) error {
+	pkg.TariffID = req.TariffID
+	pkg.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	pkg.Name = req.Name
+	pkg.Description = req.Description
+	pkg.Type = req.Type
+	pkg.Itinerary = req.Itinerary
+	pkg.DurationMinutes = req.DurationMinutes
+	pkg.MaxDurationMinutes = req.MaxDurationMinutes
+	pkg.VehicleTypes = req.VehicleTypes
+	pkg.Price = req.Price
+	pkg.OvertimePerHour = req.OvertimePerHour
+	if req.OvertimeBlockMinutes != nil {
+		pkg.OvertimeBlockMinutes = *req.OvertimeBlockMinutes
+	}
+	if req.OvertimeGraceMinutes != nil {
+		pkg.OvertimeGraceMinutes = *req.OvertimeGraceMinutes
+	}
+	if req.Capacity > 0 {
+		pkg.Capacity = req.Capacity
+	}
+	pkg.MaxConcurrent = req.MaxConcurrent
+	if req.AvailableFrom != "" {
+		pkg.AvailableFrom = req.AvailableFrom
+	}
+	if req.AvailableUntil != "" {
+		pkg.AvailableUntil = req.AvailableUntil
+	}
+	if req.IsActive != nil {
+		pkg.IsActive = *req.IsActive
+	}
+	return services.ValidateTourPackage(*pkg)
+}
+
+// GetTourPackages - Katalog paket tour dan sewa per jam yang aktif
+func GetTourPackages(c *gin.Context) {
+	db := database.GetDB()
+
+	query := db.Where("is_active = ?", true)
+	if packageType := c.Query("type"); packageType != "" {
+		query = query.Where("type = ?", packageType)
+	}
+	if tariffID := c.Query("tariff_id"); tariffID != "" {
+		query = query.Where("tariff_id = ?", tariffID)
+	}
+
+	var packages []models.TourPackage
+	if err := query.Order("price ASC").Find(&packages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch packages"})
+		return
+	}
+
+	// Vehicle types are stored as JSON, so filter them here
+	if vehicle := models.VehicleType(c.Query("vehicle_type")); vehicle != "" {
+		filtered := packages[:0]
+		for _, pkg := range packages {
+			if pkg.SupportsVehicle(vehicle) {
+				filtered = append(filtered, pkg)
+			}
+		}
+		packages = filtered
+	}
+
+	c.JSON(http.StatusOK, gin.H{"packages": packages})
+}
+
+// GetTourPackage - Detail paket aktif
+func GetTourPackage(c *gin.Context) {
+	var pkg models.TourPackage
+	if err := database.GetDB().Where("is_active = ?", true).First(&pkg, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"package": pkg})
+}
+
+// GetTourPackageAvailability - Cek ketersediaan dan harga paket untuk waktu mulai tertentu
+func GetTourPackageAvailability(c *gin.Context) {
+	db := database.GetDB()
+
+	var pkg models.TourPackage
+	if err := db.Preload("Tariff").Where("is_active = ?", true).First(&pkg, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+		return
+	}
+
+	start := time.Now()
+	if value := c.Query("start"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start, use RFC 3339"})
+			return
+		}
+		start = parsed
+	}
+	hours, _ := strconv.Atoi(c.Query("hours"))
+	passengers, _ := strconv.Atoi(c.DefaultQuery("passengers", "1"))
+	vehicle := models.VehicleType(c.Query("vehicle_type"))
+	if vehicle == "" && len(pkg.VehicleTypes) > 0 {
+		vehicle = pkg.VehicleTypes[0]
+	}
+
+	booking := services.PackageBooking{Start: start, Hours: hours, Passengers: passengers, Vehicle: vehicle}
+	minutes := services.PackageMinutes(pkg, hours)
+	resp := gin.H{
+		"package_id":     pkg.ID,
+		"start":          start,
+		"booked_minutes": minutes,
+	}
+
+	if err := services.ValidatePackageBooking(pkg, booking); err != nil {
+		resp["available"] = false
+		resp["reason"] = err.Error()
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	left, err := services.PackageSlotsLeft(db, pkg, start, minutes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check availability"})
+		return
+	}
+
+	var tariff models.Tariff
+	if pkg.Tariff != nil {
+		tariff = *pkg.Tariff
+	}
+	resp["available"] = left != 0
+	if left >= 0 {
+		resp["slots_left"] = left
+	}
+	resp["fare_breakdown"] = services.PackageFare(pkg, tariff, minutes, vehicle)
+	c.JSON(http.StatusOK, resp)
+}
+
+// applyOrderPackage books the package on the order and writes the error response
+// when the package is unknown, the booking is invalid or the package is full
+func applyOrderPackage(c *gin.Context, db *gorm.DB, order *models.Order, input OrderPackageInput, vehicle models.VehicleType) bool {
+	var pkg models.TourPackage
+	if err := db.Preload("Tariff").First(&pkg, input.PackageID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+		return false
+	}
+	if pkg.Tariff == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tariff not found"})
+		return false
+	}
+	if vehicle == "" && len(pkg.VehicleTypes) > 0 {
+		vehicle = pkg.VehicleTypes[0]
+	}
+	if input.Passengers == 0 {
+		input.Passengers = 1
+	}
+
+	start := time.Now()
+	if order.ScheduledAt != nil {
+		start = *order.ScheduledAt
+	}
+	booking := services.PackageBooking{Start: start, Hours: input.Hours, Passengers: input.Passengers, Vehicle: vehicle}
+	if err := services.ValidatePackageBooking(pkg, booking); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	left, err := services.PackageSlotsLeft(db, pkg, start, services.PackageMinutes(pkg, input.Hours))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check availability"})
+		return false
+	}
+	if left == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": services.ErrPackageUnavailable.Error()})
+		return false
+	}
+
+	if err := services.ApplyPackage(order, pkg, *pkg.Tariff, booking); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if distance, ok := routedTripDistance(c.Request.Context(), *order, vehicle); ok {
+		order.Distance = distance
+	}
+	return true
+}
+
+// createPackageOrder inserts a package order, writing a 409 when the package
+// filled up since its availability was checked
+func createPackageOrder(c *gin.Context, db *gorm.DB, order *models.Order) bool {
+	err := services.CreatePackageOrder(db, order)
+	if errors.Is(err, services.ErrPackageUnavailable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+		return false
+	}
+	return true
+}
+
+// CreateDriverPackageOrder - Driver menjual paket ke customer di tempat; order langsung diterima driver
+func CreateDriverPackageOrder(c *gin.Context) {
+	var req CreateDriverPackageOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	driver, ok := currentDriver(c, db)
+	if !ok {
+		return
+	}
+	if driver.Status != models.DriverStatusActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Driver is not available"})
+		return
+	}
+
+	if req.CustomerName == "" {
+		req.CustomerName = "Customer"
+	}
+	order := models.Order{
+		BecakCode:     driver.DriverCode,
+		Status:        models.OrderStatusPending,
+		PaymentStatus: "pending",
+		CustomerPhone: strings.TrimSpace(req.CustomerPhone),
+		CustomerName:  req.CustomerName,
+		Notes:         req.Notes,
+	}
+
+	// Without an explicit pickup the trip starts where the driver is
+	if req.Pickup == nil {
+		var location models.DriverLocation
+		if err := db.Where("driver_id = ?", driver.ID).First(&location).Error; err == nil {
+			req.Pickup = &services.OrderPlace{Lat: &location.Latitude, Lng: &location.Longitude}
+		}
+	}
+	if req.Pickup != nil {
+		if err := services.SetOrderPickup(&order, *req.Pickup); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pickup coordinates"})
+			return
+		}
+	}
+
+	req.PackageID = parseUintParam(c.Param("id"))
+	if !applyOrderPackage(c, db, &order, req.OrderPackageInput, driver.VehicleType) {
+		return
+	}
+
+	if !createPackageOrder(c, db, &order) {
+		return
+	}
+
+	actorID, _ := orderActor(c)
+	if err := services.RecordOrderCreated(db, &order, actorID, services.ActorRoleDriver); err != nil {
+		log.Printf("Failed to record creation of order %d: %v", order.ID, err)
+	}
+	services.Hub.Publish(services.TopicAdmin, services.EventOrderCreated, order)
+
+	if err := services.AcceptOrderForDriver(db, &order, &driver, actorID); err != nil {
+		respondOrderTransitionError(c, err, "Failed to accept order")
+		return
+	}
+	services.PublishOrderStatus(&order)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Package order created successfully",
+		"order":   order,
+	})
+}
+
+// GetTourPackagesAdmin - Semua paket termasuk yang tidak aktif (admin)
+func GetTourPackagesAdmin(c *gin.Context) {
+	var packages []models.TourPackage
+	if err := database.GetDB().Preload("Tariff").Order("id ASC").Find(&packages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch packages"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"packages": packages})
+}
+
+// CreateTourPackage - Tambah paket tour atau sewa per jam (admin)
+func CreateTourPackage(c *gin.Context) {
+	var req TourPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pkg := models.TourPackage{
+		OvertimeBlockMinutes: 15,
+		OvertimeGraceMinutes: 10,
+		Capacity:             2,
+		AvailableFrom:        "08:00",
+		AvailableUntil:       "21:00",
+		IsActive:             true,
+	}
+	saveTourPackage(c, &pkg, req, http.StatusCreated, "Package created successfully")
+}
+
+// UpdateTourPackage - Ubah paket (admin); body sama dengan pembuatan paket
+func UpdateTourPackage(c *gin.Context) {
+	var req TourPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var pkg models.TourPackage
+	if err := database.GetDB().First(&pkg, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+		return
+	}
+	saveTourPackage(c, &pkg, req, http.StatusOK, "Package updated successfully")
+}
+
+// saveTourPackage validates and stores an admin package request
+func saveTourPackage(c *gin.Context, pkg *models.TourPackage, req TourPackageRequest, status int, message string) {
+	db := database.GetDB()
+
+	if err := applyTourPackageRequest(pkg, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.First(&models.Tariff{}, pkg.TariffID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tariff not found"})
+		return
+	}
+
+	if err := db.Save(pkg).Error; err != nil {
+		if services.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Package code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save package"})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"message": message,
+		"package": pkg,
+	})
+}
+
+// DeleteTourPackage - Hapus paket (admin); order lama tetap menyimpan package_id
+func DeleteTourPackage(c *gin.Context) {
+	if err := database.GetDB().Delete(&models.TourPackage{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete package"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Package deleted successfully"})
+}
+
+// parseUintParam returns 0 for a missing or malformed id
+func parseUintParam(value string) uint {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"greenbecak-backend/database"
 	"greenbecak-backend/models"
+	"greenbecak-backend/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -49,14 +51,15 @@ func CreateWithdrawal(c *gin.Context) {
 	}
 
 	// Check if driver has sufficient balance
-	// Calculate available balance: total_earnings - approved withdrawals
-	var approvedWithdrawals float64
-	db.Model(&models.Withdrawal{}).Where("driver_id = ? AND status = ?", driver.ID, "approved").Select("SUM(amount)").Scan(&approvedWithdrawals)
-
-	availableBalance := driver.TotalEarnings - approvedWithdrawals
+	// Available balance comes from the ledger, less withdrawals still pending
+	wallet, err := services.GetDriverWallet(db, driver.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balance"})
+		return
+	}
 
-	if availableBalance < req.Amount {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance", "available_balance": availableBalance, "requested_amount": req.Amount})
+	if wallet.Available < req.Amount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance", "available_balance": wallet.Available, "requested_amount": req.Amount})
 		return
 	}
 
@@ -70,7 +73,7 @@ func CreateWithdrawal(c *gin.Context) {
 		Notes:         req.Notes,
 	}
 
-	if err := db.Create(&withdrawal).Error; err != nil {
+	if err := services.CreateWithReferenceNumber(db, services.ReferencePrefixWithdrawal, &withdrawal, func(number string) { withdrawal.Reference = number }); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create withdrawal request"})
 		return
 	}
@@ -105,7 +108,13 @@ func GetDriverWithdrawals(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"withdrawals": withdrawals})
+	wallet, err := services.GetDriverWallet(db, driver.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"withdrawals": withdrawals, "wallet": wallet})
 }
 
 func GetWithdrawals(c *gin.Context) {
@@ -128,16 +137,24 @@ func GetWithdrawals(c *gin.Context) {
 		return
 	}
 
-			// Calculate available balance for each withdrawal
-		for i := range withdrawals {
-			var approvedWithdrawals float64
-			db.Model(&models.Withdrawal{}).Where("driver_id = ? AND status = ?", withdrawals[i].DriverID, "approved").Select("SUM(amount)").Scan(&approvedWithdrawals)
-
-			// Add calculated fields to response
-			withdrawals[i].Driver.AvailableBalance = withdrawals[i].Driver.TotalEarnings - approvedWithdrawals
-			withdrawals[i].Driver.CompletedWithdrawals = approvedWithdrawals
+	// Calculate available balance for each driver from the ledger
+	wallets := make(map[uint]services.DriverWallet)
+	for i := range withdrawals {
+		wallet, ok := wallets[withdrawals[i].DriverID]
+		if !ok {
+			var err error
+			if wallet, err = services.GetDriverWallet(db, withdrawals[i].DriverID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balance"})
+				return
+			}
+			wallets[withdrawals[i].DriverID] = wallet
 		}
 
+		// Add calculated fields to response
+		withdrawals[i].Driver.AvailableBalance = wallet.Available
+		withdrawals[i].Driver.CompletedWithdrawals = wallet.Withdrawn
+	}
+
 	c.JSON(http.StatusOK, gin.H{"withdrawals": withdrawals})
 }
 
@@ -170,57 +187,27 @@ func UpdateWithdrawal(c *gin.Context) {
 		return
 	}
 
-	// Update status
-	withdrawal.Status = models.WithdrawalStatus(req.Status)
-	now := time.Now()
-
-	switch req.Status {
-	case "approved":
-		withdrawal.ApprovedAt = &now
-		if req.ApprovedBy != "" {
-			withdrawal.ApprovedBy = &req.ApprovedBy
-		}
-
-				// Update driver's total earnings when withdrawal is approved
-		var driver models.Driver
-		if err := db.First(&driver, withdrawal.DriverID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find driver"})
-			return
-		}
-
-		// Check if driver has sufficient balance
-		if driver.TotalEarnings < withdrawal.Amount {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance", "available_balance": driver.TotalEarnings, "requested_amount": withdrawal.Amount})
-			return
-		}
-
-		// Reduce total earnings by withdrawal amount
-		driver.TotalEarnings -= withdrawal.Amount
-		if driver.TotalEarnings < 0 {
-			driver.TotalEarnings = 0
-		}
-
-		if err := db.Save(&driver).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update driver earnings"})
-			return
-		}
-
-	case "rejected":
-		withdrawal.RejectedAt = &now
-		if req.RejectedBy != "" {
-			withdrawal.RejectedBy = &req.RejectedBy
-		}
-
-	case "completed":
-		withdrawal.CompletedAt = &now
-		// No additional logic needed for completed status since balance is already deducted on approval
-	}
-
-	if req.Notes != "" {
-		withdrawal.Notes = req.Notes
+	// Status changes and the matching wallet postings happen in one transaction
+	actor := req.ApprovedBy
+	if req.Status == string(models.WithdrawalStatusRejected) {
+		actor = req.RejectedBy
 	}
-
-	if err := db.Save(&withdrawal).Error; err != nil {
+	err := services.UpdateWithdrawalStatus(db, &withdrawal, services.WithdrawalUpdate{
+		Status: models.WithdrawalStatus(req.Status),
+		Notes:  req.Notes,
+		Actor:  actor,
+	}, time.Now())
+	switch {
+	case errors.Is(err, services.ErrInvalidWithdrawalStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrWithdrawalTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": withdrawal.Status})
+		return
+	case errors.Is(err, services.ErrInsufficientFunds):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance", "detail": err.Error(), "requested_amount": withdrawal.Amount})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update withdrawal"})
 		return
 	}
@@ -9,9 +9,11 @@ import (
 
 	"greenbecak-backend/config"
 	"greenbecak-backend/database"
+	"greenbecak-backend/handlers"
 	"greenbecak-backend/middleware"
 	"greenbecak-backend/monitoring"
 	"greenbecak-backend/routes"
+	"greenbecak-backend/services"
 	"greenbecak-backend/utils"
 
 	"github.com/gin-contrib/cors"
@@ -29,6 +31,9 @@ func main() {
 	if err := utils.ValidateEnvironment(); err != nil {
 		log.Fatal("Environment validation failed:", err)
 	}
+	if _, err := services.LoadPaymentGateway(services.LoadPaymentGatewayConfig()); err != nil {
+		log.Fatal("Payment gateway configuration failed:", err)
+	}
 
 	// Initialize database (non-blocking)
 	db := database.InitDB()
@@ -70,9 +75,9 @@ func main() {
 	}
 
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "Idempotency-Key", "X-Order-Token"}
 	corsConfig.AllowCredentials = true
-	corsConfig.ExposeHeaders = []string{"Content-Length", "Content-Type"}
+	corsConfig.ExposeHeaders = []string{"Content-Length", "Content-Type", "Idempotent-Replayed"}
 
 	// Log CORS configuration for debugging
 	log.Printf("CORS Allowed Origins: %v", corsConfig.AllowOrigins)
@@ -92,6 +97,13 @@ func main() {
 	r.Use(middleware.ValidationMiddleware())
 	r.Use(middleware.MetricsMiddleware())
 
+	// Replay responses for retried creates sent with an Idempotency-Key
+	r.Use(middleware.IdempotencyMiddleware(
+		"POST /api/orders/public",
+		"POST /api/payments/",
+		"POST /api/driver/withdrawals",
+	))
+
 	// Initialize routes
 	routes.SetupRoutes(r, db)
 
@@ -102,6 +114,7 @@ func main() {
 	go func() {
 		time.Sleep(10 * time.Second) // Wait for database connection to be ready
 		monitoring.StartAllSchedulers()
+		monitoring.StartScheduledOrderScheduler(1*time.Minute, handlers.DispatchScheduledOrder)
 	}()
 
 	// Start server
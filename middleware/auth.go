@@ -69,3 +69,32 @@ func DriverMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// StreamAuthMiddleware authenticates long-lived stream requests.
+// Browsers cannot set headers on EventSource, so the JWT may also be passed
+// as the "token" query parameter.
+func StreamAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.Replace(c.GetHeader("Authorization"), "Bearer ", "", 1)
+		if tokenString == "" {
+			tokenString = c.Query("token")
+		}
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
+			c.Abort()
+			return
+		}
+
+		claims, err := utils.ValidateToken(tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+		c.Next()
+	}
+}
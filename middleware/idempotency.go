@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"greenbecak-backend/database"
+	"greenbecak-backend/services"
+	"greenbecak-backend/utils"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// idempotencyWriter keeps a copy of the response so it can be stored for replay
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware honours the Idempotency-Key header on the given routes
+// ("METHOD /full/route/path"). The first request with a key runs normally and
+// its response is stored; retries with the same key and body replay that
+// response, and reusing the key with a different body is rejected with 422.
+// Requests without the header, or on other routes, pass through untouched.
+func IdempotencyMiddleware(routes ...string) gin.HandlerFunc {
+	enabled := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		enabled[route] = true
+	}
+
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+		route := c.Request.Method + " " + c.FullPath()
+		if key == "" || !enabled[route] {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)})
+			c.Abort()
+			return
+		}
+
+		db := database.GetDB()
+		if db == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scopeKey := services.IdempotencyScopeKey(idempotencyScope(c), route, key)
+		fingerprint := services.IdempotencyFingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		stored, err := services.ClaimIdempotencyKey(db, scopeKey, route, fingerprint, services.IdempotencyTTL())
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request body"})
+			c.Abort()
+			return
+		case errors.Is(err, services.ErrIdempotencyInProgress):
+			c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+			c.Abort()
+			return
+		case err != nil:
+			log.Printf("Idempotency check failed, processing request without it: %v", err)
+			c.Next()
+			return
+		case stored != nil:
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(stored.ResponseStatus, stored.ContentType, []byte(stored.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		writer := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if !services.IdempotencyStoresResponse(status) {
+			// Server errors are not stored so the client can retry with the same key
+			if err := services.ReleaseIdempotencyKey(db, scopeKey); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+			return
+		}
+		if err := services.CompleteIdempotencyKey(db, scopeKey, status, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+	}
+}
+
+// idempotencyScope keeps keys of different users apart. The middleware runs
+// before route auth, so the token is read here; anonymous callers share a scope.
+func idempotencyScope(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return services.IdempotencyAnonymousScope
+	}
+	claims, err := utils.ValidateToken(strings.Replace(authHeader, "Bearer ", "", 1))
+	if err != nil {
+		return services.IdempotencyAnonymousScope
+	}
+	return fmt.Sprintf("user:%d", claims.UserID)
+}
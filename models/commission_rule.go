@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Commission programs, derived from the order's tariff flags
+const (
+	CommissionProgramSubsidi  = "subsidi"
+	CommissionProgramGojek    = "gojek"
+	CommissionProgramNonTunai = "non_tunai"
+)
+
+// CommissionRule sets the platform's share of a fare. Empty criteria match
+// any order; when several rules match, the most specific one wins.
+type CommissionRule struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"not null"`
+	TariffID    *uint          `json:"tariff_id" gorm:"index"`
+	VehicleType VehicleType    `json:"vehicle_type" gorm:"size:20"`
+	Program     string         `json:"program" gorm:"size:20"` // subsidi, gojek, non_tunai atau kosong
+	Rate        float64        `json:"rate" gorm:"default:0"`  // Persentase dari harga order (0.1 = 10%)
+	FlatFee     float64        `json:"flat_fee" gorm:"default:0"`
+	MinFee      float64        `json:"min_fee" gorm:"default:0"`
+	MaxFee      float64        `json:"max_fee" gorm:"default:0"`  // 0 = tanpa batas
+	Priority    int            `json:"priority" gorm:"default:0"` // Pemenang jika kekhususan sama
+	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relationships
+	Tariff *Tariff `json:"tariff,omitempty" gorm:"foreignKey:TariffID;references:ID"`
+}
+
+func (r *CommissionRule) TableName() string {
+	return "commission_rules"
+}
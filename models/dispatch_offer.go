@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+type DispatchOfferStatus string
+
+const (
+	DispatchOfferStatusOffered   DispatchOfferStatus = "offered"
+	DispatchOfferStatusAccepted  DispatchOfferStatus = "accepted"
+	DispatchOfferStatusDeclined  DispatchOfferStatus = "declined"
+	DispatchOfferStatusTimeout   DispatchOfferStatus = "timeout"
+	DispatchOfferStatusCancelled DispatchOfferStatus = "cancelled"
+)
+
+// DispatchOffer records an order being offered to a single driver during dispatch
+type DispatchOffer struct {
+	ID          uint                `json:"id" gorm:"primaryKey"`
+	OrderID     uint                `json:"order_id" gorm:"not null;index"`
+	DriverID    uint                `json:"driver_id" gorm:"not null;index"`
+	Wave        int                 `json:"wave" gorm:"not null"`
+	RadiusKm    float64             `json:"radius_km"`
+	DistanceKm  float64             `json:"distance_km"`
+	Status      DispatchOfferStatus `json:"status" gorm:"type:enum('offered','accepted','declined','timeout','cancelled');default:'offered'"`
+	OfferedAt   time.Time           `json:"offered_at"`
+	ExpiresAt   time.Time           `json:"expires_at"`
+	RespondedAt *time.Time          `json:"responded_at"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+
+	// Relationships
+	Driver Driver `json:"driver,omitempty" gorm:"foreignKey:DriverID;references:ID"`
+}
+
+func (o *DispatchOffer) TableName() string {
+	return "dispatch_offers"
+}
@@ -24,25 +24,27 @@ const (
 )
 
 type Driver struct {
-	ID            uint           `json:"id" gorm:"primaryKey"`
-	UserID        *uint          `json:"user_id" gorm:"unique"`
-	DriverCode    string         `json:"driver_code" gorm:"unique;not null"`
-	Name          string         `json:"name" gorm:"not null"`
-	Phone         string         `json:"phone" gorm:"not null"`
-	Email         string         `json:"email"`
-	Address       string         `json:"address"`
-	IDCard        string         `json:"id_card"`
-	VehicleNumber string         `json:"vehicle_number"`
-	VehicleType   VehicleType    `json:"vehicle_type" gorm:"type:enum('becak_manual','becak_motor','becak_listrik','andong');default:'becak_manual'"`
-	Status        DriverStatus   `json:"status" gorm:"type:enum('active','inactive','on_trip');default:'active'"`
-	IsActive      bool           `json:"is_active" gorm:"default:true"`
-	Rating        float64        `json:"rating" gorm:"default:0"`
-	TotalTrips    int            `json:"total_trips" gorm:"default:0"`
-	TotalEarnings float64        `json:"total_earnings" gorm:"default:0"`
-	FCMToken      string         `json:"fcm_token" gorm:"column:fcm_token"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                  uint           `json:"id" gorm:"primaryKey"`
+	UserID              *uint          `json:"user_id" gorm:"unique"`
+	DriverCode          string         `json:"driver_code" gorm:"unique;not null"`
+	Name                string         `json:"name" gorm:"not null"`
+	Phone               string         `json:"phone" gorm:"not null"`
+	Email               string         `json:"email"`
+	Address             string         `json:"address"`
+	IDCard              string         `json:"id_card"`
+	VehicleNumber       string         `json:"vehicle_number"`
+	VehicleType         VehicleType    `json:"vehicle_type" gorm:"type:enum('becak_manual','becak_motor','becak_listrik','andong');default:'becak_manual'"`
+	Status              DriverStatus   `json:"status" gorm:"type:enum('active','inactive','on_trip');default:'active'"`
+	IsActive            bool           `json:"is_active" gorm:"default:true"`
+	Rating              float64        `json:"rating" gorm:"default:0"`       // Bayesian average of published reviews
+	RatingCount         int            `json:"rating_count" gorm:"default:0"` // Number of published reviews
+	TotalTrips          int            `json:"total_trips" gorm:"default:0"`
+	CancellationStrikes int            `json:"cancellation_strikes" gorm:"default:0"` // Strike karena membatalkan order
+	TotalEarnings       float64        `json:"total_earnings" gorm:"default:0"`
+	FCMToken            string         `json:"fcm_token" gorm:"column:fcm_token"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Calculated fields (not stored in database)
 	AvailableBalance     float64 `json:"available_balance,omitempty" gorm:"-"`
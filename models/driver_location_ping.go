@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// DriverLocationPing is an append-only breadcrumb written on every location update.
+// The current position stays in driver_locations for fast nearby queries.
+type DriverLocationPing struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	DriverID   uint      `json:"driver_id" gorm:"not null"`
+	OrderID    *uint     `json:"order_id"` // Active trip at the time of the ping, if any
+	Latitude   float64   `json:"latitude" gorm:"not null"`
+	Longitude  float64   `json:"longitude" gorm:"not null"`
+	Accuracy   float64   `json:"accuracy"`
+	Speed      float64   `json:"speed"`
+	Heading    float64   `json:"heading"`
+	RecordedAt time.Time `json:"recorded_at" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (p *DriverLocationPing) TableName() string {
+	return "driver_location_pings"
+}
@@ -0,0 +1,43 @@
+package models
+
+// Fare line codes used in FareBreakdown.Items
+const (
+	FareItemBase              = "base"
+	FareItemDistance          = "distance"
+	FareItemTime              = "time"
+	FareItemWaiting           = "waiting"
+	FareItemPackage           = "package"
+	FareItemOvertime          = "overtime"
+	FareItemVehicleMultiplier = "vehicle_multiplier"
+	FareItemMinimumFare       = "minimum_fare"
+	FareItemNonTunaiFee       = "non_tunai_fee"
+	FareItemRounding          = "rounding"
+)
+
+// FareItem is a single line of an itemized fare
+type FareItem struct {
+	Code   string  `json:"code"`
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+}
+
+// FareBreakdown is the itemized fare stored on an order.
+// Total is the gross fare (Order.Price); CustomerTotal is what the customer pays
+// after any subsidy.
+type FareBreakdown struct {
+	TariffID          uint        `json:"tariff_id"`
+	TariffName        string      `json:"tariff_name"`
+	VehicleType       VehicleType `json:"vehicle_type"`
+	DistanceKm        float64     `json:"distance_km"`
+	DurationMinutes   int         `json:"duration_minutes"`
+	WaitMinutes       int         `json:"wait_minutes,omitempty"` // Waktu tunggu di titik singgah
+	VehicleMultiplier float64     `json:"vehicle_multiplier"`
+	IsSubsidi         bool        `json:"is_subsidi"`
+	IsGojek           bool        `json:"is_gojek"`
+	IsNonTunai        bool        `json:"is_non_tunai"`
+	Items             []FareItem  `json:"items"`
+	Total             float64     `json:"total"`
+	Subsidy           float64     `json:"subsidy"`
+	CustomerTotal     float64     `json:"customer_total"`
+	Quoted            bool        `json:"quoted,omitempty"` // Harga dikunci quote token, tidak dihitung ulang dari jarak
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+type IdempotencyStatus string
+
+const (
+	IdempotencyStatusProcessing IdempotencyStatus = "processing"
+	IdempotencyStatusCompleted  IdempotencyStatus = "completed"
+)
+
+// IdempotencyRecord stores the response to a request made with an Idempotency-Key
+// so retries of the same request get the same response instead of a duplicate
+type IdempotencyRecord struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	ScopeKey       string            `json:"scope_key" gorm:"size:64;uniqueIndex;not null"` // sha256(scope, route, Idempotency-Key)
+	Route          string            `json:"route" gorm:"size:120"`
+	Fingerprint    string            `json:"fingerprint" gorm:"size:64;not null"` // sha256 dari method, path dan body
+	Status         IdempotencyStatus `json:"status" gorm:"size:20;not null"`
+	ResponseStatus int               `json:"response_status"`
+	ContentType    string            `json:"content_type" gorm:"size:100"`
+	ResponseBody   string            `json:"response_body" gorm:"type:mediumtext"`
+	ExpiresAt      time.Time         `json:"expires_at" gorm:"index"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+func (r *IdempotencyRecord) TableName() string {
+	return "idempotency_records"
+}
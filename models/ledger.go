@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrLedgerImmutable is returned when a journal entry or posting is modified;
+// mistakes are corrected with a reversing entry instead
+var ErrLedgerImmutable = errors.New("ledger records are immutable")
+
+// LedgerAccountType follows the usual accounting classification
+type LedgerAccountType string
+
+const (
+	LedgerAccountAsset     LedgerAccountType = "asset"
+	LedgerAccountLiability LedgerAccountType = "liability"
+	LedgerAccountRevenue   LedgerAccountType = "revenue"
+	LedgerAccountExpense   LedgerAccountType = "expense"
+)
+
+// JournalEntryType is the business event a journal entry records
+type JournalEntryType string
+
+const (
+	JournalEntryEarning    JournalEntryType = "earning"    // Order selesai: pendapatan driver dan komisi platform
+	JournalEntryWithdrawal JournalEntryType = "withdrawal" // Pencairan saldo driver
+	JournalEntryRefund     JournalEntryType = "refund"     // Pengembalian dana ke customer
+	JournalEntryAdjustment JournalEntryType = "adjustment" // Koreksi manual oleh admin
+	JournalEntrySettlement JournalEntryType = "settlement" // Pelunasan piutang komisi order tunai
+	JournalEntryReversal   JournalEntryType = "reversal"   // Pembalikan entry sebelumnya
+)
+
+// LedgerAccount is one account in the chart of accounts. Driver wallets are
+// liabilities of the platform (what it owes the driver).
+type LedgerAccount struct {
+	ID        uint              `json:"id" gorm:"primaryKey"`
+	Code      string            `json:"code" gorm:"size:64;uniqueIndex;not null"` // mis. driver:12:wallet, platform:commission
+	Name      string            `json:"name" gorm:"size:100"`
+	Type      LedgerAccountType `json:"type" gorm:"size:20;not null"`
+	DriverID  *uint             `json:"driver_id" gorm:"index"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func (a *LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+// CreditNormal reports whether the account's balance grows with credits
+func (a LedgerAccount) CreditNormal() bool {
+	return a.Type == LedgerAccountLiability || a.Type == LedgerAccountRevenue
+}
+
+// JournalEntry groups balanced postings recorded for one business event
+type JournalEntry struct {
+	ID            uint             `json:"id" gorm:"primaryKey"`
+	Key           string           `json:"key" gorm:"column:entry_key;size:100;uniqueIndex;not null"` // Kunci idempotensi, mis. order:42:earning
+	Type          JournalEntryType `json:"type" gorm:"size:20;not null;index"`
+	ReferenceType string           `json:"reference_type" gorm:"size:20;index:idx_journal_entries_reference"` // order, withdrawal, driver
+	ReferenceID   uint             `json:"reference_id" gorm:"index:idx_journal_entries_reference"`
+	ReversesID    *uint            `json:"reverses_id,omitempty"` // Entry yang dibalik oleh entry ini
+	Memo          string           `json:"memo"`
+	CreatedBy     *uint            `json:"created_by,omitempty"`
+	PostedAt      time.Time        `json:"posted_at" gorm:"index"`
+	CreatedAt     time.Time        `json:"created_at"`
+
+	// Relationships
+	Postings []LedgerPosting `json:"postings,omitempty" gorm:"foreignKey:EntryID"`
+}
+
+func (e *JournalEntry) TableName() string {
+	return "journal_entries"
+}
+
+func (e *JournalEntry) BeforeUpdate(tx *gorm.DB) error { return ErrLedgerImmutable }
+func (e *JournalEntry) BeforeDelete(tx *gorm.DB) error { return ErrLedgerImmutable }
+
+// LedgerPosting is one side of a journal entry. Amount is signed: debits are
+// positive and credits negative, so the postings of an entry sum to zero.
+type LedgerPosting struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EntryID   uint      `json:"entry_id" gorm:"not null;index"`
+	AccountID uint      `json:"account_id" gorm:"not null;index:idx_ledger_postings_account_time"`
+	Amount    float64   `json:"amount" gorm:"type:decimal(15,2);not null"`
+	PostedAt  time.Time `json:"posted_at" gorm:"index:idx_ledger_postings_account_time"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Account LedgerAccount `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+}
+
+func (p *LedgerPosting) TableName() string {
+	return "ledger_postings"
+}
+
+func (p *LedgerPosting) BeforeUpdate(tx *gorm.DB) error { return ErrLedgerImmutable }
+func (p *LedgerPosting) BeforeDelete(tx *gorm.DB) error { return ErrLedgerImmutable }
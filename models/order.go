@@ -3,49 +3,105 @@ package models
 import (
 	"time"
 
+	"greenbecak-backend/geo"
+
 	"gorm.io/gorm"
 )
 
 type OrderStatus string
 
 const (
-	OrderStatusPending   OrderStatus = "pending"
-	OrderStatusAccepted  OrderStatus = "accepted"
-	OrderStatusCompleted OrderStatus = "completed"
-	OrderStatusCancelled OrderStatus = "cancelled"
+	OrderStatusPending    OrderStatus = "pending"
+	OrderStatusAccepted   OrderStatus = "accepted"
+	OrderStatusPickedUp   OrderStatus = "picked_up"
+	OrderStatusInProgress OrderStatus = "in_progress"
+	OrderStatusCompleted  OrderStatus = "completed"
+	OrderStatusCancelled  OrderStatus = "cancelled"
+	OrderStatusExpired    OrderStatus = "expired"
+	OrderStatusNoShow     OrderStatus = "no_show"
 )
 
 type Order struct {
-	ID             uint           `json:"id" gorm:"primaryKey"`
-	OrderNumber    string         `json:"order_number" gorm:"unique;not null"`
-	CustomerID     *uint          `json:"customer_id"`
-	DriverID       *uint          `json:"driver_id"`
-	BecakCode      string         `json:"becak_code" gorm:"not null"` // Kode dari sticker barcode
-	TariffID       uint           `json:"tariff_id"`
-	PickupLocation string         `json:"pickup_location"` // Bisa null, diisi nanti oleh sistem
-	DropLocation   string         `json:"drop_location"`   // Bisa null, diisi nanti oleh sistem
-	Distance       float64        `json:"distance" gorm:"not null"`
-	Price          float64        `json:"price" gorm:"not null"`
-	ETA            int            `json:"eta" gorm:"-"` // Estimated Time of Arrival in minutes (calculated field)
-	Status         OrderStatus    `json:"status" gorm:"type:enum('pending','accepted','completed','cancelled');default:'pending'"`
-	PaymentStatus  string         `json:"payment_status" gorm:"default:'pending'"`
-	CustomerPhone  string         `json:"customer_phone" gorm:"not null"`
-	CustomerName   string         `json:"customer_name"`
-	Notes          string         `json:"notes"`
-	AcceptedAt     *time.Time     `json:"accepted_at"`
-	CompletedAt    *time.Time     `json:"completed_at"`
-	CancelledAt    *time.Time     `json:"cancelled_at"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	OrderNumber     string         `json:"order_number" gorm:"unique;not null"`
+	CustomerID      *uint          `json:"customer_id"`
+	DriverID        *uint          `json:"driver_id"`
+	BecakCode       string         `json:"becak_code" gorm:"not null"` // Kode dari sticker barcode
+	TariffID        uint           `json:"tariff_id"`
+	PickupLocation  string         `json:"pickup_location"` // Bisa null, diisi nanti oleh sistem (label alamat)
+	DropLocation    string         `json:"drop_location"`   // Bisa null, diisi nanti oleh sistem (label alamat)
+	PickupLat       *float64       `json:"pickup_lat"`
+	PickupLng       *float64       `json:"pickup_lng"`
+	PickupLandmark  string         `json:"pickup_landmark"`
+	PickupPlaceID   string         `json:"pickup_place_id"`
+	DropLat         *float64       `json:"drop_lat"`
+	DropLng         *float64       `json:"drop_lng"`
+	DropLandmark    string         `json:"drop_landmark"`
+	DropPlaceID     string         `json:"drop_place_id"`
+	Distance        float64        `json:"distance" gorm:"not null"`
+	Price           float64        `json:"price" gorm:"not null"`
+	FareBreakdown   *FareBreakdown `json:"fare_breakdown,omitempty" gorm:"type:json;serializer:json"`
+	CancellationFee float64        `json:"cancellation_fee" gorm:"default:0"`
+	RoundTrip       bool           `json:"round_trip" gorm:"default:false"` // Kembali ke titik jemput setelah singgah
+	WaitMinutes     int            `json:"wait_minutes" gorm:"default:0"`   // Total waktu tunggu di titik singgah yang ditagih
+	PackageID       *uint          `json:"package_id" gorm:"index"`         // Paket tour atau sewa per jam
+	Passengers      int            `json:"passengers" gorm:"default:1"`
+	BookedMinutes   int            `json:"booked_minutes" gorm:"default:0"` // Durasi paket yang dipesan
+	OvertimeMinutes int            `json:"overtime_minutes" gorm:"default:0"`
+	OvertimeFee     float64        `json:"overtime_fee" gorm:"default:0"`
+	Commission      float64        `json:"commission" gorm:"default:0"`        // Komisi platform, dihitung saat order selesai
+	CollectedBy     string         `json:"collected_by" gorm:"size:10"`        // driver (tunai), platform (non-tunai) atau pending (non-tunai belum lunas)
+	ETA             int            `json:"eta" gorm:"-"`                       // Estimated Time of Arrival in minutes (calculated field)
+	PickupDistance  float64        `json:"pickup_distance,omitempty" gorm:"-"` // Distance from the requesting driver in km (calculated field)
+	Status          OrderStatus    `json:"status" gorm:"type:enum('pending','accepted','picked_up','in_progress','completed','cancelled','expired','no_show');default:'pending'"`
+	PaymentStatus   string         `json:"payment_status" gorm:"default:'pending'"`
+	CustomerPhone   string         `json:"customer_phone" gorm:"not null"`
+	CustomerName    string         `json:"customer_name"`
+	Notes           string         `json:"notes"`
+	ScheduledAt     *time.Time     `json:"scheduled_at" gorm:"index"` // Awal jendela jemput untuk booking terjadwal, null untuk order langsung
+	ScheduledUntil  *time.Time     `json:"scheduled_until"`           // Akhir jendela jemput
+	CommittedAt     *time.Time     `json:"committed_at"`              // Driver menyanggupi booking sebelum dispatch
+	DispatchedAt    *time.Time     `json:"dispatched_at"`             // Booking mulai didispatch ke driver
+	RemindedAt      *time.Time     `json:"reminded_at"`
+	AcceptedAt      *time.Time     `json:"accepted_at"`
+	PickedUpAt      *time.Time     `json:"picked_up_at"`
+	StartedAt       *time.Time     `json:"started_at"`
+	CompletedAt     *time.Time     `json:"completed_at"`
+	CancelledAt     *time.Time     `json:"cancelled_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	Customer User     `json:"customer,omitempty" gorm:"foreignKey:CustomerID;references:ID"`
-	Driver   Driver   `json:"driver,omitempty" gorm:"foreignKey:DriverID;references:ID"`
-	Tariff   Tariff   `json:"tariff,omitempty" gorm:"foreignKey:TariffID;references:ID"`
-	Payment  *Payment `json:"payment,omitempty" gorm:"foreignKey:OrderID;references:ID"`
+	Customer User         `json:"customer,omitempty" gorm:"foreignKey:CustomerID;references:ID"`
+	Driver   Driver       `json:"driver,omitempty" gorm:"foreignKey:DriverID;references:ID"`
+	Tariff   Tariff       `json:"tariff,omitempty" gorm:"foreignKey:TariffID;references:ID"`
+	Payment  *Payment     `json:"payment,omitempty" gorm:"foreignKey:OrderID;references:ID"`
+	Stops    []OrderStop  `json:"stops,omitempty" gorm:"foreignKey:OrderID;references:ID"`
+	Package  *TourPackage `json:"package,omitempty" gorm:"foreignKey:PackageID;references:ID"`
 }
 
 func (o *Order) TableName() string {
 	return "orders"
 }
+
+// IsScheduled reports whether the order is an advance booking
+func (o *Order) IsScheduled() bool {
+	return o.ScheduledAt != nil
+}
+
+// PickupPoint returns the pickup coordinates and whether they are known
+func (o *Order) PickupPoint() (geo.Point, bool) {
+	if o.PickupLat == nil || o.PickupLng == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: *o.PickupLat, Lng: *o.PickupLng}, true
+}
+
+// DropPoint returns the drop coordinates and whether they are known
+func (o *Order) DropPoint() (geo.Point, bool) {
+	if o.DropLat == nil || o.DropLng == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: *o.DropLat, Lng: *o.DropLng}, true
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+)
+
+// CancellationReason is a reason code chosen when cancelling an order
+type CancellationReason string
+
+const (
+	// Customer reasons
+	CancelReasonChangedPlans   CancellationReason = "changed_plans"
+	CancelReasonDriverTooFar   CancellationReason = "driver_too_far"
+	CancelReasonDriverLate     CancellationReason = "driver_late"
+	CancelReasonWrongLocation  CancellationReason = "wrong_location"
+	CancelReasonFoundOtherRide CancellationReason = "found_other_ride"
+	CancelReasonPriceTooHigh   CancellationReason = "price_too_high"
+
+	// Driver reasons
+	CancelReasonCustomerNoShow      CancellationReason = "customer_no_show"
+	CancelReasonCustomerUnreachable CancellationReason = "customer_unreachable"
+	CancelReasonVehicleProblem      CancellationReason = "vehicle_problem"
+	CancelReasonPickupTooFar        CancellationReason = "pickup_too_far"
+	CancelReasonUnsafeRequest       CancellationReason = "unsafe_request"
+
+	// Admin reasons
+	CancelReasonFraud          CancellationReason = "fraud"
+	CancelReasonDuplicateOrder CancellationReason = "duplicate_order"
+	CancelReasonSupport        CancellationReason = "customer_support"
+
+	// Any actor
+	CancelReasonOther CancellationReason = "other"
+)
+
+// CancellationStage is how far an order had progressed when it was cancelled
+type CancellationStage string
+
+const (
+	CancelStageScheduled CancellationStage = "scheduled" // Booking terjadwal yang belum didispatch
+	CancelStagePending   CancellationStage = "pending"   // Belum ada driver
+	CancelStageAccepted  CancellationStage = "accepted"  // Driver menuju lokasi jemput
+	CancelStageArrived   CancellationStage = "arrived"   // Driver sudah di lokasi jemput
+	CancelStageOnTrip    CancellationStage = "on_trip"   // Customer sudah dijemput
+)
+
+// Parties a cancellation fee is charged to
+const (
+	FeePayerCustomer = "customer"
+	FeePayerDriver   = "driver"
+)
+
+// OrderCancellation records who cancelled an order, why, and the penalty applied
+type OrderCancellation struct {
+	ID           uint               `json:"id" gorm:"primaryKey"`
+	OrderID      uint               `json:"order_id" gorm:"not null;uniqueIndex"`
+	DriverID     *uint              `json:"driver_id" gorm:"index"`
+	ActorID      *uint              `json:"actor_id"`
+	ActorRole    string             `json:"actor_role" gorm:"size:20;not null"` // admin, customer, driver
+	ReasonCode   CancellationReason `json:"reason_code" gorm:"size:40;not null"`
+	Note         string             `json:"note"`
+	FromStatus   OrderStatus        `json:"from_status" gorm:"size:20"`
+	Stage        CancellationStage  `json:"stage" gorm:"size:20"`
+	Rule         string             `json:"rule" gorm:"size:60"` // Nama aturan yang cocok
+	Fee          float64            `json:"fee" gorm:"default:0"`
+	FeePayer     string             `json:"fee_payer" gorm:"size:20"` // customer, driver, atau kosong jika tanpa biaya
+	DriverStrike bool               `json:"driver_strike" gorm:"default:false"`
+	DriverStatus DriverStatus       `json:"driver_status" gorm:"size:20"` // Status driver setelah pembatalan
+	CreatedAt    time.Time          `json:"created_at"`
+
+	// Relationships
+	Order  *Order  `json:"order,omitempty" gorm:"foreignKey:OrderID;references:ID"`
+	Driver *Driver `json:"driver,omitempty" gorm:"foreignKey:DriverID;references:ID"`
+}
+
+func (c *OrderCancellation) TableName() string {
+	return "order_cancellations"
+}
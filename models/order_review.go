@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+type ReviewStatus string
+
+const (
+	ReviewStatusPublished ReviewStatus = "published"
+	ReviewStatusHidden    ReviewStatus = "hidden"
+)
+
+// OrderReview is a customer's rating of a completed order and its driver
+type OrderReview struct {
+	ID             uint         `json:"id" gorm:"primaryKey"`
+	OrderID        uint         `json:"order_id" gorm:"not null;uniqueIndex"` // Satu review per order
+	DriverID       uint         `json:"driver_id" gorm:"not null;index"`
+	CustomerID     *uint        `json:"customer_id"`
+	CustomerPhone  string       `json:"-" gorm:"size:20"`
+	CustomerName   string       `json:"customer_name"`
+	Rating         int          `json:"rating" gorm:"not null"` // 1-5
+	Tags           []string     `json:"tags" gorm:"type:json;serializer:json"`
+	Comment        string       `json:"comment" gorm:"type:text"`
+	Status         ReviewStatus `json:"status" gorm:"type:enum('published','hidden');default:'published'"`
+	ModeratedBy    *uint        `json:"moderated_by,omitempty"`
+	ModeratedAt    *time.Time   `json:"moderated_at,omitempty"`
+	ModerationNote string       `json:"moderation_note,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+
+	// Relationships
+	Order  *Order  `json:"order,omitempty" gorm:"foreignKey:OrderID;references:ID"`
+	Driver *Driver `json:"driver,omitempty" gorm:"foreignKey:DriverID;references:ID"`
+}
+
+func (r *OrderReview) TableName() string {
+	return "order_reviews"
+}
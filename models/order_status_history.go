@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// OrderStatusHistory is an append-only audit trail of order status changes
+type OrderStatusHistory struct {
+	ID         uint        `json:"id" gorm:"primaryKey"`
+	OrderID    uint        `json:"order_id" gorm:"not null;index"`
+	FromStatus OrderStatus `json:"from_status" gorm:"size:20"`
+	ToStatus   OrderStatus `json:"to_status" gorm:"size:20;not null"`
+	ActorID    *uint       `json:"actor_id"`
+	ActorRole  string      `json:"actor_role" gorm:"size:20"` // admin, customer, driver, system
+	Reason     string      `json:"reason"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func (h *OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
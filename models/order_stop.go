@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"greenbecak-backend/geo"
+)
+
+// OrderStop is an intermediate stop between pickup and drop, visited in Sequence order
+type OrderStop struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	OrderID       uint       `json:"order_id" gorm:"not null;uniqueIndex:idx_order_stops_sequence"`
+	Sequence      int        `json:"sequence" gorm:"not null;uniqueIndex:idx_order_stops_sequence"` // Urutan singgah, mulai dari 1
+	Address       string     `json:"address"`
+	Lat           *float64   `json:"lat"`
+	Lng           *float64   `json:"lng"`
+	Landmark      string     `json:"landmark"`
+	PlaceID       string     `json:"place_id"`
+	WaitMinutes   int        `json:"wait_minutes" gorm:"default:0"`   // Waktu tunggu yang dipesan
+	WaitedMinutes int        `json:"waited_minutes" gorm:"default:0"` // Waktu tunggu sebenarnya, diisi saat driver berangkat
+	ArrivedAt     *time.Time `json:"arrived_at"`
+	DepartedAt    *time.Time `json:"departed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (s *OrderStop) TableName() string {
+	return "order_stops"
+}
+
+// Point returns the stop coordinates and whether they are known
+func (s *OrderStop) Point() (geo.Point, bool) {
+	if s.Lat == nil || s.Lng == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: *s.Lat, Lng: *s.Lng}, true
+}
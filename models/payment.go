@@ -15,25 +15,44 @@ const (
 	PaymentStatusFailed   PaymentStatus = "failed"
 	PaymentStatusRefunded PaymentStatus = "refunded"
 
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+
 	PaymentMethodCash     PaymentMethod = "cash"
 	PaymentMethodTransfer PaymentMethod = "transfer"
 	PaymentMethodQR       PaymentMethod = "qr"
 )
 
 type Payment struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	OrderID   uint           `json:"order_id" gorm:"unique"`
-	Amount    float64        `json:"amount" gorm:"not null"`
-	Method    PaymentMethod  `json:"method" gorm:"type:enum('cash','transfer','qr');default:'cash'"`
-	Status    PaymentStatus  `json:"status" gorm:"type:enum('pending','paid','failed','refunded');default:'pending'"`
-	Reference string         `json:"reference"`
-	PaidAt    *time.Time     `json:"paid_at"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	OrderID    uint           `json:"order_id" gorm:"unique"`
+	Amount     float64        `json:"amount" gorm:"not null"`
+	Method     PaymentMethod  `json:"method" gorm:"type:enum('cash','transfer','qr');default:'cash'"`
+	Status     PaymentStatus  `json:"status" gorm:"type:enum('pending','paid','failed','refunded','partially_refunded');default:'pending'"`
+	Reference  string         `json:"reference" gorm:"size:32;index"` // Nomor pembayaran PY-YYYYMMDD-NNNNNNC
+	Notes      string         `json:"notes"`
+	Gateway    string         `json:"gateway" gorm:"size:20"`                // Gateway yang menagih, kosong untuk tunai
+	GatewayRef string         `json:"gateway_ref" gorm:"size:64;index"`      // ID transaksi di gateway
+	PaymentURL string         `json:"payment_url"`                           // Halaman bayar, QR atau nomor virtual account
+	QRPayload  string         `json:"qr_payload,omitempty" gorm:"type:text"` // Payload QRIS dinamis untuk metode qr
+	ExpiresAt  *time.Time     `json:"expires_at"`
+	PaidAt     *time.Time     `json:"paid_at"`
+	PaidLate   bool           `json:"paid_late" gorm:"default:false"`                          // Dibayar setelah QR kedaluwarsa
+	Refunded   float64        `json:"refunded_amount" gorm:"column:refunded_amount;default:0"` // Total refund yang berhasil
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	Order Order `json:"order,omitempty" gorm:"foreignKey:OrderID;references:ID"`
+	Order   Order    `json:"order,omitempty" gorm:"foreignKey:OrderID;references:ID"`
+	Refunds []Refund `json:"refunds,omitempty" gorm:"foreignKey:PaymentID;references:ID"`
+}
+
+// Refundable is the part of the payment not refunded yet
+func (p *Payment) Refundable() float64 {
+	if p.Status != PaymentStatusPaid && p.Status != PaymentStatusPartiallyRefunded {
+		return 0
+	}
+	return p.Amount - p.Refunded
 }
 
 func (p *Payment) TableName() string {
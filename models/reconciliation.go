@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+)
+
+type ReconciliationSource string
+
+const (
+	ReconciliationSourceFile    ReconciliationSource = "file"    // File settlement CSV dari PSP
+	ReconciliationSourceGateway ReconciliationSource = "gateway" // Status tagihan dari API payment gateway
+)
+
+type ReconciliationStatus string
+
+const (
+	ReconciliationStatusRunning   ReconciliationStatus = "running"
+	ReconciliationStatusCompleted ReconciliationStatus = "completed"
+	ReconciliationStatusFailed    ReconciliationStatus = "failed"
+)
+
+// ReconciliationResult is the outcome of matching one settlement entry or payment
+type ReconciliationResult string
+
+const (
+	ReconResultMatched          ReconciliationResult = "matched"
+	ReconResultMissingPayment   ReconciliationResult = "missing_payment"   // Ada di settlement, tidak ada di payments
+	ReconResultMissingStatement ReconciliationResult = "missing_statement" // Ada di payments, tidak ada di settlement
+	ReconResultDuplicate        ReconciliationResult = "duplicate"
+	ReconResultAmountMismatch   ReconciliationResult = "amount_mismatch"
+	ReconResultStatusMismatch   ReconciliationResult = "status_mismatch"
+	ReconResultInvalidRow       ReconciliationResult = "invalid_row" // Baris file yang tidak bisa dibaca
+)
+
+// ReconciliationRun is one comparison of a settlement source against payments
+type ReconciliationRun struct {
+	ID          uint                 `json:"id" gorm:"primaryKey"`
+	JobKey      *string              `json:"job_key,omitempty" gorm:"size:100;uniqueIndex"` // Diisi job harian agar satu hari hanya direkonsiliasi sekali
+	Source      ReconciliationSource `json:"source" gorm:"size:10;not null"`
+	Provider    string               `json:"provider" gorm:"size:30"` // midtrans, xendit, doku, qris, ...
+	Gateway     string               `json:"gateway" gorm:"size:20"`  // Membatasi payment yang diharapkan ada di settlement
+	FileName    string               `json:"file_name"`
+	Date        *time.Time           `json:"date" gorm:"type:date"` // Hari settlement (WIB)
+	Status      ReconciliationStatus `json:"status" gorm:"size:10;default:'running'"`
+	Error       string               `json:"error,omitempty" gorm:"type:text"`
+	TotalRows   int                  `json:"total_rows"`
+	Matched     int                  `json:"matched"`
+	Exceptions  int                  `json:"exceptions"`
+	TotalAmount float64              `json:"total_amount"` // Jumlah nominal di settlement
+	CreatedBy   *uint                `json:"created_by"`
+	CompletedAt *time.Time           `json:"completed_at"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+func (r *ReconciliationRun) TableName() string {
+	return "reconciliation_runs"
+}
+
+// ReconciliationItem is one matched or flagged entry of a run
+type ReconciliationItem struct {
+	ID              uint                 `json:"id" gorm:"primaryKey"`
+	RunID           uint                 `json:"run_id" gorm:"index;not null"`
+	Line            int                  `json:"line" gorm:"column:line_no"` // Nomor baris di file, 0 untuk payment yang tidak ada di settlement
+	Result          ReconciliationResult `json:"result" gorm:"size:20;index"`
+	PaymentID       *uint                `json:"payment_id" gorm:"index"`
+	Reference       string               `json:"reference" gorm:"size:64"`
+	ProviderRef     string               `json:"provider_ref" gorm:"size:100"`
+	StatementAmount float64              `json:"statement_amount"`
+	StatementStatus string               `json:"statement_status" gorm:"size:30"`
+	PaymentAmount   float64              `json:"payment_amount"`
+	PaymentStatus   string               `json:"payment_status" gorm:"size:30"`
+	TransactionAt   *time.Time           `json:"transaction_at"`
+	Detail          string               `json:"detail"`
+	CreatedAt       time.Time            `json:"created_at"`
+}
+
+func (i *ReconciliationItem) TableName() string {
+	return "reconciliation_items"
+}
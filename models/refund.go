@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// RefundKind separates refunds we issue from chargebacks the customer's bank forced
+type RefundKind string
+
+const (
+	RefundKindRefund     RefundKind = "refund"
+	RefundKindChargeback RefundKind = "chargeback"
+)
+
+// RefundReason is a reason code chosen when refunding a payment
+type RefundReason string
+
+const (
+	RefundReasonCustomerRequest  RefundReason = "customer_request"
+	RefundReasonDriverNoShow     RefundReason = "driver_no_show"
+	RefundReasonOvercharge       RefundReason = "overcharge"
+	RefundReasonDuplicatePayment RefundReason = "duplicate_payment"
+	RefundReasonServiceIssue     RefundReason = "service_issue"
+	RefundReasonChargeback       RefundReason = "chargeback"
+	RefundReasonOther            RefundReason = "other"
+)
+
+// Refund is money returned to the customer for (part of) a payment
+type Refund struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	PaymentID     uint           `json:"payment_id" gorm:"index;not null"`
+	OrderID       uint           `json:"order_id" gorm:"index;not null"`
+	Reference     string         `json:"reference" gorm:"size:32;index"` // Nomor refund RF-YYYYMMDD-NNNNNNC
+	Kind          RefundKind     `json:"kind" gorm:"type:enum('refund','chargeback');default:'refund'"`
+	Amount        float64        `json:"amount" gorm:"not null"`
+	Reason        RefundReason   `json:"reason" gorm:"size:30;not null"`
+	Note          string         `json:"note" gorm:"type:text"`
+	Status        RefundStatus   `json:"status" gorm:"type:enum('pending','succeeded','failed');default:'pending'"`
+	Gateway       string         `json:"gateway" gorm:"size:20"` // Kosong jika dikembalikan manual (tunai/transfer)
+	ProviderRef   string         `json:"provider_ref" gorm:"size:100"`
+	FailureReason string         `json:"failure_reason"`
+	RequestedBy   *uint          `json:"requested_by"`
+	ProcessedAt   *time.Time     `json:"processed_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relationships
+	Payment *Payment `json:"payment,omitempty" gorm:"foreignKey:PaymentID;references:ID"`
+}
+
+func (r *Refund) TableName() string {
+	return "refunds"
+}
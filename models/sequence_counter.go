@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// SequenceCounter holds the last number handed out for a named sequence,
+// e.g. "GB:20261017" for the orders created on 17 October 2026
+type SequenceCounter struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:40"`
+	Value     int64     `json:"value" gorm:"not null;default:0"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *SequenceCounter) TableName() string {
+	return "sequence_counters"
+}
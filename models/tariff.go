@@ -11,8 +11,11 @@ type Tariff struct {
 	Name         string         `json:"name" gorm:"not null"`
 	MinDistance  float64        `json:"min_distance" gorm:"not null"`
 	MaxDistance  float64        `json:"max_distance" gorm:"not null"`
-	Price        float64        `json:"price" gorm:"not null"`
-	Destinations string         `json:"destinations"` // Contoh destinasi (opsional)
+	Price        float64        `json:"price" gorm:"not null"` // Tarif dasar (base fare) untuk band ini
+	PerKm        float64        `json:"per_km" gorm:"default:0"`
+	PerMinute    float64        `json:"per_minute" gorm:"default:0"`
+	MinimumFare  float64        `json:"minimum_fare" gorm:"default:0"`
+	Destinations string         `json:"destinations"` // Contoh destinasi (opsional); paket tour ada di Packages
 	IsActive     bool           `json:"is_active" gorm:"default:true"`
 	IsGojek      bool           `json:"is_gojek" gorm:"default:false"`
 	IsSubsidi    bool           `json:"is_subsidi" gorm:"default:false"`
@@ -22,7 +25,8 @@ type Tariff struct {
 	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	Orders []Order `json:"orders,omitempty" gorm:"foreignKey:TariffID;references:ID"`
+	Orders   []Order       `json:"orders,omitempty" gorm:"foreignKey:TariffID;references:ID"`
+	Packages []TourPackage `json:"packages,omitempty" gorm:"foreignKey:TariffID;references:ID"`
 }
 
 func (t *Tariff) TableName() string {
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PackageType string
+
+const (
+	PackageTypeTour   PackageType = "tour"   // Paket wisata dengan rute dan durasi tetap
+	PackageTypeHourly PackageType = "hourly" // Sewa per jam
+)
+
+// PackageStop is one stop of a tour package itinerary
+type PackageStop struct {
+	Name        string   `json:"name"`
+	Lat         *float64 `json:"lat"`
+	Lng         *float64 `json:"lng"`
+	WaitMinutes int      `json:"wait_minutes"`
+}
+
+// TourPackage is a fixed-price tour or an hourly rental sold by operators.
+// Orders for a package are priced by the package instead of the tariff bands;
+// TariffID only carries the fare program (subsidi, non-tunai, ...).
+type TourPackage struct {
+	ID                   uint           `json:"id" gorm:"primaryKey"`
+	TariffID             uint           `json:"tariff_id" gorm:"not null;index"`
+	Code                 string         `json:"code" gorm:"size:40;uniqueIndex;not null"`
+	Name                 string         `json:"name" gorm:"not null"`
+	Description          string         `json:"description" gorm:"type:text"`
+	Type                 PackageType    `json:"type" gorm:"size:20;not null;default:'tour'"`
+	Itinerary            []PackageStop  `json:"itinerary" gorm:"type:json;serializer:json"`
+	DurationMinutes      int            `json:"duration_minutes" gorm:"not null"` // Durasi paket tour, atau minimal sewa untuk hourly
+	MaxDurationMinutes   int            `json:"max_duration_minutes"`             // Maksimal sewa untuk hourly, 0 tanpa batas
+	VehicleTypes         []VehicleType  `json:"vehicle_types" gorm:"type:json;serializer:json"`
+	Price                float64        `json:"price" gorm:"not null"` // Harga paket tour, atau harga per jam untuk hourly
+	OvertimePerHour      float64        `json:"overtime_per_hour" gorm:"default:0"`
+	OvertimeBlockMinutes int            `json:"overtime_block_minutes" gorm:"default:15"` // Overtime ditagih per blok ini
+	OvertimeGraceMinutes int            `json:"overtime_grace_minutes" gorm:"default:10"`
+	Capacity             int            `json:"capacity" gorm:"default:2"`                     // Penumpang per kendaraan
+	MaxConcurrent        int            `json:"max_concurrent" gorm:"default:0"`               // Kendaraan yang bisa menjalankan paket bersamaan, 0 tanpa batas
+	AvailableFrom        string         `json:"available_from" gorm:"size:5;default:'08:00'"`  // Jam mulai paling awal (WIB)
+	AvailableUntil       string         `json:"available_until" gorm:"size:5;default:'21:00'"` // Paket harus selesai sebelum jam ini (WIB)
+	IsActive             bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt            time.Time      `json:"created_at"`
+	UpdatedAt            time.Time      `json:"updated_at"`
+	DeletedAt            gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relationships
+	Tariff *Tariff `json:"tariff,omitempty" gorm:"foreignKey:TariffID;references:ID"`
+}
+
+func (p *TourPackage) TableName() string {
+	return "tour_packages"
+}
+
+// SupportsVehicle reports whether the package can be run with the vehicle type
+func (p *TourPackage) SupportsVehicle(vehicle VehicleType) bool {
+	for _, supported := range p.VehicleTypes {
+		if supported == vehicle {
+			return true
+		}
+	}
+	return false
+}
@@ -18,6 +18,7 @@ const (
 type Withdrawal struct {
 	ID            uint             `json:"id" gorm:"primaryKey"`
 	DriverID      uint             `json:"driver_id"`
+	Reference     string           `json:"reference" gorm:"size:32;index"` // Nomor withdrawal WD-YYYYMMDD-NNNNNNC
 	Amount        float64          `json:"amount" gorm:"not null"`
 	Status        WithdrawalStatus `json:"status" gorm:"type:enum('pending','approved','rejected','completed');default:'pending'"`
 	BankName      string           `json:"bank_name"`
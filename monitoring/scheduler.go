@@ -1,8 +1,13 @@
 package monitoring
 
 import (
+	"context"
 	"log"
 	"time"
+
+	"greenbecak-backend/database"
+	"greenbecak-backend/models"
+	"greenbecak-backend/services"
 )
 
 type Scheduler struct {
@@ -59,6 +64,244 @@ func StartAlertCleanupScheduler(interval time.Duration) {
 	}()
 }
 
+// StartLocationHistoryPruneScheduler periodically deletes location pings past the retention period
+func StartLocationHistoryPruneScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("Location history prune scheduler started with %v interval", interval)
+
+		for {
+			select {
+			case <-ticker.C:
+				db := database.GetDB()
+				if db == nil {
+					continue
+				}
+				cutoff := time.Now().Add(-services.LocationHistoryRetention())
+				deleted, err := services.PruneLocationPings(db, cutoff)
+				if err != nil {
+					log.Printf("Failed to prune location history: %v", err)
+				} else if deleted > 0 {
+					log.Printf("Pruned %d location pings older than %s", deleted, cutoff.Format(time.RFC3339))
+				}
+			case <-scheduler.stopChan:
+				log.Println("Location history prune scheduler stopped")
+				return
+			}
+		}
+	}()
+}
+
+// StartIdempotencyPruneScheduler periodically deletes expired idempotency keys
+func StartIdempotencyPruneScheduler(interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Idempotency prune scheduler disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("Idempotency prune scheduler started with %v interval", interval)
+
+		for {
+			select {
+			case <-ticker.C:
+				db := database.GetDB()
+				if db == nil {
+					continue
+				}
+				if deleted, err := services.PruneIdempotencyRecords(db, time.Now()); err != nil {
+					log.Printf("Failed to prune idempotency records: %v", err)
+				} else if deleted > 0 {
+					log.Printf("Pruned %d expired idempotency records", deleted)
+				}
+			case <-scheduler.stopChan:
+				log.Println("Idempotency prune scheduler stopped")
+				return
+			}
+		}
+	}()
+}
+
+// StartOrderExpiryScheduler periodically expires stale pending and never-started orders
+func StartOrderExpiryScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("Order expiry scheduler started with %v interval", interval)
+
+		for {
+			select {
+			case <-ticker.C:
+				db := database.GetDB()
+				if db == nil {
+					continue
+				}
+				expired, err := services.ExpireStaleOrders(db, services.LoadOrderExpiryConfig(), time.Now())
+				if err != nil {
+					log.Printf("Failed to expire stale orders: %v", err)
+				}
+				for _, order := range expired {
+					services.NotifyOrderExpired(db, order)
+				}
+				if len(expired) > 0 {
+					log.Printf("Expired %d stale orders", len(expired))
+				}
+			case <-scheduler.stopChan:
+				log.Println("Order expiry scheduler stopped")
+				return
+			}
+		}
+	}()
+}
+
+// StartScheduledOrderScheduler sends booking reminders and, at the dispatch lead
+// time, hands bookings to their committed driver or to dispatch
+func StartScheduledOrderScheduler(interval time.Duration, dispatch func(models.Order)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("Scheduled order scheduler started with %v interval", interval)
+
+		for {
+			select {
+			case <-ticker.C:
+				db := database.GetDB()
+				if db == nil {
+					continue
+				}
+				config := services.LoadScheduleConfig()
+				now := time.Now()
+
+				reminders, err := services.ClaimBookingReminders(db, config, now)
+				if err != nil {
+					log.Printf("Failed to claim booking reminders: %v", err)
+				}
+				for _, order := range reminders {
+					services.NotifyBookingReminder(db, order)
+				}
+
+				due, err := services.ClaimDueBookings(db, config, now)
+				if err != nil {
+					log.Printf("Failed to claim due bookings: %v", err)
+				}
+				for i := range due {
+					order := due[i]
+					assigned, err := services.AssignCommittedDriver(db, &order)
+					if err != nil {
+						log.Printf("Failed to assign committed driver for booking %d: %v", order.ID, err)
+						// Lepas klaim agar booking dicoba lagi pada tick berikutnya
+						if err := services.ReleaseDueBooking(db, &order); err != nil {
+							log.Printf("Failed to release booking %d: %v", order.ID, err)
+						}
+						continue
+					}
+					if assigned {
+						services.PublishOrderStatus(&order)
+						continue
+					}
+					go dispatch(order)
+				}
+				if len(due) > 0 {
+					log.Printf("Released %d scheduled bookings", len(due))
+				}
+			case <-scheduler.stopChan:
+				log.Println("Scheduled order scheduler stopped")
+				return
+			}
+		}
+	}()
+}
+
+// StartReconciliationScheduler reconciles the previous day's gateway payments once per day;
+// without a real gateway there is no settlement to compare against
+func StartReconciliationScheduler(interval time.Duration) {
+	gateway, err := services.LoadPaymentGateway(services.LoadPaymentGatewayConfig())
+	if err != nil {
+		log.Printf("Reconciliation scheduler disabled: %v", err)
+		return
+	}
+	if gateway.Name() == services.PaymentGatewayFake || gateway.Name() == services.PaymentGatewayNone {
+		log.Printf("Reconciliation scheduler disabled for the %s payment gateway", gateway.Name())
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("Reconciliation scheduler started with %v interval", interval)
+
+		for {
+			select {
+			case <-ticker.C:
+				db := database.GetDB()
+				if db == nil {
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+				run, ran, err := services.ReconcileYesterday(ctx, db, gateway, time.Now())
+				cancel()
+				if err != nil {
+					log.Printf("Failed to reconcile payments: %v", err)
+				} else if ran {
+					log.Printf("Reconciliation run %d: %d matched, %d exceptions", run.ID, run.Matched, run.Exceptions)
+				}
+			case <-scheduler.stopChan:
+				log.Println("Reconciliation scheduler stopped")
+				return
+			}
+		}
+	}()
+}
+
+// StartRefundRetryScheduler periodically finishes gateway refunds left pending;
+// the fake gateway keeps its refunds in memory, so there is nothing to retry
+func StartRefundRetryScheduler(interval time.Duration) {
+	gateway, err := services.LoadPaymentGateway(services.LoadPaymentGatewayConfig())
+	if err != nil {
+		log.Printf("Refund retry scheduler disabled: %v", err)
+		return
+	}
+	if gateway.Name() == services.PaymentGatewayFake || gateway.Name() == services.PaymentGatewayNone {
+		log.Printf("Refund retry scheduler disabled for the %s payment gateway", gateway.Name())
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("Refund retry scheduler started with %v interval", interval)
+
+		for {
+			select {
+			case <-ticker.C:
+				db := database.GetDB()
+				if db == nil {
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+				completed, err := services.RetryPendingRefunds(ctx, db, gateway, time.Now())
+				cancel()
+				if err != nil {
+					log.Printf("Failed to retry pending refunds: %v", err)
+				} else if completed > 0 {
+					log.Printf("Completed %d pending refunds", completed)
+				}
+			case <-scheduler.stopChan:
+				log.Println("Refund retry scheduler stopped")
+				return
+			}
+		}
+	}()
+}
+
 // StartAllSchedulers starts all monitoring schedulers
 func StartAllSchedulers() {
 	// Start health check scheduler (every 30 seconds)
@@ -66,6 +309,21 @@ func StartAllSchedulers() {
 	
 	// Start alert cleanup scheduler (every hour)
 	StartAlertCleanupScheduler(1 * time.Hour)
+
+	// Start location history prune scheduler (every 6 hours)
+	StartLocationHistoryPruneScheduler(6 * time.Hour)
+
+	// Start idempotency key prune scheduler (IDEMPOTENCY_PRUNE_INTERVAL, default every hour)
+	StartIdempotencyPruneScheduler(services.IdempotencyPruneInterval())
+
+	// Start order expiry scheduler (every minute)
+	StartOrderExpiryScheduler(1 * time.Minute)
+
+	// Start payment reconciliation scheduler (every hour, runs once per day)
+	StartReconciliationScheduler(1 * time.Hour)
+
+	// Start refund retry scheduler (every 5 minutes)
+	StartRefundRetryScheduler(5 * time.Minute)
 	
 	log.Println("All monitoring schedulers started")
 }
@@ -0,0 +1,477 @@
+// Package qrcode encodes byte payloads (such as QRIS strings) as QR Code
+// symbols following ISO/IEC 18004 and renders them as PNG or SVG. Only byte
+// mode is supported, which is what EMVCo merchant-presented payloads use.
+package qrcode
+
+import (
+	"errors"
+)
+
+// ErrDataTooLong is returned when the data does not fit a version 40 symbol
+var ErrDataTooLong = errors.New("data too long for a QR code")
+
+// Level is the error correction level of a symbol
+type Level int
+
+const (
+	LevelL Level = iota // ~7% of codewords can be restored
+	LevelM              // ~15%
+	LevelQ              // ~25%
+	LevelH              // ~30%
+)
+
+// formatBits are the two error correction bits in the format information
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+// eccCodewordsPerBlock and numErrorCorrectionBlocks are indexed [level][version]
+var eccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var numErrorCorrectionBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// Code is an encoded QR Code symbol
+type Code struct {
+	Version int
+	Level   Level
+	Mask    int
+	Size    int
+
+	modules    [][]bool // [y][x], true is dark
+	isFunction [][]bool
+}
+
+// Dark reports whether the module at column x, row y is dark. Coordinates
+// outside the symbol (the quiet zone) are light.
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && x < c.Size && y >= 0 && y < c.Size && c.modules[y][x]
+}
+
+// Encode encodes data in byte mode at the given error correction level using
+// the smallest version that fits
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < LevelL || level > LevelH {
+		return nil, errors.New("invalid error correction level")
+	}
+
+	version := 0
+	for v := minVersion; v <= maxVersion; v++ {
+		if 4+charCountBits(v)+8*len(data) <= numDataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrDataTooLong
+	}
+
+	var bb bitBuffer
+	bb.append(0x4, 4) // Byte mode
+	bb.append(len(data), charCountBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	capacity := numDataCodewords(version, level) * 8
+	terminator := capacity - len(bb)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bb.append(0, terminator)
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+
+	code := newCode(version, level)
+	code.drawFunctionPatterns()
+	code.drawCodewords(addEccAndInterleave(codewords, version, level))
+	code.chooseMask()
+	return code, nil
+}
+
+func newCode(version int, level Level) *Code {
+	size := version*4 + 17
+	code := &Code{Version: version, Level: level, Size: size}
+	code.modules = make([][]bool, size)
+	code.isFunction = make([][]bool, size)
+	for i := range code.modules {
+		code.modules[i] = make([]bool, size)
+		code.isFunction[i] = make([]bool, size)
+	}
+	return code
+}
+
+// charCountBits is the length of the byte mode character count field
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// numRawDataModules is the number of modules available for data and error
+// correction after the function patterns are drawn
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*numErrorCorrectionBlocks[level][version]
+}
+
+// addEccAndInterleave splits the data into blocks, appends Reed-Solomon error
+// correction to each and interleaves the result
+func addEccAndInterleave(data []byte, version int, level Level) []byte {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	blockEccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockEccLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		datLen := shortBlockLen - blockEccLen
+		if i >= numShortBlocks {
+			datLen++
+		}
+		block := append([]byte(nil), data[k:k+datLen]...)
+		k += datLen
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			block = append(block, 0) // Placeholder so every block has the same length
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunction[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	positions := alignmentPatternPositions(c.Version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // Overlaps a finder pattern
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	c.drawFormatBits(0) // Reserved now, written once the mask is chosen
+	c.drawVersion()
+}
+
+// drawFinder draws a finder pattern and its separator centred at x, y
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.Size || yy < 0 || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// alignmentPatternPositions returns the row/column centres of the alignment patterns
+func alignmentPatternPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// formatInformation returns the 15 BCH-protected format bits for level and mask
+func formatInformation(level Level, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatInformation(c.Level, mask)
+
+	// Copy around the top-left finder
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// Copy split between the top-right and bottom-left finders
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.Size-8, true) // Always dark
+}
+
+// versionInformation returns the 18 BCH-protected version bits (version 7 and up)
+func versionInformation(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionInformation(c.Version)
+	for i := 0; i < 18; i++ {
+		a := c.Size - 11 + i%3
+		b := i / 3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords places the codewords in the zigzag order, skipping function modules
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Skip the vertical timing pattern
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert // Upward column
+				}
+				if !c.isFunction[y][x] && i < len(data)*8 {
+					c.modules[y][x] = bit(int(data[i>>3]), 7-(i&7))
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask XORs the data modules with mask pattern; applying it twice undoes it
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.isFunction[y][x] && maskBit(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// chooseMask applies the mask pattern with the lowest penalty score
+func (c *Code) chooseMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask)
+	}
+	c.Mask = best
+	c.applyMask(best)
+	c.drawFormatBits(best)
+}
+
+// penalty scores the symbol by the four rules of ISO/IEC 18004 section 7.8.3
+func (c *Code) penalty() int {
+	score := 0
+	size := c.Size
+
+	// Runs of five or more same-coloured modules, and finder-like patterns
+	for i := 0; i < size; i++ {
+		score += linePenalty(size, func(j int) bool { return c.modules[i][j] })
+		score += linePenalty(size, func(j int) bool { return c.modules[j][i] })
+	}
+
+	// 2x2 blocks of one colour
+	for y := 0; y < size-1; y++ {
+		for x := 0; x < size-1; x++ {
+			color := c.modules[y][x]
+			if color == c.modules[y][x+1] && color == c.modules[y+1][x] && color == c.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+
+	// Balance of dark and light modules
+	dark := 0
+	for _, row := range c.modules {
+		for _, module := range row {
+			if module {
+				dark++
+			}
+		}
+	}
+	total := size * size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	score += k * 10
+	return score
+}
+
+var finderLike = []bool{true, false, true, true, true, false, true}
+
+func linePenalty(size int, at func(int) bool) int {
+	score := 0
+	run := 1
+	for j := 1; j <= size; j++ {
+		if j < size && at(j) == at(j-1) {
+			run++
+			continue
+		}
+		if run >= 5 {
+			score += 3 + run - 5
+		}
+		run = 1
+	}
+
+	light := func(j int) bool { return j < 0 || j >= size || !at(j) }
+	for j := 0; j+len(finderLike) <= size; j++ {
+		match := true
+		for k, dark := range finderLike {
+			if at(j+k) != dark {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		before, after := true, true
+		for k := 1; k <= 4; k++ {
+			before = before && light(j-k)
+			after = after && light(j+len(finderLike)-1+k)
+		}
+		if before || after {
+			score += 40
+		}
+	}
+	return score
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*bb = append(*bb, (value>>uint(i))&1 != 0)
+	}
+}
+
+func bit(value, i int) bool {
+	return (value>>uint(i))&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
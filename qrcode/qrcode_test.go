@@ -0,0 +1,208 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" as 1-Q from the ISO/IEC 18004 worked example
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ecc)
+}
+
+func TestFormatAndVersionInformation(t *testing.T) {
+	assert.Equal(t, 0b111011111000100, formatInformation(LevelL, 0))
+	assert.Equal(t, 0b101010000010010, formatInformation(LevelM, 0))
+	assert.Equal(t, 0b011010101011111, formatInformation(LevelQ, 0))
+	assert.Equal(t, 0b001011010001001, formatInformation(LevelH, 0))
+	assert.Equal(t, 0b100000011001110, formatInformation(LevelM, 5))
+
+	assert.Equal(t, 0b000111110010010100, versionInformation(7))
+	assert.Equal(t, 0b101000110001101001, versionInformation(40))
+}
+
+func TestAlignmentPatternPositions(t *testing.T) {
+	assert.Nil(t, alignmentPatternPositions(1))
+	assert.Equal(t, []int{6, 18}, alignmentPatternPositions(2))
+	assert.Equal(t, []int{6, 22, 38}, alignmentPatternPositions(7))
+	assert.Equal(t, []int{6, 34, 60, 86, 112, 138}, alignmentPatternPositions(32))
+	assert.Equal(t, []int{6, 30, 58, 86, 114, 142, 170}, alignmentPatternPositions(40))
+}
+
+func TestCapacityTables(t *testing.T) {
+	// Byte mode capacities from the ISO/IEC 18004 tables
+	assert.Equal(t, 17, byteCapacity(1, LevelL))
+	assert.Equal(t, 14, byteCapacity(1, LevelM))
+	assert.Equal(t, 11, byteCapacity(1, LevelQ))
+	assert.Equal(t, 7, byteCapacity(1, LevelH))
+	assert.Equal(t, 213, byteCapacity(10, LevelM))
+	assert.Equal(t, 2953, byteCapacity(40, LevelL))
+	assert.Equal(t, 2331, byteCapacity(40, LevelM))
+	assert.Equal(t, 1663, byteCapacity(40, LevelQ))
+	assert.Equal(t, 1273, byteCapacity(40, LevelH))
+
+	for level := LevelL; level <= LevelH; level++ {
+		for version := minVersion; version <= maxVersion; version++ {
+			blocks := numErrorCorrectionBlocks[level][version]
+			raw := numRawDataModules(version) / 8
+			assert.Greater(t, raw/blocks, eccCodewordsPerBlock[level][version], "version %d level %d", version, level)
+		}
+	}
+}
+
+func byteCapacity(version int, level Level) int {
+	return (numDataCodewords(version, level)*8 - 4 - charCountBits(version)) / 8
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	payloads := []string{
+		"greenbecak",
+		strings.Repeat("QRIS", 40),
+		"00020101021226610016ID.CO.GREENBECAK0118936009150000000001021000000000010303UMI51440014ID.CO.QRIS.WWW0215ID10264419876540303UMI520441215303360540525000580" +
+			"2ID5913GREEN BECAK JOGJA6010YOGYAKARTA61055511162470119PY-20261017-00000190519PY-20261017-00000196304ABCD",
+		strings.Repeat("x", 1000),
+	}
+
+	for _, payload := range payloads {
+		for level := LevelL; level <= LevelH; level++ {
+			code, err := Encode([]byte(payload), level)
+			assert.NoError(t, err)
+			assert.Equal(t, code.Version*4+17, code.Size)
+			assert.Equal(t, payload, decodeForTest(t, code), "version %d level %d", code.Version, level)
+		}
+	}
+
+	_, err := Encode(bytes.Repeat([]byte("x"), 2954), LevelL)
+	assert.ErrorIs(t, err, ErrDataTooLong)
+}
+
+func TestRender(t *testing.T) {
+	code, err := Encode([]byte("PY-20261017-0000019"), LevelM)
+	assert.NoError(t, err)
+
+	data, err := code.PNG(4)
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	side := (code.Size + 2*QuietZone) * 4
+	assert.Equal(t, side, img.Bounds().Dx())
+
+	// The top-left finder is dark at its corner and the quiet zone is light
+	r, _, _, _ := img.At(QuietZone*4, QuietZone*4).RGBA()
+	assert.Equal(t, uint32(0), r)
+	r, _, _, _ = img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+
+	svg := code.SVG()
+	assert.True(t, strings.HasPrefix(svg, "<?xml"))
+	assert.Contains(t, svg, fmt.Sprintf(`viewBox="0 0 %d %d"`, code.Size+2*QuietZone, code.Size+2*QuietZone))
+	assert.Contains(t, svg, "M4,4h1v1h-1z")
+}
+
+// decodeForTest reads a symbol back: format information, unmasking, codeword
+// order, de-interleaving and the byte mode segment. It also checks every
+// block's error correction.
+func decodeForTest(t *testing.T, code *Code) string {
+	t.Helper()
+
+	format := 0
+	for i := 0; i <= 5; i++ {
+		format |= b2i(code.modules[i][8]) << i
+	}
+	format |= b2i(code.modules[7][8]) << 6
+	format |= b2i(code.modules[8][8]) << 7
+	format |= b2i(code.modules[8][7]) << 8
+	for i := 9; i < 15; i++ {
+		format |= b2i(code.modules[8][14-i]) << i
+	}
+	assert.Equal(t, formatInformation(code.Level, code.Mask), format)
+
+	masked := newCode(code.Version, code.Level)
+	masked.drawFunctionPatterns()
+	for y := range code.modules {
+		copy(masked.modules[y], code.modules[y])
+	}
+	masked.applyMask(code.Mask)
+
+	var bits []bool
+	for right := masked.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < masked.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = masked.Size - 1 - vert
+				}
+				if !masked.isFunction[y][x] {
+					bits = append(bits, masked.modules[y][x])
+				}
+			}
+		}
+	}
+	raw := make([]byte, numRawDataModules(code.Version)/8)
+	for i := range raw {
+		for j := 0; j < 8; j++ {
+			raw[i] = raw[i]<<1 | byte(b2i(bits[i*8+j]))
+		}
+	}
+
+	numBlocks := numErrorCorrectionBlocks[code.Level][code.Version]
+	eccLen := eccCodewordsPerBlock[code.Level][code.Version]
+	numShort := numBlocks - len(raw)%numBlocks
+	shortData := len(raw)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortData; i++ {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for j, block := range blocks {
+		var ecc []byte
+		for i := 0; i < eccLen; i++ {
+			ecc = append(ecc, raw[k+i*numBlocks+j])
+		}
+		assert.Equal(t, reedSolomonRemainder(block, reedSolomonDivisor(eccLen)), ecc, "block %d", j)
+		data = append(data, block...)
+	}
+
+	var stream bitBuffer
+	for _, b := range data {
+		stream.append(int(b), 8)
+	}
+	read := func(n int) int {
+		value := 0
+		for i := 0; i < n; i++ {
+			value = value<<1 | b2i(stream[0])
+			stream = stream[1:]
+		}
+		return value
+	}
+	assert.Equal(t, 0x4, read(4))
+	length := read(charCountBits(code.Version))
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = byte(read(8))
+	}
+	return string(out)
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
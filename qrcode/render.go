@@ -0,0 +1,56 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// QuietZone is the light border, in modules, required around a symbol
+const QuietZone = 4
+
+// PNG renders the symbol with scale pixels per module and the standard quiet zone
+func (c *Code) PNG(scale int) ([]byte, error) {
+	if scale < 1 {
+		scale = 1
+	}
+	side := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for py := 0; py < side; py++ {
+		y := py/scale - QuietZone
+		for px := 0; px < side; px++ {
+			if c.Dark(px/scale-QuietZone, y) {
+				img.SetColorIndex(px, py, 1)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the symbol as a scalable SVG document, one unit per module
+func (c *Code) SVG() string {
+	side := c.Size + 2*QuietZone
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+QuietZone, y+QuietZone)
+			}
+		}
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" version="1.1" viewBox="0 0 %d %d" shape-rendering="crispEdges">
+<rect width="100%%" height="100%%" fill="#FFFFFF"/>
+<path d="%s" fill="#000000"/>
+</svg>
+`, side, side, path.String())
+}
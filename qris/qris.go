@@ -0,0 +1,315 @@
+// Package qris builds and parses QRIS merchant-presented payloads (EMVCo QR
+// Code Specification for Payment Systems with the Bank Indonesia QRIS
+// profile). A payload is a sequence of ID-length-value fields ending with a
+// CRC-16/CCITT-FALSE checksum in field 63.
+package qris
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMalformed       = errors.New("malformed QRIS payload")
+	ErrInvalidChecksum = errors.New("QRIS checksum mismatch")
+	ErrInvalidPayload  = errors.New("invalid QRIS payload")
+)
+
+// Top level field IDs
+const (
+	TagPayloadFormat        = "00"
+	TagPointOfInitiation    = "01"
+	TagMerchantCategoryCode = "52"
+	TagCurrency             = "53"
+	TagAmount               = "54"
+	TagCountryCode          = "58"
+	TagMerchantName         = "59"
+	TagMerchantCity         = "60"
+	TagPostalCode           = "61"
+	TagAdditionalData       = "62"
+	TagCRC                  = "63"
+)
+
+// Additional data field (62) sub IDs
+const (
+	additionalBillNumber     = "01"
+	additionalReferenceLabel = "05"
+	additionalTerminalLabel  = "07"
+)
+
+// Merchant account information (26-51) sub IDs
+const (
+	accountGUID       = "00"
+	accountPAN        = "01"
+	accountMerchantID = "02"
+	accountCriteria   = "03"
+)
+
+const (
+	pointOfInitiationStatic  = "11"
+	pointOfInitiationDynamic = "12"
+
+	// CurrencyIDR is the ISO 4217 numeric code for rupiah
+	CurrencyIDR = "360"
+	// GUIDQRIS identifies the national QRIS merchant account (field 51)
+	GUIDQRIS = "ID.CO.QRIS.WWW"
+)
+
+// MerchantAccount is one merchant account information template (IDs 26-51)
+type MerchantAccount struct {
+	Tag        string `json:"tag"`         // "26".."51"
+	GUID       string `json:"guid"`        // Reverse domain acquirer, mis. ID.CO.QRIS.WWW
+	PAN        string `json:"pan"`         // Merchant PAN (acquirer)
+	MerchantID string `json:"merchant_id"` // Merchant ID atau NMID
+	Criteria   string `json:"criteria"`    // UMI, UKE, UME, UBE, URE
+}
+
+// Payload is a decoded QRIS payload
+type Payload struct {
+	Dynamic              bool              `json:"dynamic"`
+	MerchantAccounts     []MerchantAccount `json:"merchant_accounts"`
+	MerchantCategoryCode string            `json:"merchant_category_code"`
+	Currency             string            `json:"currency"`
+	Amount               int64             `json:"amount"` // Rupiah, 0 jika customer mengisi sendiri
+	CountryCode          string            `json:"country_code"`
+	MerchantName         string            `json:"merchant_name"`
+	MerchantCity         string            `json:"merchant_city"`
+	PostalCode           string            `json:"postal_code"`
+	BillNumber           string            `json:"bill_number"`
+	ReferenceLabel       string            `json:"reference_label"`
+	TerminalLabel        string            `json:"terminal_label"`
+}
+
+// Build encodes p as a payload string with its checksum
+func Build(p Payload) (string, error) {
+	if err := validate(p); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	writeField(&b, TagPayloadFormat, "01")
+	if p.Dynamic {
+		writeField(&b, TagPointOfInitiation, pointOfInitiationDynamic)
+	} else {
+		writeField(&b, TagPointOfInitiation, pointOfInitiationStatic)
+	}
+
+	accounts := append([]MerchantAccount(nil), p.MerchantAccounts...)
+	sort.SliceStable(accounts, func(i, j int) bool { return accounts[i].Tag < accounts[j].Tag })
+	for _, account := range accounts {
+		var t strings.Builder
+		writeField(&t, accountGUID, account.GUID)
+		writeField(&t, accountPAN, account.PAN)
+		writeField(&t, accountMerchantID, account.MerchantID)
+		writeField(&t, accountCriteria, account.Criteria)
+		writeField(&b, account.Tag, t.String())
+	}
+
+	writeField(&b, TagMerchantCategoryCode, p.MerchantCategoryCode)
+	writeField(&b, TagCurrency, p.Currency)
+	if p.Amount > 0 {
+		writeField(&b, TagAmount, strconv.FormatInt(p.Amount, 10))
+	}
+	writeField(&b, TagCountryCode, p.CountryCode)
+	writeField(&b, TagMerchantName, p.MerchantName)
+	writeField(&b, TagMerchantCity, p.MerchantCity)
+	writeField(&b, TagPostalCode, p.PostalCode)
+
+	var additional strings.Builder
+	writeField(&additional, additionalBillNumber, p.BillNumber)
+	writeField(&additional, additionalReferenceLabel, p.ReferenceLabel)
+	writeField(&additional, additionalTerminalLabel, p.TerminalLabel)
+	writeField(&b, TagAdditionalData, additional.String())
+
+	b.WriteString(TagCRC + "04")
+	return b.String() + checksum(b.String()), nil
+}
+
+// Parse decodes a payload string, verifying its checksum
+func Parse(s string) (Payload, error) {
+	var p Payload
+	if len(s) < 8 || s[len(s)-8:len(s)-4] != TagCRC+"04" {
+		return p, fmt.Errorf("%w: missing CRC field", ErrMalformed)
+	}
+	if !strings.EqualFold(checksum(s[:len(s)-4]), s[len(s)-4:]) {
+		return p, ErrInvalidChecksum
+	}
+
+	fields, err := parseFields(s)
+	if err != nil {
+		return p, err
+	}
+	if fieldValue(fields, TagPayloadFormat) != "01" {
+		return p, fmt.Errorf("%w: unsupported payload format", ErrInvalidPayload)
+	}
+
+	for _, f := range fields {
+		switch {
+		case f.tag == TagPointOfInitiation:
+			p.Dynamic = f.value == pointOfInitiationDynamic
+		case f.tag >= "26" && f.tag <= "51":
+			sub, err := parseFields(f.value)
+			if err != nil {
+				return p, err
+			}
+			p.MerchantAccounts = append(p.MerchantAccounts, MerchantAccount{
+				Tag:        f.tag,
+				GUID:       fieldValue(sub, accountGUID),
+				PAN:        fieldValue(sub, accountPAN),
+				MerchantID: fieldValue(sub, accountMerchantID),
+				Criteria:   fieldValue(sub, accountCriteria),
+			})
+		case f.tag == TagMerchantCategoryCode:
+			p.MerchantCategoryCode = f.value
+		case f.tag == TagCurrency:
+			p.Currency = f.value
+		case f.tag == TagAmount:
+			amount, err := parseAmount(f.value)
+			if err != nil {
+				return p, err
+			}
+			p.Amount = amount
+		case f.tag == TagCountryCode:
+			p.CountryCode = f.value
+		case f.tag == TagMerchantName:
+			p.MerchantName = f.value
+		case f.tag == TagMerchantCity:
+			p.MerchantCity = f.value
+		case f.tag == TagPostalCode:
+			p.PostalCode = f.value
+		case f.tag == TagAdditionalData:
+			sub, err := parseFields(f.value)
+			if err != nil {
+				return p, err
+			}
+			p.BillNumber = fieldValue(sub, additionalBillNumber)
+			p.ReferenceLabel = fieldValue(sub, additionalReferenceLabel)
+			p.TerminalLabel = fieldValue(sub, additionalTerminalLabel)
+		}
+	}
+	return p, nil
+}
+
+// MerchantAccount returns the merchant account template with the given GUID
+func (p Payload) MerchantAccount(guid string) (MerchantAccount, bool) {
+	for _, account := range p.MerchantAccounts {
+		if account.GUID == guid {
+			return account, true
+		}
+	}
+	return MerchantAccount{}, false
+}
+
+func validate(p Payload) error {
+	if len(p.MerchantAccounts) == 0 {
+		return fmt.Errorf("%w: at least one merchant account is required", ErrInvalidPayload)
+	}
+	for _, account := range p.MerchantAccounts {
+		if account.Tag < "26" || account.Tag > "51" || len(account.Tag) != 2 || account.GUID == "" {
+			return fmt.Errorf("%w: merchant account %q needs a tag 26-51 and a GUID", ErrInvalidPayload, account.Tag)
+		}
+	}
+	if len(p.MerchantCategoryCode) != 4 || len(p.Currency) != 3 || len(p.CountryCode) != 2 {
+		return fmt.Errorf("%w: merchant category code, currency and country code are required", ErrInvalidPayload)
+	}
+	if p.MerchantName == "" || len(p.MerchantName) > 25 || !isASCII(p.MerchantName) {
+		return fmt.Errorf("%w: merchant name must be 1-25 ASCII characters", ErrInvalidPayload)
+	}
+	if p.MerchantCity == "" || len(p.MerchantCity) > 15 || !isASCII(p.MerchantCity) {
+		return fmt.Errorf("%w: merchant city must be 1-15 ASCII characters", ErrInvalidPayload)
+	}
+	if p.Amount < 0 || len(strconv.FormatInt(p.Amount, 10)) > 13 {
+		return fmt.Errorf("%w: amount out of range", ErrInvalidPayload)
+	}
+	if p.Dynamic && p.Amount == 0 {
+		return fmt.Errorf("%w: a dynamic payload needs an amount", ErrInvalidPayload)
+	}
+	for _, label := range []string{p.BillNumber, p.ReferenceLabel, p.TerminalLabel} {
+		if len(label) > 25 {
+			return fmt.Errorf("%w: additional data values are limited to 25 characters", ErrInvalidPayload)
+		}
+	}
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// writeField appends an ID-length-value field, skipping empty values
+func writeField(b *strings.Builder, tag, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(b, "%s%02d%s", tag, len(value), value)
+}
+
+type field struct {
+	tag   string
+	value string
+}
+
+func parseFields(s string) ([]field, error) {
+	var fields []field
+	for i := 0; i < len(s); {
+		if i+4 > len(s) {
+			return nil, fmt.Errorf("%w: truncated field at %d", ErrMalformed, i)
+		}
+		tag := s[i : i+2]
+		length, err := strconv.Atoi(s[i+2 : i+4])
+		if err != nil || length < 0 || i+4+length > len(s) {
+			return nil, fmt.Errorf("%w: bad length for field %s", ErrMalformed, tag)
+		}
+		fields = append(fields, field{tag: tag, value: s[i+4 : i+4+length]})
+		i += 4 + length
+	}
+	return fields, nil
+}
+
+func fieldValue(fields []field, tag string) string {
+	for _, f := range fields {
+		if f.tag == tag {
+			return f.value
+		}
+	}
+	return ""
+}
+
+// parseAmount reads field 54; rupiah amounts may carry a ".00" fraction
+func parseAmount(value string) (int64, error) {
+	whole, fraction, _ := strings.Cut(value, ".")
+	amount, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || amount < 0 || strings.Trim(fraction, "0") != "" {
+		return 0, fmt.Errorf("%w: invalid amount %q", ErrInvalidPayload, value)
+	}
+	return amount, nil
+}
+
+// checksum returns the CRC-16/CCITT-FALSE of data as four uppercase hex digits
+func checksum(data string) string {
+	return fmt.Sprintf("%04X", CRC16([]byte(data)))
+}
+
+// CRC16 computes CRC-16/CCITT-FALSE (polynomial 0x1021, initial value 0xFFFF)
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,102 @@
+package qris
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPayload() Payload {
+	return Payload{
+		Dynamic: true,
+		MerchantAccounts: []MerchantAccount{
+			{Tag: "51", GUID: GUIDQRIS, MerchantID: "ID1026441987654", Criteria: "UMI"},
+			{Tag: "26", GUID: "ID.CO.BANKJOGJA.WWW", PAN: "9360011200000000019", MerchantID: "000000000001", Criteria: "UMI"},
+		},
+		MerchantCategoryCode: "4121",
+		Currency:             CurrencyIDR,
+		Amount:               25000,
+		CountryCode:          "ID",
+		MerchantName:         "GREEN BECAK JOGJA",
+		MerchantCity:         "YOGYAKARTA",
+		PostalCode:           "55111",
+		BillNumber:           "PY-20261017-0000019",
+		ReferenceLabel:       "PY-20261017-0000019",
+		TerminalLabel:        "GBK01",
+	}
+}
+
+func TestCRC16(t *testing.T) {
+	// CRC-16/CCITT-FALSE check value
+	assert.Equal(t, uint16(0x29B1), CRC16([]byte("123456789")))
+}
+
+func TestBuildAndParse(t *testing.T) {
+	payload, err := Build(testPayload())
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(payload, "000201010212"))
+	assert.Contains(t, payload, "5204412153033605405250005802ID")
+	assert.Contains(t, payload, "5917GREEN BECAK JOGJA6010YOGYAKARTA")
+	assert.Regexp(t, `6304[0-9A-F]{4}$`, payload)
+	assert.Less(t, strings.Index(payload, "26"+"55"), strings.Index(payload, "51"+"44"), "merchant accounts are written in tag order")
+
+	parsed, err := Parse(payload)
+	assert.NoError(t, err)
+	want := testPayload()
+	want.MerchantAccounts = []MerchantAccount{want.MerchantAccounts[1], want.MerchantAccounts[0]}
+	assert.Equal(t, want, parsed)
+
+	account, ok := parsed.MerchantAccount(GUIDQRIS)
+	assert.True(t, ok)
+	assert.Equal(t, "ID1026441987654", account.MerchantID)
+}
+
+func TestParseRejectsTampering(t *testing.T) {
+	payload, err := Build(testPayload())
+	assert.NoError(t, err)
+
+	tampered := strings.Replace(payload, "540525000", "540520000", 1)
+	_, err = Parse(tampered)
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+
+	// A lowercase checksum is still accepted
+	_, err = Parse(payload[:len(payload)-4] + strings.ToLower(payload[len(payload)-4:]))
+	assert.NoError(t, err)
+
+	_, err = Parse(payload[:len(payload)-8])
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	body := "00020101021252044121530336054059999"
+	_, err = Parse(body + "6304" + checksum(body+"6304"))
+	assert.ErrorIs(t, err, ErrMalformed, "the amount field claims more characters than remain")
+}
+
+func TestParseAmount(t *testing.T) {
+	body := "000201010211" + "5204412153033605409150000.0058" + "02ID"
+	parsed, err := Parse(body + "6304" + checksum(body+"6304"))
+	assert.NoError(t, err)
+	assert.False(t, parsed.Dynamic)
+	assert.Equal(t, int64(150000), parsed.Amount)
+
+	body = "0002010102115406150.505802ID"
+	_, err = Parse(body + "6304" + checksum(body+"6304"))
+	assert.ErrorIs(t, err, ErrInvalidPayload, "rupiah has no cents")
+}
+
+func TestBuildValidation(t *testing.T) {
+	p := testPayload()
+	p.Amount = 0
+	_, err := Build(p)
+	assert.ErrorIs(t, err, ErrInvalidPayload, "dynamic payloads carry an amount")
+
+	p = testPayload()
+	p.MerchantName = "BECAK WISATA MALIOBORO YOGYAKARTA"
+	_, err = Build(p)
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+
+	p = testPayload()
+	p.MerchantAccounts = []MerchantAccount{{Tag: "52", GUID: GUIDQRIS}}
+	_, err = Build(p)
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+}
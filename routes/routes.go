@@ -46,11 +46,30 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 			location.GET("/routes/:order_id", handlers.GetDriverRoute)
 		}
 
+		// Real-time streams (SSE); token may be passed as ?token= for EventSource clients
+		stream := api.Group("/")
+		stream.Use(middleware.StreamAuthMiddleware())
+		{
+			stream.GET("/orders/:id/stream", handlers.StreamOrderEvents)
+			stream.GET("/notifications/stream", handlers.StreamNotifications)
+			stream.GET("/admin/stream", middleware.AdminMiddleware(), handlers.StreamAdminEvents)
+			stream.GET("/driver/stream", middleware.DriverMiddleware(), handlers.StreamDriverEvents)
+		}
+
 		// Public order endpoints (no auth)
+		api.POST("/orders/quote", handlers.QuoteFare)
 		api.POST("/orders/public", handlers.CreateOrderPublic)
 		api.POST("/orders/public/:id/pay", handlers.ConfirmOrderPaymentPublic)
+		api.POST("/orders/public/:id/review", handlers.CreateOrderReviewPublic)
+		api.POST("/orders/public/:id/cancel", handlers.CancelOrderPublic)
+		api.GET("/orders/cancellation-reasons", handlers.GetCancellationReasons)
+		api.GET("/reviews/tags", handlers.GetReviewTags)
 		api.GET("/orders/history", handlers.GetOrderHistory)
 
+		// Payment gateway notifications (signed with PAYMENT_WEBHOOK_SECRET)
+		api.POST("/payments/webhook", handlers.PaymentWebhook)
+		api.POST("/payments/qris/notify", handlers.QRISSettlementWebhook)
+
 		// Public admin creation endpoint (no auth required)
 		api.POST("/admin/public", handlers.CreateAdminPublic)
 
@@ -58,6 +77,11 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		api.GET("/tariffs/public", handlers.GetTariffsPublic)
 		api.GET("/tariffs/public/:id", handlers.GetTariffPublic)
 
+		// Public tour package catalog (no auth required)
+		api.GET("/packages", handlers.GetTourPackages)
+		api.GET("/packages/:id", handlers.GetTourPackage)
+		api.GET("/packages/:id/availability", handlers.GetTourPackageAvailability)
+
 		// Public driver check endpoint
 		api.GET("/drivers/public/check/:code", handlers.CheckDriverByCodePublic)
 
@@ -86,6 +110,10 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				orders.GET("/:id", handlers.GetOrder)
 				orders.PUT("/:id", handlers.UpdateOrder)
 				orders.PUT("/:id/location", handlers.UpdateOrderLocation)
+				orders.GET("/:id/status-history", handlers.GetOrderStatusHistory)
+				orders.GET("/:id/track", handlers.GetOrderTrack)
+				orders.POST("/:id/review", handlers.CreateOrderReview)
+				orders.POST("/:id/cancel", handlers.CancelOrder)
 				orders.DELETE("/:id", handlers.DeleteOrder)
 			}
 
@@ -102,8 +130,9 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				payments.POST("/", handlers.CreatePayment)
 				payments.GET("/", handlers.GetPayments)
 				payments.GET("/:id", handlers.GetPayment)
-				payments.PUT("/:id/status", handlers.UpdatePaymentStatus)
 				payments.POST("/:id/process", handlers.ProcessPayment)
+				payments.GET("/:id/qris", handlers.GetPaymentQRIS)
+				payments.POST("/:id/qris", handlers.IssuePaymentQRIS)
 				payments.GET("/stats", handlers.GetPaymentStats)
 			}
 
@@ -143,6 +172,11 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				drivers.PUT("/:id", handlers.UpdateDriver)
 				drivers.DELETE("/:id", handlers.DeleteDriver)
 				drivers.GET("/:id/performance", handlers.GetDriverPerformance)
+				drivers.GET("/:id/location-history", handlers.GetDriverLocationHistory)
+				drivers.GET("/:id/wallet", handlers.GetDriverWallet)
+				drivers.POST("/:id/wallet/adjustments", handlers.CreateWalletAdjustment)
+				drivers.GET("/:id/receivable", handlers.GetDriverReceivable)
+				drivers.POST("/:id/receivable/settlements", handlers.SettleDriverReceivable)
 				drivers.GET("/financial-data", handlers.GetDriverFinancialData)
 			}
 
@@ -155,10 +189,27 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				tariffs.DELETE("/:id", handlers.DeleteTariff)
 			}
 
+			// Tour packages and hourly rentals
+			packages := admin.Group("/packages")
+			{
+				packages.GET("/", handlers.GetTourPackagesAdmin)
+				packages.POST("/", handlers.CreateTourPackage)
+				packages.PUT("/:id", handlers.UpdateTourPackage)
+				packages.DELETE("/:id", handlers.DeleteTourPackage)
+			}
+
+			// Dispatch
+			admin.GET("/orders/:id/dispatch-offers", handlers.GetOrderDispatchOffers)
+
+			// Review moderation
+			admin.GET("/reviews", handlers.GetReviews)
+			admin.PUT("/reviews/:id/moderate", handlers.ModerateReview)
+
 			// Analytics
 			admin.GET("/analytics", handlers.GetAnalytics)
 			admin.GET("/analytics/revenue", handlers.GetRevenueAnalytics)
 			admin.GET("/analytics/orders", handlers.GetOrderAnalytics)
+			admin.GET("/analytics/cancellations", handlers.GetCancellationAnalytics)
 
 			// Withdrawal management
 			withdrawals := admin.Group("/withdrawals")
@@ -169,6 +220,20 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				withdrawals.DELETE("/:id", handlers.DeleteWithdrawal)
 			}
 
+			// Driver wallet ledger
+			admin.GET("/ledger/accounts", handlers.GetLedgerAccounts)
+			admin.GET("/ledger/entries", handlers.GetJournalEntries)
+			admin.GET("/receivables", handlers.GetReceivables)
+
+			// Platform commission rules
+			commissionRules := admin.Group("/commission-rules")
+			{
+				commissionRules.GET("/", handlers.GetCommissionRules)
+				commissionRules.POST("/", handlers.CreateCommissionRule)
+				commissionRules.PUT("/:id", handlers.UpdateCommissionRule)
+				commissionRules.DELETE("/:id", handlers.DeleteCommissionRule)
+			}
+
 			// Payment management (admin only)
 			payments := admin.Group("/payments")
 			{
@@ -176,8 +241,24 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 				payments.GET("/:id", handlers.GetPayment)
 				payments.PUT("/:id/status", handlers.UpdatePaymentStatus)
 				payments.POST("/:id/process", handlers.ProcessPayment)
+				payments.GET("/:id/qris", handlers.GetPaymentQRIS)
+				payments.POST("/:id/qris", handlers.IssuePaymentQRIS)
+				payments.GET("/:id/refunds", handlers.GetPaymentRefunds)
+				payments.POST("/:id/refunds", handlers.CreatePaymentRefund)
 				payments.GET("/stats", handlers.GetPaymentStats)
 			}
+			admin.GET("/refunds", handlers.GetRefunds)
+			admin.POST("/refunds/:id/retry", handlers.RetryRefund)
+
+			// Payment reconciliation (admin only)
+			reconciliations := admin.Group("/reconciliations")
+			{
+				reconciliations.GET("/", handlers.GetReconciliationRuns)
+				reconciliations.POST("/", handlers.UploadSettlementFile)
+				reconciliations.POST("/gateway", handlers.RunGatewayReconciliation)
+				reconciliations.GET("/:id", handlers.GetReconciliationRun)
+				reconciliations.GET("/:id/exceptions.csv", handlers.ExportReconciliationExceptions)
+			}
 
 			// Notification management (admin only)
 			notifications := admin.Group("/notifications")
@@ -198,11 +279,25 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		driver.Use(middleware.AuthMiddleware(), middleware.DriverMiddleware())
 		{
 			driver.GET("/orders", handlers.GetDriverOrders)
+			driver.GET("/orders/available", handlers.GetAvailableOrders)
 			driver.PUT("/orders/:id/accept", handlers.AcceptOrder)
+			driver.PUT("/orders/:id/pickup", handlers.PickupOrder)
+			driver.PUT("/orders/:id/start", handlers.StartOrder)
+			driver.PUT("/orders/:id/decline", handlers.DeclineOrder)
 			driver.PUT("/orders/:id/complete", handlers.CompleteOrder)
+			driver.PUT("/orders/:id/cancel", handlers.CancelOrder)
+			driver.PUT("/orders/:id/stops/:sequence/arrive", handlers.ArriveAtStop)
+			driver.PUT("/orders/:id/stops/:sequence/depart", handlers.DepartFromStop)
+			driver.POST("/packages/:id/orders", handlers.CreateDriverPackageOrder)
+			driver.GET("/bookings/available", handlers.GetAvailableBookings)
+			driver.GET("/bookings/upcoming", handlers.GetUpcomingTrips)
+			driver.PUT("/bookings/:id/commit", handlers.CommitBooking)
+			driver.PUT("/bookings/:id/withdraw", handlers.WithdrawBooking)
 			driver.GET("/earnings", handlers.GetDriverEarnings)
 			driver.POST("/withdrawals", handlers.CreateWithdrawal)
 			driver.GET("/withdrawals", handlers.GetDriverWithdrawals)
+			driver.GET("/wallet", handlers.GetMyWallet)
+			driver.GET("/reviews", handlers.GetMyReviews)
 
 			// FCM Token management
 			driver.POST("/fcm-token", handlers.UpdateFCMToken)
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Cancellation Policy
+// ===================
+// Customer, driver dan admin membatalkan order lewat CancelOrder dengan kode alasan.
+// Aturan (CancellationRule) dicocokkan berurutan berdasarkan peran, tahap order
+// dan alasan; aturan pertama yang cocok menentukan biaya pembatalan, strike driver
+// dan apakah driver dikembalikan ke status active.
+
+// ErrInvalidCancelReason is returned when a reason code is not allowed for the actor
+var ErrInvalidCancelReason = errors.New("invalid cancellation reason")
+
+// EventOrderCancelled is pushed to the assigned driver's topic when their order is cancelled
+const EventOrderCancelled = "order_cancelled"
+
+// CancellationReasons lists the reason codes each actor role may use
+var CancellationReasons = map[string][]models.CancellationReason{
+	ActorRoleCustomer: {
+		models.CancelReasonChangedPlans,
+		models.CancelReasonDriverTooFar,
+		models.CancelReasonDriverLate,
+		models.CancelReasonWrongLocation,
+		models.CancelReasonFoundOtherRide,
+		models.CancelReasonPriceTooHigh,
+		models.CancelReasonOther,
+	},
+	ActorRoleDriver: {
+		models.CancelReasonCustomerNoShow,
+		models.CancelReasonCustomerUnreachable,
+		models.CancelReasonVehicleProblem,
+		models.CancelReasonPickupTooFar,
+		models.CancelReasonUnsafeRequest,
+		models.CancelReasonOther,
+	},
+	ActorRoleAdmin: {
+		models.CancelReasonFraud,
+		models.CancelReasonDuplicateOrder,
+		models.CancelReasonSupport,
+		models.CancelReasonOther,
+	},
+}
+
+// IsValidCancelReason reports whether role may cancel with reason
+func IsValidCancelReason(role string, reason models.CancellationReason) bool {
+	for _, allowed := range CancellationReasons[role] {
+		if allowed == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// CancellationRule decides the outcome of cancellations it matches.
+// Empty Stages or Reasons match any stage or reason.
+type CancellationRule struct {
+	Name      string                      `json:"name"`
+	ActorRole string                      `json:"actor_role"`
+	Stages    []models.CancellationStage  `json:"stages,omitempty"`
+	Reasons   []models.CancellationReason `json:"reasons,omitempty"`
+	// MinMinutes only matches once this long has passed since the driver
+	// accepted (or since the order was created, if no driver has accepted)
+	MinMinutes float64 `json:"min_minutes,omitempty"`
+	// WithinMinutesOfPickup only matches bookings cancelled less than this
+	// long before their scheduled pickup
+	WithinMinutesOfPickup float64 `json:"within_minutes_of_pickup,omitempty"`
+
+	Fee      float64 `json:"fee,omitempty"`      // Biaya tetap
+	FeeRate  float64 `json:"fee_rate,omitempty"` // Persentase dari harga order (0.5 = 50%)
+	FeePayer string  `json:"fee_payer,omitempty"`
+	Strike   bool    `json:"strike,omitempty"`
+	// SuspendDriver sets the driver inactive instead of releasing them to active
+	SuspendDriver bool `json:"suspend_driver,omitempty"`
+}
+
+// DefaultCancellationRules is used when CANCELLATION_RULES is not set
+var DefaultCancellationRules = []CancellationRule{
+	{
+		Name:                  "customer_scheduled_late_cancel",
+		ActorRole:             ActorRoleCustomer,
+		Stages:                []models.CancellationStage{models.CancelStageScheduled},
+		WithinMinutesOfPickup: 60,
+		Fee:                   5000,
+		FeePayer:              models.FeePayerCustomer,
+	},
+	{
+		Name:       "customer_late_cancel",
+		ActorRole:  ActorRoleCustomer,
+		Stages:     []models.CancellationStage{models.CancelStageAccepted},
+		MinMinutes: 3,
+		Fee:        2000,
+		FeePayer:   models.FeePayerCustomer,
+	},
+	{
+		Name:      "customer_after_arrival",
+		ActorRole: ActorRoleCustomer,
+		Stages:    []models.CancellationStage{models.CancelStageArrived},
+		Fee:       5000,
+		FeePayer:  models.FeePayerCustomer,
+	},
+	{
+		Name:      "customer_on_trip",
+		ActorRole: ActorRoleCustomer,
+		Stages:    []models.CancellationStage{models.CancelStageOnTrip},
+		FeeRate:   0.5,
+		FeePayer:  models.FeePayerCustomer,
+	},
+	{
+		Name:       "driver_customer_no_show",
+		ActorRole:  ActorRoleDriver,
+		Stages:     []models.CancellationStage{models.CancelStageArrived},
+		Reasons:    []models.CancellationReason{models.CancelReasonCustomerNoShow, models.CancelReasonCustomerUnreachable},
+		MinMinutes: 5,
+		Fee:        5000,
+		FeePayer:   models.FeePayerCustomer,
+	},
+	{
+		Name:      "driver_unsafe_request",
+		ActorRole: ActorRoleDriver,
+		Reasons:   []models.CancellationReason{models.CancelReasonUnsafeRequest},
+	},
+	{
+		Name:      "driver_cancel",
+		ActorRole: ActorRoleDriver,
+		Stages:    []models.CancellationStage{models.CancelStageAccepted, models.CancelStageArrived, models.CancelStageOnTrip},
+		Strike:    true,
+	},
+}
+
+type CancellationPolicy struct {
+	Rules []CancellationRule
+	// ArrivedRadiusKm is how close the driver must be to pickup to count as arrived
+	ArrivedRadiusKm float64
+	// A driver reaching StrikeLimit strikes within StrikeWindow is set inactive
+	StrikeLimit  int
+	StrikeWindow time.Duration
+}
+
+// LoadCancellationPolicy reads cancellation settings from the environment.
+// CANCELLATION_RULES holds a JSON array of rules replacing the defaults.
+func LoadCancellationPolicy() CancellationPolicy {
+	policy := CancellationPolicy{
+		Rules:           DefaultCancellationRules,
+		ArrivedRadiusKm: envFloat("CANCELLATION_ARRIVED_RADIUS_KM", 0.1),
+		StrikeLimit:     int(envFloat("CANCELLATION_STRIKE_LIMIT", 3)),
+		StrikeWindow:    envDuration("CANCELLATION_STRIKE_WINDOW", 7*24*time.Hour),
+	}
+	if raw := os.Getenv("CANCELLATION_RULES"); raw != "" {
+		var rules []CancellationRule
+		if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+			log.Printf("Invalid CANCELLATION_RULES, using defaults: %v", err)
+		} else {
+			policy.Rules = rules
+		}
+	}
+	return policy
+}
+
+// CancellationStageOf determines how far the order had progressed. An accepted
+// order counts as arrived when the driver is within radiusKm of the pickup point.
+func CancellationStageOf(order models.Order, driverAt *geo.Point, radiusKm float64) models.CancellationStage {
+	switch order.Status {
+	case models.OrderStatusPending:
+		if order.IsScheduled() && order.DispatchedAt == nil {
+			return models.CancelStageScheduled
+		}
+		return models.CancelStagePending
+	case models.OrderStatusPickedUp, models.OrderStatusInProgress:
+		return models.CancelStageOnTrip
+	}
+	if pickup, ok := order.PickupPoint(); ok && driverAt != nil && geo.Distance(*driverAt, pickup) <= radiusKm {
+		return models.CancelStageArrived
+	}
+	return models.CancelStageAccepted
+}
+
+// CancellationOutcome is the result of applying the policy to a cancellation
+type CancellationOutcome struct {
+	Stage         models.CancellationStage
+	Rule          string
+	Fee           float64
+	FeePayer      string
+	Strike        bool
+	SuspendDriver bool
+}
+
+// EvaluateCancellation finds the first rule matching a cancellation made at now
+func (p CancellationPolicy) EvaluateCancellation(order models.Order, role string, reason models.CancellationReason, stage models.CancellationStage, now time.Time) CancellationOutcome {
+	since := order.CreatedAt
+	if order.AcceptedAt != nil {
+		since = *order.AcceptedAt
+	}
+	elapsed := now.Sub(since)
+	untilPickup := time.Duration(-1)
+	if order.ScheduledAt != nil {
+		untilPickup = order.ScheduledAt.Sub(now)
+	}
+
+	outcome := CancellationOutcome{Stage: stage}
+	for _, rule := range p.Rules {
+		if !rule.matches(role, reason, stage, elapsed, untilPickup) {
+			continue
+		}
+		fee := rule.Fee + rule.FeeRate*order.Price
+		if fee > 0 {
+			outcome.Fee = math.Round(fee)
+			outcome.FeePayer = rule.FeePayer
+			if outcome.FeePayer == "" {
+				outcome.FeePayer = models.FeePayerCustomer
+			}
+		}
+		outcome.Rule = rule.Name
+		outcome.Strike = rule.Strike
+		outcome.SuspendDriver = rule.SuspendDriver
+		return outcome
+	}
+	return outcome
+}
+
+// matches reports whether the rule applies; untilPickup is negative for immediate orders
+func (r CancellationRule) matches(role string, reason models.CancellationReason, stage models.CancellationStage, elapsed, untilPickup time.Duration) bool {
+	if r.ActorRole != "" && r.ActorRole != role {
+		return false
+	}
+	if len(r.Stages) > 0 && !containsStage(r.Stages, stage) {
+		return false
+	}
+	if len(r.Reasons) > 0 && !containsReason(r.Reasons, reason) {
+		return false
+	}
+	if r.WithinMinutesOfPickup > 0 && (untilPickup < 0 || untilPickup >= minutes(r.WithinMinutesOfPickup)) {
+		return false
+	}
+	return elapsed >= minutes(r.MinMinutes)
+}
+
+func minutes(m float64) time.Duration {
+	return time.Duration(m * float64(time.Minute))
+}
+
+func containsStage(stages []models.CancellationStage, stage models.CancellationStage) bool {
+	for _, s := range stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+func containsReason(reasons []models.CancellationReason, reason models.CancellationReason) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// CancelRequest is a cancellation requested by a customer, driver or admin
+type CancelRequest struct {
+	ActorID   *uint
+	ActorRole string
+	Reason    models.CancellationReason
+	Note      string
+}
+
+// CancelOrder cancels the order under the policy in one transaction: the order
+// moves to cancelled, the cancellation and any fee are recorded, and the
+// assigned driver is released (or suspended) and given a strike if a rule says so.
+func CancelOrder(db *gorm.DB, order *models.Order, req CancelRequest, policy CancellationPolicy) (*models.OrderCancellation, error) {
+	if !IsValidCancelReason(req.ActorRole, req.Reason) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCancelReason, req.Reason)
+	}
+	if !CanTransitionOrder(order.Status, models.OrderStatusCancelled) {
+		return nil, &InvalidTransitionError{OrderID: order.ID, From: order.Status, To: models.OrderStatusCancelled}
+	}
+
+	var driverAt *geo.Point
+	if order.DriverID != nil && order.Status == models.OrderStatusAccepted {
+		var location models.DriverLocation
+		if err := db.Where("driver_id = ?", *order.DriverID).First(&location).Error; err == nil {
+			driverAt = &geo.Point{Lat: location.Latitude, Lng: location.Longitude}
+		}
+	}
+
+	now := time.Now()
+	stage := CancellationStageOf(*order, driverAt, policy.ArrivedRadiusKm)
+	outcome := policy.EvaluateCancellation(*order, req.ActorRole, req.Reason, stage, now)
+
+	cancellation := models.OrderCancellation{
+		OrderID:      order.ID,
+		DriverID:     order.DriverID,
+		ActorID:      req.ActorID,
+		ActorRole:    req.ActorRole,
+		ReasonCode:   req.Reason,
+		Note:         strings.TrimSpace(req.Note),
+		FromStatus:   order.Status,
+		Stage:        outcome.Stage,
+		Rule:         outcome.Rule,
+		Fee:          outcome.Fee,
+		FeePayer:     outcome.FeePayer,
+		DriverStrike: outcome.Strike && order.DriverID != nil,
+		CreatedAt:    now,
+	}
+
+	reason := string(req.Reason)
+	if cancellation.Note != "" {
+		reason += ": " + cancellation.Note
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		order.CancellationFee = outcome.Fee
+		err := TransitionOrder(tx, order, OrderTransition{
+			To:        models.OrderStatusCancelled,
+			ActorID:   req.ActorID,
+			ActorRole: req.ActorRole,
+			Reason:    reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Drivers of pending orders (e.g. pre-committed to a booking) are not on this trip yet
+		if order.DriverID != nil && cancellation.FromStatus != models.OrderStatusPending {
+			status, err := releaseCancelledDriver(tx, *order.DriverID, cancellation.DriverStrike, outcome.SuspendDriver, policy, now)
+			if err != nil {
+				return err
+			}
+			cancellation.DriverStatus = status
+		}
+
+		if err := tx.Create(&cancellation).Error; err != nil {
+			return err
+		}
+
+		// Stop any dispatch offers still open for the order
+		return tx.Model(&models.DispatchOffer{}).
+			Where("order_id = ? AND status = ?", order.ID, models.DispatchOfferStatusOffered).
+			Updates(map[string]interface{}{"status": models.DispatchOfferStatusCancelled, "responded_at": now}).Error
+	})
+	if err != nil {
+		order.CancellationFee = 0
+		return nil, err
+	}
+
+	return &cancellation, nil
+}
+
+// releaseCancelledDriver applies a strike if needed and frees the driver from the
+// cancelled trip. Drivers reaching the strike limit are set inactive for review.
+func releaseCancelledDriver(tx *gorm.DB, driverID uint, strike, suspend bool, policy CancellationPolicy, now time.Time) (models.DriverStatus, error) {
+	if strike {
+		if err := tx.Model(&models.Driver{}).Where("id = ?", driverID).
+			Update("cancellation_strikes", gorm.Expr("cancellation_strikes + ?", 1)).Error; err != nil {
+			return "", err
+		}
+
+		if policy.StrikeLimit > 0 {
+			var recent int64
+			if err := tx.Model(&models.OrderCancellation{}).
+				Where("driver_id = ? AND driver_strike = ? AND created_at > ?", driverID, true, now.Add(-policy.StrikeWindow)).
+				Count(&recent).Error; err != nil {
+				return "", err
+			}
+			// The current cancellation is not stored yet
+			if int(recent)+1 >= policy.StrikeLimit {
+				suspend = true
+			}
+		}
+	}
+
+	if suspend {
+		err := tx.Model(&models.Driver{}).Where("id = ?", driverID).Update("status", models.DriverStatusInactive).Error
+		return models.DriverStatusInactive, err
+	}
+
+	// Only a driver still on this trip is released; inactive drivers stay inactive
+	err := tx.Model(&models.Driver{}).
+		Where("id = ? AND status = ?", driverID, models.DriverStatusOnTrip).
+		Update("status", models.DriverStatusActive).Error
+	return models.DriverStatusActive, err
+}
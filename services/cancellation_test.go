@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancellationStageOf(t *testing.T) {
+	lat, lng := tuguJogja.Lat, tuguJogja.Lng
+	accepted := models.Order{Status: models.OrderStatusAccepted, PickupLat: &lat, PickupLng: &lng}
+	nearby := geo.Destination(tuguJogja, 90, 0.05)
+
+	tests := []struct {
+		name     string
+		order    models.Order
+		driverAt *geo.Point
+		want     models.CancellationStage
+	}{
+		{"pending", models.Order{Status: models.OrderStatusPending}, nil, models.CancelStagePending},
+		{"driver en route", accepted, &kraton, models.CancelStageAccepted},
+		{"driver location unknown", accepted, nil, models.CancelStageAccepted},
+		{"driver at pickup", accepted, &nearby, models.CancelStageArrived},
+		{"picked up", models.Order{Status: models.OrderStatusPickedUp}, nil, models.CancelStageOnTrip},
+		{"in progress", models.Order{Status: models.OrderStatusInProgress}, nil, models.CancelStageOnTrip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CancellationStageOf(tt.order, tt.driverAt, 0.1))
+		})
+	}
+}
+
+func TestEvaluateCancellation(t *testing.T) {
+	policy := CancellationPolicy{Rules: DefaultCancellationRules}
+	order := models.Order{Price: 15000}
+
+	tests := []struct {
+		name       string
+		role       string
+		reason     models.CancellationReason
+		stage      models.CancellationStage
+		elapsed    time.Duration
+		wantRule   string
+		wantFee    float64
+		wantStrike bool
+	}{
+		{"customer before accept is free", ActorRoleCustomer, models.CancelReasonChangedPlans, models.CancelStagePending, 10 * time.Minute, "", 0, false},
+		{"customer within grace is free", ActorRoleCustomer, models.CancelReasonChangedPlans, models.CancelStageAccepted, time.Minute, "", 0, false},
+		{"customer after grace", ActorRoleCustomer, models.CancelReasonDriverLate, models.CancelStageAccepted, 4 * time.Minute, "customer_late_cancel", 2000, false},
+		{"customer after arrival", ActorRoleCustomer, models.CancelReasonChangedPlans, models.CancelStageArrived, time.Minute, "customer_after_arrival", 5000, false},
+		{"customer on trip pays half", ActorRoleCustomer, models.CancelReasonOther, models.CancelStageOnTrip, time.Minute, "customer_on_trip", 7500, false},
+		{"driver waited for no-show", ActorRoleDriver, models.CancelReasonCustomerNoShow, models.CancelStageArrived, 6 * time.Minute, "driver_customer_no_show", 5000, false},
+		{"driver no-show too early", ActorRoleDriver, models.CancelReasonCustomerNoShow, models.CancelStageArrived, 2 * time.Minute, "driver_cancel", 0, true},
+		{"driver unsafe request", ActorRoleDriver, models.CancelReasonUnsafeRequest, models.CancelStageAccepted, 0, "driver_unsafe_request", 0, false},
+		{"driver cancels", ActorRoleDriver, models.CancelReasonVehicleProblem, models.CancelStageAccepted, 0, "driver_cancel", 0, true},
+		{"booking cancelled early is free", ActorRoleCustomer, models.CancelReasonChangedPlans, models.CancelStageScheduled, 0, "", 0, false},
+		{"admin has no penalty", ActorRoleAdmin, models.CancelReasonFraud, models.CancelStageOnTrip, 0, "", 0, false},
+	}
+
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := order
+			acceptedAt := now.Add(-tt.elapsed)
+			order.AcceptedAt = &acceptedAt
+			outcome := policy.EvaluateCancellation(order, tt.role, tt.reason, tt.stage, now)
+			assert.Equal(t, tt.wantRule, outcome.Rule)
+			assert.Equal(t, tt.wantFee, outcome.Fee)
+			assert.Equal(t, tt.wantStrike, outcome.Strike)
+			if tt.wantFee > 0 {
+				assert.Equal(t, models.FeePayerCustomer, outcome.FeePayer)
+			}
+		})
+	}
+}
+
+func TestIsValidCancelReason(t *testing.T) {
+	assert.True(t, IsValidCancelReason(ActorRoleCustomer, models.CancelReasonChangedPlans))
+	assert.True(t, IsValidCancelReason(ActorRoleDriver, models.CancelReasonCustomerNoShow))
+	assert.False(t, IsValidCancelReason(ActorRoleCustomer, models.CancelReasonCustomerNoShow))
+	assert.False(t, IsValidCancelReason(ActorRoleSystem, models.CancelReasonOther))
+}
+
+func TestEvaluateScheduledCancellation(t *testing.T) {
+	policy := CancellationPolicy{Rules: DefaultCancellationRules}
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		untilPickup time.Duration
+		wantFee     float64
+	}{
+		{"day before", 24 * time.Hour, 0},
+		{"just over an hour before", 61 * time.Minute, 0},
+		{"within an hour", 45 * time.Minute, 5000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduledAt := now.Add(tt.untilPickup)
+			order := models.Order{Status: models.OrderStatusPending, ScheduledAt: &scheduledAt, CreatedAt: now.Add(-48 * time.Hour)}
+			stage := CancellationStageOf(order, nil, 0.1)
+			assert.Equal(t, models.CancelStageScheduled, stage)
+
+			outcome := policy.EvaluateCancellation(order, ActorRoleCustomer, models.CancelReasonChangedPlans, stage, now)
+			assert.Equal(t, tt.wantFee, outcome.Fee)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Platform Commission
+// ===================
+// Komisi platform per order ditentukan aturan komisi yang paling spesifik
+// untuk tarif, jenis kendaraan driver dan program tarif (subsidi, kemitraan
+// Gojek, non-tunai). Tanpa aturan yang cocok dipakai DRIVER_COMMISSION_RATE.
+//
+// Order non-tunai ditagih platform, jadi wallet driver dikreditkan harga
+// dikurangi komisi. Order tunai dibayar langsung ke driver, jadi komisinya
+// dicatat sebagai piutang driver (driver:{id}:receivable) yang dilunasi
+// lewat setoran tunai/transfer atau dipotong dari wallet.
+
+var ErrInvalidCommissionRule = errors.New("invalid commission rule")
+
+// Who collected the fare of a completed order
+const (
+	CollectedByDriver   = "driver"   // Tunai ke driver
+	CollectedByPlatform = "platform" // Non-tunai lewat platform
+	CollectedByPending  = "pending"  // Non-tunai yang belum lunas saat order selesai
+)
+
+// CommissionContext is what commission rules are matched against
+type CommissionContext struct {
+	TariffID    uint
+	VehicleType models.VehicleType
+	Programs    []string
+}
+
+// TariffPrograms lists the commission programs a tariff belongs to
+func TariffPrograms(tariff models.Tariff) []string {
+	var programs []string
+	if tariff.IsSubsidi {
+		programs = append(programs, models.CommissionProgramSubsidi)
+	}
+	if tariff.IsGojek {
+		programs = append(programs, models.CommissionProgramGojek)
+	}
+	if tariff.IsNonTunai {
+		programs = append(programs, models.CommissionProgramNonTunai)
+	}
+	return programs
+}
+
+// ValidateCommissionRule checks a rule before it is saved
+func ValidateCommissionRule(rule models.CommissionRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCommissionRule)
+	}
+	if rule.Rate < 0 || rule.Rate > 1 {
+		return fmt.Errorf("%w: rate must be between 0 and 1", ErrInvalidCommissionRule)
+	}
+	if rule.FlatFee < 0 || rule.MinFee < 0 || rule.MaxFee < 0 {
+		return fmt.Errorf("%w: fees must not be negative", ErrInvalidCommissionRule)
+	}
+	if rule.MaxFee > 0 && rule.MaxFee < rule.MinFee {
+		return fmt.Errorf("%w: max_fee must not be below min_fee", ErrInvalidCommissionRule)
+	}
+	switch rule.Program {
+	case "", models.CommissionProgramSubsidi, models.CommissionProgramGojek, models.CommissionProgramNonTunai:
+	default:
+		return fmt.Errorf("%w: program must be subsidi, gojek or non_tunai", ErrInvalidCommissionRule)
+	}
+	if rule.VehicleType != "" {
+		if _, ok := DefaultVehicleMultipliers[rule.VehicleType]; !ok {
+			return fmt.Errorf("%w: unknown vehicle type %q", ErrInvalidCommissionRule, rule.VehicleType)
+		}
+	}
+	return nil
+}
+
+func commissionRuleMatches(rule models.CommissionRule, ctx CommissionContext) bool {
+	if !rule.IsActive {
+		return false
+	}
+	if rule.TariffID != nil && *rule.TariffID != ctx.TariffID {
+		return false
+	}
+	if rule.VehicleType != "" && rule.VehicleType != ctx.VehicleType {
+		return false
+	}
+	if rule.Program != "" {
+		for _, program := range ctx.Programs {
+			if program == rule.Program {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// commissionRuleSpecificity ranks a tariff match above a program match above
+// a vehicle match
+func commissionRuleSpecificity(rule models.CommissionRule) int {
+	specificity := 0
+	if rule.TariffID != nil {
+		specificity += 4
+	}
+	if rule.Program != "" {
+		specificity += 2
+	}
+	if rule.VehicleType != "" {
+		specificity++
+	}
+	return specificity
+}
+
+// MatchCommissionRule picks the most specific active rule for ctx. Ties go
+// to the higher priority, then the newest rule. Nil means no rule applies.
+func MatchCommissionRule(rules []models.CommissionRule, ctx CommissionContext) *models.CommissionRule {
+	var matches []models.CommissionRule
+	for _, rule := range rules {
+		if commissionRuleMatches(rule, ctx) {
+			matches = append(matches, rule)
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		si, sj := commissionRuleSpecificity(matches[i]), commissionRuleSpecificity(matches[j])
+		if si != sj {
+			return si > sj
+		}
+		if matches[i].Priority != matches[j].Priority {
+			return matches[i].Priority > matches[j].Priority
+		}
+		return matches[i].ID > matches[j].ID
+	})
+	return &matches[0]
+}
+
+// RuleCommission is the commission a rule charges on a fare, never more than the fare
+func RuleCommission(rule models.CommissionRule, price float64) float64 {
+	fee := rule.FlatFee + rule.Rate*price
+	if fee < rule.MinFee {
+		fee = rule.MinFee
+	}
+	if rule.MaxFee > 0 && fee > rule.MaxFee {
+		fee = rule.MaxFee
+	}
+	return roundRupiah(math.Min(math.Max(fee, 0), price))
+}
+
+// OrderCommission returns the commission for a completed order and the rule
+// that set it (nil when the DRIVER_COMMISSION_RATE default applied)
+func OrderCommission(db *gorm.DB, order models.Order, driver models.Driver) (float64, *models.CommissionRule, error) {
+	var tariff models.Tariff
+	if err := db.Unscoped().First(&tariff, order.TariffID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil, err
+	}
+
+	var rules []models.CommissionRule
+	if err := db.Where("is_active = ?", true).Find(&rules).Error; err != nil {
+		return 0, nil, err
+	}
+
+	rule := MatchCommissionRule(rules, CommissionContext{
+		TariffID:    order.TariffID,
+		VehicleType: driver.VehicleType,
+		Programs:    TariffPrograms(tariff),
+	})
+	if rule == nil {
+		return roundRupiah(math.Min(order.Price*CommissionRate(), order.Price)), nil, nil
+	}
+	return RuleCommission(*rule, order.Price), rule, nil
+}
+
+// PaymentCollectedBy reports who holds the fare of an order with the given
+// payment: orders without a payment or paid in cash were paid to the driver,
+// and non-cash fares only reach the platform once the payment is paid
+func PaymentCollectedBy(payment *models.Payment) string {
+	if payment == nil || payment.Method == models.PaymentMethodCash {
+		return CollectedByDriver
+	}
+	switch payment.Status {
+	case models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded:
+		return CollectedByPlatform
+	}
+	return CollectedByPending
+}
+
+// OrderCollectedBy reports who collected an order's fare, see PaymentCollectedBy
+func OrderCollectedBy(db *gorm.DB, order models.Order) (string, error) {
+	var payment models.Payment
+	// A locking read sees a payment that settled while the order was completing
+	err := db.Clauses(clause.Locking{Strength: "SHARE"}).Where("order_id = ?", order.ID).First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return PaymentCollectedBy(nil), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return PaymentCollectedBy(&payment), nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"testing"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func uintPtr(v uint) *uint { return &v }
+
+func TestTariffPrograms(t *testing.T) {
+	assert.Nil(t, TariffPrograms(models.Tariff{}))
+	assert.Equal(t, []string{models.CommissionProgramSubsidi, models.CommissionProgramNonTunai},
+		TariffPrograms(models.Tariff{IsSubsidi: true, IsNonTunai: true}))
+}
+
+func TestValidateCommissionRule(t *testing.T) {
+	valid := models.CommissionRule{Name: "Default", Rate: 0.1, MinFee: 1000, MaxFee: 5000}
+	assert.NoError(t, ValidateCommissionRule(valid))
+
+	invalid := []models.CommissionRule{
+		{Rate: 0.1},
+		{Name: "Rate", Rate: 1.2},
+		{Name: "Fee", FlatFee: -500},
+		{Name: "Range", MinFee: 5000, MaxFee: 1000},
+		{Name: "Program", Program: "grab"},
+		{Name: "Vehicle", VehicleType: "ojek"},
+	}
+	for _, rule := range invalid {
+		assert.ErrorIs(t, ValidateCommissionRule(rule), ErrInvalidCommissionRule, rule.Name)
+	}
+}
+
+func TestMatchCommissionRule(t *testing.T) {
+	rules := []models.CommissionRule{
+		{ID: 1, Name: "Default", Rate: 0.1, IsActive: true},
+		{ID: 2, Name: "Becak listrik", VehicleType: models.VehicleTypeBecakListrik, Rate: 0.08, IsActive: true},
+		{ID: 3, Name: "Subsidi", Program: models.CommissionProgramSubsidi, Rate: 0, IsActive: true},
+		{ID: 4, Name: "Tarif wisata", TariffID: uintPtr(9), Rate: 0.15, IsActive: true},
+		{ID: 5, Name: "Inactive tariff", TariffID: uintPtr(7), Rate: 0.5, IsActive: false},
+		{ID: 6, Name: "Gojek", Program: models.CommissionProgramGojek, Rate: 0.2, IsActive: true},
+		{ID: 7, Name: "Gojek promo", Program: models.CommissionProgramGojek, Rate: 0.12, Priority: 10, IsActive: true},
+	}
+
+	match := func(ctx CommissionContext) string {
+		rule := MatchCommissionRule(rules, ctx)
+		if rule == nil {
+			return ""
+		}
+		return rule.Name
+	}
+
+	assert.Equal(t, "Default", match(CommissionContext{TariffID: 1, VehicleType: models.VehicleTypeBecakManual}))
+	assert.Equal(t, "Becak listrik", match(CommissionContext{TariffID: 1, VehicleType: models.VehicleTypeBecakListrik}))
+	assert.Equal(t, "Subsidi", match(CommissionContext{TariffID: 1, VehicleType: models.VehicleTypeBecakListrik, Programs: []string{models.CommissionProgramSubsidi}}))
+	assert.Equal(t, "Tarif wisata", match(CommissionContext{TariffID: 9, Programs: []string{models.CommissionProgramSubsidi}}))
+	assert.Equal(t, "Default", match(CommissionContext{TariffID: 7}), "inactive rules never match")
+	assert.Equal(t, "Gojek promo", match(CommissionContext{TariffID: 1, Programs: []string{models.CommissionProgramGojek}}), "priority breaks ties")
+
+	assert.Nil(t, MatchCommissionRule(rules[1:2], CommissionContext{VehicleType: models.VehicleTypeAndong}))
+}
+
+func TestRuleCommission(t *testing.T) {
+	assert.Equal(t, 2000.0, RuleCommission(models.CommissionRule{Rate: 0.1}, 20000))
+	assert.Equal(t, 2500.0, RuleCommission(models.CommissionRule{Rate: 0.1, FlatFee: 500}, 20000))
+	assert.Equal(t, 1500.0, RuleCommission(models.CommissionRule{Rate: 0.1, MinFee: 1500}, 10000))
+	assert.Equal(t, 3000.0, RuleCommission(models.CommissionRule{Rate: 0.1, MaxFee: 3000}, 50000))
+	assert.Equal(t, 8000.0, RuleCommission(models.CommissionRule{FlatFee: 10000}, 8000), "never more than the fare")
+	assert.Equal(t, 0.0, RuleCommission(models.CommissionRule{}, 8000))
+}
+
+func TestCashCommissionLines(t *testing.T) {
+	lines := CashCommissionLines(5, 1500)
+	assert.Equal(t, int64(0), sumLines(lines))
+	assert.Equal(t, "driver:5:receivable", lines[0].Account.Code)
+	assert.Equal(t, 1500.0, lines[0].Amount)
+	assert.False(t, lines[0].Account.CreditNormal())
+	assert.Equal(t, LedgerAccountCommission.Code, lines[1].Account.Code)
+}
+
+func TestPaymentCollectedBy(t *testing.T) {
+	assert.Equal(t, CollectedByDriver, PaymentCollectedBy(nil), "orders without a payment were paid in cash")
+	assert.Equal(t, CollectedByDriver, PaymentCollectedBy(&models.Payment{Method: models.PaymentMethodCash, Status: models.PaymentStatusPending}))
+
+	for _, status := range []models.PaymentStatus{models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded} {
+		assert.Equal(t, CollectedByPlatform, PaymentCollectedBy(&models.Payment{Method: models.PaymentMethodQR, Status: status}), status)
+	}
+	for _, status := range []models.PaymentStatus{models.PaymentStatusPending, models.PaymentStatusFailed} {
+		assert.Equal(t, CollectedByPending, PaymentCollectedBy(&models.Payment{Method: models.PaymentMethodTransfer, Status: status}), status)
+	}
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Dispatch Engine
+// ===============
+// Menawarkan order baru ke driver terdekat secara bergelombang (wave).
+// Setiap wave ditawarkan ke beberapa driver terdekat dengan batas waktu;
+// jika tidak ada yang menerima, radius diperluas sampai batas maksimum.
+
+// EventOrderOffer is pushed to a driver's topic when an order is offered to them
+const EventOrderOffer = "order_offer"
+
+// EventDispatchExhausted is pushed to the admin topic when dispatch gives up on
+// an order that is still pending, so ops can assign it by hand
+const EventDispatchExhausted = "dispatch_exhausted"
+
+type DispatchConfig struct {
+	InitialRadiusKm float64
+	RadiusStepKm    float64
+	MaxRadiusKm     float64
+	WaveSize        int
+	// MaxWavesWithoutPickup caps how many waves an order without pickup
+	// coordinates is offered in, since those waves are not ordered by distance
+	MaxWavesWithoutPickup int
+	OfferTimeout          time.Duration
+	LocationMaxAge        time.Duration
+	PollInterval          time.Duration
+}
+
+// LoadDispatchConfig reads dispatch settings from the environment
+func LoadDispatchConfig() DispatchConfig {
+	return DispatchConfig{
+		InitialRadiusKm:       envFloat("DISPATCH_INITIAL_RADIUS_KM", 1.5),
+		RadiusStepKm:          envFloat("DISPATCH_RADIUS_STEP_KM", 1.5),
+		MaxRadiusKm:           envFloat("DISPATCH_MAX_RADIUS_KM", 6),
+		WaveSize:              int(envFloat("DISPATCH_WAVE_SIZE", 3)),
+		MaxWavesWithoutPickup: int(envFloat("DISPATCH_MAX_WAVES_WITHOUT_PICKUP", 3)),
+		OfferTimeout:          envDuration("DISPATCH_OFFER_TIMEOUT", 30*time.Second),
+		LocationMaxAge:        envDuration("DISPATCH_LOCATION_MAX_AGE", 5*time.Minute),
+		PollInterval:          2 * time.Second,
+	}
+}
+
+// OfferNotifier delivers an order offer to a driver (push notification, stream, ...)
+type OfferNotifier func(driver models.Driver, order models.Order, offer models.DispatchOffer)
+
+// DispatchCandidate is an available driver near the pickup point
+type DispatchCandidate struct {
+	Driver     models.Driver
+	Location   models.DriverLocation
+	DistanceKm float64
+}
+
+type Dispatcher struct {
+	db     *gorm.DB
+	config DispatchConfig
+	notify OfferNotifier
+
+	mutex   sync.Mutex
+	running map[uint]bool
+}
+
+func NewDispatcher(db *gorm.DB, config DispatchConfig, notify OfferNotifier) *Dispatcher {
+	return &Dispatcher{
+		db:      db,
+		config:  config,
+		notify:  notify,
+		running: make(map[uint]bool),
+	}
+}
+
+// Dispatch offers the order to nearby drivers in waves until one accepts,
+// the order leaves pending, or the maximum radius is exhausted. Orders without
+// pickup coordinates are offered to all available drivers in waves instead.
+// It blocks for the duration of dispatch, so callers usually run it in a goroutine.
+func (d *Dispatcher) Dispatch(order models.Order) {
+	d.mutex.Lock()
+	if d.running[order.ID] {
+		d.mutex.Unlock()
+		return
+	}
+	d.running[order.ID] = true
+	d.mutex.Unlock()
+
+	defer func() {
+		d.mutex.Lock()
+		delete(d.running, order.ID)
+		d.mutex.Unlock()
+	}()
+
+	if order.PickupLat == nil || order.PickupLng == nil {
+		d.dispatchWithoutPickup(order)
+		return
+	}
+
+	offered := make(map[uint]bool)
+	radius := d.config.InitialRadiusKm
+	wave := 0
+
+	for {
+		candidates, err := d.FindCandidates(*order.PickupLat, *order.PickupLng, radius)
+		if err != nil {
+			log.Printf("Dispatch for order %d failed to load candidates: %v", order.ID, err)
+			return
+		}
+
+		var waveCandidates []DispatchCandidate
+		for _, candidate := range candidates {
+			if offered[candidate.Driver.ID] {
+				continue
+			}
+			waveCandidates = append(waveCandidates, candidate)
+			if len(waveCandidates) == d.config.WaveSize {
+				break
+			}
+		}
+
+		if len(waveCandidates) > 0 {
+			wave++
+			for _, candidate := range waveCandidates {
+				offered[candidate.Driver.ID] = true
+			}
+			if d.runWave(order, wave, radius, waveCandidates) {
+				return
+			}
+		}
+
+		if radius >= d.config.MaxRadiusKm {
+			log.Printf("Dispatch exhausted for order %d after %d waves (radius %.1f km)", order.ID, wave, radius)
+			publishDispatchExhausted(order, wave)
+			return
+		}
+		radius = math.Min(radius+d.config.RadiusStepKm, d.config.MaxRadiusKm)
+	}
+}
+
+// dispatchWithoutPickup offers an order without pickup coordinates to the
+// available drivers, most recently seen first, one wave at a time. It stops
+// after MaxWavesWithoutPickup waves; the order then stays in the available
+// list and is flagged to admins instead of being broadcast to the whole fleet.
+func (d *Dispatcher) dispatchWithoutPickup(order models.Order) {
+	candidates, err := d.FindAvailableDrivers()
+	if err != nil {
+		log.Printf("Dispatch for order %d failed to load candidates: %v", order.ID, err)
+		return
+	}
+
+	waves := SplitWaves(candidates, d.config.WaveSize)
+	if d.config.MaxWavesWithoutPickup > 0 && len(waves) > d.config.MaxWavesWithoutPickup {
+		waves = waves[:d.config.MaxWavesWithoutPickup]
+	}
+	for i, candidates := range waves {
+		if d.runWave(order, i+1, 0, candidates) {
+			return
+		}
+	}
+	log.Printf("Dispatch exhausted for order %d without pickup coordinates after %d waves", order.ID, len(waves))
+	publishDispatchExhausted(order, len(waves))
+}
+
+// publishDispatchExhausted flags an order that no driver accepted on the admin stream
+func publishDispatchExhausted(order models.Order, waves int) {
+	Hub.Publish(TopicAdmin, EventDispatchExhausted, map[string]interface{}{
+		"order_id":     order.ID,
+		"order_number": order.OrderNumber,
+		"waves":        waves,
+		"has_pickup":   order.PickupLat != nil && order.PickupLng != nil,
+	})
+}
+
+// SplitWaves groups candidates into consecutive waves of at most size drivers
+func SplitWaves(candidates []DispatchCandidate, size int) [][]DispatchCandidate {
+	if size < 1 {
+		size = 1
+	}
+	var waves [][]DispatchCandidate
+	for len(candidates) > 0 {
+		n := min(size, len(candidates))
+		waves = append(waves, candidates[:n])
+		candidates = candidates[n:]
+	}
+	return waves
+}
+
+// runWave offers the order to a set of drivers and waits for the offers to expire.
+// It returns true when dispatch should stop because the order is no longer pending.
+func (d *Dispatcher) runWave(order models.Order, wave int, radius float64, candidates []DispatchCandidate) bool {
+	now := time.Now()
+	expiresAt := now.Add(d.config.OfferTimeout)
+
+	for _, candidate := range candidates {
+		offer := models.DispatchOffer{
+			OrderID:    order.ID,
+			DriverID:   candidate.Driver.ID,
+			Wave:       wave,
+			RadiusKm:   radius,
+			DistanceKm: candidate.DistanceKm,
+			Status:     models.DispatchOfferStatusOffered,
+			OfferedAt:  now,
+			ExpiresAt:  expiresAt,
+		}
+		if err := d.db.Create(&offer).Error; err != nil {
+			log.Printf("Failed to record dispatch offer for order %d driver %d: %v", order.ID, candidate.Driver.ID, err)
+			continue
+		}
+		if d.notify != nil {
+			d.notify(candidate.Driver, order, offer)
+		}
+	}
+
+	for time.Now().Before(expiresAt) {
+		if !d.orderPending(order.ID) {
+			d.closeOffers(order.ID, models.DispatchOfferStatusCancelled)
+			return true
+		}
+		if d.openOffers(order.ID, wave) == 0 {
+			break // everyone in this wave declined
+		}
+		time.Sleep(d.config.PollInterval)
+	}
+
+	d.closeOffers(order.ID, models.DispatchOfferStatusTimeout)
+	if !d.orderPending(order.ID) {
+		return true
+	}
+	return false
+}
+
+// availableLocations selects the locations of online, active drivers seen recently
+func (d *Dispatcher) availableLocations() *gorm.DB {
+	return d.db.
+		Joins("JOIN drivers ON drivers.id = driver_locations.driver_id AND drivers.deleted_at IS NULL").
+		Where("driver_locations.is_online = ? AND driver_locations.last_seen > ?", true, time.Now().Add(-d.config.LocationMaxAge)).
+		Where("drivers.status = ? AND drivers.is_active = ?", models.DriverStatusActive, true)
+}
+
+// FindAvailableDrivers returns all online, active drivers, most recently seen first
+func (d *Dispatcher) FindAvailableDrivers() ([]DispatchCandidate, error) {
+	var locations []models.DriverLocation
+	if err := d.availableLocations().Order("driver_locations.last_seen DESC").Preload("Driver").Find(&locations).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := make([]DispatchCandidate, 0, len(locations))
+	for _, location := range locations {
+		candidates = append(candidates, DispatchCandidate{Driver: location.Driver, Location: location})
+	}
+	return candidates, nil
+}
+
+// FindCandidates returns online, active drivers within radiusKm of the point, nearest first
+func (d *Dispatcher) FindCandidates(lat, lng, radiusKm float64) ([]DispatchCandidate, error) {
+	box := geo.BoundingBoxAround(geo.Point{Lat: lat, Lng: lng}, radiusKm)
+
+	var locations []models.DriverLocation
+	err := d.availableLocations().
+		Where("driver_locations.latitude BETWEEN ? AND ?", box.MinLat, box.MaxLat).
+		Where("driver_locations.longitude BETWEEN ? AND ?", box.MinLng, box.MaxLng).
+		Preload("Driver").
+		Find(&locations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return RankCandidates(lat, lng, radiusKm, locations), nil
+}
+
+// RankCandidates filters locations to radiusKm and sorts them by distance from the point
+func RankCandidates(lat, lng, radiusKm float64, locations []models.DriverLocation) []DispatchCandidate {
+	pickup := geo.Point{Lat: lat, Lng: lng}
+
+	var candidates []DispatchCandidate
+	for _, location := range locations {
+		distance := geo.Distance(pickup, geo.Point{Lat: location.Latitude, Lng: location.Longitude})
+		if distance > radiusKm {
+			continue
+		}
+		candidates = append(candidates, DispatchCandidate{
+			Driver:     location.Driver,
+			Location:   location,
+			DistanceKm: distance,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].DistanceKm < candidates[j].DistanceKm
+	})
+	return candidates
+}
+
+func (d *Dispatcher) orderPending(orderID uint) bool {
+	var order models.Order
+	if err := d.db.Select("id", "status").First(&order, orderID).Error; err != nil {
+		return false
+	}
+	return order.Status == models.OrderStatusPending
+}
+
+func (d *Dispatcher) openOffers(orderID uint, wave int) int64 {
+	var count int64
+	d.db.Model(&models.DispatchOffer{}).
+		Where("order_id = ? AND wave = ? AND status = ?", orderID, wave, models.DispatchOfferStatusOffered).
+		Count(&count)
+	return count
+}
+
+func (d *Dispatcher) closeOffers(orderID uint, status models.DispatchOfferStatus) {
+	now := time.Now()
+	d.db.Model(&models.DispatchOffer{}).
+		Where("order_id = ? AND status = ?", orderID, models.DispatchOfferStatusOffered).
+		Updates(map[string]interface{}{"status": status, "responded_at": now})
+}
+
+// RespondToOffer records a driver's answer to an open offer.
+// It returns gorm.ErrRecordNotFound when the driver has no open offer for the order.
+func RespondToOffer(db *gorm.DB, orderID, driverID uint, status models.DispatchOfferStatus) error {
+	result := db.Model(&models.DispatchOffer{}).
+		Where("order_id = ? AND driver_id = ? AND status = ?", orderID, driverID, models.DispatchOfferStatusOffered).
+		Updates(map[string]interface{}{"status": status, "responded_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
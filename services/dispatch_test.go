@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankCandidatesOrdersByDistanceWithinRadius(t *testing.T) {
+	// Pickup at Tugu Jogja
+	lat, lng := -7.782889, 110.367083
+
+	locations := []models.DriverLocation{
+		{DriverID: 1, Latitude: -7.792600, Longitude: 110.365800, Driver: models.Driver{ID: 1}}, // Malioboro, ~1.1 km
+		{DriverID: 2, Latitude: -7.783500, Longitude: 110.367500, Driver: models.Driver{ID: 2}}, // next to Tugu
+		{DriverID: 3, Latitude: -7.805300, Longitude: 110.364200, Driver: models.Driver{ID: 3}}, // Kraton, ~2.5 km
+	}
+
+	candidates := RankCandidates(lat, lng, 2, locations)
+
+	assert.Len(t, candidates, 2)
+	assert.Equal(t, uint(2), candidates[0].Driver.ID)
+	assert.Equal(t, uint(1), candidates[1].Driver.ID)
+	assert.InDelta(t, 1.09, candidates[1].DistanceKm, 0.05)
+}
+
+func TestSplitWavesGroupsCandidatesInOrder(t *testing.T) {
+	candidates := []DispatchCandidate{
+		{Driver: models.Driver{ID: 1}},
+		{Driver: models.Driver{ID: 2}},
+		{Driver: models.Driver{ID: 3}},
+	}
+
+	waves := SplitWaves(candidates, 2)
+	assert.Len(t, waves, 2)
+	assert.Len(t, waves[0], 2)
+	assert.Equal(t, uint(3), waves[1][0].Driver.ID)
+
+	assert.Len(t, SplitWaves(candidates, 0), 3)
+	assert.Empty(t, SplitWaves(nil, 2))
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment helpers shared by the service configs; unset or unparsable
+// values fall back to the default.
+
+func envFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func envDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func envString(key string, defaultValue string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return defaultValue
+}
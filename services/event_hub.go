@@ -0,0 +1,224 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"greenbecak-backend/models"
+)
+
+// Event Hub
+// =========
+// Pub/sub in-memory untuk streaming real-time (SSE) ke customer, driver dan admin.
+// Setiap topic menyimpan beberapa event terakhir supaya client yang reconnect
+// dengan Last-Event-ID bisa replay event yang terlewat.
+
+const (
+	// TopicAdmin receives city-wide updates for the admin dashboard
+	TopicAdmin = "admin"
+
+	eventHubBufferSize  = 64
+	eventHubHistorySize = 50
+	eventHubHistoryTTL  = 10 * time.Minute
+	eventHubPruneEvery  = 1000
+)
+
+// Event types pushed to stream subscribers
+const (
+	EventDriverLocation = "driver_location"
+	EventOrderStatus    = "order_status"
+	EventOrderCreated   = "order_created"
+	EventNotification   = "notification"
+)
+
+// OrderTopic returns the topic for updates about a single order
+func OrderTopic(orderID uint) string {
+	return fmt.Sprintf("order:%d", orderID)
+}
+
+// DriverTopic returns the topic for updates about a single driver
+func DriverTopic(driverID uint) string {
+	return fmt.Sprintf("driver:%d", driverID)
+}
+
+// UserTopic returns the topic for notifications addressed to a user
+func UserTopic(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// StreamEvent is a single message delivered to subscribers
+type StreamEvent struct {
+	ID        uint64      `json:"id"`
+	Topic     string      `json:"topic"`
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Subscriber receives events for a set of topics.
+// The channel is closed when the subscriber is evicted for being too slow
+// or after Unsubscribe.
+type Subscriber struct {
+	topics []string
+	events chan StreamEvent
+	once   sync.Once
+}
+
+// Events returns the channel events are delivered on
+func (s *Subscriber) Events() <-chan StreamEvent {
+	return s.events
+}
+
+func (s *Subscriber) close() {
+	s.once.Do(func() {
+		close(s.events)
+	})
+}
+
+type EventHub struct {
+	mutex       sync.RWMutex
+	subscribers map[string]map[*Subscriber]struct{}
+	history     map[string][]StreamEvent
+	lastID      uint64
+}
+
+// Hub is the process-wide event hub
+var Hub = NewEventHub()
+
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subscribers: make(map[string]map[*Subscriber]struct{}),
+		history:     make(map[string][]StreamEvent),
+	}
+}
+
+// Subscribe registers a new subscriber for the given topics
+func (h *EventHub) Subscribe(topics ...string) *Subscriber {
+	sub := &Subscriber{
+		topics: topics,
+		events: make(chan StreamEvent, eventHubBufferSize),
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, topic := range topics {
+		if h.subscribers[topic] == nil {
+			h.subscribers[topic] = make(map[*Subscriber]struct{})
+		}
+		h.subscribers[topic][sub] = struct{}{}
+	}
+	return sub
+}
+
+// Unsubscribe removes the subscriber from all its topics and closes its channel
+func (h *EventHub) Unsubscribe(sub *Subscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.removeLocked(sub)
+}
+
+func (h *EventHub) removeLocked(sub *Subscriber) {
+	for _, topic := range sub.topics {
+		if subs, ok := h.subscribers[topic]; ok {
+			delete(subs, sub)
+			if len(subs) == 0 {
+				delete(h.subscribers, topic)
+			}
+		}
+	}
+	sub.close()
+}
+
+// Publish delivers an event to every subscriber of topic without blocking.
+// Subscribers whose buffer is full are evicted; they are expected to reconnect
+// and replay missed events with Last-Event-ID.
+func (h *EventHub) Publish(topic, eventType string, data interface{}) StreamEvent {
+	event := StreamEvent{
+		ID:        atomic.AddUint64(&h.lastID, 1),
+		Topic:     topic,
+		Type:      eventType,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	var slow []*Subscriber
+
+	h.mutex.RLock()
+	for sub := range h.subscribers[topic] {
+		select {
+		case sub.events <- event:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mutex.RUnlock()
+
+	h.mutex.Lock()
+	history := append(h.history[topic], event)
+	if len(history) > eventHubHistorySize {
+		history = history[len(history)-eventHubHistorySize:]
+	}
+	h.history[topic] = history
+	if event.ID%eventHubPruneEvery == 0 {
+		h.pruneHistoryLocked(event.Timestamp.Add(-eventHubHistoryTTL))
+	}
+	for _, sub := range slow {
+		h.removeLocked(sub)
+	}
+	h.mutex.Unlock()
+
+	return event
+}
+
+// pruneHistoryLocked drops replay buffers of topics that have been quiet since cutoff
+func (h *EventHub) pruneHistoryLocked(cutoff time.Time) {
+	for topic, events := range h.history {
+		if len(events) == 0 || events[len(events)-1].Timestamp.Before(cutoff) {
+			delete(h.history, topic)
+		}
+	}
+}
+
+// Replay returns buffered events for topics with an ID greater than afterID, oldest first
+func (h *EventHub) Replay(topics []string, afterID uint64) []StreamEvent {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	var events []StreamEvent
+	for _, topic := range topics {
+		for _, event := range h.history[topic] {
+			if event.ID > afterID {
+				events = append(events, event)
+			}
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	return events
+}
+
+// SubscriberCount returns the number of subscribers for a topic
+func (h *EventHub) SubscriberCount(topic string) int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.subscribers[topic])
+}
+
+// PublishOrderStatus pushes an order status change to the order's subscribers and admins
+func PublishOrderStatus(order *models.Order) {
+	data := map[string]interface{}{
+		"order_id":     order.ID,
+		"order_number": order.OrderNumber,
+		"status":       order.Status,
+		"driver_id":    order.DriverID,
+		"updated_at":   time.Now(),
+	}
+	Hub.Publish(OrderTopic(order.ID), EventOrderStatus, data)
+	Hub.Publish(TopicAdmin, EventOrderStatus, data)
+}
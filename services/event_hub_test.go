@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventHubDeliversToTopicSubscribers(t *testing.T) {
+	hub := NewEventHub()
+	sub := hub.Subscribe(OrderTopic(1))
+	defer hub.Unsubscribe(sub)
+
+	hub.Publish(OrderTopic(2), EventOrderStatus, "other order")
+	published := hub.Publish(OrderTopic(1), EventOrderStatus, "accepted")
+
+	event := <-sub.Events()
+	assert.Equal(t, published.ID, event.ID)
+	assert.Equal(t, "accepted", event.Data)
+	assert.Len(t, sub.Events(), 0)
+}
+
+func TestEventHubReplaysMissedEvents(t *testing.T) {
+	hub := NewEventHub()
+	first := hub.Publish(TopicAdmin, EventDriverLocation, 1)
+	hub.Publish(TopicAdmin, EventDriverLocation, 2)
+	hub.Publish(TopicAdmin, EventDriverLocation, 3)
+
+	events := hub.Replay([]string{TopicAdmin}, first.ID)
+	assert.Len(t, events, 2)
+	assert.Equal(t, 2, events[0].Data)
+	assert.Equal(t, 3, events[1].Data)
+}
+
+func TestEventHubEvictsSlowSubscribers(t *testing.T) {
+	hub := NewEventHub()
+	sub := hub.Subscribe(TopicAdmin)
+
+	for i := 0; i <= eventHubBufferSize; i++ {
+		hub.Publish(TopicAdmin, EventDriverLocation, i)
+	}
+
+	assert.Equal(t, 0, hub.SubscriberCount(TopicAdmin))
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+	assert.Equal(t, eventHubBufferSize, received)
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Fare Engine
+// ===========
+// Tarif = base (Tariff.Price) + per-km + per-menit, dikali multiplier jenis
+// kendaraan, minimal Tariff.MinimumFare, lalu dibulatkan ke atas. Band tarif
+// dipilih dari tariff aktif dengan program yang sama (subsidi, gojek,
+// non-tunai) yang mencakup jarak perjalanan.
+
+// ErrTariffNotFound is returned when no tariff can price a trip
+var ErrTariffNotFound = errors.New("tariff not found")
+
+type FareConfig struct {
+	// VehicleMultipliers scale the fare per vehicle type; missing types use 1
+	VehicleMultipliers map[models.VehicleType]float64
+	// SubsidyRate is the share of a subsidi fare covered by the program (0-1)
+	SubsidyRate float64
+	// NonTunaiFeeRate is an optional surcharge on non-tunai fares (e.g. 0.007 for MDR)
+	NonTunaiFeeRate float64
+	// RoundTo rounds the total up to a multiple of this amount in Rupiah
+	RoundTo float64
+	// WaitingPerMinute is charged for waiting at stops beyond FreeWaitingMinutes
+	WaitingPerMinute   float64
+	FreeWaitingMinutes int
+	// Speeds estimate trip duration for the per-minute component
+	Speeds SpeedProfiles
+}
+
+// DefaultVehicleMultipliers reflect operating cost relative to a manual becak
+var DefaultVehicleMultipliers = map[models.VehicleType]float64{
+	models.VehicleTypeBecakManual:  1.0,
+	models.VehicleTypeBecakListrik: 1.1,
+	models.VehicleTypeBecakMotor:   1.2,
+	models.VehicleTypeAndong:       1.5,
+}
+
+// LoadFareConfig reads fare settings from the environment
+func LoadFareConfig() FareConfig {
+	multipliers := make(map[models.VehicleType]float64, len(DefaultVehicleMultipliers))
+	for vehicle, multiplier := range DefaultVehicleMultipliers {
+		key := "FARE_MULTIPLIER_" + strings.ToUpper(string(vehicle))
+		multipliers[vehicle] = envFloat(key, multiplier)
+	}
+
+	return FareConfig{
+		VehicleMultipliers: multipliers,
+		SubsidyRate:        math.Min(math.Max(envFloat("FARE_SUBSIDY_RATE", 1), 0), 1),
+		NonTunaiFeeRate:    envFloat("FARE_NON_TUNAI_FEE_RATE", 0),
+		RoundTo:            envFloat("FARE_ROUND_TO", 500),
+		WaitingPerMinute:   envFloat("FARE_WAITING_PER_MINUTE", 250),
+		FreeWaitingMinutes: int(envFloat("FARE_FREE_WAITING_MINUTES", 5)),
+		Speeds:             DefaultSpeedProfiles,
+	}
+}
+
+// Multiplier returns the fare multiplier for a vehicle type
+func (c FareConfig) Multiplier(vehicle models.VehicleType) float64 {
+	if multiplier, ok := c.VehicleMultipliers[vehicle]; ok && multiplier > 0 {
+		return multiplier
+	}
+	return 1
+}
+
+// SameFareProgram reports whether two tariffs belong to the same fare program
+func SameFareProgram(a, b models.Tariff) bool {
+	return a.IsSubsidi == b.IsSubsidi && a.IsGojek == b.IsGojek && a.IsNonTunai == b.IsNonTunai
+}
+
+// SelectTariffBand picks the band among tariffs (same program as requested, active)
+// that covers distanceKm. Trips longer than every band use the longest band and
+// trips shorter than every band use the shortest; with no candidate bands the
+// requested tariff is used as-is.
+func SelectTariffBand(tariffs []models.Tariff, requested models.Tariff, distanceKm float64) models.Tariff {
+	var bands []models.Tariff
+	for _, tariff := range tariffs {
+		if tariff.IsActive && SameFareProgram(tariff, requested) {
+			bands = append(bands, tariff)
+		}
+	}
+	if len(bands) == 0 {
+		return requested
+	}
+
+	sort.SliceStable(bands, func(i, j int) bool {
+		if bands[i].MinDistance != bands[j].MinDistance {
+			return bands[i].MinDistance < bands[j].MinDistance
+		}
+		return bands[i].MaxDistance < bands[j].MaxDistance
+	})
+
+	for _, band := range bands {
+		if distanceKm >= band.MinDistance && distanceKm <= band.MaxDistance {
+			return band
+		}
+	}
+
+	if distanceKm < bands[0].MinDistance {
+		return bands[0]
+	}
+	longest := bands[0]
+	for _, band := range bands[1:] {
+		if band.MaxDistance > longest.MaxDistance {
+			longest = band
+		}
+	}
+	return longest
+}
+
+// CalculateFare prices a trip on a tariff band and returns the itemized breakdown.
+// Gojek and subsidi tariffs are program fares and are not scaled by vehicle type.
+func CalculateFare(tariff models.Tariff, distanceKm float64, vehicle models.VehicleType, config FareConfig) models.FareBreakdown {
+	return CalculateTripFare(tariff, distanceKm, 0, vehicle, config)
+}
+
+// CalculateTripFare is CalculateFare for trips with stops: distanceKm is the total
+// route distance and waitMinutes the waiting time at stops.
+func CalculateTripFare(tariff models.Tariff, distanceKm float64, waitMinutes int, vehicle models.VehicleType, config FareConfig) models.FareBreakdown {
+	if vehicle == "" {
+		vehicle = models.VehicleTypeBecakManual
+	}
+	speeds := config.Speeds
+	if speeds == nil {
+		speeds = DefaultSpeedProfiles
+	}
+	minutes := DurationMinutes(speeds.TravelTime(distanceKm, vehicle))
+
+	breakdown := models.FareBreakdown{
+		TariffID:          tariff.ID,
+		TariffName:        tariff.Name,
+		VehicleType:       vehicle,
+		DistanceKm:        distanceKm,
+		DurationMinutes:   minutes,
+		WaitMinutes:       waitMinutes,
+		VehicleMultiplier: 1,
+		IsSubsidi:         tariff.IsSubsidi,
+		IsGojek:           tariff.IsGojek,
+		IsNonTunai:        tariff.IsNonTunai,
+	}
+
+	total := 0.0
+	add := func(code, label string, amount float64) {
+		amount = roundRupiah(amount)
+		if amount == 0 && code != models.FareItemBase {
+			return
+		}
+		breakdown.Items = append(breakdown.Items, models.FareItem{Code: code, Label: label, Amount: amount})
+		total += amount
+	}
+
+	add(models.FareItemBase, "Tarif dasar", tariff.Price)
+	add(models.FareItemDistance, fmt.Sprintf("Jarak %.2f km x Rp%.0f", distanceKm, tariff.PerKm), tariff.PerKm*distanceKm)
+	add(models.FareItemTime, fmt.Sprintf("Waktu %d menit x Rp%.0f", minutes, tariff.PerMinute), tariff.PerMinute*float64(minutes))
+	if charged := waitMinutes - config.FreeWaitingMinutes; charged > 0 {
+		add(models.FareItemWaiting, fmt.Sprintf("Waktu tunggu %d menit x Rp%.0f", charged, config.WaitingPerMinute), config.WaitingPerMinute*float64(charged))
+	}
+
+	if !tariff.IsGojek && !tariff.IsSubsidi {
+		breakdown.VehicleMultiplier = config.Multiplier(vehicle)
+		add(models.FareItemVehicleMultiplier, fmt.Sprintf("Kendaraan %s x%.2f", vehicle, breakdown.VehicleMultiplier), total*(breakdown.VehicleMultiplier-1))
+	}
+
+	if total < tariff.MinimumFare {
+		add(models.FareItemMinimumFare, "Penyesuaian tarif minimum", tariff.MinimumFare-total)
+	}
+
+	if tariff.IsNonTunai && config.NonTunaiFeeRate > 0 {
+		add(models.FareItemNonTunaiFee, "Biaya pembayaran non-tunai", total*config.NonTunaiFeeRate)
+	}
+
+	if config.RoundTo > 0 {
+		add(models.FareItemRounding, "Pembulatan", math.Ceil(total/config.RoundTo)*config.RoundTo-total)
+	}
+
+	breakdown.Total = roundRupiah(total)
+	if tariff.IsSubsidi {
+		breakdown.Subsidy = roundRupiah(breakdown.Total * config.SubsidyRate)
+	}
+	breakdown.CustomerTotal = breakdown.Total - breakdown.Subsidy
+	return breakdown
+}
+
+// HasFixedFare reports whether an order's fare was set by a package or a fare
+// quote, so it must not be repriced from the trip distance
+func HasFixedFare(order models.Order) bool {
+	return order.PackageID != nil || (order.FareBreakdown != nil && order.FareBreakdown.Quoted)
+}
+
+// AddWaitingCharge adds the waiting an order ran up beyond what its fixed
+// fare was priced for as a separate line item, leaving the rest of the fare
+// as quoted. It reports whether the fare changed.
+func AddWaitingCharge(order *models.Order, config FareConfig) bool {
+	if order.FareBreakdown == nil {
+		return false
+	}
+	fare := *order.FareBreakdown
+	priced := max(fare.WaitMinutes-config.FreeWaitingMinutes, 0)
+	charged := max(order.WaitMinutes-config.FreeWaitingMinutes, 0)
+	amount := roundRupiah(config.WaitingPerMinute * float64(charged-priced))
+	if charged <= priced || amount <= 0 {
+		return false
+	}
+
+	fare.Items = append(append([]models.FareItem(nil), fare.Items...), models.FareItem{
+		Code:   models.FareItemWaiting,
+		Label:  fmt.Sprintf("Tambahan waktu tunggu %d menit x Rp%.0f", charged-priced, config.WaitingPerMinute),
+		Amount: amount,
+	})
+	fare.WaitMinutes = order.WaitMinutes
+	fare.Total += amount
+	fare.CustomerTotal += amount
+	order.Price = fare.Total
+	order.FareBreakdown = &fare
+	return true
+}
+
+// QuoteFare loads the requested tariff, picks the band for distanceKm and prices the
+// trip including waitMinutes of waiting at stops
+func QuoteFare(db *gorm.DB, tariffID uint, distanceKm float64, waitMinutes int, vehicle models.VehicleType, config FareConfig) (models.Tariff, models.FareBreakdown, error) {
+	var requested models.Tariff
+	if err := db.First(&requested, tariffID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Tariff{}, models.FareBreakdown{}, ErrTariffNotFound
+		}
+		return models.Tariff{}, models.FareBreakdown{}, err
+	}
+
+	var tariffs []models.Tariff
+	if err := db.Where("is_active = ? AND is_subsidi = ? AND is_gojek = ? AND is_non_tunai = ?",
+		true, requested.IsSubsidi, requested.IsGojek, requested.IsNonTunai).Find(&tariffs).Error; err != nil {
+		return models.Tariff{}, models.FareBreakdown{}, err
+	}
+
+	band := SelectTariffBand(tariffs, requested, distanceKm)
+	return band, CalculateTripFare(band, distanceKm, waitMinutes, vehicle, config), nil
+}
+
+// roundRupiah rounds to whole Rupiah
+func roundRupiah(amount float64) float64 {
+	return math.Round(amount)
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Fare quotes are signed with a key derived from the server secret so that a
+// quote token can never be mistaken for an auth token and vice versa.
+
+const fareQuoteSubject = "fare_quote"
+
+var (
+	ErrQuoteExpired = errors.New("fare quote expired")
+	ErrQuoteInvalid = errors.New("invalid fare quote")
+)
+
+// PlaceLabel is the address shown for a quoted pickup or drop point
+type PlaceLabel struct {
+	Address  string `json:"address,omitempty"`
+	Landmark string `json:"landmark,omitempty"`
+	PlaceID  string `json:"place_id,omitempty"`
+}
+
+// FareQuote is a priced trip a customer can lock in when creating an order.
+// A quote is an offer, not a reservation: the same token may create several
+// orders (e.g. two becaks for one group) until it expires.
+type FareQuote struct {
+	ID              string               `json:"id"`
+	TariffID        uint                 `json:"tariff_id"`
+	VehicleType     models.VehicleType   `json:"vehicle_type"`
+	BecakCode       string               `json:"becak_code,omitempty"`
+	Pickup          geo.Point            `json:"pickup"`
+	Drop            geo.Point            `json:"drop"`
+	PickupLabel     PlaceLabel           `json:"pickup_label"`
+	DropLabel       PlaceLabel           `json:"drop_label"`
+	Stops           []geo.Point          `json:"stops,omitempty"`
+	RoundTrip       bool                 `json:"round_trip,omitempty"`
+	WaitMinutes     int                  `json:"wait_minutes,omitempty"`
+	DistanceKm      float64              `json:"distance_km"`
+	DurationMinutes int                  `json:"duration_minutes"`
+	Fare            models.FareBreakdown `json:"fare"`
+	ExpiresAt       time.Time            `json:"expires_at"`
+}
+
+type fareQuoteClaims struct {
+	Quote FareQuote `json:"quote"`
+	jwt.RegisteredClaims
+}
+
+func fareQuoteKey(secret string) []byte {
+	key := sha256.Sum256([]byte("greenbecak/fare-quote/" + secret))
+	return key[:]
+}
+
+// SignFareQuote stamps the quote with an ID and expiry and returns its token
+func SignFareQuote(quote *FareQuote, secret string, ttl time.Duration) (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	quote.ID = hex.EncodeToString(id)
+	quote.ExpiresAt = now.Add(ttl).Truncate(time.Second)
+
+	claims := fareQuoteClaims{
+		Quote: *quote,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        quote.ID,
+			Subject:   fareQuoteSubject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(quote.ExpiresAt),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(fareQuoteKey(secret))
+}
+
+// VerifyFareQuote checks a quote token's signature and expiry and returns the quote
+func VerifyFareQuote(token, secret string) (*FareQuote, error) {
+	claims := &fareQuoteClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return fareQuoteKey(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithSubject(fareQuoteSubject))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrQuoteExpired
+		}
+		return nil, ErrQuoteInvalid
+	}
+	if !parsed.Valid || claims.Quote.ID != claims.ID {
+		return nil, ErrQuoteInvalid
+	}
+	return &claims.Quote, nil
+}
+
+// OrderPickupLabel returns the order's pickup address for a quote
+func OrderPickupLabel(order models.Order) PlaceLabel {
+	return PlaceLabel{Address: order.PickupLocation, Landmark: order.PickupLandmark, PlaceID: order.PickupPlaceID}
+}
+
+// OrderDropLabel returns the order's drop address for a quote
+func OrderDropLabel(order models.Order) PlaceLabel {
+	return PlaceLabel{Address: order.DropLocation, Landmark: order.DropLandmark, PlaceID: order.DropPlaceID}
+}
+
+// ApplyFareQuote locks the quoted trip and price onto the order. Pickup and
+// drop addresses come from the quote too, so they always match its coordinates.
+func ApplyFareQuote(order *models.Order, quote FareQuote) {
+	pickup, drop := quote.Pickup, quote.Drop
+	order.TariffID = quote.TariffID
+	order.PickupLat, order.PickupLng = &pickup.Lat, &pickup.Lng
+	order.DropLat, order.DropLng = &drop.Lat, &drop.Lng
+	order.PickupLocation = quote.PickupLabel.Address
+	order.PickupLandmark = quote.PickupLabel.Landmark
+	order.PickupPlaceID = quote.PickupLabel.PlaceID
+	order.DropLocation = quote.DropLabel.Address
+	order.DropLandmark = quote.DropLabel.Landmark
+	order.DropPlaceID = quote.DropLabel.PlaceID
+	order.Distance = quote.DistanceKm
+	order.Price = quote.Fare.Total
+	fare := quote.Fare
+	fare.Quoted = true
+	order.FareBreakdown = &fare
+}
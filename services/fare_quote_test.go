@@ -0,0 +1,89 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testQuoteSecret = "test-secret-at-least-16-chars"
+
+func testQuote() FareQuote {
+	return FareQuote{
+		TariffID:    2,
+		VehicleType: models.VehicleTypeBecakMotor,
+		BecakCode:   "DRV-001",
+		Pickup:      tuguJogja,
+		Drop:        kraton,
+		PickupLabel: PlaceLabel{Address: "Tugu Jogja", PlaceID: "osm:123"},
+		DropLabel:   PlaceLabel{Address: "Kraton", Landmark: "Alun-alun utara"},
+		DistanceKm:  2.9,
+		Fare:        models.FareBreakdown{TariffID: 2, Total: 15500, CustomerTotal: 15500},
+	}
+}
+
+func TestFareQuoteRoundTrip(t *testing.T) {
+	quote := testQuote()
+	token, err := SignFareQuote(&quote, testQuoteSecret, 5*time.Minute)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, quote.ID)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), quote.ExpiresAt, 2*time.Second)
+
+	verified, err := VerifyFareQuote(token, testQuoteSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, quote.ID, verified.ID)
+	assert.Equal(t, 15500.0, verified.Fare.Total)
+	assert.Equal(t, kraton, verified.Drop)
+
+	// Addresses sent with the order are replaced by the quoted ones
+	order := models.Order{PickupLocation: "Somewhere else", DropLandmark: "Other landmark"}
+	ApplyFareQuote(&order, *verified)
+	assert.Equal(t, "Tugu Jogja", order.PickupLocation)
+	assert.Equal(t, "osm:123", order.PickupPlaceID)
+	assert.Equal(t, "Kraton", order.DropLocation)
+	assert.Equal(t, "Alun-alun utara", order.DropLandmark)
+	assert.Equal(t, uint(2), order.TariffID)
+	assert.Equal(t, 2.9, order.Distance)
+	assert.Equal(t, 15500.0, order.Price)
+	assert.Equal(t, kraton.Lat, *order.DropLat)
+}
+
+func TestFareQuoteRejected(t *testing.T) {
+	quote := testQuote()
+	token, err := SignFareQuote(&quote, testQuoteSecret, 5*time.Minute)
+	assert.NoError(t, err)
+
+	expiredQuote := testQuote()
+	expired, err := SignFareQuote(&expiredQuote, testQuoteSecret, -time.Minute)
+	assert.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	tamperedPayload := parts[0] + "." + parts[1][:len(parts[1])-4] + "AAAA." + parts[2]
+
+	// A JWT signed with the raw secret (e.g. a login token) is not a quote
+	otherQuote := testQuote()
+	wrongKey, err := SignFareQuote(&otherQuote, "another-secret-value", 5*time.Minute)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"expired", expired, ErrQuoteExpired},
+		{"tampered payload", tamperedPayload, ErrQuoteInvalid},
+		{"wrong secret", wrongKey, ErrQuoteInvalid},
+		{"garbage", "not-a-token", ErrQuoteInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyFareQuote(tt.token, testQuoteSecret)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
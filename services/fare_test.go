@@ -0,0 +1,204 @@
+package services
+
+import (
+	"testing"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testFareConfig() FareConfig {
+	return FareConfig{
+		VehicleMultipliers: DefaultVehicleMultipliers,
+		SubsidyRate:        1,
+		RoundTo:            500,
+		Speeds:             DefaultSpeedProfiles,
+	}
+}
+
+func fareItem(breakdown models.FareBreakdown, code string) (models.FareItem, bool) {
+	for _, item := range breakdown.Items {
+		if item.Code == code {
+			return item, true
+		}
+	}
+	return models.FareItem{}, false
+}
+
+func TestSelectTariffBand(t *testing.T) {
+	short := models.Tariff{ID: 1, Name: "Dekat", MinDistance: 0, MaxDistance: 2, IsActive: true}
+	medium := models.Tariff{ID: 2, Name: "Sedang", MinDistance: 2, MaxDistance: 5, IsActive: true}
+	long := models.Tariff{ID: 3, Name: "Jauh", MinDistance: 5, MaxDistance: 10, IsActive: true}
+	inactive := models.Tariff{ID: 4, Name: "Lama", MinDistance: 0, MaxDistance: 20, IsActive: false}
+	subsidi := models.Tariff{ID: 5, Name: "Subsidi", MinDistance: 0, MaxDistance: 10, IsActive: true, IsSubsidi: true}
+	tariffs := []models.Tariff{long, inactive, subsidi, medium, short}
+
+	tests := []struct {
+		name      string
+		requested models.Tariff
+		distance  float64
+		want      uint
+	}{
+		{"inside first band", short, 1.2, 1},
+		{"band boundary picks lower band", long, 2, 1},
+		{"inside middle band", short, 3.4, 2},
+		{"longer than every band", short, 14, 3},
+		{"program is kept", subsidi, 3.4, 5},
+		{"no active band in program", models.Tariff{ID: 9, IsGojek: true}, 3, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SelectTariffBand(tariffs, tt.requested, tt.distance).ID)
+		})
+	}
+}
+
+func TestCalculateFare(t *testing.T) {
+	metered := models.Tariff{ID: 1, Name: "Kota", Price: 5000, PerKm: 2000, PerMinute: 200, MinimumFare: 10000}
+
+	tests := []struct {
+		name         string
+		tariff       models.Tariff
+		distance     float64
+		vehicle      models.VehicleType
+		wantTotal    float64
+		wantMinutes  int
+		wantCustomer float64
+		wantItems    []string
+	}{
+		{
+			name: "becak manual", tariff: metered, distance: 3, vehicle: models.VehicleTypeBecakManual,
+			wantTotal: 15000, wantMinutes: 20, wantCustomer: 15000,
+			wantItems: []string{models.FareItemBase, models.FareItemDistance, models.FareItemTime},
+		},
+		{
+			name: "becak motor multiplier and rounding", tariff: metered, distance: 3, vehicle: models.VehicleTypeBecakMotor,
+			wantTotal: 15500, wantMinutes: 9, wantCustomer: 15500,
+			wantItems: []string{models.FareItemBase, models.FareItemDistance, models.FareItemTime, models.FareItemVehicleMultiplier, models.FareItemRounding},
+		},
+		{
+			name: "minimum fare", tariff: metered, distance: 0.5, vehicle: models.VehicleTypeBecakManual,
+			wantTotal: 10000, wantMinutes: 4, wantCustomer: 10000,
+			wantItems: []string{models.FareItemBase, models.FareItemDistance, models.FareItemTime, models.FareItemMinimumFare},
+		},
+		{
+			name: "flat legacy tariff with andong", tariff: models.Tariff{Price: 15000}, distance: 4, vehicle: models.VehicleTypeAndong,
+			wantTotal: 22500, wantMinutes: 22, wantCustomer: 22500,
+			wantItems: []string{models.FareItemBase, models.FareItemVehicleMultiplier},
+		},
+		{
+			name: "subsidi is not scaled and fully covered", tariff: models.Tariff{Price: 10000, IsSubsidi: true}, distance: 4, vehicle: models.VehicleTypeBecakMotor,
+			wantTotal: 10000, wantMinutes: 12, wantCustomer: 0,
+			wantItems: []string{models.FareItemBase},
+		},
+		{
+			name: "gojek is not scaled", tariff: models.Tariff{Price: 12000, IsGojek: true}, distance: 2, vehicle: models.VehicleTypeAndong,
+			wantTotal: 12000, wantMinutes: 11, wantCustomer: 12000,
+			wantItems: []string{models.FareItemBase},
+		},
+		{
+			name: "empty vehicle type defaults to becak manual", tariff: metered, distance: 3, vehicle: "",
+			wantTotal: 15000, wantMinutes: 20, wantCustomer: 15000,
+			wantItems: []string{models.FareItemBase, models.FareItemDistance, models.FareItemTime},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fare := CalculateFare(tt.tariff, tt.distance, tt.vehicle, testFareConfig())
+
+			assert.Equal(t, tt.wantTotal, fare.Total)
+			assert.Equal(t, tt.wantMinutes, fare.DurationMinutes)
+			assert.Equal(t, tt.wantCustomer, fare.CustomerTotal)
+
+			var codes []string
+			sum := 0.0
+			for _, item := range fare.Items {
+				codes = append(codes, item.Code)
+				sum += item.Amount
+			}
+			assert.Equal(t, tt.wantItems, codes)
+			assert.Equal(t, fare.Total, sum, "items add up to the total")
+		})
+	}
+}
+
+func TestCalculateFareSubsidyRateAndNonTunaiFee(t *testing.T) {
+	config := testFareConfig()
+	config.SubsidyRate = 0.5
+	config.NonTunaiFeeRate = 0.007
+
+	subsidi := CalculateFare(models.Tariff{Price: 10000, IsSubsidi: true}, 2, models.VehicleTypeBecakManual, config)
+	assert.Equal(t, 5000.0, subsidi.Subsidy)
+	assert.Equal(t, 5000.0, subsidi.CustomerTotal)
+
+	nonTunai := CalculateFare(models.Tariff{Price: 15000, IsNonTunai: true}, 2, models.VehicleTypeBecakManual, config)
+	fee, ok := fareItem(nonTunai, models.FareItemNonTunaiFee)
+	assert.True(t, ok)
+	assert.Equal(t, 105.0, fee.Amount)
+	assert.Equal(t, 15500.0, nonTunai.Total)
+}
+
+func TestCalculateTripFareWaiting(t *testing.T) {
+	config := testFareConfig()
+	config.WaitingPerMinute = 250
+	config.FreeWaitingMinutes = 5
+	tariff := models.Tariff{Price: 5000, PerKm: 2000}
+
+	withinFree := CalculateTripFare(tariff, 2, 5, models.VehicleTypeBecakManual, config)
+	_, ok := fareItem(withinFree, models.FareItemWaiting)
+	assert.False(t, ok, "free waiting minutes are not charged")
+	assert.Equal(t, 5, withinFree.WaitMinutes)
+
+	waited := CalculateTripFare(tariff, 2, 25, models.VehicleTypeBecakManual, config)
+	item, ok := fareItem(waited, models.FareItemWaiting)
+	assert.True(t, ok)
+	assert.Equal(t, 5000.0, item.Amount)
+	assert.Equal(t, withinFree.Total+5000, waited.Total)
+}
+
+func TestHasFixedFare(t *testing.T) {
+	assert.False(t, HasFixedFare(models.Order{FareBreakdown: &models.FareBreakdown{Total: 10000}}))
+	assert.True(t, HasFixedFare(models.Order{FareBreakdown: &models.FareBreakdown{Total: 10000, Quoted: true}}))
+	assert.True(t, HasFixedFare(models.Order{PackageID: uintPtr(3)}))
+
+	order := models.Order{}
+	ApplyFareQuote(&order, FareQuote{Fare: models.FareBreakdown{Total: 12000}})
+	assert.True(t, HasFixedFare(order), "quoted fares are locked")
+}
+
+func TestAddWaitingCharge(t *testing.T) {
+	config := FareConfig{FreeWaitingMinutes: 5, WaitingPerMinute: 500}
+	quoted := models.FareBreakdown{
+		WaitMinutes:   10,
+		Items:         []models.FareItem{{Code: models.FareItemBase, Amount: 15000}},
+		Total:         15000,
+		CustomerTotal: 15000,
+		Quoted:        true,
+	}
+	order := models.Order{WaitMinutes: 10, Price: 15000, FareBreakdown: &quoted}
+
+	assert.False(t, AddWaitingCharge(&order, config), "waiting within the quote is already priced")
+
+	order.WaitMinutes = 16
+	assert.True(t, AddWaitingCharge(&order, config))
+	assert.Equal(t, 18000.0, order.Price, "6 extra minutes on top of the quoted price")
+	assert.Equal(t, 18000.0, order.FareBreakdown.CustomerTotal)
+	assert.Equal(t, 16, order.FareBreakdown.WaitMinutes)
+	item, ok := fareItem(*order.FareBreakdown, models.FareItemWaiting)
+	assert.True(t, ok)
+	assert.Equal(t, 3000.0, item.Amount)
+	assert.Len(t, quoted.Items, 1, "the original breakdown is not modified")
+
+	assert.False(t, AddWaitingCharge(&order, config), "the same waiting is not charged twice")
+
+	// Free minutes that the quote did not use are still free
+	short := models.FareBreakdown{Total: 10000, CustomerTotal: 10000, Quoted: true}
+	order = models.Order{WaitMinutes: 4, Price: 10000, FareBreakdown: &short}
+	assert.False(t, AddWaitingCharge(&order, config))
+	order.WaitMinutes = 8
+	assert.True(t, AddWaitingCharge(&order, config))
+	assert.Equal(t, 11500.0, order.Price)
+}
@@ -232,6 +232,22 @@ func (fs *FirebaseService) SendOrderCompletedNotification(driverToken string, or
 	return fs.SendToDevice(driverToken, notification, data)
 }
 
+// Send order cancelled notification
+func (fs *FirebaseService) SendOrderCancelledNotification(driverToken string, orderData map[string]interface{}) error {
+	notification := FCMMessageNotification{
+		Title: "❌ Pesanan Dibatalkan",
+		Body:  fmt.Sprintf("Pesanan %v dibatalkan", orderData["order_number"]),
+	}
+
+	data := map[string]string{
+		"type":    "order_cancelled",
+		"orderId": fmt.Sprintf("%v", orderData["id"]),
+		"reason":  fmt.Sprintf("%v", orderData["reason_code"]),
+	}
+
+	return fs.SendToDevice(driverToken, notification, data)
+}
+
 // Send withdrawal approved notification
 func (fs *FirebaseService) SendWithdrawalApprovedNotification(driverToken string, withdrawalData map[string]interface{}) error {
 	notification := FCMMessageNotification{
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Idempotency Keys
+// ================
+// Request dengan header Idempotency-Key diklaim di tabel idempotency_records
+// sebelum handler berjalan. Retry dengan key dan body yang sama mendapat
+// respons yang tersimpan; key yang sama dengan body berbeda ditolak. Unique
+// index pada scope_key membuat klaim aman di beberapa replica.
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is reused for a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+	// ErrIdempotencyInProgress is returned while the first request with a key is still running
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is still in progress")
+)
+
+const (
+	idempotencyPruneBatch = 1000
+	// A key still processing after this long belongs to a request that died
+	// mid-way (e.g. the instance restarted) and may be claimed again
+	idempotencyProcessingTimeout = time.Minute
+)
+
+// IdempotencyAnonymousScope is the scope shared by every caller without a valid token
+const IdempotencyAnonymousScope = "anonymous"
+
+// IdempotencyTTL returns how long stored responses are replayed
+func IdempotencyTTL() time.Duration {
+	return envDuration("IDEMPOTENCY_TTL", 24*time.Hour)
+}
+
+// IdempotencyPruneInterval returns how often expired records are deleted; 0 disables pruning
+func IdempotencyPruneInterval() time.Duration {
+	return envDuration("IDEMPOTENCY_PRUNE_INTERVAL", time.Hour)
+}
+
+// IdempotencyScopeKey identifies a key within the caller's scope (user or anonymous) and route
+func IdempotencyScopeKey(scope, route, key string) string {
+	return sha256Hex(scope + "\n" + route + "\n" + key)
+}
+
+// IdempotencyFingerprint identifies the request a key was first used with
+func IdempotencyFingerprint(method, path string, body []byte) string {
+	return sha256Hex(method + " " + path + "\n" + string(body))
+}
+
+func sha256Hex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClaimIdempotencyKey reserves scopeKey for the request with fingerprint. It
+// returns the stored record (and no error) when the same request already
+// completed, so the caller can replay its response; nil when the caller now
+// owns the key and should run the request.
+func ClaimIdempotencyKey(db *gorm.DB, scopeKey, route, fingerprint string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	now := time.Now()
+	record := models.IdempotencyRecord{
+		ScopeKey:    scopeKey,
+		Route:       route,
+		Fingerprint: fingerprint,
+		Status:      models.IdempotencyStatusProcessing,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		err := db.Create(&record).Error
+		if err == nil {
+			return nil, nil
+		}
+		if !IsDuplicateKeyError(err) {
+			return nil, err
+		}
+
+		var existing models.IdempotencyRecord
+		if err := db.Where("scope_key = ?", scopeKey).First(&existing).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue // released between our insert and read
+			}
+			return nil, err
+		}
+
+		reclaim, err := ResolveIdempotencyClaim(existing, fingerprint, now)
+		if reclaim {
+			// Only one request wins the delete; the others see its new claim
+			db.Where("id = ? AND (expires_at < ? OR (status = ? AND created_at < ?))", existing.ID, now,
+				models.IdempotencyStatusProcessing, now.Add(-idempotencyProcessingTimeout)).Delete(&models.IdempotencyRecord{})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+	return nil, ErrIdempotencyInProgress
+}
+
+// ResolveIdempotencyClaim decides what a request with fingerprint gets when
+// existing already holds its key: reclaim is true when the record expired or
+// was abandoned mid-way and may be claimed again; otherwise a nil error means
+// the stored response is replayed.
+func ResolveIdempotencyClaim(existing models.IdempotencyRecord, fingerprint string, now time.Time) (bool, error) {
+	if existing.ExpiresAt.Before(now) {
+		return true, nil
+	}
+	if existing.Status == models.IdempotencyStatusProcessing && existing.CreatedAt.Before(now.Add(-idempotencyProcessingTimeout)) {
+		return true, nil
+	}
+	if existing.Fingerprint != fingerprint {
+		return false, ErrIdempotencyKeyReused
+	}
+	if existing.Status != models.IdempotencyStatusCompleted {
+		return false, ErrIdempotencyInProgress
+	}
+	return false, nil
+}
+
+// IdempotencyStoresResponse reports whether a response with status is kept
+// for replay. Server errors release the key so the client can retry with it.
+func IdempotencyStoresResponse(status int) bool {
+	return status < http.StatusInternalServerError
+}
+
+// CompleteIdempotencyKey stores the response for replay
+func CompleteIdempotencyKey(db *gorm.DB, scopeKey string, status int, contentType string, body []byte) error {
+	return db.Model(&models.IdempotencyRecord{}).
+		Where("scope_key = ?", scopeKey).
+		Updates(map[string]interface{}{
+			"status":          models.IdempotencyStatusCompleted,
+			"response_status": status,
+			"content_type":    contentType,
+			"response_body":   string(body),
+		}).Error
+}
+
+// ReleaseIdempotencyKey forgets a claimed key so the request can be retried,
+// used when the request failed with a server error
+func ReleaseIdempotencyKey(db *gorm.DB, scopeKey string) error {
+	return db.Where("scope_key = ? AND status = ?", scopeKey, models.IdempotencyStatusProcessing).
+		Delete(&models.IdempotencyRecord{}).Error
+}
+
+// PruneIdempotencyRecords deletes expired records in batches and returns how many were deleted
+func PruneIdempotencyRecords(db *gorm.DB, now time.Time) (int64, error) {
+	var total int64
+	for {
+		result := db.Exec("DELETE FROM idempotency_records WHERE expires_at < ? LIMIT ?", now, idempotencyPruneBatch)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < idempotencyPruneBatch {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	body := []byte(`{"order_id":1,"method":"cash","amount":10000}`)
+	fingerprint := IdempotencyFingerprint("POST", "/api/payments/", body)
+
+	assert.Len(t, fingerprint, 64)
+	assert.Equal(t, fingerprint, IdempotencyFingerprint("POST", "/api/payments/", body))
+	assert.NotEqual(t, fingerprint, IdempotencyFingerprint("POST", "/api/payments/", []byte(`{"order_id":1,"method":"cash","amount":20000}`)))
+
+	// The same key from different users or on different routes does not collide
+	key := IdempotencyScopeKey("user:1", "POST /api/payments/", "abc")
+	assert.NotEqual(t, key, IdempotencyScopeKey("user:2", "POST /api/payments/", "abc"))
+	assert.NotEqual(t, key, IdempotencyScopeKey("user:1", "POST /api/driver/withdrawals", "abc"))
+}
+
+func TestResolveIdempotencyClaim(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	fingerprint := IdempotencyFingerprint("POST", "/api/orders/public", []byte(`{"becak_code":"BCK-001"}`))
+	record := models.IdempotencyRecord{
+		Fingerprint:    fingerprint,
+		Status:         models.IdempotencyStatusCompleted,
+		ResponseStatus: http.StatusCreated,
+		CreatedAt:      now.Add(-time.Hour),
+		ExpiresAt:      now.Add(23 * time.Hour),
+	}
+
+	// A completed request with the same body is replayed
+	reclaim, err := ResolveIdempotencyClaim(record, fingerprint, now)
+	assert.False(t, reclaim)
+	assert.NoError(t, err)
+
+	// The same key with another body is rejected
+	_, err = ResolveIdempotencyClaim(record, "other", now)
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// A request still running makes retries wait
+	inFlight := record
+	inFlight.Status = models.IdempotencyStatusProcessing
+	inFlight.CreatedAt = now.Add(-10 * time.Second)
+	reclaim, err = ResolveIdempotencyClaim(inFlight, fingerprint, now)
+	assert.False(t, reclaim)
+	assert.ErrorIs(t, err, ErrIdempotencyInProgress)
+
+	// A request that died mid-way gives its key up
+	inFlight.CreatedAt = now.Add(-2 * idempotencyProcessingTimeout)
+	reclaim, _ = ResolveIdempotencyClaim(inFlight, fingerprint, now)
+	assert.True(t, reclaim)
+
+	// Expired keys may be claimed again, whatever their body
+	record.ExpiresAt = now.Add(-time.Second)
+	reclaim, _ = ResolveIdempotencyClaim(record, "other", now)
+	assert.True(t, reclaim)
+}
+
+func TestIdempotencyStoresResponse(t *testing.T) {
+	assert.True(t, IdempotencyStoresResponse(http.StatusCreated))
+	assert.True(t, IdempotencyStoresResponse(http.StatusUnprocessableEntity), "client errors are replayed")
+	assert.False(t, IdempotencyStoresResponse(http.StatusInternalServerError), "server errors release the key")
+	assert.False(t, IdempotencyStoresResponse(http.StatusBadGateway))
+}
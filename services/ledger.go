@@ -0,0 +1,776 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Driver Wallet Ledger
+// ====================
+// Saldo driver dicatat dengan pembukuan berpasangan (double-entry): setiap
+// kejadian (order selesai, pencairan, refund, koreksi admin) menjadi satu
+// journal entry dengan posting yang jumlahnya nol. Posting tidak pernah
+// diubah; kesalahan dikoreksi dengan entry pembalik. Saldo wallet adalah
+// jumlah posting pada akun driver:{id}:wallet, bukan kolom yang di-update.
+
+var (
+	ErrUnbalancedEntry    = errors.New("journal entry postings do not balance")
+	ErrInvalidPosting     = errors.New("invalid ledger posting")
+	ErrEntryNotFound      = errors.New("journal entry not found")
+	ErrInsufficientFunds  = errors.New("insufficient wallet balance")
+	ErrNothingToReverse   = errors.New("journal entry has already been reversed")
+	ErrInvalidAdjustment  = errors.New("adjustment amount must not be zero")
+	ErrAdjustmentNoKey    = errors.New("adjustment needs a request key")
+	ErrInvalidRefundShare = errors.New("refund exceeds the order's recorded earning")
+)
+
+// Platform accounts
+var (
+	// Uang ongkos yang diterima platform dari customer
+	LedgerAccountClearing = models.LedgerAccount{Code: "platform:clearing", Name: "Fare clearing", Type: models.LedgerAccountAsset}
+	// Komisi platform dari setiap order
+	LedgerAccountCommission = models.LedgerAccount{Code: "platform:commission", Name: "Platform commission", Type: models.LedgerAccountRevenue}
+	// Rekening bank platform untuk pencairan saldo driver
+	LedgerAccountPayouts = models.LedgerAccount{Code: "platform:payouts", Name: "Driver payouts", Type: models.LedgerAccountAsset}
+	// Koreksi saldo manual oleh admin
+	LedgerAccountAdjustments = models.LedgerAccount{Code: "platform:adjustments", Name: "Wallet adjustments", Type: models.LedgerAccountExpense}
+	// Setoran tunai/transfer driver untuk melunasi komisi order tunai
+	LedgerAccountCollections = models.LedgerAccount{Code: "platform:collections", Name: "Driver settlements", Type: models.LedgerAccountAsset}
+)
+
+// DriverWalletAccount is the liability account holding what the platform owes a driver
+func DriverWalletAccount(driverID uint) models.LedgerAccount {
+	return models.LedgerAccount{
+		Code:     fmt.Sprintf("driver:%d:wallet", driverID),
+		Name:     fmt.Sprintf("Driver %d wallet", driverID),
+		Type:     models.LedgerAccountLiability,
+		DriverID: &driverID,
+	}
+}
+
+// DriverReceivableAccount is the asset account holding the commission a driver
+// owes the platform for fares collected in cash
+func DriverReceivableAccount(driverID uint) models.LedgerAccount {
+	return models.LedgerAccount{
+		Code:     fmt.Sprintf("driver:%d:receivable", driverID),
+		Name:     fmt.Sprintf("Driver %d receivable", driverID),
+		Type:     models.LedgerAccountAsset,
+		DriverID: &driverID,
+	}
+}
+
+// LedgerLine is one posting of a journal being recorded; Amount is positive
+// for a debit and negative for a credit
+type LedgerLine struct {
+	Account models.LedgerAccount
+	Amount  float64
+}
+
+func debit(account models.LedgerAccount, amount float64) LedgerLine {
+	return LedgerLine{Account: account, Amount: amount}
+}
+
+func credit(account models.LedgerAccount, amount float64) LedgerLine {
+	return LedgerLine{Account: account, Amount: -amount}
+}
+
+// JournalRequest describes a journal entry to record
+type JournalRequest struct {
+	Key           string
+	Type          models.JournalEntryType
+	ReferenceType string
+	ReferenceID   uint
+	ReversesID    *uint
+	Memo          string
+	CreatedBy     *uint
+	PostedAt      time.Time
+	Lines         []LedgerLine
+}
+
+// toCents compares ledger amounts without float drift
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// ValidateLines checks that a journal has at least two non-zero postings
+// that sum to zero. Zero lines are dropped from the returned slice.
+func ValidateLines(lines []LedgerLine) ([]LedgerLine, error) {
+	var kept []LedgerLine
+	var sum int64
+	for _, line := range lines {
+		if line.Account.Code == "" || line.Account.Type == "" {
+			return nil, fmt.Errorf("%w: posting without an account", ErrInvalidPosting)
+		}
+		if math.IsNaN(line.Amount) || math.IsInf(line.Amount, 0) {
+			return nil, fmt.Errorf("%w: amount for %s is not a number", ErrInvalidPosting, line.Account.Code)
+		}
+		cents := toCents(line.Amount)
+		if cents == 0 {
+			continue
+		}
+		sum += cents
+		kept = append(kept, LedgerLine{Account: line.Account, Amount: float64(cents) / 100})
+	}
+	if len(kept) < 2 {
+		return nil, fmt.Errorf("%w: an entry needs at least two non-zero postings", ErrInvalidPosting)
+	}
+	if sum != 0 {
+		return nil, fmt.Errorf("%w: off by %.2f", ErrUnbalancedEntry, float64(sum)/100)
+	}
+	return kept, nil
+}
+
+// ensureLedgerAccount returns the stored account for spec, creating it on first use
+func ensureLedgerAccount(tx *gorm.DB, spec models.LedgerAccount) (models.LedgerAccount, error) {
+	var account models.LedgerAccount
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LedgerAccount{
+		Code:     spec.Code,
+		Name:     spec.Name,
+		Type:     spec.Type,
+		DriverID: spec.DriverID,
+	}).Error
+	if err != nil {
+		return account, err
+	}
+	err = tx.Where("code = ?", spec.Code).First(&account).Error
+	return account, err
+}
+
+// PostJournal records a balanced journal entry. Keys make posting idempotent:
+// if an entry with the same key exists it is returned with created=false and
+// nothing is written.
+func PostJournal(tx *gorm.DB, req JournalRequest) (models.JournalEntry, bool, error) {
+	var entry models.JournalEntry
+	if req.Key == "" {
+		return entry, false, fmt.Errorf("%w: journal entry needs a key", ErrInvalidPosting)
+	}
+	lines, err := ValidateLines(req.Lines)
+	if err != nil {
+		return entry, false, err
+	}
+
+	if err := tx.Where("entry_key = ?", req.Key).First(&entry).Error; err == nil {
+		return entry, false, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return entry, false, err
+	}
+
+	postedAt := req.PostedAt
+	if postedAt.IsZero() {
+		postedAt = time.Now()
+	}
+
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		entry = models.JournalEntry{
+			Key:           req.Key,
+			Type:          req.Type,
+			ReferenceType: req.ReferenceType,
+			ReferenceID:   req.ReferenceID,
+			ReversesID:    req.ReversesID,
+			Memo:          req.Memo,
+			CreatedBy:     req.CreatedBy,
+			PostedAt:      postedAt,
+		}
+		if err := tx.Omit(clause.Associations).Create(&entry).Error; err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			account, err := ensureLedgerAccount(tx, line.Account)
+			if err != nil {
+				return err
+			}
+			posting := models.LedgerPosting{
+				EntryID:   entry.ID,
+				AccountID: account.ID,
+				Amount:    line.Amount,
+				PostedAt:  postedAt,
+			}
+			if err := tx.Omit(clause.Associations).Create(&posting).Error; err != nil {
+				return err
+			}
+			posting.Account = account
+			entry.Postings = append(entry.Postings, posting)
+		}
+		return nil
+	})
+	if err != nil && IsDuplicateKeyError(err) {
+		// Entry yang sama dicatat bersamaan oleh proses lain
+		var existing models.JournalEntry
+		if findErr := tx.Where("entry_key = ?", req.Key).First(&existing).Error; findErr == nil {
+			return existing, false, nil
+		}
+	}
+	if err != nil {
+		return models.JournalEntry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// ReverseJournal records an entry that cancels every posting of the entry
+// with the given key
+func ReverseJournal(tx *gorm.DB, key, reversalKey, memo string, createdBy *uint, now time.Time) (models.JournalEntry, bool, error) {
+	var original models.JournalEntry
+	if err := tx.Preload("Postings.Account").Where("entry_key = ?", key).First(&original).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return original, false, fmt.Errorf("%w: %s", ErrEntryNotFound, key)
+		}
+		return original, false, err
+	}
+
+	var reversed int64
+	if err := tx.Model(&models.JournalEntry{}).Where("reverses_id = ? AND entry_key <> ?", original.ID, reversalKey).Count(&reversed).Error; err != nil {
+		return original, false, err
+	}
+	if reversed > 0 {
+		return original, false, ErrNothingToReverse
+	}
+
+	lines := make([]LedgerLine, 0, len(original.Postings))
+	for _, posting := range original.Postings {
+		lines = append(lines, LedgerLine{Account: posting.Account, Amount: -posting.Amount})
+	}
+	return PostJournal(tx, JournalRequest{
+		Key:           reversalKey,
+		Type:          models.JournalEntryReversal,
+		ReferenceType: original.ReferenceType,
+		ReferenceID:   original.ReferenceID,
+		ReversesID:    &original.ID,
+		Memo:          memo,
+		CreatedBy:     createdBy,
+		PostedAt:      now,
+		Lines:         lines,
+	})
+}
+
+// CommissionRate is the share of each fare kept by the platform
+// (DRIVER_COMMISSION_RATE, 0.1 = 10%)
+func CommissionRate() float64 {
+	rate := envFloat("DRIVER_COMMISSION_RATE", 0)
+	if rate < 0 || rate > 1 {
+		return 0
+	}
+	return rate
+}
+
+// OrderEarningKey is the journal key of an order's completion entry
+func OrderEarningKey(orderID uint) string {
+	return fmt.Sprintf("order:%d:earning", orderID)
+}
+
+// EarningLines splits a completed order's fare between the driver's wallet
+// and the platform's commission
+func EarningLines(driverID uint, price, commission float64) []LedgerLine {
+	commission = math.Min(math.Max(commission, 0), price)
+	return []LedgerLine{
+		debit(LedgerAccountClearing, price),
+		credit(DriverWalletAccount(driverID), price-commission),
+		credit(LedgerAccountCommission, commission),
+	}
+}
+
+// CashCommissionLines charge the commission on a fare the driver collected
+// in cash to the driver's receivable
+func CashCommissionLines(driverID uint, commission float64) []LedgerLine {
+	return []LedgerLine{
+		debit(DriverReceivableAccount(driverID), commission),
+		credit(LedgerAccountCommission, commission),
+	}
+}
+
+// PostOrderEarning books a completed order. Fares collected by the platform
+// credit the driver's wallet less commission; fares paid in cash to the
+// driver only charge the commission to the driver's receivable, so a cash
+// order without commission posts nothing. Fares whose payment is still
+// pending post nothing until the payment settles (see PostLateOrderPayment).
+// Refunds paid before the order was earned are left out of the split.
+func PostOrderEarning(tx *gorm.DB, order models.Order, commission float64, collectedBy string, postedAt time.Time) (models.JournalEntry, bool, error) {
+	if order.DriverID == nil {
+		return models.JournalEntry{}, false, fmt.Errorf("%w: order %d has no driver", ErrInvalidPosting, order.ID)
+	}
+	if collectedBy == CollectedByPending {
+		return models.JournalEntry{}, false, nil
+	}
+
+	refunded, err := OrderRefunded(tx, order.ID)
+	if err != nil {
+		return models.JournalEntry{}, false, err
+	}
+	price, commission := NetFare(order.Price, commission, refunded)
+	if toCents(price) <= 0 {
+		return models.JournalEntry{}, false, nil
+	}
+
+	req := JournalRequest{
+		Key:           OrderEarningKey(order.ID),
+		Type:          models.JournalEntryEarning,
+		ReferenceType: "order",
+		ReferenceID:   order.ID,
+		Memo:          fmt.Sprintf("Order %s completed", order.OrderNumber),
+		PostedAt:      postedAt,
+		Lines:         EarningLines(*order.DriverID, price, commission),
+	}
+	if collectedBy == CollectedByDriver {
+		if toCents(commission) <= 0 {
+			return models.JournalEntry{}, false, nil
+		}
+		req.Memo = fmt.Sprintf("Order %s completed, paid in cash", order.OrderNumber)
+		req.Lines = CashCommissionLines(*order.DriverID, commission)
+	}
+	return PostJournal(tx, req)
+}
+
+// OrderRefunded is the amount already refunded on an order's payment
+func OrderRefunded(tx *gorm.DB, orderID uint) (float64, error) {
+	var refunded float64
+	err := tx.Model(&models.Payment{}).Where("order_id = ?", orderID).
+		Select("COALESCE(SUM(refunded_amount), 0)").Scan(&refunded).Error
+	return refunded, err
+}
+
+// NetFare is the fare and commission left to split once refunded has gone
+// back to the customer; the commission shrinks in the same proportion as in
+// RefundLines
+func NetFare(price, commission, refunded float64) (float64, float64) {
+	commission = math.Min(math.Max(commission, 0), price)
+	if toCents(refunded) <= 0 || toCents(price) <= 0 {
+		return price, commission
+	}
+	if toCents(refunded) >= toCents(price) {
+		return 0, 0
+	}
+	commissionShare := math.Round(commission/price*refunded*100) / 100
+	return roundCents(price - refunded), roundCents(commission - commissionShare)
+}
+
+// OrderCollectionKey is the journal key of the entry rebooking a fare first
+// booked as cash once the customer paid it through the platform
+func OrderCollectionKey(orderID uint) string {
+	return fmt.Sprintf("order:%d:collection", orderID)
+}
+
+// CollectionLines rebook a fare charged as cash as collected by the platform:
+// the wallet is credited the fare less commission and the commission charged
+// to the receivable is cleared
+func CollectionLines(driverID uint, price, commission float64) []LedgerLine {
+	commission = math.Min(math.Max(commission, 0), price)
+	return []LedgerLine{
+		debit(LedgerAccountClearing, price),
+		credit(DriverWalletAccount(driverID), price-commission),
+		credit(DriverReceivableAccount(driverID), commission),
+	}
+}
+
+// PostLateOrderPayment books a non-cash payment that settled after its order
+// was completed. Orders completed while the payment was pending get their
+// earning entry now, under the same key, so repeats are no-ops; orders
+// completed without a payment were booked as cash and are rebooked to the
+// platform. order must be locked by the caller.
+func PostLateOrderPayment(tx *gorm.DB, order *models.Order, postedAt time.Time) (bool, error) {
+	if !OrderEarned(*order) || toCents(order.Price) <= 0 {
+		return false, nil
+	}
+
+	var created bool
+	var err error
+	switch order.CollectedBy {
+	case CollectedByPending:
+		_, created, err = PostOrderEarning(tx, *order, order.Commission, CollectedByPlatform, postedAt)
+	case CollectedByDriver:
+		_, created, err = PostJournal(tx, JournalRequest{
+			Key:           OrderCollectionKey(order.ID),
+			Type:          models.JournalEntryEarning,
+			ReferenceType: "order",
+			ReferenceID:   order.ID,
+			Memo:          fmt.Sprintf("Order %s paid through the platform", order.OrderNumber),
+			PostedAt:      postedAt,
+			Lines:         CollectionLines(*order.DriverID, order.Price, order.Commission),
+		})
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	order.CollectedBy = CollectedByPlatform
+	return created, tx.Model(order).Update("collected_by", CollectedByPlatform).Error
+}
+
+// RefundLines takes a refund back from the driver and the platform in the
+// same proportion the fare was split when the order was earned. The driver's
+// share comes out of the wallet for fares the platform collected, and is added
+// to the receivable for cash fares the driver kept.
+func RefundLines(order models.Order, amount float64) ([]LedgerLine, error) {
+	if order.DriverID == nil {
+		return nil, fmt.Errorf("%w: order has no driver", ErrInvalidRefundShare)
+	}
+	if toCents(amount) <= 0 || toCents(amount) > toCents(order.Price) {
+		return nil, fmt.Errorf("%w: refund %.2f, fare %.2f", ErrInvalidRefundShare, amount, order.Price)
+	}
+
+	commission := math.Min(math.Max(order.Commission, 0), order.Price)
+	commissionShare := math.Round(commission/order.Price*amount*100) / 100
+	driverShare := amount - commissionShare
+
+	driverAccount := DriverWalletAccount(*order.DriverID)
+	if order.CollectedBy == CollectedByDriver {
+		driverAccount = DriverReceivableAccount(*order.DriverID)
+	}
+	return []LedgerLine{
+		credit(LedgerAccountClearing, amount),
+		debit(driverAccount, driverShare),
+		debit(LedgerAccountCommission, commissionShare),
+	}, nil
+}
+
+// OrderRefundKey is the journal key of a refund's ledger entry
+func OrderRefundKey(refundID uint) string {
+	return fmt.Sprintf("refund:%d", refundID)
+}
+
+// OrderEarned reports whether a completed order's fare was split with a
+// driver, so refunds have to be taken back from them
+func OrderEarned(order models.Order) bool {
+	return order.Status == models.OrderStatusCompleted && order.DriverID != nil
+}
+
+// PostOrderRefund takes part (or all) of an order's fare back for a refund
+// paid to the customer. key identifies the refund so retries are no-ops.
+// Orders that were never earned have nothing to take back.
+func PostOrderRefund(tx *gorm.DB, order models.Order, key string, amount float64, memo string, createdBy *uint, now time.Time) (models.JournalEntry, bool, error) {
+	if !OrderEarned(order) {
+		return models.JournalEntry{}, false, nil
+	}
+
+	lines, err := RefundLines(order, amount)
+	if err != nil {
+		return models.JournalEntry{}, false, err
+	}
+	return PostJournal(tx, JournalRequest{
+		Key:           key,
+		Type:          models.JournalEntryRefund,
+		ReferenceType: "order",
+		ReferenceID:   order.ID,
+		Memo:          memo,
+		CreatedBy:     createdBy,
+		PostedAt:      now,
+		Lines:         lines,
+	})
+}
+
+// WithdrawalPayoutKey is the journal key of a withdrawal's payout entry
+func WithdrawalPayoutKey(withdrawalID uint) string {
+	return fmt.Sprintf("withdrawal:%d:payout", withdrawalID)
+}
+
+// PostWithdrawalPayout debits the driver's wallet for an approved withdrawal
+func PostWithdrawalPayout(tx *gorm.DB, withdrawal models.Withdrawal, postedAt time.Time) (models.JournalEntry, bool, error) {
+	return PostJournal(tx, JournalRequest{
+		Key:           WithdrawalPayoutKey(withdrawal.ID),
+		Type:          models.JournalEntryWithdrawal,
+		ReferenceType: "withdrawal",
+		ReferenceID:   withdrawal.ID,
+		Memo:          fmt.Sprintf("Withdrawal %s", withdrawal.Reference),
+		PostedAt:      postedAt,
+		Lines: []LedgerLine{
+			debit(DriverWalletAccount(withdrawal.DriverID), withdrawal.Amount),
+			credit(LedgerAccountPayouts, withdrawal.Amount),
+		},
+	})
+}
+
+// PostWalletAdjustment credits (positive amount) or debits (negative amount)
+// a driver's wallet against the platform's adjustment account. requestKey
+// (the admin's Idempotency-Key) names the entry, so a resubmitted adjustment
+// returns the first entry with created=false instead of posting twice.
+func PostWalletAdjustment(tx *gorm.DB, driverID uint, requestKey string, amount float64, memo string, createdBy *uint, now time.Time) (models.JournalEntry, bool, error) {
+	if requestKey == "" {
+		return models.JournalEntry{}, false, ErrAdjustmentNoKey
+	}
+	if toCents(amount) == 0 {
+		return models.JournalEntry{}, false, ErrInvalidAdjustment
+	}
+	// Hashed so any client key fits the entry key column
+	hash := sha256.Sum256([]byte(requestKey))
+	return PostJournal(tx, JournalRequest{
+		Key:           fmt.Sprintf("driver:%d:adjustment:%s", driverID, hex.EncodeToString(hash[:16])),
+		Type:          models.JournalEntryAdjustment,
+		ReferenceType: "driver",
+		ReferenceID:   driverID,
+		Memo:          memo,
+		CreatedBy:     createdBy,
+		PostedAt:      now,
+		Lines: []LedgerLine{
+			debit(LedgerAccountAdjustments, amount),
+			credit(DriverWalletAccount(driverID), amount),
+		},
+	})
+}
+
+// AccountBalance is the balance of the account with the given code, positive
+// on the account's normal side (credits for wallets, debits for assets)
+func AccountBalance(db *gorm.DB, spec models.LedgerAccount) (float64, error) {
+	var sum float64
+	err := db.Model(&models.LedgerPosting{}).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Where("ledger_accounts.code = ?", spec.Code).
+		Select("COALESCE(SUM(ledger_postings.amount), 0)").
+		Scan(&sum).Error
+	if spec.CreditNormal() {
+		sum = -sum
+	}
+	return sum, err
+}
+
+// DriverWallet summarises a driver's wallet from the ledger
+type DriverWallet struct {
+	DriverID    uint    `json:"driver_id"`
+	Balance     float64 `json:"balance"`     // Saldo wallet menurut ledger
+	Held        float64 `json:"held"`        // Pencairan yang masih pending
+	Available   float64 `json:"available"`   // Saldo yang bisa dicairkan
+	Earnings    float64 `json:"earnings"`    // Pendapatan order bersih (setelah komisi dan refund)
+	Withdrawn   float64 `json:"withdrawn"`   // Total pencairan yang disetujui
+	Settlements float64 `json:"settlements"` // Pelunasan piutang yang dipotong dari wallet
+	Adjustments float64 `json:"adjustments"` // Total koreksi admin
+	Receivable  float64 `json:"receivable"`  // Komisi order tunai yang belum disetor
+}
+
+// GetDriverWallet derives a driver's wallet balance from postings. Pending
+// withdrawals are held so they cannot be requested twice, and unpaid cash
+// commission must be settled before the rest can be withdrawn.
+func GetDriverWallet(db *gorm.DB, driverID uint) (DriverWallet, error) {
+	wallet := DriverWallet{DriverID: driverID}
+
+	var rows []struct {
+		ReferenceType string
+		Type          models.JournalEntryType
+		Total         float64
+	}
+	err := db.Model(&models.LedgerPosting{}).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Joins("JOIN journal_entries ON journal_entries.id = ledger_postings.entry_id").
+		Where("ledger_accounts.code = ?", DriverWalletAccount(driverID).Code).
+		Group("journal_entries.reference_type, journal_entries.type").
+		Select("journal_entries.reference_type AS reference_type, journal_entries.type AS type, SUM(ledger_postings.amount) AS total").
+		Scan(&rows).Error
+	if err != nil {
+		return wallet, err
+	}
+	for _, row := range rows {
+		// Wallet adalah liabilitas: kredit menambah saldo
+		amount := -row.Total
+		wallet.Balance += amount
+		switch {
+		case row.ReferenceType == "order":
+			wallet.Earnings += amount
+		case row.ReferenceType == "withdrawal":
+			wallet.Withdrawn -= amount
+		case row.Type == models.JournalEntrySettlement:
+			wallet.Settlements -= amount
+		default:
+			wallet.Adjustments += amount
+		}
+	}
+
+	if wallet.Receivable, err = AccountBalance(db, DriverReceivableAccount(driverID)); err != nil {
+		return wallet, err
+	}
+
+	err = db.Model(&models.Withdrawal{}).
+		Where("driver_id = ? AND status = ?", driverID, models.WithdrawalStatusPending).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&wallet.Held).Error
+	if err != nil {
+		return wallet, err
+	}
+
+	wallet.Balance = roundCents(wallet.Balance)
+	wallet.Earnings = roundCents(wallet.Earnings)
+	wallet.Withdrawn = roundCents(wallet.Withdrawn)
+	wallet.Settlements = roundCents(wallet.Settlements)
+	wallet.Adjustments = roundCents(wallet.Adjustments)
+	wallet.Receivable = roundCents(wallet.Receivable)
+	wallet.Available = roundCents(wallet.Balance - wallet.Held - math.Max(wallet.Receivable, 0))
+	return wallet, nil
+}
+
+func roundCents(amount float64) float64 {
+	return float64(toCents(amount)) / 100
+}
+
+// WalletLine is one movement on a driver's wallet (or another account);
+// Amount is positive when it grew the balance
+type WalletLine struct {
+	EntryID       uint                    `json:"entry_id"`
+	Key           string                  `json:"key"`
+	Type          models.JournalEntryType `json:"type"`
+	ReferenceType string                  `json:"reference_type"`
+	ReferenceID   uint                    `json:"reference_id"`
+	Memo          string                  `json:"memo"`
+	Amount        float64                 `json:"amount"`
+	PostedAt      time.Time               `json:"posted_at"`
+}
+
+// WalletStatement lists a driver's wallet movements, newest first
+func WalletStatement(db *gorm.DB, driverID uint, offset, limit int) ([]WalletLine, int64, error) {
+	return AccountStatement(db, DriverWalletAccount(driverID), offset, limit)
+}
+
+// AccountStatement lists the movements of an account, newest first, signed
+// so that positive amounts grow the account's balance
+func AccountStatement(db *gorm.DB, spec models.LedgerAccount, offset, limit int) ([]WalletLine, int64, error) {
+	query := db.Model(&models.LedgerPosting{}).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Joins("JOIN journal_entries ON journal_entries.id = ledger_postings.entry_id").
+		Where("ledger_accounts.code = ?", spec.Code)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	amount := "ledger_postings.amount"
+	if spec.CreditNormal() {
+		amount = "-ledger_postings.amount"
+	}
+	lines := []WalletLine{}
+	err := query.
+		Select("journal_entries.id AS entry_id, journal_entries.entry_key AS `key`, journal_entries.type, " +
+			"journal_entries.reference_type, journal_entries.reference_id, journal_entries.memo, " +
+			amount + " AS amount, ledger_postings.posted_at").
+		Order("ledger_postings.posted_at DESC, ledger_postings.id DESC").
+		Offset(offset).Limit(limit).
+		Scan(&lines).Error
+	return lines, total, err
+}
+
+// AccountSummary is an account with its balance on its normal side
+type AccountSummary struct {
+	models.LedgerAccount
+	Debits  float64 `json:"debits"`
+	Credits float64 `json:"credits"`
+	Balance float64 `json:"balance"`
+}
+
+// TrialBalance returns every account with its total debits, credits and
+// balance. Total debits always equal total credits.
+func TrialBalance(db *gorm.DB, accountType string) ([]AccountSummary, error) {
+	var accounts []models.LedgerAccount
+	query := db.Order("code")
+	if accountType != "" {
+		query = query.Where("type = ?", accountType)
+	}
+	if err := query.Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	var totals []struct {
+		AccountID uint
+		Debits    float64
+		Credits   float64
+	}
+	err := db.Model(&models.LedgerPosting{}).
+		Select("account_id, " +
+			"COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0) AS debits, " +
+			"COALESCE(SUM(CASE WHEN amount < 0 THEN -amount ELSE 0 END), 0) AS credits").
+		Group("account_id").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	byAccount := make(map[uint]int, len(totals))
+	for i, total := range totals {
+		byAccount[total.AccountID] = i
+	}
+
+	summaries := make([]AccountSummary, 0, len(accounts))
+	for _, account := range accounts {
+		summary := AccountSummary{LedgerAccount: account}
+		if i, ok := byAccount[account.ID]; ok {
+			summary.Debits = roundCents(totals[i].Debits)
+			summary.Credits = roundCents(totals[i].Credits)
+		}
+		if account.CreditNormal() {
+			summary.Balance = roundCents(summary.Credits - summary.Debits)
+		} else {
+			summary.Balance = roundCents(summary.Debits - summary.Credits)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// BackfillLedger records journal entries for completed orders and paid out
+// withdrawals that predate the ledger, then recomputes drivers.total_earnings
+// as lifetime earnings. Entry keys make it safe to run on every start.
+func BackfillLedger(db *gorm.DB) error {
+	var orders []models.Order
+	posted := 0
+	err := db.Where("status = ? AND driver_id IS NOT NULL", models.OrderStatusCompleted).
+		Where("(collected_by IS NULL OR collected_by = '')").
+		Where("NOT EXISTS (SELECT 1 FROM journal_entries WHERE journal_entries.reference_type = 'order' AND journal_entries.reference_id = orders.id AND journal_entries.type = ?)", models.JournalEntryEarning).
+		FindInBatches(&orders, 200, func(tx *gorm.DB, batch int) error {
+			for _, order := range orders {
+				postedAt := order.UpdatedAt
+				if order.CompletedAt != nil {
+					postedAt = *order.CompletedAt
+				}
+				// Order lama dikreditkan penuh ke driver, sama seperti total_earnings sebelumnya
+				_, created, err := PostOrderEarning(db, order, 0, CollectedByPlatform, postedAt)
+				if err != nil {
+					return fmt.Errorf("order %d: %w", order.ID, err)
+				}
+				if created {
+					posted++
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	var withdrawals []models.Withdrawal
+	err = db.Where("status IN ?", []models.WithdrawalStatus{models.WithdrawalStatusApproved, models.WithdrawalStatusCompleted}).
+		Where("NOT EXISTS (SELECT 1 FROM journal_entries WHERE journal_entries.entry_key = CONCAT('withdrawal:', withdrawals.id, ':payout'))").
+		Find(&withdrawals).Error
+	if err != nil {
+		return err
+	}
+	for _, withdrawal := range withdrawals {
+		postedAt := withdrawal.UpdatedAt
+		if withdrawal.ApprovedAt != nil {
+			postedAt = *withdrawal.ApprovedAt
+		} else if withdrawal.CompletedAt != nil {
+			postedAt = *withdrawal.CompletedAt
+		}
+		_, created, err := PostWithdrawalPayout(db, withdrawal, postedAt)
+		if err != nil {
+			return fmt.Errorf("withdrawal %d: %w", withdrawal.ID, err)
+		}
+		if created {
+			posted++
+		}
+	}
+	if posted == 0 {
+		return nil
+	}
+
+	// total_earnings dulu dikurangi saat pencairan disetujui; kini berisi
+	// pendapatan seumur hidup (harga dikurangi komisi) dan saldo diambil dari ledger
+	return db.Exec(`UPDATE drivers SET total_earnings = (
+		SELECT COALESCE(SUM(orders.price - orders.commission), 0)
+		FROM orders
+		WHERE orders.driver_id = drivers.id AND orders.status = ? AND orders.deleted_at IS NULL
+	)`, models.OrderStatusCompleted).Error
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"testing"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sumLines(lines []LedgerLine) int64 {
+	var sum int64
+	for _, line := range lines {
+		sum += toCents(line.Amount)
+	}
+	return sum
+}
+
+func TestValidateLines(t *testing.T) {
+	wallet := DriverWalletAccount(7)
+
+	lines, err := ValidateLines([]LedgerLine{
+		debit(LedgerAccountClearing, 15000),
+		credit(wallet, 15000),
+		credit(LedgerAccountCommission, 0),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, lines, 2, "zero postings are dropped")
+
+	// Float drift below a cent does not unbalance an entry
+	_, err = ValidateLines([]LedgerLine{
+		debit(LedgerAccountClearing, 0.1+0.2),
+		credit(wallet, 0.3),
+	})
+	assert.NoError(t, err)
+
+	_, err = ValidateLines([]LedgerLine{
+		debit(LedgerAccountClearing, 15000),
+		credit(wallet, 14000),
+	})
+	assert.ErrorIs(t, err, ErrUnbalancedEntry)
+
+	_, err = ValidateLines([]LedgerLine{debit(LedgerAccountClearing, 0), credit(wallet, 0)})
+	assert.ErrorIs(t, err, ErrInvalidPosting)
+
+	_, err = ValidateLines([]LedgerLine{debit(models.LedgerAccount{}, 10), credit(wallet, 10)})
+	assert.ErrorIs(t, err, ErrInvalidPosting)
+}
+
+func TestDriverWalletAccount(t *testing.T) {
+	wallet := DriverWalletAccount(12)
+	assert.Equal(t, "driver:12:wallet", wallet.Code)
+	assert.Equal(t, uint(12), *wallet.DriverID)
+	assert.True(t, wallet.CreditNormal())
+	assert.False(t, LedgerAccountClearing.CreditNormal())
+}
+
+func TestEarningLines(t *testing.T) {
+	lines := EarningLines(3, 20000, 2000)
+	assert.Equal(t, int64(0), sumLines(lines))
+	assert.Equal(t, 20000.0, lines[0].Amount)
+	assert.Equal(t, -18000.0, lines[1].Amount)
+	assert.Equal(t, "driver:3:wallet", lines[1].Account.Code)
+	assert.Equal(t, -2000.0, lines[2].Amount)
+
+	// Commission is capped at the fare
+	lines = EarningLines(3, 5000, 8000)
+	assert.Equal(t, 0.0, lines[1].Amount)
+	assert.Equal(t, -5000.0, lines[2].Amount)
+}
+
+func TestCollectionLines(t *testing.T) {
+	// Cash booking charged 2000 to the receivable; rebooking credits the wallet and clears it
+	lines := CollectionLines(3, 20000, 2000)
+	assert.Equal(t, int64(0), sumLines(lines))
+	assert.Equal(t, LedgerAccountClearing.Code, lines[0].Account.Code)
+	assert.Equal(t, 20000.0, lines[0].Amount)
+	assert.Equal(t, "driver:3:wallet", lines[1].Account.Code)
+	assert.Equal(t, -18000.0, lines[1].Amount)
+	assert.Equal(t, "driver:3:receivable", lines[2].Account.Code)
+	assert.Equal(t, -2000.0, lines[2].Amount)
+}
+
+func TestCommissionRate(t *testing.T) {
+	t.Setenv("DRIVER_COMMISSION_RATE", "0.15")
+	assert.Equal(t, 0.15, CommissionRate())
+
+	t.Setenv("DRIVER_COMMISSION_RATE", "1.5")
+	assert.Equal(t, 0.0, CommissionRate())
+}
+
+func TestRefundLines(t *testing.T) {
+	driverID := uint(4)
+	order := models.Order{Price: 30000, Commission: 3000, DriverID: &driverID, CollectedBy: CollectedByPlatform}
+
+	lines, err := RefundLines(order, 10000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), sumLines(lines))
+	assert.Equal(t, -10000.0, lines[0].Amount)
+	assert.Equal(t, "driver:4:wallet", lines[1].Account.Code)
+	assert.Equal(t, 9000.0, lines[1].Amount)
+	assert.Equal(t, 1000.0, lines[2].Amount)
+
+	// Rounding remainders stay inside the entry
+	order.Commission = 10000
+	lines, err = RefundLines(order, 10000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), sumLines(lines))
+
+	// A full refund mirrors the earning exactly
+	order.Commission = 3000
+	lines, err = RefundLines(order, 30000)
+	assert.NoError(t, err)
+	assert.Equal(t, 27000.0, lines[1].Amount)
+	assert.Equal(t, 3000.0, lines[2].Amount)
+
+	// Cash fares the driver kept are charged to the receivable
+	order.CollectedBy = CollectedByDriver
+	lines, err = RefundLines(order, 30000)
+	assert.NoError(t, err)
+	assert.Equal(t, "driver:4:receivable", lines[1].Account.Code)
+	assert.Equal(t, 27000.0, lines[1].Amount)
+
+	_, err = RefundLines(order, 30001)
+	assert.ErrorIs(t, err, ErrInvalidRefundShare)
+
+	_, err = RefundLines(models.Order{Price: 30000}, 1000)
+	assert.ErrorIs(t, err, ErrInvalidRefundShare)
+}
+
+func TestNetFare(t *testing.T) {
+	price, commission := NetFare(30000, 3000, 0)
+	assert.Equal(t, 30000.0, price)
+	assert.Equal(t, 3000.0, commission)
+
+	// A refund before completion shrinks the fare and commission proportionally
+	price, commission = NetFare(30000, 3000, 10000)
+	assert.Equal(t, 20000.0, price)
+	assert.Equal(t, 2000.0, commission)
+
+	// Together with a later refund it takes back exactly what was earned
+	driverID := uint(4)
+	lines, err := RefundLines(models.Order{Price: 30000, Commission: 3000, DriverID: &driverID}, 20000)
+	assert.NoError(t, err)
+	assert.Equal(t, commission, lines[2].Amount)
+
+	price, commission = NetFare(30000, 3000, 30000)
+	assert.Equal(t, 0.0, price)
+	assert.Equal(t, 0.0, commission)
+}
+
+func TestCanTransitionWithdrawal(t *testing.T) {
+	assert.True(t, CanTransitionWithdrawal(models.WithdrawalStatusPending, models.WithdrawalStatusApproved))
+	assert.True(t, CanTransitionWithdrawal(models.WithdrawalStatusPending, models.WithdrawalStatusCompleted))
+	assert.True(t, CanTransitionWithdrawal(models.WithdrawalStatusApproved, models.WithdrawalStatusRejected))
+	assert.True(t, CanTransitionWithdrawal(models.WithdrawalStatusApproved, models.WithdrawalStatusCompleted))
+
+	assert.False(t, CanTransitionWithdrawal(models.WithdrawalStatusApproved, models.WithdrawalStatusApproved))
+	assert.False(t, CanTransitionWithdrawal(models.WithdrawalStatusRejected, models.WithdrawalStatusApproved))
+	assert.False(t, CanTransitionWithdrawal(models.WithdrawalStatusCompleted, models.WithdrawalStatusRejected))
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+const locationPingPruneBatch = 5000
+
+// ActiveTripStatuses are the order statuses during which a driver is on a trip
+var ActiveTripStatuses = []models.OrderStatus{
+	models.OrderStatusAccepted,
+	models.OrderStatusPickedUp,
+	models.OrderStatusInProgress,
+}
+
+// ActiveOrderIDForDriver returns the driver's current trip, if any
+func ActiveOrderIDForDriver(db *gorm.DB, driverID uint) *uint {
+	var order models.Order
+	err := db.Select("id").
+		Where("driver_id = ? AND status IN ?", driverID, ActiveTripStatuses).
+		Order("accepted_at DESC").
+		First(&order).Error
+	if err != nil {
+		return nil
+	}
+	return &order.ID
+}
+
+// LocationHistoryRetention returns how long location pings are kept
+func LocationHistoryRetention() time.Duration {
+	return envDuration("LOCATION_HISTORY_RETENTION", 30*24*time.Hour)
+}
+
+// PruneLocationPings deletes pings recorded before cutoff in small batches
+// so the table is never locked for long. It returns the number of rows deleted.
+func PruneLocationPings(db *gorm.DB, cutoff time.Time) (int64, error) {
+	var total int64
+	for {
+		result := db.Exec("DELETE FROM driver_location_pings WHERE recorded_at < ? LIMIT ?", cutoff, locationPingPruneBatch)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < locationPingPruneBatch {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"greenbecak-backend/models"
+)
+
+// Public Order Access
+// ===================
+// Order yang dibuat tanpa akun (scan sticker becak) hanya bisa dibayar,
+// dibatalkan atau direview dengan order token yang dikembalikan saat order
+// dibuat. Token adalah HMAC dari ID dan nomor order dengan kunci turunan
+// server secret: tidak perlu disimpan, tidak bisa ditebak dari ID order, dan
+// tidak bisa dipakai untuk order lain.
+
+var ErrInvalidOrderToken = errors.New("invalid order token")
+
+func orderAccessKey(secret string) []byte {
+	key := sha256.Sum256([]byte("greenbecak/order-access/" + secret))
+	return key[:]
+}
+
+// OrderAccessToken returns the capability token for a public order
+func OrderAccessToken(order models.Order, secret string) string {
+	mac := hmac.New(sha256.New, orderAccessKey(secret))
+	fmt.Fprintf(mac, "%d:%s", order.ID, order.OrderNumber)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyOrderAccessToken checks token against the order in constant time
+func VerifyOrderAccessToken(order models.Order, secret, token string) error {
+	if token == "" || order.ID == 0 {
+		return ErrInvalidOrderToken
+	}
+	given, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ErrInvalidOrderToken
+	}
+	expected, _ := base64.RawURLEncoding.DecodeString(OrderAccessToken(order, secret))
+	if !hmac.Equal(expected, given) {
+		return ErrInvalidOrderToken
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"testing"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderAccessToken(t *testing.T) {
+	order := models.Order{ID: 42, OrderNumber: "GB-20261017-000042X"}
+	token := OrderAccessToken(order, "secret")
+
+	assert.NoError(t, VerifyOrderAccessToken(order, "secret", token))
+	assert.Equal(t, token, OrderAccessToken(order, "secret"), "tokens are stable")
+
+	// A token only opens the order it was issued for
+	other := models.Order{ID: 43, OrderNumber: "GB-20261017-000043Y"}
+	assert.ErrorIs(t, VerifyOrderAccessToken(other, "secret", token), ErrInvalidOrderToken)
+	assert.ErrorIs(t, VerifyOrderAccessToken(order, "another", token), ErrInvalidOrderToken)
+
+	assert.ErrorIs(t, VerifyOrderAccessToken(order, "secret", ""), ErrInvalidOrderToken)
+	assert.ErrorIs(t, VerifyOrderAccessToken(order, "secret", "not base64!"), ErrInvalidOrderToken)
+	assert.ErrorIs(t, VerifyOrderAccessToken(order, "secret", token[:10]), ErrInvalidOrderToken)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrDriverUnavailable is returned when the driver is not free to take an order
+var ErrDriverUnavailable = errors.New("driver is not available")
+
+// AcceptOrderForDriver assigns a pending order to a driver in one transaction.
+// Both the order (pending -> accepted) and the driver (active -> on_trip) are
+// updated conditionally, so when several drivers accept at once exactly one wins
+// and the others get ErrOrderConflict.
+func AcceptOrderForDriver(db *gorm.DB, order *models.Order, driver *models.Driver, actorID *uint) error {
+	previousDriverID := order.DriverID
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Driver{}).
+			Where("id = ? AND status = ?", driver.ID, models.DriverStatusActive).
+			Update("status", models.DriverStatusOnTrip)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDriverUnavailable
+		}
+
+		order.DriverID = &driver.ID
+		err := TransitionOrder(tx, order, OrderTransition{
+			To:        models.OrderStatusAccepted,
+			ActorID:   actorID,
+			ActorRole: ActorRoleDriver,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Close the driver's dispatch offer, if the order reached them through dispatch
+		return tx.Model(&models.DispatchOffer{}).
+			Where("order_id = ? AND driver_id = ? AND status = ?", order.ID, driver.ID, models.DispatchOfferStatusOffered).
+			Updates(map[string]interface{}{"status": models.DispatchOfferStatusAccepted, "responded_at": time.Now()}).Error
+	})
+	if err != nil {
+		order.DriverID = previousDriverID
+		return err
+	}
+
+	driver.Status = models.DriverStatusOnTrip
+	return nil
+}
+
+// CompleteOrderForDriver completes an order and credits the driver in one transaction.
+// Trip count and earnings are incremented in SQL so concurrent completions cannot
+// overwrite each other. Completing the trip confirms that the driver received a
+// pending cash payment, which is settled here.
+func CompleteOrderForDriver(db *gorm.DB, order *models.Order, driver *models.Driver, actorID *uint, actorRole string) error {
+	var cash *models.Payment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Rentals that ran past their booked duration are charged overtime
+		if err := applyOvertime(tx, order, time.Now()); err != nil {
+			return err
+		}
+
+		err := TransitionOrder(tx, order, OrderTransition{
+			To:        models.OrderStatusCompleted,
+			ActorID:   actorID,
+			ActorRole: actorRole,
+		})
+		if err != nil {
+			return err
+		}
+
+		if cash, err = SettleCashPayment(tx, order, time.Now()); err != nil {
+			return err
+		}
+
+		// Earnings are booked in the ledger; total_earnings is only a lifetime statistic.
+		// Cash fares stay with the driver, who then owes the platform its commission.
+		commission, _, err := OrderCommission(tx, *order, *driver)
+		if err != nil {
+			return err
+		}
+		collectedBy, err := OrderCollectedBy(tx, *order)
+		if err != nil {
+			return err
+		}
+		order.Commission = commission
+		order.CollectedBy = collectedBy
+		err = tx.Model(order).Updates(map[string]interface{}{"commission": commission, "collected_by": collectedBy}).Error
+		if err != nil {
+			return err
+		}
+		if _, _, err := PostOrderEarning(tx, *order, commission, collectedBy, time.Now()); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Driver{}).
+			Where("id = ?", driver.ID).
+			Updates(map[string]interface{}{
+				"status":         models.DriverStatusActive,
+				"total_trips":    gorm.Expr("total_trips + ?", 1),
+				"total_earnings": gorm.Expr("total_earnings + ?", order.Price-commission),
+			}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if cash != nil {
+		PublishPaymentStatus(*cash)
+	}
+
+	return db.First(driver, driver.ID).Error
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Order Expiry
+// ============
+// Order pending yang tidak diterima driver dalam PendingTTL, dan order accepted
+// yang tidak pernah dimulai dalam AcceptedTTL, diubah ke status expired oleh job
+// berkala. Setiap order dipindahkan lewat TransitionOrder yang bersyarat pada
+// status lama, jadi jika job berjalan di beberapa replica sekaligus hanya satu
+// yang berhasil memproses order tersebut; replica lain mendapat ErrOrderConflict
+// dan melewatinya.
+
+type OrderExpiryConfig struct {
+	PendingTTL  time.Duration // 0 disables expiry of pending orders
+	AcceptedTTL time.Duration // 0 disables time-out of accepted trips
+	BatchSize   int
+}
+
+// LoadOrderExpiryConfig reads order expiry settings from the environment
+func LoadOrderExpiryConfig() OrderExpiryConfig {
+	return OrderExpiryConfig{
+		PendingTTL:  envDuration("ORDER_PENDING_TTL", 15*time.Minute),
+		AcceptedTTL: envDuration("ORDER_ACCEPTED_TTL", 45*time.Minute),
+		BatchSize:   int(envFloat("ORDER_EXPIRY_BATCH_SIZE", 100)),
+	}
+}
+
+// ExpireStaleOrders expires pending and accepted-but-never-started orders older
+// than their TTL and returns the orders this call expired.
+func ExpireStaleOrders(db *gorm.DB, config OrderExpiryConfig, now time.Time) ([]models.Order, error) {
+	var expired []models.Order
+
+	if config.PendingTTL > 0 {
+		// Bookings are measured from the start of their pickup window
+		orders, err := expireOrders(db, config, "status = ? AND COALESCE(scheduled_at, created_at) < ?",
+			[]interface{}{models.OrderStatusPending, now.Add(-config.PendingTTL)}, now)
+		expired = append(expired, orders...)
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	if config.AcceptedTTL > 0 {
+		cutoff := now.Add(-config.AcceptedTTL)
+		orders, err := expireOrders(db, config, "status = ? AND accepted_at < ? AND COALESCE(scheduled_at, accepted_at) < ?",
+			[]interface{}{models.OrderStatusAccepted, cutoff, cutoff}, now)
+		expired = append(expired, orders...)
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
+
+// OrderExpiry reports whether order is stale at now and why. It mirrors the
+// queries of ExpireStaleOrders: pending orders expire PendingTTL after they
+// were created, accepted ones AcceptedTTL after acceptance, and bookings are
+// measured from their scheduled pickup instead.
+func OrderExpiry(order models.Order, config OrderExpiryConfig, now time.Time) (string, bool) {
+	switch order.Status {
+	case models.OrderStatusPending:
+		if config.PendingTTL <= 0 {
+			return "", false
+		}
+		since := order.CreatedAt
+		if order.ScheduledAt != nil {
+			since = *order.ScheduledAt
+		}
+		if since.Before(now.Add(-config.PendingTTL)) {
+			return fmt.Sprintf("no driver accepted within %s", config.PendingTTL), true
+		}
+	case models.OrderStatusAccepted:
+		if config.AcceptedTTL <= 0 || order.AcceptedAt == nil {
+			return "", false
+		}
+		cutoff := now.Add(-config.AcceptedTTL)
+		since := *order.AcceptedAt
+		if order.ScheduledAt != nil {
+			since = *order.ScheduledAt
+		}
+		if order.AcceptedAt.Before(cutoff) && since.Before(cutoff) {
+			return fmt.Sprintf("trip not started within %s of acceptance", config.AcceptedTTL), true
+		}
+	}
+	return "", false
+}
+
+func expireOrders(db *gorm.DB, config OrderExpiryConfig, where string, args []interface{}, now time.Time) ([]models.Order, error) {
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	var candidates []models.Order
+	if err := db.Where(where, args...).Order("id ASC").Limit(batchSize).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	expired := make([]models.Order, 0, len(candidates))
+	for i := range candidates {
+		order := candidates[i]
+		reason, stale := OrderExpiry(order, config, now)
+		if !stale {
+			continue
+		}
+		err := expireOrder(db, &order, reason)
+		if errors.Is(err, ErrOrderConflict) {
+			continue // another replica or a driver got there first
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, order)
+	}
+	return expired, nil
+}
+
+// expireOrder moves one order to expired, releases its driver and closes open offers
+func expireOrder(db *gorm.DB, order *models.Order, reason string) error {
+	wasAccepted := order.Status == models.OrderStatusAccepted
+	now := time.Now()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := TransitionOrder(tx, order, OrderTransition{
+			To:        models.OrderStatusExpired,
+			ActorRole: ActorRoleSystem,
+			Reason:    reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		if wasAccepted && order.DriverID != nil {
+			if err := tx.Model(&models.Driver{}).
+				Where("id = ? AND status = ?", *order.DriverID, models.DriverStatusOnTrip).
+				Update("status", models.DriverStatusActive).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.DispatchOffer{}).
+			Where("order_id = ? AND status = ?", order.ID, models.DispatchOfferStatusOffered).
+			Updates(map[string]interface{}{"status": models.DispatchOfferStatusTimeout, "responded_at": now}).Error
+	})
+}
+
+// NotifyOrderExpired tells the customer (and driver, if any) that the order expired.
+// Customers with an account also get a stored notification.
+func NotifyOrderExpired(db *gorm.DB, order models.Order) {
+	PublishOrderStatus(&order)
+
+	if order.DriverID != nil {
+		Hub.Publish(DriverTopic(*order.DriverID), EventOrderStatus, map[string]interface{}{
+			"order_id":     order.ID,
+			"order_number": order.OrderNumber,
+			"status":       order.Status,
+		})
+	}
+
+	if order.CustomerID == nil {
+		return
+	}
+
+	message := fmt.Sprintf("Maaf, belum ada driver yang menerima pesanan %s. Silakan pesan kembali.", order.OrderNumber)
+	if order.AcceptedAt != nil {
+		message = fmt.Sprintf("Pesanan %s dibatalkan otomatis karena perjalanan tidak dimulai. Silakan pesan kembali.", order.OrderNumber)
+	}
+	storeNotification(db, *order.CustomerID, "Pesanan Kedaluwarsa", message, map[string]interface{}{
+		"order_id": order.ID,
+		"status":   order.Status,
+	})
+}
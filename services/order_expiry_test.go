@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadOrderExpiryConfig(t *testing.T) {
+	config := LoadOrderExpiryConfig()
+	assert.Equal(t, 15*time.Minute, config.PendingTTL)
+	assert.Equal(t, 45*time.Minute, config.AcceptedTTL)
+
+	t.Setenv("ORDER_PENDING_TTL", "5m")
+	t.Setenv("ORDER_ACCEPTED_TTL", "0")
+	config = LoadOrderExpiryConfig()
+	assert.Equal(t, 5*time.Minute, config.PendingTTL)
+	assert.Equal(t, time.Duration(0), config.AcceptedTTL)
+}
+
+func TestExpireStaleOrdersDisabled(t *testing.T) {
+	// With both TTLs disabled the job does not touch the database
+	expired, err := ExpireStaleOrders(nil, OrderExpiryConfig{}, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+}
+
+func TestOrderExpirySelectsStaleOrders(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	config := OrderExpiryConfig{PendingTTL: 15 * time.Minute, AcceptedTTL: 45 * time.Minute}
+	at := func(ago time.Duration) *time.Time {
+		ts := now.Add(-ago)
+		return &ts
+	}
+
+	tests := []struct {
+		name   string
+		order  models.Order
+		stale  bool
+		reason string
+	}{
+		{"fresh pending", models.Order{Status: models.OrderStatusPending, CreatedAt: now.Add(-10 * time.Minute)}, false, ""},
+		{"stale pending", models.Order{Status: models.OrderStatusPending, CreatedAt: now.Add(-16 * time.Minute)}, true, "no driver accepted within 15m0s"},
+		{"pending booking before its pickup", models.Order{Status: models.OrderStatusPending, CreatedAt: now.Add(-48 * time.Hour), ScheduledAt: at(-2 * time.Hour)}, false, ""},
+		{"pending booking past its pickup", models.Order{Status: models.OrderStatusPending, CreatedAt: now.Add(-48 * time.Hour), ScheduledAt: at(20 * time.Minute)}, true, "no driver accepted within 15m0s"},
+		{"pending uses the pending TTL, not the accepted one", models.Order{Status: models.OrderStatusPending, CreatedAt: now.Add(-30 * time.Minute)}, true, "no driver accepted within 15m0s"},
+		{"recently accepted", models.Order{Status: models.OrderStatusAccepted, CreatedAt: now.Add(-2 * time.Hour), AcceptedAt: at(30 * time.Minute)}, false, ""},
+		{"accepted and never started", models.Order{Status: models.OrderStatusAccepted, AcceptedAt: at(50 * time.Minute)}, true, "trip not started within 45m0s of acceptance"},
+		{"accepted booking before its pickup", models.Order{Status: models.OrderStatusAccepted, AcceptedAt: at(24 * time.Hour), ScheduledAt: at(-time.Hour)}, false, ""},
+		{"accepted booking past its pickup", models.Order{Status: models.OrderStatusAccepted, AcceptedAt: at(24 * time.Hour), ScheduledAt: at(time.Hour)}, true, "trip not started within 45m0s of acceptance"},
+		{"accepted without acceptance time", models.Order{Status: models.OrderStatusAccepted}, false, ""},
+		{"started trips never expire", models.Order{Status: models.OrderStatusInProgress, CreatedAt: now.Add(-5 * time.Hour), AcceptedAt: at(5 * time.Hour)}, false, ""},
+		{"completed trips never expire", models.Order{Status: models.OrderStatusCompleted, CreatedAt: now.Add(-5 * time.Hour)}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, stale := OrderExpiry(tt.order, config, now)
+			assert.Equal(t, tt.stale, stale)
+			assert.Equal(t, tt.reason, reason)
+			if stale {
+				assert.True(t, CanTransitionOrder(tt.order.Status, models.OrderStatusExpired), "stale orders can move to expired")
+			}
+		})
+	}
+}
+
+func TestOrderExpiryRespectsDisabledTTLs(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	acceptedAt := now.Add(-24 * time.Hour)
+	pending := models.Order{Status: models.OrderStatusPending, CreatedAt: now.Add(-24 * time.Hour)}
+	accepted := models.Order{Status: models.OrderStatusAccepted, AcceptedAt: &acceptedAt}
+
+	_, stale := OrderExpiry(pending, OrderExpiryConfig{AcceptedTTL: time.Minute}, now)
+	assert.False(t, stale)
+	_, stale = OrderExpiry(accepted, OrderExpiryConfig{PendingTTL: time.Minute}, now)
+	assert.False(t, stale)
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Order Lifecycle
+// ===============
+// Semua perubahan status order harus lewat TransitionOrder supaya
+// transisi ilegal ditolak dan setiap perubahan tercatat di order_status_history.
+
+// Actor roles recorded in order history
+const (
+	ActorRoleAdmin    = "admin"
+	ActorRoleCustomer = "customer"
+	ActorRoleDriver   = "driver"
+	ActorRoleSystem   = "system"
+)
+
+// orderTransitions lists the allowed next statuses for each status.
+// Completing directly from accepted/picked_up is kept for driver apps
+// that do not report the intermediate steps.
+var orderTransitions = map[models.OrderStatus][]models.OrderStatus{
+	models.OrderStatusPending: {
+		models.OrderStatusAccepted,
+		models.OrderStatusCancelled,
+		models.OrderStatusExpired,
+	},
+	models.OrderStatusAccepted: {
+		models.OrderStatusPickedUp,
+		models.OrderStatusInProgress,
+		models.OrderStatusCompleted,
+		models.OrderStatusCancelled,
+		models.OrderStatusExpired,
+		models.OrderStatusNoShow,
+	},
+	models.OrderStatusPickedUp: {
+		models.OrderStatusInProgress,
+		models.OrderStatusCompleted,
+		models.OrderStatusCancelled,
+	},
+	models.OrderStatusInProgress: {
+		models.OrderStatusCompleted,
+		models.OrderStatusCancelled,
+	},
+}
+
+// ErrOrderConflict is returned when another request changed the order status first
+var ErrOrderConflict = errors.New("order was updated by another request")
+
+// InvalidTransitionError is returned when an order cannot move to the requested status
+type InvalidTransitionError struct {
+	OrderID uint
+	From    models.OrderStatus
+	To      models.OrderStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("order %d cannot move from %s to %s", e.OrderID, e.From, e.To)
+}
+
+// IsValidOrderStatus reports whether status is a known order status
+func IsValidOrderStatus(status models.OrderStatus) bool {
+	switch status {
+	case models.OrderStatusPending,
+		models.OrderStatusAccepted,
+		models.OrderStatusPickedUp,
+		models.OrderStatusInProgress,
+		models.OrderStatusCompleted,
+		models.OrderStatusCancelled,
+		models.OrderStatusExpired,
+		models.OrderStatusNoShow:
+		return true
+	}
+	return false
+}
+
+// CanTransitionOrder reports whether an order may move from one status to another
+func CanTransitionOrder(from, to models.OrderStatus) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminalOrderStatus reports whether no further transitions are possible
+func IsTerminalOrderStatus(status models.OrderStatus) bool {
+	return len(orderTransitions[status]) == 0
+}
+
+// OrderTransition describes a requested status change and who requested it
+type OrderTransition struct {
+	To        models.OrderStatus
+	ActorID   *uint
+	ActorRole string
+	Reason    string
+}
+
+// TransitionOrder validates the transition, stamps the matching timestamp,
+// saves the order and writes an order_status_history row in one transaction.
+// Any other pending field changes on order are saved along with the status.
+// The save is conditional on the status the order was loaded with, so if a
+// concurrent request moved it first ErrOrderConflict is returned.
+func TransitionOrder(db *gorm.DB, order *models.Order, t OrderTransition) error {
+	from := order.Status
+	if !CanTransitionOrder(from, t.To) {
+		return &InvalidTransitionError{OrderID: order.ID, From: from, To: t.To}
+	}
+
+	// Restored if the save fails, so the caller's order never carries
+	// timestamps of a transition that did not happen
+	stamps := [...]*time.Time{order.AcceptedAt, order.PickedUpAt, order.StartedAt, order.CompletedAt, order.CancelledAt}
+
+	now := time.Now()
+	order.Status = t.To
+	switch t.To {
+	case models.OrderStatusAccepted:
+		order.AcceptedAt = &now
+	case models.OrderStatusPickedUp:
+		order.PickedUpAt = &now
+	case models.OrderStatusInProgress:
+		order.StartedAt = &now
+	case models.OrderStatusCompleted:
+		order.CompletedAt = &now
+	case models.OrderStatusCancelled, models.OrderStatusExpired, models.OrderStatusNoShow:
+		order.CancelledAt = &now
+	}
+
+	actorRole := t.ActorRole
+	if actorRole == "" {
+		actorRole = ActorRoleSystem
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", order.ID, from).
+			Select("*").
+			Omit("id", "created_at", clause.Associations).
+			Updates(order)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderConflict
+		}
+
+		history := models.OrderStatusHistory{
+			OrderID:    order.ID,
+			FromStatus: from,
+			ToStatus:   t.To,
+			ActorID:    t.ActorID,
+			ActorRole:  actorRole,
+			Reason:     t.Reason,
+			CreatedAt:  now,
+		}
+		return tx.Create(&history).Error
+	})
+	if err != nil {
+		order.Status = from
+		order.AcceptedAt, order.PickedUpAt, order.StartedAt, order.CompletedAt, order.CancelledAt = stamps[0], stamps[1], stamps[2], stamps[3], stamps[4]
+		return err
+	}
+
+	return nil
+}
+
+// RecordOrderCreated writes the initial history row for a newly created order
+func RecordOrderCreated(db *gorm.DB, order *models.Order, actorID *uint, actorRole string) error {
+	if actorRole == "" {
+		actorRole = ActorRoleSystem
+	}
+	history := models.OrderStatusHistory{
+		OrderID:   order.ID,
+		ToStatus:  order.Status,
+		ActorID:   actorID,
+		ActorRole: actorRole,
+		Reason:    "order created",
+		CreatedAt: time.Now(),
+	}
+	return db.Create(&history).Error
+}
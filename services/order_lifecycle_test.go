@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransitionOrder(t *testing.T) {
+	tests := []struct {
+		from models.OrderStatus
+		to   models.OrderStatus
+		want bool
+	}{
+		{models.OrderStatusPending, models.OrderStatusAccepted, true},
+		{models.OrderStatusPending, models.OrderStatusExpired, true},
+		{models.OrderStatusPending, models.OrderStatusCompleted, false},
+		{models.OrderStatusAccepted, models.OrderStatusPickedUp, true},
+		{models.OrderStatusAccepted, models.OrderStatusNoShow, true},
+		{models.OrderStatusPickedUp, models.OrderStatusInProgress, true},
+		{models.OrderStatusInProgress, models.OrderStatusCompleted, true},
+		{models.OrderStatusInProgress, models.OrderStatusAccepted, false},
+		{models.OrderStatusCompleted, models.OrderStatusCancelled, false},
+		{models.OrderStatusCancelled, models.OrderStatusPending, false},
+		{models.OrderStatusExpired, models.OrderStatusAccepted, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CanTransitionOrder(tt.from, tt.to), "%s -> %s", tt.from, tt.to)
+	}
+}
+
+func TestTransitionOrderRejectsIllegalTransition(t *testing.T) {
+	order := &models.Order{ID: 7, Status: models.OrderStatusCompleted}
+
+	err := TransitionOrder(nil, order, OrderTransition{To: models.OrderStatusCancelled})
+
+	var transitionErr *InvalidTransitionError
+	assert.True(t, errors.As(err, &transitionErr))
+	assert.Equal(t, models.OrderStatusCompleted, transitionErr.From)
+	assert.Equal(t, models.OrderStatusCompleted, order.Status)
+	assert.Nil(t, order.CancelledAt)
+}
+
+func TestTerminalOrderStatuses(t *testing.T) {
+	assert.True(t, IsTerminalOrderStatus(models.OrderStatusCompleted))
+	assert.True(t, IsTerminalOrderStatus(models.OrderStatusNoShow))
+	assert.False(t, IsTerminalOrderStatus(models.OrderStatusPending))
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCoordinates is returned when a place has missing or out-of-range coordinates
+var ErrInvalidCoordinates = errors.New("invalid coordinates")
+
+// OrderPlace is a structured pickup or drop point sent by clients
+type OrderPlace struct {
+	Lat      *float64 `json:"lat"`
+	Lng      *float64 `json:"lng"`
+	Address  string   `json:"address"`
+	Landmark string   `json:"landmark"`
+	PlaceID  string   `json:"place_id"`
+}
+
+// Point validates and returns the place's coordinates
+func (p OrderPlace) Point() (geo.Point, error) {
+	if p.Lat == nil || p.Lng == nil {
+		return geo.Point{}, ErrInvalidCoordinates
+	}
+	point := geo.Point{Lat: *p.Lat, Lng: *p.Lng}
+	if !point.Valid() || point.IsZero() {
+		return geo.Point{}, ErrInvalidCoordinates
+	}
+	return point, nil
+}
+
+// SetOrderPickup copies a structured pickup point onto the order.
+// The address label is only replaced when the place carries one.
+func SetOrderPickup(order *models.Order, place OrderPlace) error {
+	point, err := place.Point()
+	if err != nil {
+		return err
+	}
+	order.PickupLat = &point.Lat
+	order.PickupLng = &point.Lng
+	order.PickupLandmark = place.Landmark
+	order.PickupPlaceID = place.PlaceID
+	if place.Address != "" {
+		order.PickupLocation = place.Address
+	}
+	return nil
+}
+
+// SetOrderDrop copies a structured drop point onto the order.
+// The address label is only replaced when the place carries one.
+func SetOrderDrop(order *models.Order, place OrderPlace) error {
+	point, err := place.Point()
+	if err != nil {
+		return err
+	}
+	order.DropLat = &point.Lat
+	order.DropLng = &point.Lng
+	order.DropLandmark = place.Landmark
+	order.DropPlaceID = place.PlaceID
+	if place.Address != "" {
+		order.DropLocation = place.Address
+	}
+	return nil
+}
+
+// TripDistanceKm returns the straight-line distance from pickup through any stops
+// to drop in km, rounded to 10 m, and whether pickup and drop are known
+func TripDistanceKm(order models.Order) (float64, bool) {
+	if _, ok := order.PickupPoint(); !ok {
+		return 0, false
+	}
+	if _, ok := order.DropPoint(); !ok {
+		return 0, false
+	}
+	points := RoutePoints(order)
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += geo.Distance(points[i-1], points[i])
+	}
+	return math.Round(total*100) / 100, true
+}
+
+// orderLocationColumns are the columns a location change (and the repricing
+// that may follow it) writes
+var orderLocationColumns = []string{
+	"pickup_location", "pickup_lat", "pickup_lng", "pickup_landmark", "pickup_place_id",
+	"drop_location", "drop_lat", "drop_lng", "drop_landmark", "drop_place_id",
+	"distance", "tariff_id", "price", "fare_breakdown", "updated_at",
+}
+
+// SaveOrderLocation stores the order's pickup, drop and fare. Like
+// TransitionOrder it only writes while the order still has the status it was
+// loaded with, so a concurrent accept or expiry is not overwritten; it
+// returns ErrOrderConflict otherwise.
+func SaveOrderLocation(db *gorm.DB, order *models.Order) error {
+	order.UpdatedAt = time.Now()
+	result := db.Model(order).Where("status = ?", order.Status).Select(orderLocationColumns).Updates(order)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderConflict
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestSetOrderPickupAndDrop(t *testing.T) {
+	var order models.Order
+
+	err := SetOrderPickup(&order, OrderPlace{
+		Lat: floatPtr(-7.792600), Lng: floatPtr(110.365800),
+		Address: "Jl. Malioboro", Landmark: "Pasar Beringharjo", PlaceID: "place-1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Jl. Malioboro", order.PickupLocation)
+	assert.Equal(t, "Pasar Beringharjo", order.PickupLandmark)
+	assert.Equal(t, "place-1", order.PickupPlaceID)
+
+	_, ok := TripDistanceKm(order)
+	assert.False(t, ok, "distance needs both points")
+
+	order.DropLocation = "Tugu"
+	err = SetOrderDrop(&order, OrderPlace{Lat: floatPtr(-7.782889), Lng: floatPtr(110.367083)})
+	assert.NoError(t, err)
+	assert.Equal(t, "Tugu", order.DropLocation, "address label kept when place has none")
+
+	distance, ok := TripDistanceKm(order)
+	assert.True(t, ok)
+	assert.InDelta(t, 1.09, distance, 0.02)
+}
+
+func TestOrderPlaceRejectsInvalidCoordinates(t *testing.T) {
+	tests := []struct {
+		name  string
+		place OrderPlace
+	}{
+		{"missing lng", OrderPlace{Lat: floatPtr(-7.79)}},
+		{"zero point", OrderPlace{Lat: floatPtr(0), Lng: floatPtr(0)}},
+		{"latitude out of range", OrderPlace{Lat: floatPtr(-97.79), Lng: floatPtr(110.36)}},
+		{"longitude out of range", OrderPlace{Lat: floatPtr(-7.79), Lng: floatPtr(210.36)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order models.Order
+			assert.ErrorIs(t, SetOrderPickup(&order, tt.place), ErrInvalidCoordinates)
+			assert.Nil(t, order.PickupLat)
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Multi-stop Trips
+// ================
+// Order bisa punya daftar titik singgah berurutan di antara pickup dan drop,
+// masing-masing dengan waktu tunggu yang dipesan. Order round trip kembali ke
+// titik jemput, jadi drop diisi sama dengan pickup. Jarak order adalah total
+// rute pickup -> singgah -> drop, dan waktu tunggu ditagih lewat fare engine.
+// Driver menandai tiba/berangkat di setiap titik singgah; waktu tunggu
+// sebenarnya yang melebihi pesanan ikut ditagih.
+
+var (
+	ErrInvalidStops   = errors.New("invalid stops")
+	ErrStopNotFound   = errors.New("stop not found")
+	ErrStopOutOfOrder = errors.New("previous stop has not been departed yet")
+	ErrStopState      = errors.New("stop cannot be updated in its current state")
+	ErrNotOnBoard     = errors.New("customer has not been picked up yet")
+)
+
+// EventOrderStop is pushed on the order stream when the driver arrives at or departs a stop
+const EventOrderStop = "order_stop"
+
+// StopInput is a stop sent by clients when creating an order
+type StopInput struct {
+	OrderPlace
+	WaitMinutes int `json:"wait_minutes"`
+}
+
+type StopConfig struct {
+	MaxStops       int
+	MaxWaitMinutes int // Per titik singgah
+}
+
+// LoadStopConfig reads multi-stop limits from the environment
+func LoadStopConfig() StopConfig {
+	return StopConfig{
+		MaxStops:       int(envFloat("ORDER_MAX_STOPS", 5)),
+		MaxWaitMinutes: int(envFloat("ORDER_MAX_STOP_WAIT_MINUTES", 120)),
+	}
+}
+
+// SetOrderStops validates the stops and sets them, the total booked waiting time
+// and the round-trip flag on the order. A round trip ends at the pickup point,
+// so it must not carry its own drop.
+func SetOrderStops(order *models.Order, stops []StopInput, roundTrip bool, config StopConfig) error {
+	if len(stops) > config.MaxStops {
+		return fmt.Errorf("%w: at most %d stops are allowed", ErrInvalidStops, config.MaxStops)
+	}
+
+	order.Stops = make([]models.OrderStop, 0, len(stops))
+	order.WaitMinutes = 0
+	for i, input := range stops {
+		point, err := input.Point()
+		if err != nil {
+			return fmt.Errorf("%w: stop %d has invalid coordinates", ErrInvalidStops, i+1)
+		}
+		if input.WaitMinutes < 0 || input.WaitMinutes > config.MaxWaitMinutes {
+			return fmt.Errorf("%w: wait_minutes of stop %d must be between 0 and %d", ErrInvalidStops, i+1, config.MaxWaitMinutes)
+		}
+		order.Stops = append(order.Stops, models.OrderStop{
+			Sequence:    i + 1,
+			Address:     input.Address,
+			Lat:         &point.Lat,
+			Lng:         &point.Lng,
+			Landmark:    input.Landmark,
+			PlaceID:     input.PlaceID,
+			WaitMinutes: input.WaitMinutes,
+		})
+		order.WaitMinutes += input.WaitMinutes
+	}
+
+	order.RoundTrip = roundTrip
+	if !roundTrip {
+		return nil
+	}
+	if order.DropLat != nil || order.DropLocation != "" {
+		return fmt.Errorf("%w: a round trip returns to pickup and cannot have a drop", ErrInvalidStops)
+	}
+	if len(order.Stops) == 0 {
+		return fmt.Errorf("%w: a round trip needs at least one stop", ErrInvalidStops)
+	}
+	order.DropLat, order.DropLng = order.PickupLat, order.PickupLng
+	order.DropLocation = order.PickupLocation
+	order.DropLandmark = order.PickupLandmark
+	order.DropPlaceID = order.PickupPlaceID
+	return nil
+}
+
+// RoutePoints lists the known points of the trip in visiting order:
+// pickup, each stop with coordinates, then drop
+func RoutePoints(order models.Order) []geo.Point {
+	var points []geo.Point
+	if pickup, ok := order.PickupPoint(); ok {
+		points = append(points, pickup)
+	}
+	for i := range order.Stops {
+		if point, ok := order.Stops[i].Point(); ok {
+			points = append(points, point)
+		}
+	}
+	if drop, ok := order.DropPoint(); ok {
+		points = append(points, drop)
+	}
+	return points
+}
+
+// ArriveAtStop marks the driver as arrived at the stop with the given sequence.
+// Stops must be visited in order, after the customer has been picked up.
+func ArriveAtStop(db *gorm.DB, order *models.Order, sequence int, now time.Time) (*models.OrderStop, error) {
+	stop, err := findStop(order, sequence)
+	if err != nil {
+		return nil, err
+	}
+	if stop.ArrivedAt != nil {
+		return nil, ErrStopState
+	}
+	if sequence > 1 && findStopUnchecked(order, sequence-1).DepartedAt == nil {
+		return nil, ErrStopOutOfOrder
+	}
+
+	result := db.Model(&models.OrderStop{}).
+		Where("id = ? AND arrived_at IS NULL", stop.ID).
+		Update("arrived_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrOrderConflict
+	}
+	stop.ArrivedAt = &now
+	return stop, nil
+}
+
+// DepartFromStop marks the driver as departed from a stop and records the actual
+// waiting time. When the total actual waiting exceeds the booked waiting time,
+// order.WaitMinutes is raised and true is returned so the fare can be updated.
+func DepartFromStop(db *gorm.DB, order *models.Order, sequence int, now time.Time) (*models.OrderStop, bool, error) {
+	stop, err := findStop(order, sequence)
+	if err != nil {
+		return nil, false, err
+	}
+	if stop.ArrivedAt == nil || stop.DepartedAt != nil {
+		return nil, false, ErrStopState
+	}
+
+	waited := int(math.Ceil(now.Sub(*stop.ArrivedAt).Minutes()))
+	if waited < 0 {
+		waited = 0
+	}
+
+	result := db.Model(&models.OrderStop{}).
+		Where("id = ? AND departed_at IS NULL", stop.ID).
+		Updates(map[string]interface{}{"departed_at": now, "waited_minutes": waited})
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, false, ErrOrderConflict
+	}
+	stop.DepartedAt = &now
+	stop.WaitedMinutes = waited
+
+	return stop, raiseWaitMinutes(order), nil
+}
+
+// raiseWaitMinutes sets order.WaitMinutes to the waiting actually incurred so far
+// when that is more than booked. Stops not yet departed count their booked time.
+func raiseWaitMinutes(order *models.Order) bool {
+	total := 0
+	for _, stop := range order.Stops {
+		if stop.DepartedAt != nil && stop.WaitedMinutes > stop.WaitMinutes {
+			total += stop.WaitedMinutes
+		} else {
+			total += stop.WaitMinutes
+		}
+	}
+	if total <= order.WaitMinutes {
+		return false
+	}
+	order.WaitMinutes = total
+	return true
+}
+
+// findStop returns the order's stop with the given sequence, checking that the
+// customer is on board
+func findStop(order *models.Order, sequence int) (*models.OrderStop, error) {
+	if order.Status != models.OrderStatusPickedUp && order.Status != models.OrderStatusInProgress {
+		return nil, ErrNotOnBoard
+	}
+	stop := findStopUnchecked(order, sequence)
+	if stop == nil {
+		return nil, ErrStopNotFound
+	}
+	return stop, nil
+}
+
+func findStopUnchecked(order *models.Order, sequence int) *models.OrderStop {
+	for i := range order.Stops {
+		if order.Stops[i].Sequence == sequence {
+			return &order.Stops[i]
+		}
+	}
+	return nil
+}
+
+// OrderStopsBySequence orders preloaded stops by their visiting order
+func OrderStopsBySequence(db *gorm.DB) *gorm.DB {
+	return db.Order("sequence ASC")
+}
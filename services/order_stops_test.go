@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tugu() OrderPlace {
+	return OrderPlace{Lat: floatPtr(-7.782889), Lng: floatPtr(110.367083), Address: "Tugu Jogja"}
+}
+
+func tamanSari() OrderPlace {
+	return OrderPlace{Lat: floatPtr(-7.810046), Lng: floatPtr(110.359228), Address: "Taman Sari"}
+}
+
+func TestSetOrderStops(t *testing.T) {
+	config := StopConfig{MaxStops: 2, MaxWaitMinutes: 60}
+
+	var order models.Order
+	assert.NoError(t, SetOrderPickup(&order, OrderPlace{Lat: floatPtr(-7.792600), Lng: floatPtr(110.365800), Address: "Jl. Malioboro"}))
+
+	err := SetOrderStops(&order, []StopInput{{OrderPlace: tugu(), WaitMinutes: 10}, {OrderPlace: tamanSari(), WaitMinutes: 30}}, true, config)
+	assert.NoError(t, err)
+	assert.Len(t, order.Stops, 2)
+	assert.Equal(t, 2, order.Stops[1].Sequence)
+	assert.Equal(t, 40, order.WaitMinutes)
+	assert.True(t, order.RoundTrip)
+	assert.Equal(t, "Jl. Malioboro", order.DropLocation, "round trip returns to pickup")
+	assert.Len(t, RoutePoints(order), 4)
+
+	distance, ok := TripDistanceKm(order)
+	assert.True(t, ok)
+	assert.Greater(t, distance, 4.0, "distance covers every leg")
+}
+
+func TestSetOrderStopsRejectsInvalidInput(t *testing.T) {
+	config := StopConfig{MaxStops: 2, MaxWaitMinutes: 60}
+	pickup := OrderPlace{Lat: floatPtr(-7.792600), Lng: floatPtr(110.365800)}
+
+	tests := []struct {
+		name      string
+		stops     []StopInput
+		roundTrip bool
+		withDrop  bool
+	}{
+		{"too many stops", []StopInput{{OrderPlace: tugu()}, {OrderPlace: tugu()}, {OrderPlace: tugu()}}, false, true},
+		{"missing coordinates", []StopInput{{OrderPlace: OrderPlace{Address: "Kraton"}}}, false, true},
+		{"waiting too long", []StopInput{{OrderPlace: tugu(), WaitMinutes: 90}}, false, true},
+		{"round trip with drop", []StopInput{{OrderPlace: tugu()}}, true, true},
+		{"round trip without stops", nil, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order models.Order
+			assert.NoError(t, SetOrderPickup(&order, pickup))
+			if tt.withDrop {
+				assert.NoError(t, SetOrderDrop(&order, tamanSari()))
+			}
+			err := SetOrderStops(&order, tt.stops, tt.roundTrip, config)
+			assert.True(t, errors.Is(err, ErrInvalidStops), "got %v", err)
+		})
+	}
+}
+
+func TestStopsRequireCustomerOnBoard(t *testing.T) {
+	order := models.Order{Status: models.OrderStatusAccepted, Stops: []models.OrderStop{{Sequence: 1}}}
+	_, err := ArriveAtStop(nil, &order, 1, time.Now())
+	assert.ErrorIs(t, err, ErrNotOnBoard)
+
+	order.Status = models.OrderStatusInProgress
+	_, err = ArriveAtStop(nil, &order, 3, time.Now())
+	assert.ErrorIs(t, err, ErrStopNotFound)
+
+	order.Stops = append(order.Stops, models.OrderStop{Sequence: 2})
+	_, err = ArriveAtStop(nil, &order, 2, time.Now())
+	assert.ErrorIs(t, err, ErrStopOutOfOrder)
+
+	_, _, err = DepartFromStop(nil, &order, 1, time.Now())
+	assert.ErrorIs(t, err, ErrStopState, "cannot depart before arriving")
+}
+
+func TestRaiseWaitMinutes(t *testing.T) {
+	departed := time.Now()
+	order := models.Order{
+		WaitMinutes: 30,
+		Stops: []models.OrderStop{
+			{Sequence: 1, WaitMinutes: 10, WaitedMinutes: 8, DepartedAt: &departed},
+			{Sequence: 2, WaitMinutes: 20},
+		},
+	}
+	assert.False(t, raiseWaitMinutes(&order), "waiting less than booked keeps the booked time")
+	assert.Equal(t, 30, order.WaitMinutes)
+
+	order.Stops[1].WaitedMinutes = 35
+	order.Stops[1].DepartedAt = &departed
+	assert.True(t, raiseWaitMinutes(&order))
+	assert.Equal(t, 45, order.WaitMinutes)
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventPaymentStatus is published on the order and admin streams when a payment settles
+const EventPaymentStatus = "payment_status"
+
+var (
+	ErrPaymentNotFound       = errors.New("payment not found")
+	ErrPaymentAmountMismatch = errors.New("payment amount does not match")
+	ErrPaymentStateConflict  = errors.New("payment already settled with another status")
+	ErrPaymentExists         = errors.New("payment already exists for this order")
+)
+
+// OrderAmountDue is what the customer pays for an order: the fare after
+// subsidy plus any package overtime, or the plain price for older orders
+func OrderAmountDue(order models.Order) float64 {
+	if order.FareBreakdown != nil {
+		return order.FareBreakdown.CustomerTotal
+	}
+	return order.Price
+}
+
+// CreateOrderPayment records a pending payment of the amount due on an order.
+// An order has at most one payment; when it already has one, that payment is
+// returned with ErrPaymentExists.
+func CreateOrderPayment(db *gorm.DB, order models.Order, method models.PaymentMethod, notes string) (models.Payment, error) {
+	var existing models.Payment
+	err := db.Where("order_id = ?", order.ID).First(&existing).Error
+	if err == nil {
+		return existing, ErrPaymentExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return existing, err
+	}
+
+	payment := models.Payment{
+		OrderID: order.ID,
+		Amount:  OrderAmountDue(order),
+		Method:  method,
+		Status:  models.PaymentStatusPending,
+		Notes:   notes,
+	}
+	if err := CreateWithReferenceNumber(db, ReferencePrefixPayment, &payment, func(number string) { payment.Reference = number }); err != nil {
+		// Permintaan bersamaan untuk order yang sama kalah di unique order_id
+		if IsDuplicateKeyError(err) && db.Where("order_id = ?", order.ID).First(&existing).Error == nil {
+			return existing, ErrPaymentExists
+		}
+		return payment, err
+	}
+	return payment, nil
+}
+
+// PaymentResult is a gateway outcome to record on a payment
+type PaymentResult struct {
+	Status      models.PaymentStatus
+	ProviderRef string
+	At          time.Time
+	Late        bool // Dibayar setelah tagihan kedaluwarsa
+}
+
+// ApplyPaymentResult moves a payment to paid or failed and then mirrors it on
+// Order.PaymentStatus. It is idempotent: repeating a result that is already
+// recorded changes nothing and reports false. A failed payment may still turn
+// paid (late settlement), but a paid payment never turns failed.
+func ApplyPaymentResult(db *gorm.DB, payment *models.Payment, result PaymentResult) (bool, error) {
+	var from []models.PaymentStatus
+	switch result.Status {
+	case models.PaymentStatusPaid:
+		from = []models.PaymentStatus{models.PaymentStatusPending, models.PaymentStatusFailed}
+	case models.PaymentStatusFailed:
+		from = []models.PaymentStatus{models.PaymentStatusPending}
+	default:
+		return false, nil
+	}
+	if result.At.IsZero() {
+		result.At = time.Now()
+	}
+
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the order before the payment, as completion does, so a fare that
+		// settles while its order completes is booked exactly once
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, payment.OrderID).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"status": result.Status, "updated_at": time.Now()}
+		if result.Status == models.PaymentStatusPaid {
+			updates["paid_at"] = result.At
+			if result.Late {
+				updates["paid_late"] = true
+			}
+		}
+		if result.ProviderRef != "" {
+			updates["gateway_ref"] = result.ProviderRef
+		}
+
+		res := tx.Model(&models.Payment{}).Where("id = ? AND status IN ?", payment.ID, from).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if err := tx.First(payment, payment.ID).Error; err != nil {
+			return err
+		}
+		if res.RowsAffected == 0 {
+			if payment.Status == result.Status {
+				return nil
+			}
+			return fmt.Errorf("%w: payment %s is %s", ErrPaymentStateConflict, payment.Reference, payment.Status)
+		}
+		changed = true
+
+		orderUpdate := tx.Model(&models.Order{}).Where("id = ?", payment.OrderID)
+		if result.Status == models.PaymentStatusFailed {
+			// Jangan menimpa order yang sudah lunas lewat jalur lain
+			orderUpdate = orderUpdate.Where("payment_status <> ?", string(models.PaymentStatusPaid))
+		}
+		if err := orderUpdate.Updates(map[string]interface{}{"payment_status": string(result.Status), "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		// Non-cash fares reach the driver's wallet only once they are paid
+		if result.Status == models.PaymentStatusPaid && payment.Method != models.PaymentMethodCash {
+			_, err := PostLateOrderPayment(tx, &order, result.At)
+			return err
+		}
+		return nil
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+
+	PublishPaymentStatus(*payment)
+	return true, nil
+}
+
+// SettleCashPayment marks the order's pending cash payment paid, as the
+// driver confirms receiving the cash by completing the trip. order must be
+// locked by the caller. It returns the settled payment, or nil when there was
+// no pending cash payment.
+func SettleCashPayment(tx *gorm.DB, order *models.Order, at time.Time) (*models.Payment, error) {
+	var payment models.Payment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND method = ? AND status = ?", order.ID, models.PaymentMethodCash, models.PaymentStatusPending).
+		First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	payment.Status = models.PaymentStatusPaid
+	payment.PaidAt = &at
+	if err := tx.Model(&payment).Updates(map[string]interface{}{"status": payment.Status, "paid_at": at, "updated_at": at}).Error; err != nil {
+		return nil, err
+	}
+	order.PaymentStatus = string(models.PaymentStatusPaid)
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).
+		Updates(map[string]interface{}{"payment_status": order.PaymentStatus, "updated_at": at}).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// PublishPaymentStatus tells the order's watchers and admins about a payment's status
+func PublishPaymentStatus(payment models.Payment) {
+	data := map[string]interface{}{
+		"payment_id": payment.ID,
+		"order_id":   payment.OrderID,
+		"reference":  payment.Reference,
+		"status":     payment.Status,
+		"paid_at":    payment.PaidAt,
+		"paid_late":  payment.PaidLate,
+	}
+	Hub.Publish(OrderTopic(payment.OrderID), EventPaymentStatus, data)
+	Hub.Publish(TopicAdmin, EventPaymentStatus, data)
+}
+
+// StartGatewayCharge bills a non-cash payment at the gateway and stores the
+// charge details (gateway reference, payment URL, expiry) on the payment
+func StartGatewayCharge(ctx context.Context, db *gorm.DB, gateway PaymentGateway, payment *models.Payment) (Charge, error) {
+	var order models.Order
+	if err := db.First(&order, payment.OrderID).Error; err != nil {
+		return Charge{}, err
+	}
+
+	charge, err := gateway.CreateCharge(ctx, ChargeRequest{
+		Reference:     payment.Reference,
+		Amount:        payment.Amount,
+		Method:        payment.Method,
+		CustomerName:  order.CustomerName,
+		CustomerPhone: order.CustomerPhone,
+		Description:   fmt.Sprintf("Order %s", order.OrderNumber),
+	})
+	if err != nil {
+		return Charge{}, err
+	}
+
+	payment.Gateway = gateway.Name()
+	payment.GatewayRef = charge.ProviderRef
+	payment.PaymentURL = charge.PaymentURL
+	payment.ExpiresAt = charge.ExpiresAt
+	if err := db.Model(payment).Select("gateway", "gateway_ref", "payment_url", "expires_at").Updates(payment).Error; err != nil {
+		return Charge{}, err
+	}
+	return charge, nil
+}
+
+// SyncPaymentWithGateway reads the charge status from the gateway and records a
+// final result. A payment the gateway does not know yet is charged first.
+func SyncPaymentWithGateway(ctx context.Context, db *gorm.DB, gateway PaymentGateway, payment *models.Payment) (bool, error) {
+	charge, err := gateway.ChargeStatus(ctx, payment.Reference)
+	if errors.Is(err, ErrChargeNotFound) {
+		if _, err = StartGatewayCharge(ctx, db, gateway, payment); err != nil {
+			return false, err
+		}
+		charge, err = gateway.ChargeStatus(ctx, payment.Reference)
+	}
+	if err != nil {
+		return false, err
+	}
+	if !sameRupiah(charge.Amount, payment.Amount) {
+		return false, fmt.Errorf("%w: gateway has %.0f, payment %s has %.0f", ErrPaymentAmountMismatch, charge.Amount, payment.Reference, payment.Amount)
+	}
+
+	at := time.Now()
+	if charge.PaidAt != nil {
+		at = *charge.PaidAt
+	}
+	return ApplyPaymentResult(db, payment, PaymentResult{Status: charge.Status, ProviderRef: charge.ProviderRef, At: at})
+}
+
+// HandlePaymentWebhook verifies a signed gateway notification and applies it to
+// the payment with the same reference. Redelivered notifications are no-ops.
+func HandlePaymentWebhook(db *gorm.DB, gateway PaymentGateway, secret string, body []byte, signature string) (models.Payment, bool, error) {
+	var payment models.Payment
+	if err := VerifyWebhookSignature(secret, body, signature); err != nil {
+		return payment, false, err
+	}
+	event, err := gateway.ParseWebhook(body)
+	if err != nil {
+		return payment, false, err
+	}
+
+	if err := db.Where("reference = ?", event.Reference).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return payment, false, fmt.Errorf("%w: %s", ErrPaymentNotFound, event.Reference)
+		}
+		return payment, false, err
+	}
+	if !sameRupiah(event.Amount, payment.Amount) {
+		return payment, false, fmt.Errorf("%w: notification has %.0f, payment %s has %.0f", ErrPaymentAmountMismatch, event.Amount, payment.Reference, payment.Amount)
+	}
+
+	changed, err := ApplyPaymentResult(db, &payment, PaymentResult{Status: event.Status, ProviderRef: event.ProviderRef, At: event.OccurredAt})
+	return payment, changed, err
+}
+
+// sameRupiah compares amounts to the whole rupiah, as gateways bill in whole rupiah
+func sameRupiah(a, b float64) bool {
+	return math.Round(a) == math.Round(b)
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"greenbecak-backend/models"
+)
+
+// Payment Gateway
+// ===============
+// PaymentGateway membuat tagihan (charge), menanyakan status dan melakukan
+// refund di penyedia pembayaran. PAYMENT_GATEWAY=fake memakai gateway palsu
+// yang deterministik untuk development; PAYMENT_GATEWAY=midtrans memakai
+// adapter HTTP bergaya Midtrans/Xendit. Tanpa PAYMENT_GATEWAY semua tagihan
+// non-tunai ditolak. Hasil pembayaran dikirim gateway lewat webhook yang
+// ditandatangani HMAC-SHA256 dengan PAYMENT_WEBHOOK_SECRET.
+
+var (
+	ErrChargeNotFound           = errors.New("charge not found at gateway")
+	ErrInvalidWebhookSignature  = errors.New("invalid webhook signature")
+	ErrInvalidWebhookPayload    = errors.New("invalid webhook payload")
+	ErrRefundExceedsPaid        = errors.New("refund exceeds paid amount")
+	ErrPaymentGatewayNotAllowed = errors.New("payment method is not processed by the gateway")
+	ErrPaymentGatewayNotSet     = errors.New("payment gateway is not configured")
+	ErrInvalidGatewayConfig     = errors.New("invalid payment gateway configuration")
+)
+
+// Supported payment gateways
+const (
+	PaymentGatewayNone     = "none"
+	PaymentGatewayFake     = "fake"
+	PaymentGatewayMidtrans = "midtrans"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the raw webhook body
+const WebhookSignatureHeader = "X-Callback-Signature"
+
+// ChargeRequest asks the gateway to bill a payment. Reference is the payment
+// number (PY-...) and is used as the merchant order id at the gateway.
+type ChargeRequest struct {
+	Reference     string
+	Amount        float64
+	Method        models.PaymentMethod
+	CustomerName  string
+	CustomerPhone string
+	Description   string
+}
+
+// Charge is the gateway's view of a payment
+type Charge struct {
+	Reference   string               `json:"reference"`
+	ProviderRef string               `json:"provider_ref"`
+	Status      models.PaymentStatus `json:"status"`
+	Amount      float64              `json:"amount"`
+	PaymentURL  string               `json:"payment_url,omitempty"` // Halaman bayar, QR atau nomor virtual account
+	ExpiresAt   *time.Time           `json:"expires_at,omitempty"`
+	PaidAt      *time.Time           `json:"paid_at,omitempty"`
+}
+
+// RefundRequest asks the gateway to return (part of) a paid charge
+type RefundRequest struct {
+	Reference string
+	RefundKey string // Dipakai gateway untuk menolak refund ganda
+	Amount    float64
+	Reason    string
+}
+
+// RefundResult is the gateway's answer to a refund
+type RefundResult struct {
+	ProviderRef string  `json:"provider_ref"`
+	Amount      float64 `json:"amount"`
+	Status      string  `json:"status"`
+}
+
+// WebhookEvent is a payment notification normalised from the gateway's payload
+type WebhookEvent struct {
+	Reference   string
+	ProviderRef string
+	Status      models.PaymentStatus
+	Amount      float64
+	OccurredAt  time.Time
+}
+
+type PaymentGateway interface {
+	// Name identifies the gateway on stored payments
+	Name() string
+	// CreateCharge bills a payment; creating the same reference twice returns the existing charge
+	CreateCharge(ctx context.Context, req ChargeRequest) (Charge, error)
+	// ChargeStatus returns the current state of a charge
+	ChargeStatus(ctx context.Context, reference string) (Charge, error)
+	// Refund returns money of a paid charge to the customer
+	Refund(ctx context.Context, req RefundRequest) (RefundResult, error)
+	// ParseWebhook turns a verified notification body into an event
+	ParseWebhook(body []byte) (WebhookEvent, error)
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of body under secret
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks signature against the body in constant time.
+// An empty secret rejects every webhook.
+func VerifyWebhookSignature(secret string, body []byte, signature string) error {
+	if secret == "" || signature == "" {
+		return ErrInvalidWebhookSignature
+	}
+	expected, err := hex.DecodeString(SignWebhook(secret, body))
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	given, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil || !hmac.Equal(expected, given) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
+
+// FakeGateway is an in-memory gateway for development and tests. A charge
+// settles the first time its status is queried, so results never depend on
+// timing; amounts accepted by Decline fail instead.
+type FakeGateway struct {
+	Decline func(amount float64) bool
+	Now     func() time.Time
+
+	mu       sync.Mutex
+	charges  map[string]*Charge
+	refunded map[string]float64
+	refunds  map[string]RefundResult
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		Now:      time.Now,
+		charges:  make(map[string]*Charge),
+		refunded: make(map[string]float64),
+		refunds:  make(map[string]RefundResult),
+	}
+}
+
+func (g *FakeGateway) Name() string {
+	return PaymentGatewayFake
+}
+
+func (g *FakeGateway) CreateCharge(ctx context.Context, req ChargeRequest) (Charge, error) {
+	if req.Method == models.PaymentMethodCash {
+		return Charge{}, ErrPaymentGatewayNotAllowed
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if charge, ok := g.charges[req.Reference]; ok {
+		return *charge, nil
+	}
+
+	expiresAt := g.Now().Add(24 * time.Hour)
+	charge := &Charge{
+		Reference:   req.Reference,
+		ProviderRef: "FAKE-" + req.Reference,
+		Status:      models.PaymentStatusPending,
+		Amount:      req.Amount,
+		PaymentURL:  "fake://pay/" + req.Reference,
+		ExpiresAt:   &expiresAt,
+	}
+	g.charges[req.Reference] = charge
+	return *charge, nil
+}
+
+func (g *FakeGateway) ChargeStatus(ctx context.Context, reference string) (Charge, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	charge, ok := g.charges[reference]
+	if !ok {
+		return Charge{}, ErrChargeNotFound
+	}
+
+	if charge.Status == models.PaymentStatusPending {
+		if g.Decline != nil && g.Decline(charge.Amount) {
+			charge.Status = models.PaymentStatusFailed
+		} else {
+			paidAt := g.Now()
+			charge.Status = models.PaymentStatusPaid
+			charge.PaidAt = &paidAt
+		}
+	}
+	return *charge, nil
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if result, ok := g.refunds[req.RefundKey]; ok && req.RefundKey != "" {
+		return result, nil
+	}
+
+	charge, ok := g.charges[req.Reference]
+	if !ok {
+		return RefundResult{}, ErrChargeNotFound
+	}
+	if charge.Status != models.PaymentStatusPaid && charge.Status != models.PaymentStatusRefunded {
+		return RefundResult{}, fmt.Errorf("charge %s is %s", req.Reference, charge.Status)
+	}
+	if req.Amount <= 0 || g.refunded[req.Reference]+req.Amount > charge.Amount {
+		return RefundResult{}, ErrRefundExceedsPaid
+	}
+
+	g.refunded[req.Reference] += req.Amount
+	if g.refunded[req.Reference] >= charge.Amount {
+		charge.Status = models.PaymentStatusRefunded
+	}
+	result := RefundResult{
+		ProviderRef: fmt.Sprintf("FAKE-RF-%s-%d", req.Reference, len(g.refunds)+1),
+		Amount:      req.Amount,
+		Status:      "succeeded",
+	}
+	if req.RefundKey != "" {
+		g.refunds[req.RefundKey] = result
+	}
+	return result, nil
+}
+
+// fakeWebhook is the notification body of the fake gateway
+type fakeWebhook struct {
+	Reference   string               `json:"reference"`
+	ProviderRef string               `json:"provider_ref"`
+	Status      models.PaymentStatus `json:"status"`
+	Amount      float64              `json:"amount"`
+	OccurredAt  time.Time            `json:"occurred_at"`
+}
+
+func (g *FakeGateway) ParseWebhook(body []byte) (WebhookEvent, error) {
+	var payload fakeWebhook
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Reference == "" {
+		return WebhookEvent{}, ErrInvalidWebhookPayload
+	}
+	switch payload.Status {
+	case models.PaymentStatusPending, models.PaymentStatusPaid, models.PaymentStatusFailed, models.PaymentStatusRefunded:
+	default:
+		return WebhookEvent{}, fmt.Errorf("%w: unknown status %q", ErrInvalidWebhookPayload, payload.Status)
+	}
+	if payload.OccurredAt.IsZero() {
+		payload.OccurredAt = g.Now()
+	}
+	return WebhookEvent(payload), nil
+}
+
+// PaymentGatewayConfig holds gateway settings from the environment
+type PaymentGatewayConfig struct {
+	Gateway       string
+	BaseURL       string
+	ServerKey     string
+	WebhookSecret string
+	Timeout       time.Duration
+}
+
+// LoadPaymentGatewayConfig reads PAYMENT_GATEWAY, PAYMENT_GATEWAY_URL,
+// PAYMENT_SERVER_KEY, PAYMENT_WEBHOOK_SECRET and PAYMENT_GATEWAY_TIMEOUT
+func LoadPaymentGatewayConfig() PaymentGatewayConfig {
+	return PaymentGatewayConfig{
+		Gateway:       strings.ToLower(os.Getenv("PAYMENT_GATEWAY")),
+		BaseURL:       os.Getenv("PAYMENT_GATEWAY_URL"),
+		ServerKey:     os.Getenv("PAYMENT_SERVER_KEY"),
+		WebhookSecret: os.Getenv("PAYMENT_WEBHOOK_SECRET"),
+		Timeout:       envDuration("PAYMENT_GATEWAY_TIMEOUT", 10*time.Second),
+	}
+}
+
+// LoadPaymentGateway builds the configured gateway. The fake gateway is only
+// used when chosen explicitly; without PAYMENT_GATEWAY every charge is
+// rejected, and an unknown or incomplete configuration is an error.
+func LoadPaymentGateway(config PaymentGatewayConfig) (PaymentGateway, error) {
+	switch config.Gateway {
+	case "", PaymentGatewayNone:
+		return UnconfiguredGateway{}, nil
+	case PaymentGatewayFake:
+		return NewFakeGateway(), nil
+	case PaymentGatewayMidtrans:
+		if config.BaseURL == "" || config.ServerKey == "" {
+			return nil, fmt.Errorf("%w: PAYMENT_GATEWAY=%s needs PAYMENT_GATEWAY_URL and PAYMENT_SERVER_KEY", ErrInvalidGatewayConfig, config.Gateway)
+		}
+		gateway := NewMidtransGateway(config.BaseURL, config.ServerKey)
+		gateway.Client.Timeout = config.Timeout
+		return gateway, nil
+	}
+	return nil, fmt.Errorf("%w: unknown PAYMENT_GATEWAY %q", ErrInvalidGatewayConfig, config.Gateway)
+}
+
+// UnconfiguredGateway stands in when no payment gateway is configured: every
+// call fails, so non-cash payments are never settled by accident
+type UnconfiguredGateway struct{}
+
+func (UnconfiguredGateway) Name() string {
+	return PaymentGatewayNone
+}
+
+func (UnconfiguredGateway) CreateCharge(ctx context.Context, req ChargeRequest) (Charge, error) {
+	return Charge{}, ErrPaymentGatewayNotSet
+}
+
+func (UnconfiguredGateway) ChargeStatus(ctx context.Context, reference string) (Charge, error) {
+	return Charge{}, ErrPaymentGatewayNotSet
+}
+
+func (UnconfiguredGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	return RefundResult{}, ErrPaymentGatewayNotSet
+}
+
+func (UnconfiguredGateway) ParseWebhook(body []byte) (WebhookEvent, error) {
+	return WebhookEvent{}, ErrPaymentGatewayNotSet
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenbecak-backend/models"
+)
+
+// MidtransGateway speaks a Midtrans Core API style protocol: charges are created
+// with the payment number as order_id, status is read back per order_id and
+// refunds are keyed by refund_key. Xendit and similar Indonesian providers fit
+// the same shape with a different request mapping.
+type MidtransGateway struct {
+	BaseURL   string
+	ServerKey string
+	Bank      string // Bank virtual account untuk metode transfer
+	Client    *http.Client
+}
+
+func NewMidtransGateway(baseURL, serverKey string) *MidtransGateway {
+	return &MidtransGateway{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		ServerKey: serverKey,
+		Bank:      "bca",
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (g *MidtransGateway) Name() string {
+	return PaymentGatewayMidtrans
+}
+
+// midtransTransaction is the charge, status and notification body
+type midtransTransaction struct {
+	StatusCode        string `json:"status_code"`
+	StatusMessage     string `json:"status_message"`
+	TransactionID     string `json:"transaction_id"`
+	OrderID           string `json:"order_id"`
+	GrossAmount       string `json:"gross_amount"`
+	TransactionStatus string `json:"transaction_status"`
+	TransactionTime   string `json:"transaction_time"`
+	SettlementTime    string `json:"settlement_time"`
+	ExpiryTime        string `json:"expiry_time"`
+	VANumbers         []struct {
+		Bank     string `json:"bank"`
+		VANumber string `json:"va_number"`
+	} `json:"va_numbers"`
+	Actions []struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"actions"`
+}
+
+// midtransTimeLayout is how Midtrans formats times (WIB)
+const midtransTimeLayout = "2006-01-02 15:04:05"
+
+func (g *MidtransGateway) CreateCharge(ctx context.Context, req ChargeRequest) (Charge, error) {
+	body := map[string]interface{}{
+		"transaction_details": map[string]interface{}{
+			"order_id":     req.Reference,
+			"gross_amount": int64(req.Amount),
+		},
+		"customer_details": map[string]interface{}{
+			"first_name": req.CustomerName,
+			"phone":      req.CustomerPhone,
+		},
+	}
+	switch req.Method {
+	case models.PaymentMethodQR:
+		body["payment_type"] = "qris"
+	case models.PaymentMethodTransfer:
+		body["payment_type"] = "bank_transfer"
+		body["bank_transfer"] = map[string]string{"bank": g.Bank}
+	default:
+		return Charge{}, ErrPaymentGatewayNotAllowed
+	}
+
+	var tx midtransTransaction
+	if err := g.do(ctx, http.MethodPost, "/v2/charge", body, &tx); err != nil {
+		return Charge{}, err
+	}
+	if !strings.HasPrefix(tx.StatusCode, "2") {
+		return Charge{}, fmt.Errorf("midtrans charge %s: %s", tx.StatusCode, tx.StatusMessage)
+	}
+	return tx.charge()
+}
+
+func (g *MidtransGateway) ChargeStatus(ctx context.Context, reference string) (Charge, error) {
+	var tx midtransTransaction
+	if err := g.do(ctx, http.MethodGet, "/v2/"+url.PathEscape(reference)+"/status", nil, &tx); err != nil {
+		return Charge{}, err
+	}
+	if tx.StatusCode == "404" {
+		return Charge{}, ErrChargeNotFound
+	}
+	return tx.charge()
+}
+
+func (g *MidtransGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	body := map[string]interface{}{
+		"refund_key": req.RefundKey,
+		"amount":     int64(req.Amount),
+		"reason":     req.Reason,
+	}
+	var resp struct {
+		StatusCode        string `json:"status_code"`
+		StatusMessage     string `json:"status_message"`
+		RefundChargeID    string `json:"refund_chargeback_id"`
+		RefundAmount      string `json:"refund_amount"`
+		TransactionStatus string `json:"transaction_status"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/v2/"+url.PathEscape(req.Reference)+"/refund", body, &resp); err != nil {
+		return RefundResult{}, err
+	}
+	if resp.StatusCode != "200" {
+		return RefundResult{}, fmt.Errorf("midtrans refund %s: %s", resp.StatusCode, resp.StatusMessage)
+	}
+	amount, _ := strconv.ParseFloat(resp.RefundAmount, 64)
+	return RefundResult{ProviderRef: resp.RefundChargeID, Amount: amount, Status: resp.TransactionStatus}, nil
+}
+
+func (g *MidtransGateway) ParseWebhook(body []byte) (WebhookEvent, error) {
+	var tx midtransTransaction
+	if err := json.Unmarshal(body, &tx); err != nil || tx.OrderID == "" {
+		return WebhookEvent{}, ErrInvalidWebhookPayload
+	}
+	charge, err := tx.charge()
+	if err != nil {
+		return WebhookEvent{}, fmt.Errorf("%w: %v", ErrInvalidWebhookPayload, err)
+	}
+
+	occurredAt := time.Now()
+	if charge.PaidAt != nil {
+		occurredAt = *charge.PaidAt
+	} else if at, err := time.ParseInLocation(midtransTimeLayout, tx.TransactionTime, referenceLocation); err == nil {
+		occurredAt = at
+	}
+	return WebhookEvent{
+		Reference:   charge.Reference,
+		ProviderRef: charge.ProviderRef,
+		Status:      charge.Status,
+		Amount:      charge.Amount,
+		OccurredAt:  occurredAt,
+	}, nil
+}
+
+// charge maps a Midtrans transaction onto a Charge
+func (tx midtransTransaction) charge() (Charge, error) {
+	status, err := midtransStatus(tx.TransactionStatus)
+	if err != nil {
+		return Charge{}, err
+	}
+	amount, err := strconv.ParseFloat(tx.GrossAmount, 64)
+	if err != nil {
+		return Charge{}, fmt.Errorf("invalid gross_amount %q", tx.GrossAmount)
+	}
+
+	charge := Charge{
+		Reference:   tx.OrderID,
+		ProviderRef: tx.TransactionID,
+		Status:      status,
+		Amount:      amount,
+	}
+	for _, action := range tx.Actions {
+		if action.Name == "generate-qr-code" || action.Name == "deeplink-redirect" {
+			charge.PaymentURL = action.URL
+			break
+		}
+	}
+	if charge.PaymentURL == "" && len(tx.VANumbers) > 0 {
+		charge.PaymentURL = fmt.Sprintf("va://%s/%s", tx.VANumbers[0].Bank, tx.VANumbers[0].VANumber)
+	}
+	if at, err := time.ParseInLocation(midtransTimeLayout, tx.ExpiryTime, referenceLocation); err == nil {
+		charge.ExpiresAt = &at
+	}
+	if at, err := time.ParseInLocation(midtransTimeLayout, tx.SettlementTime, referenceLocation); err == nil && status == models.PaymentStatusPaid {
+		charge.PaidAt = &at
+	}
+	return charge, nil
+}
+
+// midtransStatus maps transaction_status onto a payment status
+func midtransStatus(status string) (models.PaymentStatus, error) {
+	switch status {
+	case "settlement", "capture":
+		return models.PaymentStatusPaid, nil
+	case "partial_refund":
+		return models.PaymentStatusPartiallyRefunded, nil
+	case "pending", "authorize":
+		return models.PaymentStatusPending, nil
+	case "deny", "cancel", "expire", "failure":
+		return models.PaymentStatusFailed, nil
+	case "refund":
+		return models.PaymentStatusRefunded, nil
+	}
+	return "", fmt.Errorf("unknown transaction_status %q", status)
+}
+
+func (g *MidtransGateway) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(g.ServerKey, "")
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("midtrans request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrChargeNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("midtrans %s %s: status %d", method, path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("midtrans response: %w", err)
+	}
+	return nil
+}
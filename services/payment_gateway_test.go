@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"reference":"PY-20261017-0000019","status":"paid","amount":25000}`)
+	signature := SignWebhook("rahasia", body)
+
+	assert.NoError(t, VerifyWebhookSignature("rahasia", body, signature))
+	assert.ErrorIs(t, VerifyWebhookSignature("lain", body, signature), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhookSignature("rahasia", append(body, ' '), signature), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhookSignature("rahasia", body, "not-hex"), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhookSignature("", body, SignWebhook("", body)), ErrInvalidWebhookSignature, "an unset secret rejects everything")
+}
+
+func TestFakeGateway(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	gateway := NewFakeGateway()
+	gateway.Now = func() time.Time { return now }
+	gateway.Decline = func(amount float64) bool { return amount == 13000 }
+	ctx := context.Background()
+
+	_, err := gateway.CreateCharge(ctx, ChargeRequest{Reference: "PY-1", Amount: 25000, Method: models.PaymentMethodCash})
+	assert.ErrorIs(t, err, ErrPaymentGatewayNotAllowed)
+
+	charge, err := gateway.CreateCharge(ctx, ChargeRequest{Reference: "PY-1", Amount: 25000, Method: models.PaymentMethodQR})
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPending, charge.Status)
+	assert.Equal(t, "FAKE-PY-1", charge.ProviderRef)
+
+	again, err := gateway.CreateCharge(ctx, ChargeRequest{Reference: "PY-1", Amount: 25000, Method: models.PaymentMethodQR})
+	assert.NoError(t, err)
+	assert.Equal(t, charge, again, "charging a reference twice returns the same charge")
+
+	charge, err = gateway.ChargeStatus(ctx, "PY-1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPaid, charge.Status)
+	assert.Equal(t, now, *charge.PaidAt)
+
+	_, err = gateway.CreateCharge(ctx, ChargeRequest{Reference: "PY-2", Amount: 13000, Method: models.PaymentMethodTransfer})
+	assert.NoError(t, err)
+	charge, err = gateway.ChargeStatus(ctx, "PY-2")
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusFailed, charge.Status)
+
+	_, err = gateway.ChargeStatus(ctx, "PY-404")
+	assert.ErrorIs(t, err, ErrChargeNotFound)
+}
+
+func TestFakeGatewayRefund(t *testing.T) {
+	gateway := NewFakeGateway()
+	ctx := context.Background()
+	gateway.CreateCharge(ctx, ChargeRequest{Reference: "PY-1", Amount: 30000, Method: models.PaymentMethodQR})
+
+	_, err := gateway.Refund(ctx, RefundRequest{Reference: "PY-1", RefundKey: "RF-1", Amount: 10000})
+	assert.Error(t, err, "a pending charge cannot be refunded")
+
+	gateway.ChargeStatus(ctx, "PY-1")
+	first, err := gateway.Refund(ctx, RefundRequest{Reference: "PY-1", RefundKey: "RF-1", Amount: 10000})
+	assert.NoError(t, err)
+	assert.Equal(t, 10000.0, first.Amount)
+
+	replay, err := gateway.Refund(ctx, RefundRequest{Reference: "PY-1", RefundKey: "RF-1", Amount: 10000})
+	assert.NoError(t, err)
+	assert.Equal(t, first, replay, "a refund key is only refunded once")
+
+	_, err = gateway.Refund(ctx, RefundRequest{Reference: "PY-1", RefundKey: "RF-2", Amount: 25000})
+	assert.ErrorIs(t, err, ErrRefundExceedsPaid)
+
+	_, err = gateway.Refund(ctx, RefundRequest{Reference: "PY-1", RefundKey: "RF-3", Amount: 20000})
+	assert.NoError(t, err)
+	charge, _ := gateway.ChargeStatus(ctx, "PY-1")
+	assert.Equal(t, models.PaymentStatusRefunded, charge.Status)
+}
+
+func TestFakeGatewayParseWebhook(t *testing.T) {
+	gateway := NewFakeGateway()
+
+	event, err := gateway.ParseWebhook([]byte(`{"reference":"PY-1","provider_ref":"FAKE-PY-1","status":"paid","amount":25000,"occurred_at":"2026-10-17T09:00:00Z"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "PY-1", event.Reference)
+	assert.Equal(t, models.PaymentStatusPaid, event.Status)
+	assert.Equal(t, 25000.0, event.Amount)
+
+	_, err = gateway.ParseWebhook([]byte(`{"reference":"PY-1","status":"settled"}`))
+	assert.ErrorIs(t, err, ErrInvalidWebhookPayload)
+	_, err = gateway.ParseWebhook([]byte(`not json`))
+	assert.ErrorIs(t, err, ErrInvalidWebhookPayload)
+}
+
+func TestMidtransGateway(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _, _ := r.BasicAuth()
+		assert.Equal(t, "SB-server-key", key)
+
+		switch r.URL.Path {
+		case "/v2/charge":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, "qris", body["payment_type"])
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status_code":        "201",
+				"transaction_id":     "b2f0-11",
+				"order_id":           "PY-1",
+				"gross_amount":       "25000.00",
+				"transaction_status": "pending",
+				"expiry_time":        "2026-10-17 09:15:00",
+				"actions":            []map[string]string{{"name": "generate-qr-code", "url": "https://api.sandbox.midtrans.com/v2/qris/b2f0-11/qr-code"}},
+			})
+		case "/v2/PY-1/status":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status_code":        "200",
+				"transaction_id":     "b2f0-11",
+				"order_id":           "PY-1",
+				"gross_amount":       "25000.00",
+				"transaction_status": "settlement",
+				"settlement_time":    "2026-10-17 09:03:00",
+			})
+		case "/v2/PY-1/refund":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status_code":          "200",
+				"refund_chargeback_id": "rf-7",
+				"refund_amount":        "5000.00",
+				"transaction_status":   "partial_refund",
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	gateway := NewMidtransGateway(server.URL+"/", "SB-server-key")
+	ctx := context.Background()
+
+	charge, err := gateway.CreateCharge(ctx, ChargeRequest{Reference: "PY-1", Amount: 25000, Method: models.PaymentMethodQR})
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPending, charge.Status)
+	assert.Equal(t, "b2f0-11", charge.ProviderRef)
+	assert.Contains(t, charge.PaymentURL, "qr-code")
+	assert.Equal(t, time.Date(2026, 10, 17, 9, 15, 0, 0, referenceLocation), *charge.ExpiresAt)
+
+	charge, err = gateway.ChargeStatus(ctx, "PY-1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPaid, charge.Status)
+	assert.Equal(t, time.Date(2026, 10, 17, 9, 3, 0, 0, referenceLocation), *charge.PaidAt)
+
+	_, err = gateway.ChargeStatus(ctx, "PY-2")
+	assert.True(t, errors.Is(err, ErrChargeNotFound))
+
+	refund, err := gateway.Refund(ctx, RefundRequest{Reference: "PY-1", RefundKey: "RF-1", Amount: 5000})
+	assert.NoError(t, err)
+	assert.Equal(t, 5000.0, refund.Amount)
+}
+
+func TestMidtransParseWebhook(t *testing.T) {
+	gateway := NewMidtransGateway("https://api.sandbox.midtrans.com", "SB-server-key")
+
+	tests := []struct {
+		status string
+		want   models.PaymentStatus
+	}{
+		{"settlement", models.PaymentStatusPaid},
+		{"capture", models.PaymentStatusPaid},
+		{"pending", models.PaymentStatusPending},
+		{"expire", models.PaymentStatusFailed},
+		{"deny", models.PaymentStatusFailed},
+		{"refund", models.PaymentStatusRefunded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{
+				"order_id":           "PY-1",
+				"transaction_id":     "b2f0-11",
+				"gross_amount":       "25000.00",
+				"transaction_status": tt.status,
+				"transaction_time":   "2026-10-17 09:00:00",
+			})
+			event, err := gateway.ParseWebhook(body)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, event.Status)
+			assert.Equal(t, 25000.0, event.Amount)
+		})
+	}
+
+	_, err := gateway.ParseWebhook([]byte(`{"order_id":"PY-1","gross_amount":"25000.00","transaction_status":"mystery"}`))
+	assert.ErrorIs(t, err, ErrInvalidWebhookPayload)
+}
+
+func TestLoadPaymentGateway(t *testing.T) {
+	gateway, err := LoadPaymentGateway(PaymentGatewayConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentGatewayNone, gateway.Name())
+	_, err = gateway.ChargeStatus(context.Background(), "PY-1")
+	assert.ErrorIs(t, err, ErrPaymentGatewayNotSet, "an unconfigured gateway never reports a charge as paid")
+
+	gateway, err = LoadPaymentGateway(PaymentGatewayConfig{Gateway: PaymentGatewayFake})
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentGatewayFake, gateway.Name())
+
+	gateway, err = LoadPaymentGateway(PaymentGatewayConfig{Gateway: PaymentGatewayMidtrans, BaseURL: "https://api.sandbox.midtrans.com", ServerKey: "key"})
+	assert.NoError(t, err)
+	assert.Equal(t, PaymentGatewayMidtrans, gateway.Name())
+
+	_, err = LoadPaymentGateway(PaymentGatewayConfig{Gateway: PaymentGatewayMidtrans, BaseURL: "https://api.sandbox.midtrans.com"})
+	assert.ErrorIs(t, err, ErrInvalidGatewayConfig, "midtrans without a server key does not fall back to the fake gateway")
+	_, err = LoadPaymentGateway(PaymentGatewayConfig{Gateway: "xendit"})
+	assert.ErrorIs(t, err, ErrInvalidGatewayConfig)
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"greenbecak-backend/models"
+	"greenbecak-backend/qris"
+
+	"gorm.io/gorm"
+)
+
+// QRIS Payments
+// =============
+// Pembayaran metode qr mendapat QRIS dinamis: payload EMVCo dengan nominal,
+// data merchant dan nomor pembayaran sebagai bill number/reference label.
+// QR hanya bisa dipindai sampai ExpiresAt; notifikasi settlement dari acquirer
+// dicocokkan kembali ke pembayaran lewat reference label dan nominalnya.
+// Settlement yang datang setelah ExpiresAt tetap dicatat lunas dan ditandai
+// PaidLate, karena dana sudah berpindah di acquirer.
+
+// PaymentGatewayQRIS marks payments collected with our own dynamic QRIS
+const PaymentGatewayQRIS = "qris"
+
+var (
+	ErrQRISNotConfigured = errors.New("QRIS merchant is not configured")
+	ErrNotQRISPayment    = errors.New("payment is not a QRIS payment")
+	ErrQRISExpired       = errors.New("QRIS code has expired")
+)
+
+// QRISConfig is the merchant data printed in every QRIS payload
+type QRISConfig struct {
+	MerchantName  string
+	MerchantCity  string
+	PostalCode    string
+	NMID          string // National Merchant ID dari QRIS (field 51)
+	AcquirerGUID  string // Template acquirer (field 26), opsional
+	MerchantPAN   string
+	MerchantID    string
+	Criteria      string
+	CategoryCode  string
+	TerminalLabel string
+	TTL           time.Duration
+}
+
+// LoadQRISConfig reads the QRIS_* environment variables
+func LoadQRISConfig() QRISConfig {
+	return QRISConfig{
+		MerchantName:  envString("QRIS_MERCHANT_NAME", ""),
+		MerchantCity:  envString("QRIS_MERCHANT_CITY", "YOGYAKARTA"),
+		PostalCode:    envString("QRIS_POSTAL_CODE", ""),
+		NMID:          envString("QRIS_NMID", ""),
+		AcquirerGUID:  envString("QRIS_ACQUIRER_GUID", ""),
+		MerchantPAN:   envString("QRIS_MERCHANT_PAN", ""),
+		MerchantID:    envString("QRIS_MERCHANT_ID", ""),
+		Criteria:      envString("QRIS_MERCHANT_CRITERIA", "UMI"),
+		CategoryCode:  envString("QRIS_MCC", "4121"),
+		TerminalLabel: envString("QRIS_TERMINAL_LABEL", ""),
+		TTL:           envDuration("QRIS_TTL", 15*time.Minute),
+	}
+}
+
+// Enabled reports whether enough merchant data is configured to issue QRIS
+func (c QRISConfig) Enabled() bool {
+	return c.NMID != "" && c.MerchantName != ""
+}
+
+// BuildPaymentQRIS returns the dynamic QRIS payload for a payment
+func BuildPaymentQRIS(payment models.Payment, config QRISConfig) (string, error) {
+	if !config.Enabled() {
+		return "", ErrQRISNotConfigured
+	}
+
+	accounts := []qris.MerchantAccount{{
+		Tag:        "51",
+		GUID:       qris.GUIDQRIS,
+		MerchantID: config.NMID,
+		Criteria:   config.Criteria,
+	}}
+	if config.AcquirerGUID != "" {
+		accounts = append(accounts, qris.MerchantAccount{
+			Tag:        "26",
+			GUID:       config.AcquirerGUID,
+			PAN:        config.MerchantPAN,
+			MerchantID: config.MerchantID,
+			Criteria:   config.Criteria,
+		})
+	}
+
+	return qris.Build(qris.Payload{
+		Dynamic:              true,
+		MerchantAccounts:     accounts,
+		MerchantCategoryCode: config.CategoryCode,
+		Currency:             qris.CurrencyIDR,
+		Amount:               int64(math.Round(payment.Amount)),
+		CountryCode:          "ID",
+		MerchantName:         strings.ToUpper(config.MerchantName),
+		MerchantCity:         strings.ToUpper(config.MerchantCity),
+		PostalCode:           config.PostalCode,
+		BillNumber:           payment.Reference,
+		ReferenceLabel:       payment.Reference,
+		TerminalLabel:        config.TerminalLabel,
+	})
+}
+
+// IssueQRIS generates (or refreshes) the QRIS of a pending qr payment and
+// starts its validity window
+func IssueQRIS(db *gorm.DB, payment *models.Payment, config QRISConfig, now time.Time) error {
+	if payment.Method != models.PaymentMethodQR {
+		return ErrNotQRISPayment
+	}
+	if payment.Status != models.PaymentStatusPending {
+		return fmt.Errorf("%w: payment %s is %s", ErrPaymentStateConflict, payment.Reference, payment.Status)
+	}
+
+	payload, err := BuildPaymentQRIS(*payment, config)
+	if err != nil {
+		return err
+	}
+	expiresAt := now.Add(config.TTL)
+
+	payment.Gateway = PaymentGatewayQRIS
+	payment.QRPayload = payload
+	payment.ExpiresAt = &expiresAt
+	return db.Model(payment).Select("gateway", "qr_payload", "expires_at").Updates(payment).Error
+}
+
+// QRISExpired reports whether the payment's QR can no longer be paid at t
+func QRISExpired(payment models.Payment, t time.Time) bool {
+	return payment.ExpiresAt != nil && t.After(*payment.ExpiresAt)
+}
+
+// ExpireQRISPayment fails a pending QRIS payment whose QR has expired
+func ExpireQRISPayment(db *gorm.DB, payment *models.Payment, now time.Time) (bool, error) {
+	if payment.Gateway != PaymentGatewayQRIS {
+		return false, ErrNotQRISPayment
+	}
+	if payment.Status != models.PaymentStatusPending || !QRISExpired(*payment, now) {
+		return false, nil
+	}
+	return ApplyPaymentResult(db, payment, PaymentResult{Status: models.PaymentStatusFailed, At: now})
+}
+
+// QRISSettlement is a payment notification from the QRIS acquirer
+type QRISSettlement struct {
+	ReferenceLabel string               `json:"reference_label"`
+	BillNumber     string               `json:"bill_number"`
+	Payload        string               `json:"qr_payload"` // Payload yang dipindai, jika dikirim acquirer
+	Amount         float64              `json:"amount"`
+	RRN            string               `json:"rrn"` // Retrieval reference number transaksi
+	Status         models.PaymentStatus `json:"status"`
+	PaidAt         time.Time            `json:"paid_at"`
+}
+
+// settlementReference finds the payment reference the settlement is for
+func settlementReference(settlement QRISSettlement) (string, error) {
+	if settlement.Payload != "" {
+		payload, err := qris.Parse(settlement.Payload)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidWebhookPayload, err)
+		}
+		if float64(payload.Amount) != math.Round(settlement.Amount) {
+			return "", fmt.Errorf("%w: scanned QR is for %d", ErrPaymentAmountMismatch, payload.Amount)
+		}
+		if payload.ReferenceLabel != "" {
+			return payload.ReferenceLabel, nil
+		}
+		return payload.BillNumber, nil
+	}
+	if settlement.ReferenceLabel != "" {
+		return settlement.ReferenceLabel, nil
+	}
+	if settlement.BillNumber != "" {
+		return settlement.BillNumber, nil
+	}
+	return "", fmt.Errorf("%w: settlement has no reference", ErrInvalidWebhookPayload)
+}
+
+// MatchQRISSettlement applies an acquirer settlement to the QRIS payment it
+// belongs to. Settlements for another amount are rejected; a payment settled
+// after its QR expired is still recorded as paid and flagged PaidLate.
+// Repeated settlements are no-ops.
+func MatchQRISSettlement(db *gorm.DB, settlement QRISSettlement) (models.Payment, bool, error) {
+	var payment models.Payment
+	reference, err := settlementReference(settlement)
+	if err != nil {
+		return payment, false, err
+	}
+
+	if err := db.Where("reference = ? AND method = ?", reference, models.PaymentMethodQR).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return payment, false, fmt.Errorf("%w: %s", ErrPaymentNotFound, reference)
+		}
+		return payment, false, err
+	}
+	if !sameRupiah(settlement.Amount, payment.Amount) {
+		return payment, false, fmt.Errorf("%w: settlement has %.0f, payment %s has %.0f", ErrPaymentAmountMismatch, settlement.Amount, payment.Reference, payment.Amount)
+	}
+
+	status := settlement.Status
+	if status == "" {
+		status = models.PaymentStatusPaid
+	}
+	paidAt := settlement.PaidAt
+	if paidAt.IsZero() {
+		paidAt = time.Now()
+	}
+	late := status == models.PaymentStatusPaid && QRISExpired(payment, paidAt)
+
+	changed, err := ApplyPaymentResult(db, &payment, PaymentResult{Status: status, ProviderRef: settlement.RRN, At: paidAt, Late: late})
+	return payment, changed, err
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+	"greenbecak-backend/qris"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testQRISConfig() QRISConfig {
+	return QRISConfig{
+		MerchantName: "Green Becak Jogja",
+		MerchantCity: "Yogyakarta",
+		PostalCode:   "55111",
+		NMID:         "ID1026441987654",
+		Criteria:     "UMI",
+		CategoryCode: "4121",
+		TTL:          15 * time.Minute,
+	}
+}
+
+func TestBuildPaymentQRIS(t *testing.T) {
+	payment := models.Payment{Reference: "PY-20261017-0000019", Amount: 25000, Method: models.PaymentMethodQR}
+
+	_, err := BuildPaymentQRIS(payment, QRISConfig{})
+	assert.ErrorIs(t, err, ErrQRISNotConfigured)
+
+	payload, err := BuildPaymentQRIS(payment, testQRISConfig())
+	assert.NoError(t, err)
+
+	parsed, err := qris.Parse(payload)
+	assert.NoError(t, err)
+	assert.True(t, parsed.Dynamic)
+	assert.Equal(t, int64(25000), parsed.Amount)
+	assert.Equal(t, "GREEN BECAK JOGJA", parsed.MerchantName)
+	assert.Equal(t, "PY-20261017-0000019", parsed.ReferenceLabel)
+	account, ok := parsed.MerchantAccount(qris.GUIDQRIS)
+	assert.True(t, ok)
+	assert.Equal(t, "ID1026441987654", account.MerchantID)
+}
+
+func TestQRISExpired(t *testing.T) {
+	expiresAt := time.Date(2026, 10, 17, 9, 15, 0, 0, time.UTC)
+	payment := models.Payment{ExpiresAt: &expiresAt}
+
+	assert.False(t, QRISExpired(payment, expiresAt))
+	assert.True(t, QRISExpired(payment, expiresAt.Add(time.Second)))
+	assert.False(t, QRISExpired(models.Payment{}, expiresAt), "a payment without expiry never expires")
+}
+
+func TestSettlementReference(t *testing.T) {
+	payload, err := BuildPaymentQRIS(models.Payment{Reference: "PY-20261017-0000019", Amount: 25000}, testQRISConfig())
+	assert.NoError(t, err)
+
+	reference, err := settlementReference(QRISSettlement{Payload: payload, Amount: 25000})
+	assert.NoError(t, err)
+	assert.Equal(t, "PY-20261017-0000019", reference)
+
+	_, err = settlementReference(QRISSettlement{Payload: payload, Amount: 20000})
+	assert.ErrorIs(t, err, ErrPaymentAmountMismatch)
+
+	_, err = settlementReference(QRISSettlement{Payload: strings.Replace(payload, "5802ID", "5802MY", 1), Amount: 25000})
+	assert.ErrorIs(t, err, ErrInvalidWebhookPayload)
+
+	reference, err = settlementReference(QRISSettlement{BillNumber: "PY-20261017-0000020", Amount: 25000})
+	assert.NoError(t, err)
+	assert.Equal(t, "PY-20261017-0000020", reference)
+
+	_, err = settlementReference(QRISSettlement{Amount: 25000})
+	assert.ErrorIs(t, err, ErrInvalidWebhookPayload)
+}
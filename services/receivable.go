@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Settlement methods for a driver's cash commission receivable
+const (
+	SettlementMethodCash     = "cash"     // Setor tunai ke kantor
+	SettlementMethodTransfer = "transfer" // Transfer ke rekening platform
+	SettlementMethodWallet   = "wallet"   // Dipotong dari saldo wallet driver
+)
+
+var (
+	ErrInvalidSettlement = errors.New("invalid settlement")
+	ErrSettlementTooHigh = errors.New("settlement exceeds the outstanding receivable")
+)
+
+// SettlementRequest is a driver paying off cash commission
+type SettlementRequest struct {
+	Amount    float64
+	Method    string
+	Reference string // Nomor bukti setoran/transfer
+	Memo      string
+	CreatedBy *uint
+}
+
+// SettlementLines moves a settlement off the driver's receivable, against
+// the platform's collections for cash/transfer or the driver's wallet
+func SettlementLines(driverID uint, method string, amount float64) ([]LedgerLine, error) {
+	if toCents(amount) <= 0 {
+		return nil, fmt.Errorf("%w: amount must be greater than 0", ErrInvalidSettlement)
+	}
+	switch method {
+	case SettlementMethodCash, SettlementMethodTransfer:
+		return []LedgerLine{
+			debit(LedgerAccountCollections, amount),
+			credit(DriverReceivableAccount(driverID), amount),
+		}, nil
+	case SettlementMethodWallet:
+		return []LedgerLine{
+			debit(DriverWalletAccount(driverID), amount),
+			credit(DriverReceivableAccount(driverID), amount),
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: method must be cash, transfer or wallet", ErrInvalidSettlement)
+}
+
+// SettleReceivable records a settlement of a driver's receivable. The driver
+// row is locked so concurrent settlements cannot overpay, and wallet
+// settlements may only use the wallet's available balance.
+func SettleReceivable(db *gorm.DB, driverID uint, req SettlementRequest, now time.Time) (models.JournalEntry, error) {
+	var entry models.JournalEntry
+	lines, err := SettlementLines(driverID, req.Method, req.Amount)
+	if err != nil {
+		return entry, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var driver models.Driver
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&driver, driverID).Error; err != nil {
+			return err
+		}
+
+		wallet, err := GetDriverWallet(tx, driverID)
+		if err != nil {
+			return err
+		}
+		if toCents(req.Amount) > toCents(wallet.Receivable) {
+			return fmt.Errorf("%w: outstanding %.0f, settlement %.0f", ErrSettlementTooHigh, wallet.Receivable, req.Amount)
+		}
+		if req.Method == SettlementMethodWallet && toCents(req.Amount) > toCents(wallet.Balance-wallet.Held) {
+			return fmt.Errorf("%w: wallet has %.0f available", ErrInsufficientFunds, wallet.Balance-wallet.Held)
+		}
+
+		memo := strings.TrimSpace(fmt.Sprintf("Commission settlement by %s %s", req.Method, req.Reference))
+		if req.Memo != "" {
+			memo += ": " + req.Memo
+		}
+		entry, _, err = PostJournal(tx, JournalRequest{
+			Key:           fmt.Sprintf("driver:%d:settlement:%d", driverID, now.UnixNano()),
+			Type:          models.JournalEntrySettlement,
+			ReferenceType: "driver",
+			ReferenceID:   driverID,
+			Memo:          memo,
+			CreatedBy:     req.CreatedBy,
+			PostedAt:      now,
+			Lines:         lines,
+		})
+		return err
+	})
+	return entry, err
+}
+
+// DriverReceivable is what a driver owes the platform in cash commission
+type DriverReceivable struct {
+	DriverID    uint    `json:"driver_id"`
+	Charged     float64 `json:"charged"`     // Total komisi order tunai
+	Settled     float64 `json:"settled"`     // Total pelunasan dan koreksi
+	Outstanding float64 `json:"outstanding"` // Sisa piutang
+}
+
+// ListReceivables returns drivers whose receivable is at least minOutstanding,
+// largest first
+func ListReceivables(db *gorm.DB, minOutstanding float64) ([]DriverReceivable, error) {
+	var rows []DriverReceivable
+	err := db.Model(&models.LedgerPosting{}).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Where("ledger_accounts.code LIKE ?", "driver:%:receivable").
+		Group("ledger_accounts.driver_id").
+		Select("ledger_accounts.driver_id AS driver_id, "+
+			"COALESCE(SUM(CASE WHEN ledger_postings.amount > 0 THEN ledger_postings.amount ELSE 0 END), 0) AS charged, "+
+			"COALESCE(SUM(CASE WHEN ledger_postings.amount < 0 THEN -ledger_postings.amount ELSE 0 END), 0) AS settled, "+
+			"COALESCE(SUM(ledger_postings.amount), 0) AS outstanding").
+		Having("COALESCE(SUM(ledger_postings.amount), 0) >= ?", minOutstanding).
+		Order("outstanding DESC").
+		Scan(&rows).Error
+	for i := range rows {
+		rows[i].Charged = roundCents(rows[i].Charged)
+		rows[i].Settled = roundCents(rows[i].Settled)
+		rows[i].Outstanding = roundCents(rows[i].Outstanding)
+	}
+	return rows, err
+}
+
+// GetDriverReceivable summarises one driver's receivable
+func GetDriverReceivable(db *gorm.DB, driverID uint) (DriverReceivable, error) {
+	receivable := DriverReceivable{DriverID: driverID}
+	err := db.Model(&models.LedgerPosting{}).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Where("ledger_accounts.code = ?", DriverReceivableAccount(driverID).Code).
+		Select("COALESCE(SUM(CASE WHEN ledger_postings.amount > 0 THEN ledger_postings.amount ELSE 0 END), 0) AS charged, " +
+			"COALESCE(SUM(CASE WHEN ledger_postings.amount < 0 THEN -ledger_postings.amount ELSE 0 END), 0) AS settled").
+		Scan(&receivable).Error
+	receivable.Charged = roundCents(receivable.Charged)
+	receivable.Settled = roundCents(receivable.Settled)
+	receivable.Outstanding = roundCents(receivable.Charged - receivable.Settled)
+	return receivable, err
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettlementLines(t *testing.T) {
+	lines, err := SettlementLines(5, SettlementMethodCash, 12000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), sumLines(lines))
+	assert.Equal(t, LedgerAccountCollections.Code, lines[0].Account.Code)
+	assert.Equal(t, "driver:5:receivable", lines[1].Account.Code)
+	assert.Equal(t, -12000.0, lines[1].Amount)
+
+	lines, err = SettlementLines(5, SettlementMethodWallet, 12000)
+	assert.NoError(t, err)
+	assert.Equal(t, "driver:5:wallet", lines[0].Account.Code)
+	assert.Equal(t, 12000.0, lines[0].Amount)
+
+	_, err = SettlementLines(5, "gopay", 12000)
+	assert.ErrorIs(t, err, ErrInvalidSettlement)
+	_, err = SettlementLines(5, SettlementMethodTransfer, 0)
+	assert.ErrorIs(t, err, ErrInvalidSettlement)
+}
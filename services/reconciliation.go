@@ -0,0 +1,653 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Payment Reconciliation
+// ======================
+// Settlement dari PSP dicocokkan dengan tabel payments. Sumbernya file CSV
+// settlement (Midtrans, Xendit, DOKU atau ekspor bank dengan header yang
+// dikenali) atau status tagihan yang dibaca dari API payment gateway. Setiap
+// baris dicocokkan berdasarkan nomor referensi PY-... (atau ID transaksi
+// gateway), lalu dibandingkan nominal dan statusnya. Payment non-tunai yang
+// lunas pada hari settlement tetapi tidak muncul di settlement juga ditandai.
+//
+// Hasilnya disimpan sebagai reconciliation run beserta item per baris; job
+// harian merekonsiliasi hari kemarin (WIB) lewat API gateway.
+
+var (
+	ErrInvalidStatement     = errors.New("invalid settlement file")
+	ErrReconciliationExists = errors.New("reconciliation already ran")
+	ErrReconciliationNoDate = errors.New("reconciliation date is required")
+)
+
+const reconciliationItemsBatch = 200
+
+// StatementEntry is one transaction reported by a settlement source
+type StatementEntry struct {
+	Line        int
+	Reference   string
+	ProviderRef string
+	Amount      float64
+	Status      models.PaymentStatus // Kosong jika sumber tidak menyertakan status
+	RawStatus   string
+	At          *time.Time
+	Error       string // Alasan baris tidak bisa dibaca
+}
+
+// Header names used by common Indonesian PSP settlement exports, after
+// normaliseHeader
+var (
+	statementReferenceHeaders = []string{"order id", "orderid", "merchant order id", "external id", "reference", "reference id",
+		"merchant reference", "merchant ref", "partner reference no", "invoice number", "invoice no", "invoice",
+		"no referensi", "nomor referensi", "referensi"}
+	statementProviderRefHeaders = []string{"transaction id", "payment id", "id", "rrn", "reference no", "transaction reference"}
+	statementAmountHeaders      = []string{"gross amount", "amount", "transaction amount", "paid amount", "nominal", "jumlah",
+		"nominal transaksi", "total"}
+	statementStatusHeaders = []string{"transaction status", "status", "payment status", "status transaksi"}
+	statementTimeHeaders   = []string{"transaction time", "settlement time", "paid at", "payment date", "transaction date",
+		"created", "created at", "waktu transaksi", "tanggal transaksi", "tanggal", "date"}
+)
+
+var statementTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02-01-2006 15:04:05",
+	"2006-01-02",
+	"02/01/2006",
+}
+
+func normaliseHeader(header string) string {
+	header = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, "\ufeff")))
+	header = strings.NewReplacer("_", " ", "-", " ", ".", " ").Replace(header)
+	return strings.Join(strings.Fields(header), " ")
+}
+
+// statementColumns maps a header row onto column indexes (-1 when absent)
+type statementColumns struct {
+	reference, providerRef, amount, status, at int
+}
+
+func findStatementColumns(header []string) (statementColumns, bool) {
+	names := make([]string, len(header))
+	for i, name := range header {
+		names[i] = normaliseHeader(name)
+	}
+	find := func(aliases []string) int {
+		for _, alias := range aliases {
+			for i, name := range names {
+				if name == alias {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	cols := statementColumns{
+		reference:   find(statementReferenceHeaders),
+		providerRef: find(statementProviderRefHeaders),
+		amount:      find(statementAmountHeaders),
+		status:      find(statementStatusHeaders),
+		at:          find(statementTimeHeaders),
+	}
+	if cols.providerRef == cols.reference {
+		cols.providerRef = -1
+	}
+	return cols, cols.amount >= 0 && (cols.reference >= 0 || cols.providerRef >= 0)
+}
+
+// detectDelimiter picks the separator used on the first line; Excel with an
+// Indonesian locale exports CSV with semicolons
+func detectDelimiter(data []byte) rune {
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line = data[:i]
+	}
+	best, count := ',', bytes.Count(line, []byte{','})
+	for _, sep := range []rune{';', '\t', '|'} {
+		if n := bytes.Count(line, []byte(string(sep))); n > count {
+			best, count = sep, n
+		}
+	}
+	return best
+}
+
+// ParseSettlementCSV reads a settlement file. The header row may follow a few
+// report title lines; unreadable rows are returned with Error set instead of
+// being dropped.
+func ParseSettlementCSV(r io.Reader) ([]StatementEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var cols statementColumns
+	found := false
+	for i := 0; i < 10 && !found; i++ {
+		header, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+		}
+		cols, found = findStatementColumns(header)
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no reference and amount columns found", ErrInvalidStatement)
+	}
+
+	var entries []StatementEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return entries, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+		}
+		line, _ := reader.FieldPos(0)
+		if blankRecord(record) {
+			continue
+		}
+		entries = append(entries, parseStatementRecord(record, cols, line))
+	}
+	return entries, nil
+}
+
+func blankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseStatementRecord(record []string, cols statementColumns, line int) StatementEntry {
+	field := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	entry := StatementEntry{
+		Line:        line,
+		Reference:   field(cols.reference),
+		ProviderRef: field(cols.providerRef),
+		RawStatus:   field(cols.status),
+	}
+	if entry.Reference == "" && entry.ProviderRef == "" {
+		entry.Error = "missing reference"
+		return entry
+	}
+
+	amount, err := ParseRupiahAmount(field(cols.amount))
+	if err != nil {
+		entry.Error = fmt.Sprintf("invalid amount %q", field(cols.amount))
+		return entry
+	}
+	entry.Amount = amount
+
+	if entry.RawStatus != "" {
+		status, ok := NormaliseSettlementStatus(entry.RawStatus)
+		if !ok {
+			entry.Error = fmt.Sprintf("unknown status %q", entry.RawStatus)
+			return entry
+		}
+		entry.Status = status
+	}
+	if value := field(cols.at); value != "" {
+		for _, layout := range statementTimeLayouts {
+			if at, err := time.ParseInLocation(layout, value, referenceLocation); err == nil {
+				entry.At = &at
+				break
+			}
+		}
+	}
+	return entry
+}
+
+// ParseRupiahAmount reads amounts as PSPs and Indonesian spreadsheets write
+// them: "Rp 25.000", "25.000,00", "IDR 25,000.00", "25000.00" or "(5.000)"
+func ParseRupiahAmount(value string) (float64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+	s = strings.NewReplacer("IDR", "", "RP", "", " ", "", "\u00a0", "").Replace(s)
+	if strings.HasPrefix(s, "-") {
+		negative = !negative
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+
+	lastDot, lastComma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// The separator that comes last is the decimal one
+		if lastComma > lastDot {
+			s = strings.ReplaceAll(s, ".", "")
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case lastDot >= 0:
+		s = normaliseSingleSeparator(s, ".")
+	case lastComma >= 0:
+		s = normaliseSingleSeparator(s, ",")
+	}
+
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// normaliseSingleSeparator treats sep as a thousands separator when it
+// repeats or is followed by exactly three digits, and as the decimal point
+// otherwise
+func normaliseSingleSeparator(s, sep string) string {
+	last := strings.LastIndex(s, sep)
+	if strings.Count(s, sep) > 1 || len(s)-last-1 == 3 {
+		return strings.ReplaceAll(s, sep, "")
+	}
+	return strings.Replace(s, sep, ".", 1)
+}
+
+// NormaliseSettlementStatus maps PSP transaction statuses onto payment statuses
+func NormaliseSettlementStatus(status string) (models.PaymentStatus, bool) {
+	switch normaliseHeader(status) {
+	case "settlement", "settled", "capture", "captured", "success", "succeeded", "successful", "paid", "completed",
+		"berhasil", "sukses", "lunas":
+		return models.PaymentStatusPaid, true
+	case "pending", "authorize", "menunggu":
+		return models.PaymentStatusPending, true
+	case "deny", "denied", "cancel", "cancelled", "canceled", "expire", "expired", "failure", "failed", "gagal",
+		"kedaluwarsa":
+		return models.PaymentStatusFailed, true
+	case "refund", "refunded", "full refund":
+		return models.PaymentStatusRefunded, true
+	case "partial refund", "partially refunded":
+		return models.PaymentStatusPartiallyRefunded, true
+	}
+	return "", false
+}
+
+// ReconcileEntries matches statement entries against payments. known holds
+// the payments the entries may refer to; expected those that should appear in
+// the statement, which are flagged missing_statement when they do not.
+func ReconcileEntries(entries []StatementEntry, known, expected []models.Payment) []models.ReconciliationItem {
+	byReference := make(map[string]models.Payment, len(known))
+	byProviderRef := make(map[string]models.Payment, len(known))
+	for _, payment := range append(append([]models.Payment{}, known...), expected...) {
+		if payment.Reference != "" {
+			byReference[payment.Reference] = payment
+		}
+		if payment.GatewayRef != "" {
+			byProviderRef[payment.GatewayRef] = payment
+		}
+	}
+
+	items := make([]models.ReconciliationItem, 0, len(entries))
+	seen := make(map[string]bool)
+	seenLine := make(map[string]int)
+	matchedPayments := make(map[uint]bool)
+	for _, entry := range entries {
+		item := models.ReconciliationItem{
+			Line:            entry.Line,
+			Reference:       entry.Reference,
+			ProviderRef:     entry.ProviderRef,
+			StatementAmount: entry.Amount,
+			StatementStatus: entry.RawStatus,
+			TransactionAt:   entry.At,
+		}
+		if entry.Error != "" {
+			item.Result = models.ReconResultInvalidRow
+			item.Detail = entry.Error
+			items = append(items, item)
+			continue
+		}
+
+		var payment models.Payment
+		var ok bool
+		if entry.Reference != "" {
+			payment, ok = byReference[entry.Reference]
+		}
+		if !ok && entry.ProviderRef != "" {
+			payment, ok = byProviderRef[entry.ProviderRef]
+		}
+		key := "ref:" + entry.Reference + "|" + entry.ProviderRef
+		if ok {
+			key = fmt.Sprintf("payment:%d", payment.ID)
+			paymentID := payment.ID
+			item.PaymentID = &paymentID
+			item.Reference = payment.Reference
+			item.PaymentAmount = payment.Amount
+			item.PaymentStatus = string(payment.Status)
+			matchedPayments[payment.ID] = true
+		}
+
+		switch {
+		case seen[key]:
+			item.Result = models.ReconResultDuplicate
+			item.Detail = "transaction reported more than once"
+			if seenLine[key] > 0 {
+				item.Detail = fmt.Sprintf("same transaction as line %d", seenLine[key])
+			}
+		case !ok:
+			item.Result = models.ReconResultMissingPayment
+			item.Detail = "no payment with this reference"
+		case !sameRupiah(entry.Amount, payment.Amount):
+			item.Result = models.ReconResultAmountMismatch
+			item.Detail = fmt.Sprintf("statement %.0f, payment %.0f", entry.Amount, payment.Amount)
+		case entry.Status != "" && entry.Status != payment.Status:
+			item.Result = models.ReconResultStatusMismatch
+			item.Detail = fmt.Sprintf("statement %s, payment %s", entry.Status, payment.Status)
+		default:
+			item.Result = models.ReconResultMatched
+		}
+		if !seen[key] {
+			seen[key] = true
+			seenLine[key] = entry.Line
+		}
+		items = append(items, item)
+	}
+
+	for _, payment := range expected {
+		if matchedPayments[payment.ID] {
+			continue
+		}
+		paymentID := payment.ID
+		items = append(items, models.ReconciliationItem{
+			Result:        models.ReconResultMissingStatement,
+			PaymentID:     &paymentID,
+			Reference:     payment.Reference,
+			ProviderRef:   payment.GatewayRef,
+			PaymentAmount: payment.Amount,
+			PaymentStatus: string(payment.Status),
+			TransactionAt: payment.PaidAt,
+			Detail:        "payment not in settlement",
+		})
+	}
+	return items
+}
+
+// ReconciliationInput describes a run
+type ReconciliationInput struct {
+	Provider  string
+	Gateway   string // Hanya payment dari gateway ini yang diharapkan ada di settlement
+	FileName  string
+	Date      *time.Time
+	CreatedBy *uint
+	JobKey    *string
+}
+
+// ReconciliationDay returns the WIB day holding t, as [start, end)
+func ReconciliationDay(t time.Time) (time.Time, time.Time) {
+	t = t.In(referenceLocation)
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, referenceLocation)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// ParseReconciliationDate reads a YYYY-MM-DD settlement day in WIB
+func ParseReconciliationDate(value string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", value, referenceLocation)
+}
+
+// RunFileReconciliation reconciles parsed settlement file entries. With a
+// date, non-cash payments settled that day that are missing from the file
+// are flagged too.
+func RunFileReconciliation(db *gorm.DB, input ReconciliationInput, entries []StatementEntry) (models.ReconciliationRun, error) {
+	run, err := startReconciliationRun(db, models.ReconciliationSourceFile, input)
+	if err != nil {
+		return run, err
+	}
+
+	var references, providerRefs []string
+	for _, entry := range entries {
+		if entry.Reference != "" {
+			references = append(references, entry.Reference)
+		}
+		if entry.ProviderRef != "" {
+			providerRefs = append(providerRefs, entry.ProviderRef)
+		}
+	}
+
+	var known []models.Payment
+	if len(references) > 0 || len(providerRefs) > 0 {
+		query := db.Where("1 = 0")
+		if len(references) > 0 {
+			query = query.Or("reference IN ?", references)
+		}
+		if len(providerRefs) > 0 {
+			query = query.Or("gateway_ref IN ?", providerRefs)
+		}
+		if err := query.Find(&known).Error; err != nil {
+			return finishReconciliationRun(db, run, nil, err)
+		}
+	}
+
+	var expected []models.Payment
+	if input.Date != nil {
+		start, end := ReconciliationDay(*input.Date)
+		query := db.Where("method <> ? AND status IN ? AND paid_at >= ? AND paid_at < ?", models.PaymentMethodCash,
+			[]models.PaymentStatus{models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded}, start, end)
+		if input.Gateway != "" {
+			query = query.Where("gateway = ?", input.Gateway)
+		}
+		if err := query.Find(&expected).Error; err != nil {
+			return finishReconciliationRun(db, run, nil, err)
+		}
+	}
+
+	return finishReconciliationRun(db, run, ReconcileEntries(entries, known, expected), nil)
+}
+
+// RunGatewayReconciliation reads the gateway's view of every payment it
+// charged on the given day and compares it with ours
+func RunGatewayReconciliation(ctx context.Context, db *gorm.DB, gateway PaymentGateway, input ReconciliationInput) (models.ReconciliationRun, error) {
+	if input.Date == nil {
+		return models.ReconciliationRun{}, ErrReconciliationNoDate
+	}
+	input.Gateway = gateway.Name()
+	if input.Provider == "" {
+		input.Provider = gateway.Name()
+	}
+	run, err := startReconciliationRun(db, models.ReconciliationSourceGateway, input)
+	if err != nil {
+		return run, err
+	}
+
+	start, end := ReconciliationDay(*input.Date)
+	var payments []models.Payment
+	if err := db.Where("gateway = ? AND ((created_at >= ? AND created_at < ?) OR (paid_at >= ? AND paid_at < ?))",
+		gateway.Name(), start, end, start, end).Order("id ASC").Find(&payments).Error; err != nil {
+		return finishReconciliationRun(db, run, nil, err)
+	}
+
+	entries := make([]StatementEntry, 0, len(payments))
+	for _, payment := range payments {
+		charge, err := gateway.ChargeStatus(ctx, payment.Reference)
+		if errors.Is(err, ErrChargeNotFound) {
+			continue // Ditandai missing_statement
+		}
+		if err != nil {
+			entries = append(entries, StatementEntry{Reference: payment.Reference, Error: fmt.Sprintf("gateway error: %v", err)})
+			continue
+		}
+		reference := charge.Reference
+		if reference == "" {
+			reference = payment.Reference
+		}
+		entries = append(entries, StatementEntry{
+			Reference:   reference,
+			ProviderRef: charge.ProviderRef,
+			Amount:      charge.Amount,
+			Status:      charge.Status,
+			RawStatus:   string(charge.Status),
+			At:          charge.PaidAt,
+		})
+	}
+
+	return finishReconciliationRun(db, run, ReconcileEntries(entries, payments, payments), nil)
+}
+
+// ReconcileYesterday runs the daily gateway reconciliation for the previous
+// WIB day once; later calls for the same day report false. A day whose run
+// failed or was abandoned mid-run is reconciled again on the next call.
+func ReconcileYesterday(ctx context.Context, db *gorm.DB, gateway PaymentGateway, now time.Time) (models.ReconciliationRun, bool, error) {
+	today, _ := ReconciliationDay(now)
+	day := today.AddDate(0, 0, -1)
+	key := fmt.Sprintf("gateway:%s:%s", gateway.Name(), day.Format("2006-01-02"))
+
+	run, err := RunGatewayReconciliation(ctx, db, gateway, ReconciliationInput{Date: &day, JobKey: &key})
+	if errors.Is(err, ErrReconciliationExists) {
+		return run, false, nil
+	}
+	return run, err == nil, err
+}
+
+func startReconciliationRun(db *gorm.DB, source models.ReconciliationSource, input ReconciliationInput) (models.ReconciliationRun, error) {
+	run := models.ReconciliationRun{
+		JobKey:    input.JobKey,
+		Source:    source,
+		Provider:  input.Provider,
+		Gateway:   input.Gateway,
+		FileName:  input.FileName,
+		Date:      input.Date,
+		Status:    models.ReconciliationStatusRunning,
+		CreatedBy: input.CreatedBy,
+	}
+	err := db.Create(&run).Error
+	if IsDuplicateKeyError(err) && input.JobKey != nil {
+		reclaimed, reclaimErr := reclaimReconciliationKey(db, *input.JobKey, time.Now())
+		if reclaimErr != nil {
+			return run, reclaimErr
+		}
+		if reclaimed {
+			err = db.Create(&run).Error
+		}
+	}
+	if err != nil {
+		if IsDuplicateKeyError(err) {
+			return run, fmt.Errorf("%w: %s", ErrReconciliationExists, *input.JobKey)
+		}
+		return run, err
+	}
+	return run, nil
+}
+
+// ReconciliationStaleAfter is how long a run may stay running before it is
+// considered abandoned (e.g. the process crashed) and its job key reclaimed
+const ReconciliationStaleAfter = time.Hour
+
+// ReconciliationKeyReclaimable reports whether run no longer holds its job
+// key: it failed, or it has been running for longer than ReconciliationStaleAfter
+func ReconciliationKeyReclaimable(run models.ReconciliationRun, now time.Time) bool {
+	switch run.Status {
+	case models.ReconciliationStatusFailed:
+		return true
+	case models.ReconciliationStatusRunning:
+		return run.CreatedAt.Before(now.Add(-ReconciliationStaleAfter))
+	}
+	return false
+}
+
+// reclaimReconciliationKey frees the job key held by a failed or abandoned
+// run so the job can run again; abandoned runs are marked failed
+func reclaimReconciliationKey(db *gorm.DB, key string, now time.Time) (bool, error) {
+	var holder models.ReconciliationRun
+	if err := db.Where("job_key = ?", key).First(&holder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	if !ReconciliationKeyReclaimable(holder, now) {
+		return false, nil
+	}
+
+	updates := map[string]interface{}{"job_key": nil}
+	if holder.Status == models.ReconciliationStatusRunning {
+		updates["status"] = models.ReconciliationStatusFailed
+		updates["error"] = fmt.Sprintf("abandoned after running since %s", holder.CreatedAt.Format(time.RFC3339))
+		updates["completed_at"] = now
+	}
+	res := db.Model(&models.ReconciliationRun{}).Where("id = ? AND status = ? AND job_key = ?", holder.ID, holder.Status, key).Updates(updates)
+	return res.RowsAffected > 0, res.Error
+}
+
+// finishReconciliationRun stores the items and totals of a run, or marks it
+// failed when runErr is set. A failed run gives up its job key so the job
+// can be retried.
+func finishReconciliationRun(db *gorm.DB, run models.ReconciliationRun, items []models.ReconciliationItem, runErr error) (models.ReconciliationRun, error) {
+	now := time.Now()
+	run.CompletedAt = &now
+
+	if runErr == nil {
+		for i := range items {
+			items[i].RunID = run.ID
+			if items[i].Result != models.ReconResultMissingStatement {
+				run.TotalRows++
+				run.TotalAmount += items[i].StatementAmount
+			}
+			if items[i].Result == models.ReconResultMatched {
+				run.Matched++
+			} else {
+				run.Exceptions++
+			}
+		}
+		run.TotalAmount = roundCents(run.TotalAmount)
+		if len(items) > 0 {
+			runErr = db.CreateInBatches(items, reconciliationItemsBatch).Error
+		}
+	}
+
+	run.Status = models.ReconciliationStatusCompleted
+	columns := []string{"status", "error", "total_rows", "matched", "exceptions", "total_amount", "completed_at"}
+	if runErr != nil {
+		run.Status = models.ReconciliationStatusFailed
+		run.Error = runErr.Error()
+		run.TotalRows, run.Matched, run.Exceptions, run.TotalAmount = 0, 0, 0, 0
+		run.JobKey = nil
+		columns = append(columns, "job_key")
+	}
+	if err := db.Model(&run).Select(columns).Updates(&run).Error; err != nil && runErr == nil {
+		runErr = err
+	}
+	return run, runErr
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRupiahAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"25000", 25000},
+		{"25000.00", 25000},
+		{"25.000", 25000},
+		{"Rp 25.000", 25000},
+		{"Rp1.250.000,50", 1250000.5},
+		{"IDR 25,000.00", 25000},
+		{"25,000", 25000},
+		{"12,5", 12.5},
+		{"(5.000)", -5000},
+		{"-5000", -5000},
+	}
+	for _, tt := range tests {
+		got, err := ParseRupiahAmount(tt.in)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	_, err := ParseRupiahAmount("")
+	assert.Error(t, err)
+	_, err = ParseRupiahAmount("dua puluh ribu")
+	assert.Error(t, err)
+}
+
+func TestNormaliseSettlementStatus(t *testing.T) {
+	status, ok := NormaliseSettlementStatus("settlement")
+	assert.True(t, ok)
+	assert.Equal(t, models.PaymentStatusPaid, status)
+
+	status, _ = NormaliseSettlementStatus("SUCCEEDED")
+	assert.Equal(t, models.PaymentStatusPaid, status)
+
+	status, _ = NormaliseSettlementStatus("Berhasil")
+	assert.Equal(t, models.PaymentStatusPaid, status)
+
+	status, _ = NormaliseSettlementStatus("partial_refund")
+	assert.Equal(t, models.PaymentStatusPartiallyRefunded, status)
+
+	status, _ = NormaliseSettlementStatus("EXPIRED")
+	assert.Equal(t, models.PaymentStatusFailed, status)
+
+	_, ok = NormaliseSettlementStatus("on hold")
+	assert.False(t, ok)
+}
+
+func TestParseSettlementCSVMidtrans(t *testing.T) {
+	file := "Order ID,Transaction ID,Payment Type,Gross Amount,Transaction Status,Transaction Time\n" +
+		"PY-20261016-000001X,b2f0-11,qris,25000.00,settlement,2026-10-16 09:03:00\n" +
+		"\n" +
+		",,,,,\n" +
+		"PY-20261016-000002Y,b2f0-12,bank_transfer,abc,settlement,2026-10-16 10:00:00\n"
+
+	entries, err := ParseSettlementCSV(strings.NewReader(file))
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "blank rows are skipped")
+
+	assert.Equal(t, 2, entries[0].Line)
+	assert.Equal(t, "PY-20261016-000001X", entries[0].Reference)
+	assert.Equal(t, "b2f0-11", entries[0].ProviderRef)
+	assert.Equal(t, 25000.0, entries[0].Amount)
+	assert.Equal(t, models.PaymentStatusPaid, entries[0].Status)
+	assert.Equal(t, time.Date(2026, 10, 16, 9, 3, 0, 0, referenceLocation), *entries[0].At)
+
+	assert.Equal(t, 5, entries[1].Line)
+	assert.Contains(t, entries[1].Error, "invalid amount")
+}
+
+func TestParseSettlementCSVSemicolonWithTitle(t *testing.T) {
+	file := "\ufeffLaporan Settlement QRIS;;;\n" +
+		"Periode 16/10/2026;;;\n" +
+		"No Referensi;RRN;Nominal;Status;Tanggal\n" +
+		"PY-20261016-000003Z;123456789012;Rp 15.000;Sukses;16/10/2026 14:20\n"
+
+	entries, err := ParseSettlementCSV(strings.NewReader(file))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "PY-20261016-000003Z", entries[0].Reference)
+	assert.Equal(t, "123456789012", entries[0].ProviderRef)
+	assert.Equal(t, 15000.0, entries[0].Amount)
+	assert.Equal(t, models.PaymentStatusPaid, entries[0].Status)
+	assert.Equal(t, 4, entries[0].Line)
+
+	_, err = ParseSettlementCSV(strings.NewReader("foo,bar\n1,2\n"))
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+}
+
+func TestReconcileEntries(t *testing.T) {
+	paid := models.Payment{ID: 1, Reference: "PY-1", GatewayRef: "tx-1", Amount: 25000, Status: models.PaymentStatusPaid}
+	short := models.Payment{ID: 2, Reference: "PY-2", Amount: 20000, Status: models.PaymentStatusPaid}
+	pending := models.Payment{ID: 3, Reference: "PY-3", Amount: 10000, Status: models.PaymentStatusPending}
+	unsettled := models.Payment{ID: 4, Reference: "PY-4", Amount: 12000, Status: models.PaymentStatusPaid}
+
+	entries := []StatementEntry{
+		{Line: 2, ProviderRef: "tx-1", Amount: 25000, Status: models.PaymentStatusPaid},
+		{Line: 3, Reference: "PY-2", Amount: 19500, Status: models.PaymentStatusPaid},
+		{Line: 4, Reference: "PY-3", Amount: 10000, Status: models.PaymentStatusPaid},
+		{Line: 5, Reference: "PY-1", Amount: 25000, Status: models.PaymentStatusPaid},
+		{Line: 6, Reference: "PY-9", Amount: 5000},
+		{Line: 7, Error: "missing reference"},
+	}
+
+	items := ReconcileEntries(entries, []models.Payment{paid, short, pending}, []models.Payment{paid, unsettled})
+	require.Len(t, items, 7)
+
+	results := make([]models.ReconciliationResult, len(items))
+	for i, item := range items {
+		results[i] = item.Result
+	}
+	assert.Equal(t, []models.ReconciliationResult{
+		models.ReconResultMatched,
+		models.ReconResultAmountMismatch,
+		models.ReconResultStatusMismatch,
+		models.ReconResultDuplicate,
+		models.ReconResultMissingPayment,
+		models.ReconResultInvalidRow,
+		models.ReconResultMissingStatement,
+	}, results)
+
+	assert.Equal(t, "PY-1", items[0].Reference, "matches by gateway reference fill in our reference")
+	assert.Equal(t, "same transaction as line 2", items[3].Detail)
+	assert.Equal(t, uint(4), *items[6].PaymentID)
+}
+
+func TestReconciliationDay(t *testing.T) {
+	start, end := ReconciliationDay(time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 17, 0, 0, 0, 0, referenceLocation), start, "20:00 UTC is already the next day in WIB")
+	assert.Equal(t, 24*time.Hour, end.Sub(start))
+}
+
+func TestReconciliationKeyReclaimable(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+
+	assert.True(t, ReconciliationKeyReclaimable(models.ReconciliationRun{Status: models.ReconciliationStatusFailed, CreatedAt: now}, now))
+	assert.False(t, ReconciliationKeyReclaimable(models.ReconciliationRun{Status: models.ReconciliationStatusCompleted, CreatedAt: now.Add(-48 * time.Hour)}, now))
+
+	running := models.ReconciliationRun{Status: models.ReconciliationStatusRunning, CreatedAt: now.Add(-10 * time.Minute)}
+	assert.False(t, ReconciliationKeyReclaimable(running, now), "a run in progress keeps its key")
+	running.CreatedAt = now.Add(-ReconciliationStaleAfter - time.Minute)
+	assert.True(t, ReconciliationKeyReclaimable(running, now), "an abandoned run gives up its key")
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reference Numbers
+// =================
+// Nomor order, pembayaran, withdrawal dan refund berbentuk PREFIX-YYYYMMDD-NNNNNNC:
+// tanggal (WIB), nomor urut harian dari tabel sequence_counters dan satu
+// karakter checksum (Luhn mod 34) supaya salah ketik terdeteksi. Nomor urut
+// diambil dengan upsert atomik sehingga aman dipakai oleh beberapa instance.
+
+// Reference number prefixes
+const (
+	ReferencePrefixOrder      = "GB"
+	ReferencePrefixPayment    = "PY"
+	ReferencePrefixWithdrawal = "WD"
+	ReferencePrefixRefund     = "RF"
+)
+
+// referenceAlphabet are the checksum characters; I and O are left out
+// because they are easily confused with 1 and 0
+const referenceAlphabet = "0123456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+const referenceCreateAttempts = 5
+
+var referenceLocation = loadReferenceLocation()
+
+func loadReferenceLocation() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Jakarta"); err == nil {
+		return loc
+	}
+	return time.FixedZone("WIB", 7*60*60)
+}
+
+// FormatReferenceNumber builds a reference number from its parts
+func FormatReferenceNumber(prefix string, day time.Time, sequence int64) string {
+	date := day.In(referenceLocation).Format("20060102")
+	number := fmt.Sprintf("%06d", sequence)
+	return fmt.Sprintf("%s-%s-%s%c", prefix, date, number, referenceChecksum(date+number))
+}
+
+// ValidReferenceNumber reports whether the checksum character of ref matches
+func ValidReferenceNumber(ref string) bool {
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(ref)), "-")
+	if len(parts) != 3 || len(parts[1]) != 8 || len(parts[2]) < 7 {
+		return false
+	}
+	number := parts[2][:len(parts[2])-1]
+	check := parts[2][len(parts[2])-1]
+	for _, r := range parts[1] + number {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return referenceChecksum(parts[1]+number) == check
+}
+
+// referenceChecksum computes the Luhn mod N check character of digits.
+// It catches any single wrong digit and most swapped neighbouring digits.
+func referenceChecksum(digits string) byte {
+	n := len(referenceAlphabet)
+	factor := 2
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		addend := factor * int(digits[i]-'0')
+		addend = addend/n + addend%n
+		sum += addend
+		if factor == 2 {
+			factor = 1
+		} else {
+			factor = 2
+		}
+	}
+	return referenceAlphabet[(n-sum%n)%n]
+}
+
+// NextReferenceNumber allocates the next number of the day for prefix
+func NextReferenceNumber(db *gorm.DB, prefix string, now time.Time) (string, error) {
+	name := prefix + ":" + now.In(referenceLocation).Format("20060102")
+	sequence, err := nextSequence(db, name)
+	if err != nil {
+		return "", err
+	}
+	return FormatReferenceNumber(prefix, now, sequence), nil
+}
+
+// nextSequence increments the named counter and returns the new value. The
+// upsert locks the counter row until commit, so concurrent callers on any
+// instance each get a distinct value.
+func nextSequence(db *gorm.DB, name string) (int64, error) {
+	var counter models.SequenceCounter
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "name"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"value":      gorm.Expr("value + 1"),
+				"updated_at": time.Now(),
+			}),
+		}).Create(&models.SequenceCounter{Name: name, Value: 1}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("name = ?", name).First(&counter).Error
+	})
+	return counter.Value, err
+}
+
+// CreateWithReferenceNumber assigns a new reference number with setNumber and
+// inserts record, retrying with a fresh number if the insert hits a duplicate
+// key (e.g. a number handed out before the counter existed).
+func CreateWithReferenceNumber(db *gorm.DB, prefix string, record interface{}, setNumber func(string)) error {
+	var err error
+	for attempt := 0; attempt < referenceCreateAttempts; attempt++ {
+		var number string
+		number, err = NextReferenceNumber(db, prefix, time.Now())
+		if err != nil {
+			return err
+		}
+		setNumber(number)
+
+		err = db.Create(record).Error
+		if err == nil || !IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// IsDuplicateKeyError reports whether err is a unique constraint violation
+func IsDuplicateKeyError(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "Duplicate entry")
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatReferenceNumber(t *testing.T) {
+	// 23:30 UTC is already the next day in WIB
+	day := time.Date(2026, 10, 16, 23, 30, 0, 0, time.UTC)
+
+	ref := FormatReferenceNumber(ReferencePrefixOrder, day, 123)
+	assert.Regexp(t, `^GB-20261017-000123[0-9A-Z]$`, ref)
+	assert.True(t, ValidReferenceNumber(ref))
+
+	assert.Regexp(t, `^GB-20261017-1234567[0-9A-Z]$`, FormatReferenceNumber(ReferencePrefixOrder, day, 1234567))
+}
+
+func TestValidReferenceNumberDetectsTypos(t *testing.T) {
+	day := time.Date(2026, 10, 17, 9, 0, 0, 0, referenceLocation)
+	ref := FormatReferenceNumber(ReferencePrefixWithdrawal, day, 4821)
+	assert.True(t, ValidReferenceNumber(ref))
+
+	// Every single-digit change in the date or number is caught
+	for i := len("WD-"); i < len(ref)-1; i++ {
+		if ref[i] == '-' {
+			continue
+		}
+		for d := byte('0'); d <= '9'; d++ {
+			if d == ref[i] {
+				continue
+			}
+			typo := ref[:i] + string(d) + ref[i+1:]
+			assert.False(t, ValidReferenceNumber(typo), typo)
+		}
+	}
+
+	// Swapping two neighbouring digits of the number is caught
+	swapped := ref[:len(ref)-3] + string(ref[len(ref)-2]) + string(ref[len(ref)-3]) + ref[len(ref)-1:]
+	assert.False(t, ValidReferenceNumber(swapped), swapped)
+
+	assert.False(t, ValidReferenceNumber("ORD-1760680000"))
+	assert.False(t, ValidReferenceNumber("GB-2026101-0001230"))
+}
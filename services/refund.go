@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Refunds & Chargebacks
+// =====================
+// Admin mengembalikan sebagian atau seluruh pembayaran dengan kode alasan.
+// Setiap refund dicatat di tabel refunds dengan nomor RF-YYYYMMDD-NNNNNNC.
+// Pembayaran yang ditagih lewat payment gateway dikembalikan lewat gateway
+// yang sama; pembayaran tunai dan QRIS sendiri dikembalikan manual dan hanya
+// dicatat. Chargeback (dana ditarik bank customer) selalu dicatat manual.
+//
+// Refund yang berhasil menambah Payment.Refunded (refunded_amount), mengubah status
+// menjadi partially_refunded atau refunded, dan untuk order yang sudah selesai
+// mengambil kembali bagian driver dan komisi platform di ledger.
+//
+// Refund gateway yang tertahan pending (mis. database gagal setelah gateway
+// mengembalikan dana) diulang dengan RefundKey yang sama; gateway tidak
+// mengembalikan dana dua kali untuk key yang sama.
+
+var (
+	ErrInvalidRefund           = errors.New("invalid refund")
+	ErrRefundNotAllowed        = errors.New("payment cannot be refunded")
+	ErrRefundExceedsRefundable = errors.New("refund exceeds the refundable amount")
+	ErrRefundGatewayMismatch   = errors.New("payment was charged by another gateway")
+	ErrRefundGatewayFailed     = errors.New("gateway refund failed")
+)
+
+// RefundInput is an admin's refund request
+type RefundInput struct {
+	Amount      float64 // 0 = seluruh sisa yang bisa direfund
+	Reason      models.RefundReason
+	Note        string
+	Kind        models.RefundKind
+	RequestedBy *uint
+}
+
+// ValidRefundReason reports whether reason is a known refund reason code
+func ValidRefundReason(reason models.RefundReason) bool {
+	switch reason {
+	case models.RefundReasonCustomerRequest, models.RefundReasonDriverNoShow, models.RefundReasonOvercharge,
+		models.RefundReasonDuplicatePayment, models.RefundReasonServiceIssue, models.RefundReasonChargeback,
+		models.RefundReasonOther:
+		return true
+	}
+	return false
+}
+
+// ValidateRefund checks a refund against a payment that already has pending
+// refunds in flight and returns the input with defaults applied
+func ValidateRefund(payment models.Payment, pending float64, input RefundInput) (RefundInput, error) {
+	if input.Kind == "" {
+		input.Kind = models.RefundKindRefund
+	}
+	if input.Kind != models.RefundKindRefund && input.Kind != models.RefundKindChargeback {
+		return input, fmt.Errorf("%w: kind must be refund or chargeback", ErrInvalidRefund)
+	}
+	if input.Reason == "" && input.Kind == models.RefundKindChargeback {
+		input.Reason = models.RefundReasonChargeback
+	}
+	if !ValidRefundReason(input.Reason) {
+		return input, fmt.Errorf("%w: unknown reason %q", ErrInvalidRefund, input.Reason)
+	}
+	if input.Reason == models.RefundReasonOther && input.Note == "" {
+		return input, fmt.Errorf("%w: a note is required for reason other", ErrInvalidRefund)
+	}
+	if input.Amount < 0 {
+		return input, fmt.Errorf("%w: amount must not be negative", ErrInvalidRefund)
+	}
+
+	if payment.Status != models.PaymentStatusPaid && payment.Status != models.PaymentStatusPartiallyRefunded {
+		return input, fmt.Errorf("%w: payment %s is %s", ErrRefundNotAllowed, payment.Reference, payment.Status)
+	}
+	refundable := roundCents(payment.Refundable() - pending)
+	if toCents(refundable) <= 0 {
+		return input, fmt.Errorf("%w: nothing left to refund on payment %s", ErrRefundExceedsRefundable, payment.Reference)
+	}
+	if input.Amount == 0 {
+		input.Amount = refundable
+	}
+	if toCents(input.Amount) > toCents(refundable) {
+		return input, fmt.Errorf("%w: %.0f requested, %.0f refundable", ErrRefundExceedsRefundable, input.Amount, refundable)
+	}
+	return input, nil
+}
+
+// RefundedStatus is the payment status after refunded of amount has been returned
+func RefundedStatus(amount, refunded float64) models.PaymentStatus {
+	if toCents(refunded) <= 0 {
+		return models.PaymentStatusPaid
+	}
+	if toCents(refunded) >= toCents(amount) {
+		return models.PaymentStatusRefunded
+	}
+	return models.PaymentStatusPartiallyRefunded
+}
+
+// refundViaGateway reports whether a refund is returned through the gateway
+// rather than paid back manually
+func refundViaGateway(payment models.Payment, kind models.RefundKind) bool {
+	return kind == models.RefundKindRefund && payment.Gateway != "" && payment.Gateway != PaymentGatewayQRIS
+}
+
+// RefundPayment refunds (part of) a paid payment. The refund is recorded as
+// pending before the gateway is called so concurrent refunds cannot exceed
+// the payment; a gateway error marks it failed and is returned wrapped in
+// ErrRefundGatewayFailed together with the failed refund.
+func RefundPayment(ctx context.Context, db *gorm.DB, gateway PaymentGateway, payment *models.Payment, input RefundInput, now time.Time) (models.Refund, error) {
+	var refund models.Refund
+	var order models.Order
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(payment, payment.ID).Error; err != nil {
+			return err
+		}
+
+		var pending float64
+		if err := tx.Model(&models.Refund{}).Where("payment_id = ? AND status = ?", payment.ID, models.RefundStatusPending).
+			Select("COALESCE(SUM(amount), 0)").Scan(&pending).Error; err != nil {
+			return err
+		}
+		var err error
+		if input, err = ValidateRefund(*payment, pending, input); err != nil {
+			return err
+		}
+
+		if refundViaGateway(*payment, input.Kind) && (gateway == nil || payment.Gateway != gateway.Name()) {
+			return fmt.Errorf("%w: payment %s was charged by %s", ErrRefundGatewayMismatch, payment.Reference, payment.Gateway)
+		}
+
+		if err := tx.First(&order, payment.OrderID).Error; err != nil {
+			return err
+		}
+		if OrderEarned(order) {
+			if _, err := RefundLines(order, input.Amount); err != nil {
+				return err
+			}
+		}
+
+		refund = models.Refund{
+			PaymentID:   payment.ID,
+			OrderID:     payment.OrderID,
+			Kind:        input.Kind,
+			Amount:      input.Amount,
+			Reason:      input.Reason,
+			Note:        input.Note,
+			Status:      models.RefundStatusPending,
+			RequestedBy: input.RequestedBy,
+		}
+		if refundViaGateway(*payment, input.Kind) {
+			refund.Gateway = payment.Gateway
+		}
+		return CreateWithReferenceNumber(tx, ReferencePrefixRefund, &refund, func(number string) { refund.Reference = number })
+	})
+	if err != nil {
+		return refund, err
+	}
+
+	if refund.Gateway != "" {
+		result, err := gateway.Refund(ctx, RefundRequest{
+			Reference: payment.Reference,
+			RefundKey: refund.Reference,
+			Amount:    refund.Amount,
+			Reason:    string(refund.Reason),
+		})
+		if err != nil {
+			refund.Status = models.RefundStatusFailed
+			refund.FailureReason = err.Error()
+			refund.ProcessedAt = &now
+			if updateErr := db.Model(&refund).Select("status", "failure_reason", "processed_at").Updates(&refund).Error; updateErr != nil {
+				return refund, updateErr
+			}
+			return refund, fmt.Errorf("%w: %v", ErrRefundGatewayFailed, err)
+		}
+		refund.ProviderRef = result.ProviderRef
+	}
+
+	if err := completeRefund(db, &refund, payment, &order, now); err != nil {
+		return refund, err
+	}
+	notifyRefund(db, refund, *payment, order)
+	return refund, nil
+}
+
+// RefundRetryAge is how long a refund may stay pending before it is retried,
+// so a refund still being processed by its request is left alone
+const RefundRetryAge = 10 * time.Minute
+
+// RetryRefund finishes a refund left pending. Gateway refunds are sent again
+// under the same refund key, so money already returned is not returned twice;
+// a gateway error keeps the refund pending for the next retry.
+func RetryRefund(ctx context.Context, db *gorm.DB, gateway PaymentGateway, refund *models.Refund, now time.Time) error {
+	if refund.Status != models.RefundStatusPending {
+		return fmt.Errorf("%w: refund %s is %s", ErrPaymentStateConflict, refund.Reference, refund.Status)
+	}
+	if refund.Gateway != "" && (gateway == nil || refund.Gateway != gateway.Name()) {
+		return fmt.Errorf("%w: refund %s was sent to %s", ErrRefundGatewayMismatch, refund.Reference, refund.Gateway)
+	}
+
+	var payment models.Payment
+	if err := db.First(&payment, refund.PaymentID).Error; err != nil {
+		return err
+	}
+	var order models.Order
+	if err := db.First(&order, refund.OrderID).Error; err != nil {
+		return err
+	}
+
+	if refund.Gateway != "" {
+		result, err := gateway.Refund(ctx, RefundRequest{
+			Reference: payment.Reference,
+			RefundKey: refund.Reference,
+			Amount:    refund.Amount,
+			Reason:    string(refund.Reason),
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRefundGatewayFailed, err)
+		}
+		refund.ProviderRef = result.ProviderRef
+	}
+
+	if err := completeRefund(db, refund, &payment, &order, now); err != nil {
+		return err
+	}
+	notifyRefund(db, *refund, payment, order)
+	return nil
+}
+
+// RetryPendingRefunds retries the gateway's refunds that have been pending
+// since before RefundRetryAge and reports how many were completed
+func RetryPendingRefunds(ctx context.Context, db *gorm.DB, gateway PaymentGateway, now time.Time) (int, error) {
+	var refunds []models.Refund
+	err := db.Where("status = ? AND gateway = ? AND created_at < ?", models.RefundStatusPending, gateway.Name(), now.Add(-RefundRetryAge)).
+		Order("id").Find(&refunds).Error
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for i := range refunds {
+		if err := RetryRefund(ctx, db, gateway, &refunds[i], now); err != nil {
+			log.Printf("Failed to retry refund %s: %v", refunds[i].Reference, err)
+			continue
+		}
+		completed++
+	}
+	return completed, nil
+}
+
+// completeRefund marks a pending refund succeeded, adds it to the payment and
+// takes it back from the driver in the ledger, all in one transaction. The
+// order is locked and reloaded first, as completion does, so a refund racing
+// the order's completion is either netted from its earning or posted here.
+func completeRefund(db *gorm.DB, refund *models.Refund, payment *models.Payment, order *models.Order, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(order, refund.OrderID).Error; err != nil {
+			return err
+		}
+
+		res := tx.Model(&models.Refund{}).Where("id = ? AND status = ?", refund.ID, models.RefundStatusPending).Updates(map[string]interface{}{
+			"status":       models.RefundStatusSucceeded,
+			"provider_ref": refund.ProviderRef,
+			"processed_at": now,
+			"updated_at":   now,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("%w: refund %s is no longer pending", ErrPaymentStateConflict, refund.Reference)
+		}
+		refund.Status = models.RefundStatusSucceeded
+		refund.ProcessedAt = &now
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(payment, payment.ID).Error; err != nil {
+			return err
+		}
+		payment.Refunded = roundCents(payment.Refunded + refund.Amount)
+		payment.Status = RefundedStatus(payment.Amount, payment.Refunded)
+		if err := tx.Model(payment).Updates(map[string]interface{}{
+			"refunded_amount": payment.Refunded,
+			"status":          payment.Status,
+			"updated_at":      now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"payment_status": string(payment.Status),
+			"updated_at":     now,
+		}).Error; err != nil {
+			return err
+		}
+
+		memo := fmt.Sprintf("Refund %s for order %s (%s)", refund.Reference, order.OrderNumber, refund.Reason)
+		_, _, err := PostOrderRefund(tx, *order, OrderRefundKey(refund.ID), refund.Amount, memo, refund.RequestedBy, now)
+		return err
+	})
+}
+
+// notifyRefund tells the order's watchers and the customer about a refund
+func notifyRefund(db *gorm.DB, refund models.Refund, payment models.Payment, order models.Order) {
+	data := map[string]interface{}{
+		"payment_id":      payment.ID,
+		"order_id":        payment.OrderID,
+		"reference":       payment.Reference,
+		"status":          payment.Status,
+		"refund_id":       refund.ID,
+		"refund":          refund.Reference,
+		"kind":            refund.Kind,
+		"amount":          refund.Amount,
+		"refunded_amount": payment.Refunded,
+	}
+	Hub.Publish(OrderTopic(payment.OrderID), EventPaymentStatus, data)
+	Hub.Publish(TopicAdmin, EventPaymentStatus, data)
+
+	if order.CustomerID == nil || refund.Kind != models.RefundKindRefund {
+		return
+	}
+	message := fmt.Sprintf("Dana sebesar Rp %.0f untuk pesanan %s telah dikembalikan.", refund.Amount, order.OrderNumber)
+	if refund.Gateway == "" {
+		message = fmt.Sprintf("Dana sebesar Rp %.0f untuk pesanan %s akan dikembalikan oleh admin.", refund.Amount, order.OrderNumber)
+	}
+	storeNotification(db, *order.CustomerID, "Pengembalian Dana", message, data)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"testing"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRefund(t *testing.T) {
+	payment := models.Payment{Reference: "PY-1", Amount: 25000, Status: models.PaymentStatusPaid}
+
+	// An empty amount refunds everything that is left
+	input, err := ValidateRefund(payment, 0, RefundInput{Reason: models.RefundReasonDriverNoShow})
+	assert.NoError(t, err)
+	assert.Equal(t, 25000.0, input.Amount)
+	assert.Equal(t, models.RefundKindRefund, input.Kind)
+
+	// Earlier and in-flight refunds shrink what is refundable
+	payment.Status = models.PaymentStatusPartiallyRefunded
+	payment.Refunded = 10000
+	input, err = ValidateRefund(payment, 5000, RefundInput{Reason: models.RefundReasonOvercharge})
+	assert.NoError(t, err)
+	assert.Equal(t, 10000.0, input.Amount)
+
+	_, err = ValidateRefund(payment, 5000, RefundInput{Amount: 10001, Reason: models.RefundReasonOvercharge})
+	assert.ErrorIs(t, err, ErrRefundExceedsRefundable)
+
+	_, err = ValidateRefund(payment, 15000, RefundInput{Reason: models.RefundReasonOvercharge})
+	assert.ErrorIs(t, err, ErrRefundExceedsRefundable)
+
+	// Chargebacks default their reason
+	input, err = ValidateRefund(payment, 0, RefundInput{Amount: 1000, Kind: models.RefundKindChargeback})
+	assert.NoError(t, err)
+	assert.Equal(t, models.RefundReasonChargeback, input.Reason)
+
+	_, err = ValidateRefund(payment, 0, RefundInput{Reason: "bored"})
+	assert.ErrorIs(t, err, ErrInvalidRefund)
+
+	_, err = ValidateRefund(payment, 0, RefundInput{Reason: models.RefundReasonOther})
+	assert.ErrorIs(t, err, ErrInvalidRefund, "reason other needs a note")
+
+	_, err = ValidateRefund(payment, 0, RefundInput{Reason: models.RefundReasonOther, Kind: "gift"})
+	assert.ErrorIs(t, err, ErrInvalidRefund)
+
+	payment.Status = models.PaymentStatusPending
+	_, err = ValidateRefund(payment, 0, RefundInput{Reason: models.RefundReasonCustomerRequest})
+	assert.ErrorIs(t, err, ErrRefundNotAllowed)
+}
+
+func TestRefundedStatus(t *testing.T) {
+	assert.Equal(t, models.PaymentStatusPaid, RefundedStatus(25000, 0))
+	assert.Equal(t, models.PaymentStatusPartiallyRefunded, RefundedStatus(25000, 10000))
+	assert.Equal(t, models.PaymentStatusRefunded, RefundedStatus(25000, 25000))
+	assert.Equal(t, models.PaymentStatusRefunded, RefundedStatus(0.3, 0.1+0.2))
+}
+
+func TestRefundViaGateway(t *testing.T) {
+	assert.True(t, refundViaGateway(models.Payment{Gateway: "midtrans"}, models.RefundKindRefund))
+	assert.False(t, refundViaGateway(models.Payment{Gateway: "midtrans"}, models.RefundKindChargeback))
+	assert.False(t, refundViaGateway(models.Payment{Gateway: PaymentGatewayQRIS}, models.RefundKindRefund))
+	assert.False(t, refundViaGateway(models.Payment{Method: models.PaymentMethodCash}, models.RefundKindRefund))
+}
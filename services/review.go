@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Reviews
+// =======
+// Customer memberi rating 1-5 untuk order yang sudah selesai, sekali per order
+// dan hanya dalam jendela waktu tertentu setelah selesai. Driver.Rating adalah
+// rata-rata Bayesian dari review yang dipublikasikan, sehingga driver baru
+// dengan sedikit review tidak langsung bernilai 1 atau 5.
+
+var (
+	ErrOrderNotReviewable = errors.New("order is not completed")
+	ErrReviewWindowClosed = errors.New("review window has closed")
+	ErrAlreadyReviewed    = errors.New("order has already been reviewed")
+	ErrInvalidRating      = errors.New("rating must be between 1 and 5")
+	ErrInvalidReviewTag   = errors.New("invalid review tag")
+)
+
+// ReviewTags are the tags customers can attach to a review
+var ReviewTags = []string{
+	"ramah",
+	"tepat_waktu",
+	"aman",
+	"bersih",
+	"rute_tepat",
+	"membantu_barang",
+	"tidak_ramah",
+	"terlambat",
+	"ugal_ugalan",
+	"kendaraan_kotor",
+	"minta_tambahan_biaya",
+}
+
+const maxReviewTags = 5
+
+type ReviewConfig struct {
+	// Window is how long after completion an order can be reviewed
+	Window time.Duration
+	// PriorMean and PriorWeight define the Bayesian prior: a driver's rating
+	// starts at PriorMean as if they already had PriorWeight reviews
+	PriorMean   float64
+	PriorWeight float64
+}
+
+// LoadReviewConfig reads review settings from the environment
+func LoadReviewConfig() ReviewConfig {
+	return ReviewConfig{
+		Window:      envDuration("REVIEW_WINDOW", 7*24*time.Hour),
+		PriorMean:   envFloat("RATING_PRIOR_MEAN", 4.5),
+		PriorWeight: envFloat("RATING_PRIOR_WEIGHT", 5),
+	}
+}
+
+// ReviewInput is a customer's rating of an order
+type ReviewInput struct {
+	Rating     int
+	Tags       []string
+	Comment    string
+	CustomerID *uint
+}
+
+// BayesianRating blends the average of count ratings summing to sum with the prior.
+// It returns 0 when there are no ratings so unrated drivers are distinguishable.
+func BayesianRating(sum float64, count int64, priorMean, priorWeight float64) float64 {
+	if count == 0 {
+		return 0
+	}
+	rating := (priorMean*priorWeight + sum) / (priorWeight + float64(count))
+	return math.Round(rating*100) / 100
+}
+
+// NormalizeReviewTags lowercases, de-duplicates and validates tags
+func NormalizeReviewTags(tags []string) ([]string, error) {
+	allowed := make(map[string]bool, len(ReviewTags))
+	for _, tag := range ReviewTags {
+		allowed[tag] = true
+	}
+
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if !allowed[tag] {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidReviewTag, tag)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxReviewTags {
+		return nil, fmt.Errorf("%w: at most %d tags", ErrInvalidReviewTag, maxReviewTags)
+	}
+	return normalized, nil
+}
+
+// CheckReviewable reports why an order cannot be reviewed at now, if at all
+func CheckReviewable(order models.Order, now time.Time, window time.Duration) error {
+	if order.Status != models.OrderStatusCompleted || order.DriverID == nil || order.CompletedAt == nil {
+		return ErrOrderNotReviewable
+	}
+	if window > 0 && now.After(order.CompletedAt.Add(window)) {
+		return ErrReviewWindowClosed
+	}
+	return nil
+}
+
+// SubmitReview stores the customer's review and refreshes the driver's rating
+func SubmitReview(db *gorm.DB, order models.Order, input ReviewInput, config ReviewConfig) (*models.OrderReview, error) {
+	if input.Rating < 1 || input.Rating > 5 {
+		return nil, ErrInvalidRating
+	}
+	tags, err := NormalizeReviewTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckReviewable(order, time.Now(), config.Window); err != nil {
+		return nil, err
+	}
+
+	review := models.OrderReview{
+		OrderID:       order.ID,
+		DriverID:      *order.DriverID,
+		CustomerID:    input.CustomerID,
+		CustomerPhone: order.CustomerPhone,
+		CustomerName:  order.CustomerName,
+		Rating:        input.Rating,
+		Tags:          tags,
+		Comment:       strings.TrimSpace(input.Comment),
+		Status:        models.ReviewStatusPublished,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.OrderReview{}).Where("order_id = ?", order.ID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadyReviewed
+		}
+		if err := tx.Create(&review).Error; err != nil {
+			// The unique index on order_id catches concurrent submissions
+			if IsDuplicateKeyError(err) {
+				return ErrAlreadyReviewed
+			}
+			return err
+		}
+		return RecomputeDriverRating(tx, review.DriverID, config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// ModerateReview publishes or hides a review and refreshes the driver's rating
+func ModerateReview(db *gorm.DB, review *models.OrderReview, status models.ReviewStatus, moderatorID *uint, note string, config ReviewConfig) error {
+	if status != models.ReviewStatusPublished && status != models.ReviewStatusHidden {
+		return fmt.Errorf("invalid review status: %s", status)
+	}
+
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"status":          status,
+			"moderated_by":    moderatorID,
+			"moderated_at":    now,
+			"moderation_note": note,
+		}
+		if err := tx.Model(review).Updates(updates).Error; err != nil {
+			return err
+		}
+		review.Status = status
+		review.ModeratedBy = moderatorID
+		review.ModeratedAt = &now
+		review.ModerationNote = note
+		return RecomputeDriverRating(tx, review.DriverID, config)
+	})
+}
+
+// RecomputeDriverRating derives Driver.Rating and RatingCount from published reviews.
+// Recomputing from the source rows keeps the rating correct after moderation.
+func RecomputeDriverRating(db *gorm.DB, driverID uint, config ReviewConfig) error {
+	var stats struct {
+		Total float64
+		Count int64
+	}
+	if err := db.Model(&models.OrderReview{}).
+		Select("COALESCE(SUM(rating), 0) AS total, COUNT(*) AS count").
+		Where("driver_id = ? AND status = ?", driverID, models.ReviewStatusPublished).
+		Scan(&stats).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&models.Driver{}).Where("id = ?", driverID).Updates(map[string]interface{}{
+		"rating":       BayesianRating(stats.Total, stats.Count, config.PriorMean, config.PriorWeight),
+		"rating_count": stats.Count,
+	}).Error
+}
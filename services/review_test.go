@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBayesianRating(t *testing.T) {
+	tests := []struct {
+		name  string
+		sum   float64
+		count int64
+		want  float64
+	}{
+		{"no reviews", 0, 0, 0},
+		{"single one star stays near prior", 1, 1, 3.92},
+		{"single five star stays near prior", 5, 1, 4.58},
+		{"many reviews dominate prior", 4.0 * 95, 95, 4.03},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, BayesianRating(tt.sum, tt.count, 4.5, 5))
+		})
+	}
+}
+
+func TestNormalizeReviewTags(t *testing.T) {
+	tags, err := NormalizeReviewTags([]string{" Ramah ", "aman", "ramah", ""})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ramah", "aman"}, tags)
+
+	_, err = NormalizeReviewTags([]string{"ramah", "mantap"})
+	assert.ErrorIs(t, err, ErrInvalidReviewTag)
+
+	_, err = NormalizeReviewTags([]string{"ramah", "aman", "bersih", "tepat_waktu", "rute_tepat", "membantu_barang"})
+	assert.ErrorIs(t, err, ErrInvalidReviewTag)
+}
+
+func TestCheckReviewable(t *testing.T) {
+	driverID := uint(7)
+	completedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	completed := models.Order{Status: models.OrderStatusCompleted, DriverID: &driverID, CompletedAt: &completedAt}
+	window := 7 * 24 * time.Hour
+
+	tests := []struct {
+		name  string
+		order models.Order
+		now   time.Time
+		want  error
+	}{
+		{"within window", completed, completedAt.Add(time.Hour), nil},
+		{"window closed", completed, completedAt.Add(window + time.Minute), ErrReviewWindowClosed},
+		{"not completed", models.Order{Status: models.OrderStatusInProgress, DriverID: &driverID}, completedAt, ErrOrderNotReviewable},
+		{"no driver", models.Order{Status: models.OrderStatusCompleted, CompletedAt: &completedAt}, completedAt, ErrOrderNotReviewable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CheckReviewable(tt.order, tt.now, window))
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+)
+
+// Routing
+// =======
+// RoutingProvider menghitung rute, ETA dan distance matrix. Default-nya
+// estimasi garis lurus dengan faktor belok; jika ROUTING_PROVIDER diisi,
+// jarak dan geometri diambil dari server OSRM/GraphHopper. Waktu tempuh
+// selalu memakai profil kecepatan per VehicleType karena server routing
+// tidak punya profil becak/andong.
+
+// ErrNoRoute is returned when a provider cannot route between the given points
+var ErrNoRoute = errors.New("no route found")
+
+// RouteResult is a computed route through a list of waypoints
+type RouteResult struct {
+	Points     []geo.Point   `json:"points"`
+	DistanceKm float64       `json:"distance_km"`
+	Duration   time.Duration `json:"duration"`
+	Provider   string        `json:"provider"`
+}
+
+// Minutes returns the route duration rounded up to whole minutes
+func (r RouteResult) Minutes() int {
+	return DurationMinutes(r.Duration)
+}
+
+// DurationMinutes rounds a travel time up to whole minutes
+func DurationMinutes(d time.Duration) int {
+	return int(math.Ceil(d.Minutes()))
+}
+
+// MatrixCell is the travel distance and time between one origin and one destination
+type MatrixCell struct {
+	DistanceKm float64       `json:"distance_km"`
+	Duration   time.Duration `json:"duration"`
+}
+
+type RoutingProvider interface {
+	// Route returns the route visiting waypoints in order
+	Route(ctx context.Context, waypoints []geo.Point, vehicle models.VehicleType) (RouteResult, error)
+	// ETA returns the travel time between two points
+	ETA(ctx context.Context, from, to geo.Point, vehicle models.VehicleType) (time.Duration, error)
+	// DistanceMatrix returns cells indexed [origin][destination]
+	DistanceMatrix(ctx context.Context, origins, destinations []geo.Point, vehicle models.VehicleType) ([][]MatrixCell, error)
+}
+
+// SpeedProfiles maps vehicle types to average city speed in km/h
+type SpeedProfiles map[models.VehicleType]float64
+
+// DefaultSpeedProfiles are average speeds in Yogyakarta city traffic
+var DefaultSpeedProfiles = SpeedProfiles{
+	models.VehicleTypeBecakManual:  9,
+	models.VehicleTypeBecakMotor:   20,
+	models.VehicleTypeBecakListrik: 15,
+	models.VehicleTypeAndong:       11,
+}
+
+// Speed returns the speed for vehicle, falling back to becak manual for unknown types
+func (p SpeedProfiles) Speed(vehicle models.VehicleType) float64 {
+	if speed, ok := p[vehicle]; ok && speed > 0 {
+		return speed
+	}
+	if speed, ok := p[models.VehicleTypeBecakManual]; ok && speed > 0 {
+		return speed
+	}
+	return DefaultSpeedProfiles[models.VehicleTypeBecakManual]
+}
+
+// TravelTime returns how long vehicle needs to cover distanceKm
+func (p SpeedProfiles) TravelTime(distanceKm float64, vehicle models.VehicleType) time.Duration {
+	hours := distanceKm / p.Speed(vehicle)
+	return time.Duration(hours * float64(time.Hour)).Round(time.Second)
+}
+
+// StraightLineRouter estimates routes from great-circle distance multiplied by a
+// detour factor for the road network. It needs no network access.
+type StraightLineRouter struct {
+	Speeds       SpeedProfiles
+	DetourFactor float64
+}
+
+func NewStraightLineRouter(speeds SpeedProfiles, detourFactor float64) *StraightLineRouter {
+	if speeds == nil {
+		speeds = DefaultSpeedProfiles
+	}
+	if detourFactor < 1 {
+		detourFactor = 1
+	}
+	return &StraightLineRouter{Speeds: speeds, DetourFactor: detourFactor}
+}
+
+func (r *StraightLineRouter) Route(ctx context.Context, waypoints []geo.Point, vehicle models.VehicleType) (RouteResult, error) {
+	if len(waypoints) < 2 {
+		return RouteResult{}, ErrNoRoute
+	}
+	distance := roundKm(geo.PathDistance(waypoints) * r.DetourFactor)
+	return RouteResult{
+		Points:     waypoints,
+		DistanceKm: distance,
+		Duration:   r.Speeds.TravelTime(distance, vehicle),
+		Provider:   "straight_line",
+	}, nil
+}
+
+func (r *StraightLineRouter) ETA(ctx context.Context, from, to geo.Point, vehicle models.VehicleType) (time.Duration, error) {
+	return r.Speeds.TravelTime(geo.Distance(from, to)*r.DetourFactor, vehicle), nil
+}
+
+func (r *StraightLineRouter) DistanceMatrix(ctx context.Context, origins, destinations []geo.Point, vehicle models.VehicleType) ([][]MatrixCell, error) {
+	matrix := make([][]MatrixCell, len(origins))
+	for i, origin := range origins {
+		matrix[i] = make([]MatrixCell, len(destinations))
+		for j, destination := range destinations {
+			distance := roundKm(geo.Distance(origin, destination) * r.DetourFactor)
+			matrix[i][j] = MatrixCell{
+				DistanceKm: distance,
+				Duration:   r.Speeds.TravelTime(distance, vehicle),
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// LoadRoutingProvider builds the routing provider configured in the environment.
+// ROUTING_PROVIDER selects "osrm" or "graphhopper" at ROUTING_URL; anything else,
+// or a missing URL, uses the straight-line estimate.
+func LoadRoutingProvider() RoutingProvider {
+	fallback := NewStraightLineRouter(DefaultSpeedProfiles, envFloat("ROUTING_DETOUR_FACTOR", 1.3))
+
+	flavor := strings.ToLower(os.Getenv("ROUTING_PROVIDER"))
+	baseURL := os.Getenv("ROUTING_URL")
+	if flavor != RoutingFlavorOSRM && flavor != RoutingFlavorGraphHopper {
+		return fallback
+	}
+	if baseURL == "" {
+		log.Printf("ROUTING_PROVIDER=%s without ROUTING_URL, using straight-line routing", flavor)
+		return fallback
+	}
+
+	router := NewHTTPRouter(flavor, baseURL, DefaultSpeedProfiles, fallback)
+	if profile := os.Getenv("ROUTING_PROFILE"); profile != "" {
+		router.Profile = profile
+	}
+	router.Client.Timeout = envDuration("ROUTING_TIMEOUT", router.Client.Timeout)
+	return router
+}
+
+func roundKm(km float64) float64 {
+	return math.Round(km*100) / 100
+}
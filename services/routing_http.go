@@ -0,0 +1,270 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+)
+
+// Supported HTTP routing server flavors
+const (
+	RoutingFlavorOSRM        = "osrm"
+	RoutingFlavorGraphHopper = "graphhopper"
+)
+
+// HTTPRouter talks to an OSRM or GraphHopper compatible server (e.g. one running
+// locally in Docker). Road distance and geometry come from the server; travel time
+// is derived from the vehicle speed profile. Failed requests fall back to Fallback.
+type HTTPRouter struct {
+	Flavor   string
+	BaseURL  string
+	Profile  string
+	Speeds   SpeedProfiles
+	Client   *http.Client
+	Fallback RoutingProvider
+}
+
+func NewHTTPRouter(flavor, baseURL string, speeds SpeedProfiles, fallback RoutingProvider) *HTTPRouter {
+	if speeds == nil {
+		speeds = DefaultSpeedProfiles
+	}
+	return &HTTPRouter{
+		Flavor:   flavor,
+		BaseURL:  strings.TrimRight(baseURL, "/"),
+		Profile:  "bike",
+		Speeds:   speeds,
+		Client:   &http.Client{Timeout: 3 * time.Second},
+		Fallback: fallback,
+	}
+}
+
+func (r *HTTPRouter) Route(ctx context.Context, waypoints []geo.Point, vehicle models.VehicleType) (RouteResult, error) {
+	if len(waypoints) < 2 {
+		return RouteResult{}, ErrNoRoute
+	}
+
+	var (
+		distanceKm float64
+		encoded    string
+		err        error
+	)
+	if r.Flavor == RoutingFlavorGraphHopper {
+		distanceKm, encoded, err = r.graphHopperRoute(ctx, waypoints)
+	} else {
+		distanceKm, encoded, err = r.osrmRoute(ctx, waypoints)
+	}
+	if err != nil {
+		return r.fallbackRoute(ctx, waypoints, vehicle, err)
+	}
+
+	points, err := geo.DecodePolyline(encoded)
+	if err != nil || len(points) == 0 {
+		points = waypoints
+	}
+	distanceKm = roundKm(distanceKm)
+	return RouteResult{
+		Points:     points,
+		DistanceKm: distanceKm,
+		Duration:   r.Speeds.TravelTime(distanceKm, vehicle),
+		Provider:   r.Flavor,
+	}, nil
+}
+
+func (r *HTTPRouter) ETA(ctx context.Context, from, to geo.Point, vehicle models.VehicleType) (time.Duration, error) {
+	route, err := r.Route(ctx, []geo.Point{from, to}, vehicle)
+	if err != nil {
+		return 0, err
+	}
+	return route.Duration, nil
+}
+
+func (r *HTTPRouter) DistanceMatrix(ctx context.Context, origins, destinations []geo.Point, vehicle models.VehicleType) ([][]MatrixCell, error) {
+	if len(origins) == 0 || len(destinations) == 0 {
+		return [][]MatrixCell{}, nil
+	}
+
+	var (
+		distances [][]float64
+		err       error
+	)
+	if r.Flavor == RoutingFlavorGraphHopper {
+		distances, err = r.graphHopperMatrix(ctx, origins, destinations)
+	} else {
+		distances, err = r.osrmMatrix(ctx, origins, destinations)
+	}
+	if err == nil && len(distances) != len(origins) {
+		err = fmt.Errorf("matrix has %d rows, expected %d", len(distances), len(origins))
+	}
+	if err != nil {
+		if r.Fallback == nil {
+			return nil, err
+		}
+		log.Printf("Routing matrix via %s failed, using fallback: %v", r.Flavor, err)
+		return r.Fallback.DistanceMatrix(ctx, origins, destinations, vehicle)
+	}
+
+	matrix := make([][]MatrixCell, len(origins))
+	for i, row := range distances {
+		matrix[i] = make([]MatrixCell, len(destinations))
+		for j := range destinations {
+			if j >= len(row) {
+				break
+			}
+			distanceKm := roundKm(row[j] / 1000)
+			matrix[i][j] = MatrixCell{
+				DistanceKm: distanceKm,
+				Duration:   r.Speeds.TravelTime(distanceKm, vehicle),
+			}
+		}
+	}
+	return matrix, nil
+}
+
+func (r *HTTPRouter) fallbackRoute(ctx context.Context, waypoints []geo.Point, vehicle models.VehicleType, cause error) (RouteResult, error) {
+	if r.Fallback == nil {
+		return RouteResult{}, cause
+	}
+	log.Printf("Routing via %s failed, using fallback: %v", r.Flavor, cause)
+	return r.Fallback.Route(ctx, waypoints, vehicle)
+}
+
+// osrmCoordinates formats points as OSRM "lng,lat;lng,lat"
+func osrmCoordinates(points []geo.Point) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = fmt.Sprintf("%.6f,%.6f", p.Lng, p.Lat)
+	}
+	return strings.Join(parts, ";")
+}
+
+func (r *HTTPRouter) osrmRoute(ctx context.Context, waypoints []geo.Point) (float64, string, error) {
+	endpoint := fmt.Sprintf("%s/route/v1/%s/%s?overview=full&geometries=polyline", r.BaseURL, r.Profile, osrmCoordinates(waypoints))
+
+	var resp struct {
+		Code   string `json:"code"`
+		Routes []struct {
+			Distance float64 `json:"distance"`
+			Geometry string  `json:"geometry"`
+		} `json:"routes"`
+	}
+	if err := r.getJSON(ctx, endpoint, &resp); err != nil {
+		return 0, "", err
+	}
+	if resp.Code != "Ok" || len(resp.Routes) == 0 {
+		return 0, "", ErrNoRoute
+	}
+	return resp.Routes[0].Distance / 1000, resp.Routes[0].Geometry, nil
+}
+
+func (r *HTTPRouter) osrmMatrix(ctx context.Context, origins, destinations []geo.Point) ([][]float64, error) {
+	points := append(append([]geo.Point{}, origins...), destinations...)
+	sources := make([]string, len(origins))
+	for i := range origins {
+		sources[i] = fmt.Sprint(i)
+	}
+	targets := make([]string, len(destinations))
+	for j := range destinations {
+		targets[j] = fmt.Sprint(len(origins) + j)
+	}
+	endpoint := fmt.Sprintf("%s/table/v1/%s/%s?annotations=distance&sources=%s&destinations=%s",
+		r.BaseURL, r.Profile, osrmCoordinates(points), url.QueryEscape(strings.Join(sources, ";")), url.QueryEscape(strings.Join(targets, ";")))
+
+	var resp struct {
+		Code      string      `json:"code"`
+		Distances [][]float64 `json:"distances"`
+	}
+	if err := r.getJSON(ctx, endpoint, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "Ok" {
+		return nil, ErrNoRoute
+	}
+	return resp.Distances, nil
+}
+
+func (r *HTTPRouter) graphHopperRoute(ctx context.Context, waypoints []geo.Point) (float64, string, error) {
+	query := url.Values{}
+	for _, p := range waypoints {
+		query.Add("point", fmt.Sprintf("%.6f,%.6f", p.Lat, p.Lng))
+	}
+	query.Set("profile", r.Profile)
+	query.Set("points_encoded", "true")
+	query.Set("instructions", "false")
+
+	var resp struct {
+		Paths []struct {
+			Distance float64 `json:"distance"`
+			Points   string  `json:"points"`
+		} `json:"paths"`
+	}
+	if err := r.getJSON(ctx, r.BaseURL+"/route?"+query.Encode(), &resp); err != nil {
+		return 0, "", err
+	}
+	if len(resp.Paths) == 0 {
+		return 0, "", ErrNoRoute
+	}
+	return resp.Paths[0].Distance / 1000, resp.Paths[0].Points, nil
+}
+
+func (r *HTTPRouter) graphHopperMatrix(ctx context.Context, origins, destinations []geo.Point) ([][]float64, error) {
+	lngLat := func(points []geo.Point) [][2]float64 {
+		out := make([][2]float64, len(points))
+		for i, p := range points {
+			out[i] = [2]float64{p.Lng, p.Lat}
+		}
+		return out
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"from_points": lngLat(origins),
+		"to_points":   lngLat(destinations),
+		"out_arrays":  []string{"distances"},
+		"profile":     r.Profile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.BaseURL+"/matrix", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp struct {
+		Distances [][]float64 `json:"distances"`
+	}
+	if err := r.doJSON(req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Distances, nil
+}
+
+func (r *HTTPRouter) getJSON(ctx context.Context, endpoint string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return r.doJSON(req, out)
+}
+
+func (r *HTTPRouter) doJSON(req *http.Request, out interface{}) error {
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("routing server returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
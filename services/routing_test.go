@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"greenbecak-backend/geo"
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	tuguJogja = geo.Point{Lat: -7.782889, Lng: 110.367083}
+	malioboro = geo.Point{Lat: -7.792600, Lng: 110.365800}
+	kraton    = geo.Point{Lat: -7.805300, Lng: 110.364200}
+)
+
+func TestSpeedProfilesTravelTime(t *testing.T) {
+	tests := []struct {
+		vehicle models.VehicleType
+		want    time.Duration
+	}{
+		{models.VehicleTypeBecakManual, 20 * time.Minute},
+		{models.VehicleTypeBecakMotor, 9 * time.Minute},
+		{models.VehicleTypeBecakListrik, 12 * time.Minute},
+		{models.VehicleTypeAndong, 16*time.Minute + 22*time.Second},
+		{models.VehicleType("unknown"), 20 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.vehicle), func(t *testing.T) {
+			assert.Equal(t, tt.want, DefaultSpeedProfiles.TravelTime(3, tt.vehicle))
+		})
+	}
+}
+
+func TestStraightLineRouter(t *testing.T) {
+	router := NewStraightLineRouter(nil, 1.3)
+	ctx := context.Background()
+
+	route, err := router.Route(ctx, []geo.Point{tuguJogja, malioboro, kraton}, models.VehicleTypeBecakMotor)
+	assert.NoError(t, err)
+	assert.Equal(t, "straight_line", route.Provider)
+	assert.InDelta(t, 2.5*1.3, route.DistanceKm, 0.05)
+	assert.Equal(t, 10, route.Minutes())
+
+	_, err = router.Route(ctx, []geo.Point{tuguJogja}, models.VehicleTypeBecakMotor)
+	assert.ErrorIs(t, err, ErrNoRoute)
+
+	matrix, err := router.DistanceMatrix(ctx, []geo.Point{tuguJogja, kraton}, []geo.Point{malioboro}, models.VehicleTypeBecakManual)
+	assert.NoError(t, err)
+	assert.Len(t, matrix, 2)
+	assert.InDelta(t, 1.09*1.3, matrix[0][0].DistanceKm, 0.05)
+	assert.InDelta(t, 1.42*1.3, matrix[1][0].DistanceKm, 0.05)
+}
+
+func TestHTTPRouterOSRM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/route/v1/bike/110.367083,-7.782889;110.365800,-7.792600"):
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": "Ok",
+				"routes": []map[string]interface{}{
+					{"distance": 1450.0, "duration": 300.0, "geometry": geo.EncodePolyline([]geo.Point{tuguJogja, malioboro})},
+				},
+			})
+		case strings.HasPrefix(r.URL.Path, "/table/v1/bike/"):
+			assert.Equal(t, "0", r.URL.Query().Get("sources"))
+			assert.Equal(t, "1;2", r.URL.Query().Get("destinations"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code":      "Ok",
+				"distances": [][]float64{{1450, 3100}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	router := NewHTTPRouter(RoutingFlavorOSRM, server.URL+"/", nil, nil)
+	ctx := context.Background()
+
+	route, err := router.Route(ctx, []geo.Point{tuguJogja, malioboro}, models.VehicleTypeBecakListrik)
+	assert.NoError(t, err)
+	assert.Equal(t, RoutingFlavorOSRM, route.Provider)
+	assert.Equal(t, 1.45, route.DistanceKm)
+	assert.Len(t, route.Points, 2)
+	assert.Equal(t, 6, route.Minutes(), "duration follows the vehicle profile, not the server")
+
+	matrix, err := router.DistanceMatrix(ctx, []geo.Point{tuguJogja}, []geo.Point{malioboro, kraton}, models.VehicleTypeBecakManual)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.45, matrix[0][0].DistanceKm)
+	assert.Equal(t, 3.1, matrix[0][1].DistanceKm)
+}
+
+func TestHTTPRouterGraphHopper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/route":
+			assert.Equal(t, []string{"-7.782889,110.367083", "-7.805300,110.364200"}, r.URL.Query()["point"])
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"paths": []map[string]interface{}{
+					{"distance": 2900.0, "time": 600000, "points": geo.EncodePolyline([]geo.Point{tuguJogja, malioboro, kraton})},
+				},
+			})
+		case "/matrix":
+			var body struct {
+				FromPoints [][2]float64 `json:"from_points"`
+				ToPoints   [][2]float64 `json:"to_points"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, [][2]float64{{tuguJogja.Lng, tuguJogja.Lat}}, body.FromPoints)
+			json.NewEncoder(w).Encode(map[string]interface{}{"distances": [][]float64{{2900}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	router := NewHTTPRouter(RoutingFlavorGraphHopper, server.URL, nil, nil)
+	ctx := context.Background()
+
+	route, err := router.Route(ctx, []geo.Point{tuguJogja, kraton}, models.VehicleTypeAndong)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.9, route.DistanceKm)
+	assert.Len(t, route.Points, 3)
+
+	matrix, err := router.DistanceMatrix(ctx, []geo.Point{tuguJogja}, []geo.Point{kraton}, models.VehicleTypeAndong)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.9, matrix[0][0].DistanceKm)
+}
+
+func TestHTTPRouterFallsBackWhenServerFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	points := []geo.Point{tuguJogja, malioboro}
+
+	withoutFallback := NewHTTPRouter(RoutingFlavorOSRM, server.URL, nil, nil)
+	_, err := withoutFallback.Route(ctx, points, models.VehicleTypeBecakManual)
+	assert.Error(t, err)
+
+	router := NewHTTPRouter(RoutingFlavorOSRM, server.URL, nil, NewStraightLineRouter(nil, 1))
+	route, err := router.Route(ctx, points, models.VehicleTypeBecakManual)
+	assert.NoError(t, err)
+	assert.Equal(t, "straight_line", route.Provider)
+	assert.InDelta(t, 1.09, route.DistanceKm, 0.02)
+
+	matrix, err := router.DistanceMatrix(ctx, points[:1], points[1:], models.VehicleTypeBecakManual)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.09, matrix[0][0].DistanceKm, 0.02)
+}
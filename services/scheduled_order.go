@@ -0,0 +1,314 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Scheduled Bookings
+// ==================
+// Order dengan scheduled_at adalah booking terjadwal. Booking tidak langsung
+// didispatch: job berkala mengirim pengingat ke customer dan driver sebelum
+// jendela jemput, lalu pada DispatchLead sebelum scheduled_at booking diberikan
+// ke driver yang sudah menyanggupi (pre-commit) atau didispatch seperti order
+// biasa. Setiap langkah diklaim dengan update bersyarat (reminded_at /
+// dispatched_at IS NULL) sehingga aman dijalankan di beberapa replica.
+
+var (
+	ErrInvalidSchedule     = errors.New("invalid pickup schedule")
+	ErrBookingNotOpen      = errors.New("booking is not open for commitment")
+	ErrBookingOverlap      = errors.New("driver already has a booking in this time window")
+	ErrBookingNotCommitted = errors.New("driver has not committed to this booking")
+)
+
+// EventBookingReminder is pushed to the customer and driver before a booking's pickup window
+const EventBookingReminder = "booking_reminder"
+
+// EventBookingCommitmentDropped is pushed to the driver and order when a
+// committed driver cannot be assigned at dispatch time
+const EventBookingCommitmentDropped = "booking_commitment_dropped"
+
+type ScheduleConfig struct {
+	MinLead       time.Duration // Booking paling cepat sejauh ini dari sekarang
+	MaxAhead      time.Duration // Booking paling jauh sejauh ini dari sekarang
+	MaxWindow     time.Duration // Lebar maksimal jendela jemput
+	DefaultWindow time.Duration
+	DispatchLead  time.Duration // Dispatch dimulai sejauh ini sebelum scheduled_at
+	ReminderLead  time.Duration // Pengingat dikirim sejauh ini sebelum scheduled_at
+	// Drivers withdrawing a commitment closer than this to pickup get a strike
+	WithdrawStrikeWindow time.Duration
+	BatchSize            int
+}
+
+// LoadScheduleConfig reads scheduled booking settings from the environment
+func LoadScheduleConfig() ScheduleConfig {
+	return ScheduleConfig{
+		MinLead:              envDuration("SCHEDULE_MIN_LEAD", 30*time.Minute),
+		MaxAhead:             envDuration("SCHEDULE_MAX_AHEAD", 30*24*time.Hour),
+		MaxWindow:            envDuration("SCHEDULE_MAX_WINDOW", 2*time.Hour),
+		DefaultWindow:        envDuration("SCHEDULE_DEFAULT_WINDOW", 15*time.Minute),
+		DispatchLead:         envDuration("SCHEDULE_DISPATCH_LEAD", 20*time.Minute),
+		ReminderLead:         envDuration("SCHEDULE_REMINDER_LEAD", time.Hour),
+		WithdrawStrikeWindow: envDuration("SCHEDULE_WITHDRAW_STRIKE_WINDOW", 2*time.Hour),
+		BatchSize:            int(envFloat("SCHEDULE_BATCH_SIZE", 100)),
+	}
+}
+
+// ApplySchedule validates the pickup window and sets it on the order.
+// A missing end defaults to DefaultWindow after the start.
+func ApplySchedule(order *models.Order, start time.Time, end *time.Time, now time.Time, config ScheduleConfig) error {
+	if start.Before(now.Add(config.MinLead)) {
+		return fmt.Errorf("%w: pickup must be at least %s from now", ErrInvalidSchedule, config.MinLead)
+	}
+	if config.MaxAhead > 0 && start.After(now.Add(config.MaxAhead)) {
+		return fmt.Errorf("%w: pickup must be within %s from now", ErrInvalidSchedule, config.MaxAhead)
+	}
+
+	until := start.Add(config.DefaultWindow)
+	if end != nil {
+		until = *end
+	}
+	if until.Before(start) {
+		return fmt.Errorf("%w: scheduled_until is before scheduled_at", ErrInvalidSchedule)
+	}
+	if config.MaxWindow > 0 && until.Sub(start) > config.MaxWindow {
+		return fmt.Errorf("%w: pickup window may be at most %s", ErrInvalidSchedule, config.MaxWindow)
+	}
+
+	order.ScheduledAt = &start
+	order.ScheduledUntil = &until
+	return nil
+}
+
+// CommitDriverToBooking lets a driver reserve an open booking ahead of dispatch.
+// The update is conditional so only one driver can commit to a booking.
+func CommitDriverToBooking(db *gorm.DB, order *models.Order, driver models.Driver) error {
+	if !order.IsScheduled() || order.Status != models.OrderStatusPending || order.DispatchedAt != nil {
+		return ErrBookingNotOpen
+	}
+
+	if err := CheckBookingOverlap(db, order, driver.ID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result := db.Model(&models.Order{}).
+		Where("id = ? AND status = ? AND driver_id IS NULL AND dispatched_at IS NULL", order.ID, models.OrderStatusPending).
+		Updates(map[string]interface{}{"driver_id": driver.ID, "committed_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderConflict
+	}
+
+	order.DriverID = &driver.ID
+	order.CommittedAt = &now
+	return nil
+}
+
+// CheckBookingOverlap returns ErrBookingOverlap when the driver already holds
+// another open booking whose pickup window overlaps the order's
+func CheckBookingOverlap(db *gorm.DB, order *models.Order, driverID uint) error {
+	var overlapping int64
+	if err := db.Model(&models.Order{}).
+		Where("driver_id = ? AND id <> ? AND status IN ? AND scheduled_at IS NOT NULL", driverID, order.ID,
+			[]models.OrderStatus{models.OrderStatusPending, models.OrderStatusAccepted}).
+		Where("scheduled_at < ? AND scheduled_until > ?", order.ScheduledUntil, order.ScheduledAt).
+		Count(&overlapping).Error; err != nil {
+		return err
+	}
+	if overlapping > 0 {
+		return ErrBookingOverlap
+	}
+	return nil
+}
+
+// WithdrawDriverCommitment releases a driver's pre-commitment so the booking is
+// open again. Withdrawing within WithdrawStrikeWindow of pickup adds a strike.
+func WithdrawDriverCommitment(db *gorm.DB, order *models.Order, driver models.Driver, config ScheduleConfig, now time.Time) (bool, error) {
+	if order.DriverID == nil || *order.DriverID != driver.ID || order.CommittedAt == nil {
+		return false, ErrBookingNotCommitted
+	}
+	if order.Status != models.OrderStatusPending || order.DispatchedAt != nil {
+		return false, ErrBookingNotOpen
+	}
+
+	strike := order.ScheduledAt.Sub(now) < config.WithdrawStrikeWindow
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND driver_id = ? AND status = ? AND dispatched_at IS NULL", order.ID, driver.ID, models.OrderStatusPending).
+			Updates(map[string]interface{}{"driver_id": nil, "committed_at": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderConflict
+		}
+		if !strike {
+			return nil
+		}
+		return tx.Model(&models.Driver{}).Where("id = ?", driver.ID).
+			Update("cancellation_strikes", gorm.Expr("cancellation_strikes + ?", 1)).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	order.DriverID = nil
+	order.CommittedAt = nil
+	return strike, nil
+}
+
+// ClaimBookingReminders marks bookings whose reminder is due and returns them
+func ClaimBookingReminders(db *gorm.DB, config ScheduleConfig, now time.Time) ([]models.Order, error) {
+	return claimScheduledOrders(db, config, "reminded_at",
+		"status IN ? AND scheduled_at IS NOT NULL AND reminded_at IS NULL AND scheduled_at > ? AND scheduled_at <= ?",
+		[]interface{}{[]models.OrderStatus{models.OrderStatusPending, models.OrderStatusAccepted}, now, now.Add(config.ReminderLead)}, now)
+}
+
+// ClaimDueBookings marks bookings whose dispatch lead time has arrived and returns them
+func ClaimDueBookings(db *gorm.DB, config ScheduleConfig, now time.Time) ([]models.Order, error) {
+	return claimScheduledOrders(db, config, "dispatched_at",
+		"status = ? AND scheduled_at IS NOT NULL AND dispatched_at IS NULL AND scheduled_at <= ?",
+		[]interface{}{models.OrderStatusPending, now.Add(config.DispatchLead)}, now)
+}
+
+// claimScheduledOrders sets column on matching orders where it is still null.
+// Only orders this call managed to update are returned.
+func claimScheduledOrders(db *gorm.DB, config ScheduleConfig, column, where string, args []interface{}, now time.Time) ([]models.Order, error) {
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	var candidates []models.Order
+	if err := db.Where(where, args...).Order("scheduled_at ASC").Limit(batchSize).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	claimed := make([]models.Order, 0, len(candidates))
+	for _, order := range candidates {
+		result := db.Model(&models.Order{}).
+			Where("id = ? AND "+column+" IS NULL", order.ID).
+			Update(column, now)
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue // claimed by another replica
+		}
+		switch column {
+		case "reminded_at":
+			order.RemindedAt = &now
+		case "dispatched_at":
+			order.DispatchedAt = &now
+		}
+		claimed = append(claimed, order)
+	}
+	return claimed, nil
+}
+
+// ReleaseDueBooking hands a claimed booking back so the next tick claims it
+// again, e.g. when its committed driver could not be assigned because of an error
+func ReleaseDueBooking(db *gorm.DB, order *models.Order) error {
+	if err := db.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, models.OrderStatusPending).
+		Update("dispatched_at", nil).Error; err != nil {
+		return err
+	}
+	order.DispatchedAt = nil
+	return nil
+}
+
+// AssignCommittedDriver gives a due booking to the driver who pre-committed to it.
+// It returns false when there is no committed driver or they are not available,
+// in which case the commitment is dropped and the booking should be dispatched.
+func AssignCommittedDriver(db *gorm.DB, order *models.Order) (bool, error) {
+	if order.DriverID == nil || order.CommittedAt == nil {
+		return false, nil
+	}
+
+	var driver models.Driver
+	err := db.First(&driver, *order.DriverID).Error
+	if err == nil {
+		err = AcceptOrderForDriver(db, order, &driver, driver.UserID)
+		if err == nil {
+			return true, nil
+		}
+	}
+	// A driver removed since committing is as unavailable as a busy one
+	if !errors.Is(err, ErrDriverUnavailable) && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	driverID := *order.DriverID
+	log.Printf("Committed driver %d unavailable for booking %d, dispatching", driverID, order.ID)
+	if err := db.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, models.OrderStatusPending).
+		Updates(map[string]interface{}{"driver_id": nil, "committed_at": nil}).Error; err != nil {
+		return false, err
+	}
+	order.DriverID = nil
+	order.CommittedAt = nil
+
+	data := map[string]interface{}{
+		"order_id":     order.ID,
+		"order_number": order.OrderNumber,
+		"driver_id":    driverID,
+		"scheduled_at": order.ScheduledAt,
+	}
+	Hub.Publish(DriverTopic(driverID), EventBookingCommitmentDropped, data)
+	Hub.Publish(OrderTopic(order.ID), EventBookingCommitmentDropped, data)
+	return false, nil
+}
+
+// NotifyBookingReminder reminds the customer and the assigned driver of an upcoming booking
+func NotifyBookingReminder(db *gorm.DB, order models.Order) {
+	pickup := order.ScheduledAt.In(referenceLocation).Format("15:04")
+	data := map[string]interface{}{
+		"order_id":        order.ID,
+		"order_number":    order.OrderNumber,
+		"scheduled_at":    order.ScheduledAt,
+		"scheduled_until": order.ScheduledUntil,
+		"pickup_location": order.PickupLocation,
+	}
+	Hub.Publish(OrderTopic(order.ID), EventBookingReminder, data)
+
+	if order.CustomerID != nil {
+		storeNotification(db, *order.CustomerID, "Pengingat Booking",
+			fmt.Sprintf("Becak Anda untuk pesanan %s dijadwalkan menjemput pukul %s WIB.", order.OrderNumber, pickup), data)
+	}
+
+	if order.DriverID != nil {
+		Hub.Publish(DriverTopic(*order.DriverID), EventBookingReminder, data)
+		var driver models.Driver
+		if db.Select("id", "user_id").First(&driver, *order.DriverID).Error == nil && driver.UserID != nil {
+			storeNotification(db, *driver.UserID, "Pengingat Booking",
+				fmt.Sprintf("Anda dijadwalkan menjemput pesanan %s pukul %s WIB di %s.", order.OrderNumber, pickup, order.PickupLocation), data)
+		}
+	}
+}
+
+// storeNotification saves an order notification for a user and pushes it to their stream
+func storeNotification(db *gorm.DB, userID uint, title, message string, data map[string]interface{}) {
+	payload, _ := json.Marshal(data)
+	notification := models.Notification{
+		UserID:   userID,
+		Title:    title,
+		Message:  message,
+		Type:     models.NotificationTypeOrder,
+		Priority: models.NotificationPriorityHigh,
+		Data:     string(payload),
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		log.Printf("Failed to store notification for user %d: %v", userID, err)
+		return
+	}
+	Hub.Publish(UserTopic(userID), EventNotification, notification)
+}
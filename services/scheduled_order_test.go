@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplySchedule(t *testing.T) {
+	config := ScheduleConfig{
+		MinLead:       30 * time.Minute,
+		MaxAhead:      30 * 24 * time.Hour,
+		MaxWindow:     2 * time.Hour,
+		DefaultWindow: 15 * time.Minute,
+	}
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		value := now.Add(d)
+		return &value
+	}
+
+	tests := []struct {
+		name      string
+		start     time.Duration
+		end       *time.Time
+		wantErr   bool
+		wantUntil time.Time
+	}{
+		{"default window", 2 * time.Hour, nil, false, now.Add(2*time.Hour + 15*time.Minute)},
+		{"explicit window", 2 * time.Hour, at(3 * time.Hour), false, now.Add(3 * time.Hour)},
+		{"too soon", 10 * time.Minute, nil, true, time.Time{}},
+		{"too far ahead", 31 * 24 * time.Hour, nil, true, time.Time{}},
+		{"window ends before start", 2 * time.Hour, at(time.Hour), true, time.Time{}},
+		{"window too wide", 2 * time.Hour, at(5 * time.Hour), true, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order models.Order
+			err := ApplySchedule(&order, now.Add(tt.start), tt.end, now, config)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidSchedule))
+				assert.False(t, order.IsScheduled())
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, order.IsScheduled())
+			assert.Equal(t, now.Add(tt.start), *order.ScheduledAt)
+			assert.Equal(t, tt.wantUntil, *order.ScheduledUntil)
+		})
+	}
+}
+
+func TestCancellationStageOfBooking(t *testing.T) {
+	scheduledAt := time.Now().Add(time.Hour)
+	order := models.Order{Status: models.OrderStatusPending, ScheduledAt: &scheduledAt}
+	assert.Equal(t, models.CancelStageScheduled, CancellationStageOf(order, nil, 0.1))
+
+	// Once dispatched a booking is cancelled like any pending order
+	dispatchedAt := time.Now()
+	order.DispatchedAt = &dispatchedAt
+	assert.Equal(t, models.CancelStagePending, CancellationStageOf(order, nil, 0.1))
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tour Packages & Hourly Rentals
+// ==============================
+// Paket tour punya rute (itinerary) dan durasi tetap dengan harga paket;
+// sewa per jam dihargai per jam yang dipesan. Itinerary paket tour menjadi
+// titik singgah order round trip. Ketersediaan dibatasi jam operasional paket
+// dan MaxConcurrent kendaraan yang menjalankan paket pada waktu yang sama.
+// Rental yang melebihi durasi pesanan (lebih dari masa tenggang) ditagih
+// overtime per blok saat order diselesaikan.
+
+var (
+	ErrInvalidTourPackage    = errors.New("invalid tour package")
+	ErrInvalidPackageBooking = errors.New("invalid package booking")
+	ErrPackageUnavailable    = errors.New("package is fully booked for this time")
+)
+
+// PackageBooking is what a customer asks for when booking a package
+type PackageBooking struct {
+	Start      time.Time
+	Hours      int // Hanya untuk sewa per jam
+	Passengers int
+	Vehicle    models.VehicleType
+}
+
+// ValidateTourPackage checks a package definition before it is saved
+func ValidateTourPackage(pkg models.TourPackage) error {
+	if pkg.Type != models.PackageTypeTour && pkg.Type != models.PackageTypeHourly {
+		return fmt.Errorf("%w: type must be tour or hourly", ErrInvalidTourPackage)
+	}
+	if pkg.DurationMinutes <= 0 || pkg.Price <= 0 {
+		return fmt.Errorf("%w: duration_minutes and price must be positive", ErrInvalidTourPackage)
+	}
+	if pkg.Type == models.PackageTypeHourly && pkg.MaxDurationMinutes > 0 && pkg.MaxDurationMinutes < pkg.DurationMinutes {
+		return fmt.Errorf("%w: max_duration_minutes is below duration_minutes", ErrInvalidTourPackage)
+	}
+	if len(pkg.VehicleTypes) == 0 {
+		return fmt.Errorf("%w: at least one vehicle type is required", ErrInvalidTourPackage)
+	}
+	for _, vehicle := range pkg.VehicleTypes {
+		if _, ok := DefaultVehicleMultipliers[vehicle]; !ok {
+			return fmt.Errorf("%w: unknown vehicle type %s", ErrInvalidTourPackage, vehicle)
+		}
+	}
+	for i, stop := range pkg.Itinerary {
+		if _, err := (OrderPlace{Lat: stop.Lat, Lng: stop.Lng}).Point(); err != nil {
+			return fmt.Errorf("%w: itinerary stop %d has invalid coordinates", ErrInvalidTourPackage, i+1)
+		}
+	}
+	if _, err := time.Parse("15:04", pkg.AvailableFrom); err != nil {
+		return fmt.Errorf("%w: available_from must be HH:MM", ErrInvalidTourPackage)
+	}
+	if _, err := time.Parse("15:04", pkg.AvailableUntil); err != nil {
+		return fmt.Errorf("%w: available_until must be HH:MM", ErrInvalidTourPackage)
+	}
+	return nil
+}
+
+// PackageMinutes returns how long the booking reserves the vehicle
+func PackageMinutes(pkg models.TourPackage, hours int) int {
+	if pkg.Type == models.PackageTypeHourly {
+		return hours * 60
+	}
+	return pkg.DurationMinutes
+}
+
+// ValidatePackageBooking checks the booking against the package's vehicle types,
+// capacity, rental limits and operating hours (WIB)
+func ValidatePackageBooking(pkg models.TourPackage, booking PackageBooking) error {
+	if !pkg.IsActive {
+		return fmt.Errorf("%w: package is not active", ErrInvalidPackageBooking)
+	}
+	if !pkg.SupportsVehicle(booking.Vehicle) {
+		return fmt.Errorf("%w: package is not offered with %s", ErrInvalidPackageBooking, booking.Vehicle)
+	}
+	if booking.Passengers < 1 || (pkg.Capacity > 0 && booking.Passengers > pkg.Capacity) {
+		return fmt.Errorf("%w: passengers must be between 1 and %d", ErrInvalidPackageBooking, pkg.Capacity)
+	}
+
+	minutes := PackageMinutes(pkg, booking.Hours)
+	if pkg.Type == models.PackageTypeHourly {
+		if minutes < pkg.DurationMinutes {
+			return fmt.Errorf("%w: minimum rental is %d minutes", ErrInvalidPackageBooking, pkg.DurationMinutes)
+		}
+		if pkg.MaxDurationMinutes > 0 && minutes > pkg.MaxDurationMinutes {
+			return fmt.Errorf("%w: maximum rental is %d minutes", ErrInvalidPackageBooking, pkg.MaxDurationMinutes)
+		}
+	}
+
+	start := booking.Start.In(referenceLocation)
+	opens, err := clockOn(start, pkg.AvailableFrom)
+	if err != nil {
+		return err
+	}
+	closes, err := clockOn(start, pkg.AvailableUntil)
+	if err != nil {
+		return err
+	}
+	if start.Before(opens) || start.Add(time.Duration(minutes)*time.Minute).After(closes) {
+		return fmt.Errorf("%w: package runs between %s and %s WIB", ErrInvalidPackageBooking, pkg.AvailableFrom, pkg.AvailableUntil)
+	}
+	return nil
+}
+
+// clockOn returns the time of day "HH:MM" on the date of day
+func clockOn(day time.Time, clock string) (time.Time, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid package hours %q", clock)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, day.Location()), nil
+}
+
+// PackageFare itemizes the price of a package booking
+func PackageFare(pkg models.TourPackage, tariff models.Tariff, minutes int, vehicle models.VehicleType) models.FareBreakdown {
+	breakdown := models.FareBreakdown{
+		TariffID:          tariff.ID,
+		TariffName:        tariff.Name,
+		VehicleType:       vehicle,
+		DurationMinutes:   minutes,
+		VehicleMultiplier: 1,
+		IsSubsidi:         tariff.IsSubsidi,
+		IsGojek:           tariff.IsGojek,
+		IsNonTunai:        tariff.IsNonTunai,
+	}
+
+	label := fmt.Sprintf("Paket %s", pkg.Name)
+	amount := pkg.Price
+	if pkg.Type == models.PackageTypeHourly {
+		hours := float64(minutes) / 60
+		label = fmt.Sprintf("Sewa %s %.0f jam x Rp%.0f", pkg.Name, hours, pkg.Price)
+		amount = pkg.Price * hours
+	}
+	amount = roundRupiah(amount)
+
+	breakdown.Items = []models.FareItem{{Code: models.FareItemPackage, Label: label, Amount: amount}}
+	breakdown.Total = amount
+	breakdown.CustomerTotal = amount
+	return breakdown
+}
+
+// ApplyPackage prices the order by the package and, for tours, turns the
+// itinerary into round-trip stops back to the pickup point
+func ApplyPackage(order *models.Order, pkg models.TourPackage, tariff models.Tariff, booking PackageBooking) error {
+	minutes := PackageMinutes(pkg, booking.Hours)
+
+	if pkg.Type == models.PackageTypeTour && len(pkg.Itinerary) > 0 {
+		stops := make([]StopInput, 0, len(pkg.Itinerary))
+		for _, stop := range pkg.Itinerary {
+			stops = append(stops, StopInput{
+				OrderPlace:  OrderPlace{Lat: stop.Lat, Lng: stop.Lng, Address: stop.Name},
+				WaitMinutes: stop.WaitMinutes,
+			})
+		}
+		config := StopConfig{MaxStops: len(stops), MaxWaitMinutes: minutes}
+		if err := SetOrderStops(order, stops, true, config); err != nil {
+			return err
+		}
+	}
+
+	fare := PackageFare(pkg, tariff, minutes, booking.Vehicle)
+	order.PackageID = &pkg.ID
+	order.TariffID = tariff.ID
+	order.Passengers = booking.Passengers
+	order.BookedMinutes = minutes
+	order.Price = fare.Total
+	order.FareBreakdown = &fare
+	return nil
+}
+
+// PackageSlotsLeft returns how many more vehicles can run the package in the
+// window starting at start, or -1 when the package has no limit
+func PackageSlotsLeft(db *gorm.DB, pkg models.TourPackage, start time.Time, minutes int) (int, error) {
+	if pkg.MaxConcurrent <= 0 {
+		return -1, nil
+	}
+
+	end := start.Add(time.Duration(minutes) * time.Minute)
+	var booked int64
+	err := db.Model(&models.Order{}).
+		Where("package_id = ? AND status IN ?", pkg.ID, []models.OrderStatus{
+			models.OrderStatusPending, models.OrderStatusAccepted, models.OrderStatusPickedUp, models.OrderStatusInProgress,
+		}).
+		Where("COALESCE(scheduled_at, created_at) < ? AND DATE_ADD(COALESCE(scheduled_at, created_at), INTERVAL booked_minutes MINUTE) > ?", end, start).
+		Count(&booked).Error
+	if err != nil {
+		return 0, err
+	}
+
+	left := pkg.MaxConcurrent - int(booked)
+	if left < 0 {
+		left = 0
+	}
+	return left, nil
+}
+
+// CreatePackageOrder inserts a package order after checking the package's
+// capacity again with the package row locked, so concurrent bookings cannot
+// both take the last slot
+func CreatePackageOrder(db *gorm.DB, order *models.Order) error {
+	if order.PackageID == nil {
+		return fmt.Errorf("%w: order has no package", ErrInvalidPackageBooking)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var pkg models.TourPackage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pkg, *order.PackageID).Error; err != nil {
+			return err
+		}
+		start := time.Now()
+		if order.ScheduledAt != nil {
+			start = *order.ScheduledAt
+		}
+		left, err := PackageSlotsLeft(tx, pkg, start, order.BookedMinutes)
+		if err != nil {
+			return err
+		}
+		if left == 0 {
+			return ErrPackageUnavailable
+		}
+		return CreateWithReferenceNumber(tx, ReferencePrefixOrder, order, func(number string) { order.OrderNumber = number })
+	})
+}
+
+// CalculateOvertime returns the minutes a rental ran past its booked duration and
+// the fee for them. Minutes within the grace period are free; beyond it every
+// started block is charged at OvertimePerHour.
+func CalculateOvertime(pkg models.TourPackage, bookedMinutes int, started, ended time.Time) (int, float64) {
+	over := int(math.Ceil(ended.Sub(started).Minutes())) - bookedMinutes
+	if over <= 0 {
+		return 0, 0
+	}
+	if over <= pkg.OvertimeGraceMinutes || pkg.OvertimePerHour <= 0 {
+		return over, 0
+	}
+
+	block := pkg.OvertimeBlockMinutes
+	if block <= 0 {
+		block = 15
+	}
+	blocks := (over + block - 1) / block
+	return over, roundRupiah(float64(blocks*block) / 60 * pkg.OvertimePerHour)
+}
+
+// applyOvertime adds any overtime fee to a package order being completed.
+// The rental clock starts when the customer is picked up.
+func applyOvertime(db *gorm.DB, order *models.Order, now time.Time) error {
+	if order.PackageID == nil || order.BookedMinutes <= 0 {
+		return nil
+	}
+	started := order.PickedUpAt
+	if started == nil {
+		started = order.StartedAt
+	}
+	if started == nil {
+		return nil
+	}
+
+	var pkg models.TourPackage
+	if err := db.Unscoped().First(&pkg, *order.PackageID).Error; err != nil {
+		return err
+	}
+
+	minutes, fee := CalculateOvertime(pkg, order.BookedMinutes, *started, now)
+	order.OvertimeMinutes = minutes
+	order.OvertimeFee = fee
+	if fee <= 0 {
+		return nil
+	}
+
+	order.Price += fee
+	if order.FareBreakdown != nil {
+		fare := *order.FareBreakdown
+		fare.Items = append(append([]models.FareItem(nil), fare.Items...), models.FareItem{
+			Code:   models.FareItemOvertime,
+			Label:  fmt.Sprintf("Overtime %d menit", minutes),
+			Amount: fee,
+		})
+		fare.Total += fee
+		fare.CustomerTotal += fee
+		order.FareBreakdown = &fare
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testTourPackage() models.TourPackage {
+	return models.TourPackage{
+		ID:              3,
+		Code:            "KRATON-TAMANSARI",
+		Name:            "Kraton & Taman Sari",
+		Type:            models.PackageTypeTour,
+		DurationMinutes: 180,
+		Itinerary: []models.PackageStop{
+			{Name: "Keraton Yogyakarta", Lat: floatPtr(-7.805279), Lng: floatPtr(110.364101), WaitMinutes: 60},
+			{Name: "Taman Sari", Lat: floatPtr(-7.810046), Lng: floatPtr(110.359228), WaitMinutes: 45},
+		},
+		VehicleTypes:         []models.VehicleType{models.VehicleTypeAndong, models.VehicleTypeBecakManual},
+		Price:                150000,
+		OvertimePerHour:      40000,
+		OvertimeBlockMinutes: 15,
+		OvertimeGraceMinutes: 10,
+		Capacity:             4,
+		AvailableFrom:        "08:00",
+		AvailableUntil:       "17:00",
+		IsActive:             true,
+	}
+}
+
+func testHourlyPackage() models.TourPackage {
+	pkg := testTourPackage()
+	pkg.Type = models.PackageTypeHourly
+	pkg.Itinerary = nil
+	pkg.DurationMinutes = 60
+	pkg.MaxDurationMinutes = 240
+	pkg.Price = 60000
+	return pkg
+}
+
+func wib(hour, minute int) time.Time {
+	return time.Date(2026, 10, 17, hour, minute, 0, 0, referenceLocation)
+}
+
+func TestValidateTourPackage(t *testing.T) {
+	assert.NoError(t, ValidateTourPackage(testTourPackage()))
+
+	invalid := testTourPackage()
+	invalid.VehicleTypes = []models.VehicleType{"delman"}
+	assert.True(t, errors.Is(ValidateTourPackage(invalid), ErrInvalidTourPackage))
+
+	invalid = testTourPackage()
+	invalid.AvailableUntil = "5pm"
+	assert.True(t, errors.Is(ValidateTourPackage(invalid), ErrInvalidTourPackage))
+
+	invalid = testHourlyPackage()
+	invalid.MaxDurationMinutes = 30
+	assert.True(t, errors.Is(ValidateTourPackage(invalid), ErrInvalidTourPackage))
+}
+
+func TestValidatePackageBooking(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkg     models.TourPackage
+		booking PackageBooking
+		wantErr bool
+	}{
+		{"tour in opening hours", testTourPackage(), PackageBooking{Start: wib(9, 0), Passengers: 2, Vehicle: models.VehicleTypeAndong}, false},
+		{"tour ending after closing", testTourPackage(), PackageBooking{Start: wib(15, 0), Passengers: 2, Vehicle: models.VehicleTypeAndong}, true},
+		{"before opening", testTourPackage(), PackageBooking{Start: wib(7, 30), Passengers: 2, Vehicle: models.VehicleTypeAndong}, true},
+		{"over capacity", testTourPackage(), PackageBooking{Start: wib(9, 0), Passengers: 5, Vehicle: models.VehicleTypeAndong}, true},
+		{"vehicle not offered", testTourPackage(), PackageBooking{Start: wib(9, 0), Passengers: 1, Vehicle: models.VehicleTypeBecakMotor}, true},
+		{"hourly rental", testHourlyPackage(), PackageBooking{Start: wib(9, 0), Hours: 3, Passengers: 1, Vehicle: models.VehicleTypeBecakManual}, false},
+		{"hourly rental without hours", testHourlyPackage(), PackageBooking{Start: wib(9, 0), Passengers: 1, Vehicle: models.VehicleTypeBecakManual}, true},
+		{"hourly rental too long", testHourlyPackage(), PackageBooking{Start: wib(9, 0), Hours: 5, Passengers: 1, Vehicle: models.VehicleTypeBecakManual}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePackageBooking(tt.pkg, tt.booking)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidPackageBooking), "got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestApplyPackage(t *testing.T) {
+	tariff := models.Tariff{ID: 7, Name: "Wisata", IsNonTunai: true}
+
+	var order models.Order
+	assert.NoError(t, SetOrderPickup(&order, OrderPlace{Lat: floatPtr(-7.792600), Lng: floatPtr(110.365800), Address: "Hotel Inna Garuda"}))
+	err := ApplyPackage(&order, testTourPackage(), tariff, PackageBooking{Start: wib(9, 0), Passengers: 3, Vehicle: models.VehicleTypeAndong})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), order.TariffID)
+	assert.Equal(t, 180, order.BookedMinutes)
+	assert.Equal(t, 150000.0, order.Price)
+	assert.True(t, order.RoundTrip)
+	assert.Len(t, order.Stops, 2)
+	assert.Equal(t, 105, order.WaitMinutes)
+	assert.Equal(t, "Hotel Inna Garuda", order.DropLocation)
+	assert.True(t, order.FareBreakdown.IsNonTunai)
+
+	var rental models.Order
+	err = ApplyPackage(&rental, testHourlyPackage(), tariff, PackageBooking{Start: wib(9, 0), Hours: 3, Passengers: 1, Vehicle: models.VehicleTypeBecakManual})
+	assert.NoError(t, err)
+	assert.Equal(t, 180, rental.BookedMinutes)
+	assert.Equal(t, 180000.0, rental.Price)
+	assert.Empty(t, rental.Stops)
+}
+
+func TestCalculateOvertime(t *testing.T) {
+	pkg := testTourPackage()
+	start := wib(9, 0)
+
+	tests := []struct {
+		name        string
+		ended       time.Time
+		wantMinutes int
+		wantFee     float64
+	}{
+		{"on time", start.Add(170 * time.Minute), 0, 0},
+		{"within grace", start.Add(188 * time.Minute), 8, 0},
+		{"one block", start.Add(192 * time.Minute), 12, 10000},
+		{"two blocks", start.Add(206 * time.Minute), 26, 20000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minutes, fee := CalculateOvertime(pkg, 180, start, tt.ended)
+			assert.Equal(t, tt.wantMinutes, minutes)
+			assert.Equal(t, tt.wantFee, fee)
+		})
+	}
+}
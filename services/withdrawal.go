@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"greenbecak-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidWithdrawalStatus = errors.New("invalid withdrawal status")
+	ErrWithdrawalTransition    = errors.New("withdrawal status change not allowed")
+)
+
+// withdrawalTransitions lists the statuses a withdrawal may move to. The
+// wallet is debited when a withdrawal is approved (or completed straight from
+// pending) and credited back if an approved withdrawal is rejected.
+var withdrawalTransitions = map[models.WithdrawalStatus][]models.WithdrawalStatus{
+	models.WithdrawalStatusPending:  {models.WithdrawalStatusApproved, models.WithdrawalStatusRejected, models.WithdrawalStatusCompleted},
+	models.WithdrawalStatusApproved: {models.WithdrawalStatusCompleted, models.WithdrawalStatusRejected},
+}
+
+// CanTransitionWithdrawal reports whether a withdrawal may move from one status to another
+func CanTransitionWithdrawal(from, to models.WithdrawalStatus) bool {
+	for _, allowed := range withdrawalTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// WithdrawalUpdate is an admin decision on a withdrawal request
+type WithdrawalUpdate struct {
+	Status models.WithdrawalStatus
+	Notes  string
+	Actor  string // Nama/ID admin untuk approved_by/rejected_by
+}
+
+// UpdateWithdrawalStatus applies an admin decision and posts the matching
+// ledger entry in one transaction. The driver row is locked so two approvals
+// cannot both spend the same balance.
+func UpdateWithdrawalStatus(db *gorm.DB, withdrawal *models.Withdrawal, update WithdrawalUpdate, now time.Time) error {
+	switch update.Status {
+	case models.WithdrawalStatusApproved, models.WithdrawalStatusRejected, models.WithdrawalStatusCompleted:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidWithdrawalStatus, update.Status)
+	}
+	from := withdrawal.Status
+	if !CanTransitionWithdrawal(from, update.Status) {
+		return fmt.Errorf("%w: %s to %s", ErrWithdrawalTransition, from, update.Status)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var driver models.Driver
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&driver, withdrawal.DriverID).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"status": update.Status}
+		var actor *string
+		if update.Actor != "" {
+			actor = &update.Actor
+		}
+		switch update.Status {
+		case models.WithdrawalStatusApproved:
+			updates["approved_at"] = now
+			if actor != nil {
+				updates["approved_by"] = actor
+			}
+		case models.WithdrawalStatusRejected:
+			updates["rejected_at"] = now
+			if actor != nil {
+				updates["rejected_by"] = actor
+			}
+		case models.WithdrawalStatusCompleted:
+			updates["completed_at"] = now
+		}
+		if update.Notes != "" {
+			updates["notes"] = update.Notes
+		}
+
+		result := tx.Model(&models.Withdrawal{}).
+			Where("id = ? AND status = ?", withdrawal.ID, from).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: withdrawal %s changed concurrently", ErrWithdrawalTransition, withdrawal.Reference)
+		}
+
+		switch {
+		case from == models.WithdrawalStatusPending && update.Status != models.WithdrawalStatusRejected:
+			balance, err := AccountBalance(tx, DriverWalletAccount(withdrawal.DriverID))
+			if err != nil {
+				return err
+			}
+			if toCents(balance) < toCents(withdrawal.Amount) {
+				return fmt.Errorf("%w: balance %.0f, requested %.0f", ErrInsufficientFunds, balance, withdrawal.Amount)
+			}
+			if _, _, err := PostWithdrawalPayout(tx, *withdrawal, now); err != nil {
+				return err
+			}
+		case from == models.WithdrawalStatusApproved && update.Status == models.WithdrawalStatusRejected:
+			key := WithdrawalPayoutKey(withdrawal.ID)
+			memo := fmt.Sprintf("Withdrawal %s rejected after approval", withdrawal.Reference)
+			if _, _, err := ReverseJournal(tx, key, key+":reversal", memo, nil, now); err != nil {
+				return err
+			}
+		}
+
+		return tx.Preload("Driver.User").First(withdrawal, withdrawal.ID).Error
+	})
+}